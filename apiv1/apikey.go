@@ -0,0 +1,180 @@
+package apiv1
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"my-embedded-api/meta"
+)
+
+// APIKeyPrefix starts every API key, so that leaked keys are recognizable
+const APIKeyPrefix = "pak"
+
+// APIKey lets a machine client authenticate as its owner without the
+// owner's password. Keys have the form pak_<prefix>_<secret>: the prefix
+// identifies the stored key and only a SHA-256 hash of the secret is kept.
+type APIKey struct {
+	meta.BaseResource `json:",inline"`
+
+	// UserID is the ID of the user the key authenticates as
+	UserID uint `gorm:"not null;index" json:"userId" binding:"required"`
+
+	// Label is a human readable description of the key
+	Label string `gorm:"size:100" json:"label,omitempty"`
+
+	// Prefix is the public part of the key used to look it up
+	Prefix string `gorm:"size:16;not null;uniqueIndex" json:"prefix"`
+
+	// SecretHash is the hex encoded SHA-256 hash of the secret part of the
+	// key (not exposed in JSON)
+	SecretHash string `gorm:"size:64;not null" json:"-"`
+
+	// ExpiresAt is when the key stops being accepted, never if nil
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+
+	// LastUsedAt is when the key last authenticated a request
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+
+	// key is the plain text key, known only to the request creating it
+	key string
+}
+
+// CreatedAPIKey is the response of the request creating an API key, the
+// only one revealing the plain text key
+type CreatedAPIKey struct {
+	APIKey `json:",inline"`
+
+	// Key is the plain text key to authenticate with
+	Key string `json:"key"`
+}
+
+// TableName specifies the table name for GORM
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// Default sets the TypeMeta fields so that a key decoded from a request
+// validates before it reaches the GORM hooks
+func (k *APIKey) Default() {
+	k.Kind = "APIKey"
+	k.APIVersion = "v1"
+}
+
+// DeepCopy returns an *APIKey copy of k, see meta.DeepCopy. The secret
+// hash and the plain text key are not copied.
+func (k *APIKey) DeepCopy() interface{} {
+	copied, err := meta.DeepCopy(k)
+	if err != nil || copied == nil {
+		return nil
+	}
+	return copied
+}
+
+// Validate implements ResourceValidator interface
+func (k *APIKey) Validate() error {
+	if err := k.BaseResource.Validate(); err != nil {
+		return err
+	}
+	if k.UserID == 0 {
+		return errors.New("userId is required")
+	}
+	if len(k.Label) > 100 {
+		return errors.New("label must be at most 100 characters long")
+	}
+	return nil
+}
+
+// ValidateUpdate rejects changes of the owner and of the key itself; keys
+// are replaced by creating a new one and deleting the old one
+func (k *APIKey) ValidateUpdate(ctx context.Context, old any) error {
+	previous, ok := old.(*APIKey)
+	if !ok {
+		return nil
+	}
+	if k.UserID != previous.UserID {
+		return errors.New("userId cannot be changed")
+	}
+	if k.Prefix != previous.Prefix {
+		return errors.New("prefix cannot be changed")
+	}
+	return nil
+}
+
+// BulkUpdateFields lists the fields bulk updates may set on keys, leaving
+// out the owner and the key itself
+func (APIKey) BulkUpdateFields() []string {
+	return []string{"label", "expiresAt"}
+}
+
+// CreateResponse returns the key together with its plain text value
+func (k *APIKey) CreateResponse() any {
+	return CreatedAPIKey{APIKey: *k, Key: k.key}
+}
+
+// Expired reports whether the key is no longer accepted at now
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// CheckSecret reports whether secret is the secret part of the key
+func (k *APIKey) CheckSecret(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashAPIKeySecret(secret)), []byte(k.SecretHash)) == 1
+}
+
+// generate replaces the key with a new random one
+func (k *APIKey) generate() error {
+	prefix := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(prefix); err != nil {
+		return err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+
+	k.Prefix = hex.EncodeToString(prefix)
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+	k.SecretHash = hashAPIKeySecret(encodedSecret)
+	k.key = APIKeyPrefix + "_" + k.Prefix + "_" + encodedSecret
+	return nil
+}
+
+// ParseAPIKey splits a key into its prefix and secret
+func ParseAPIKey(key string) (prefix, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(key, APIKeyPrefix+"_")
+	if !ok {
+		return "", "", false
+	}
+	prefix, secret, ok = strings.Cut(rest, "_")
+	if !ok || prefix == "" || secret == "" {
+		return "", "", false
+	}
+	return prefix, secret, true
+}
+
+// hashAPIKeySecret hashes the secret part of a key. Secrets are random, so
+// a fast hash is enough.
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// BeforeCreate is a GORM hook that generates the key before storing it.
+// Values supplied for the key are ignored.
+func (k *APIKey) BeforeCreate(tx *gorm.DB) error {
+	k.Default()
+	if err := k.generate(); err != nil {
+		return err
+	}
+	k.SetStatus("Active", "API key created successfully", "Created")
+	return k.BaseResource.BeforeCreate(tx)
+}
@@ -0,0 +1,60 @@
+package apiv1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKey_Generate(t *testing.T) {
+	var key APIKey
+	require.NoError(t, key.generate())
+
+	prefix, secret, ok := ParseAPIKey(key.key)
+	require.True(t, ok)
+	assert.Equal(t, key.Prefix, prefix)
+	assert.True(t, key.CheckSecret(secret))
+	assert.False(t, key.CheckSecret(secret+"x"))
+	assert.NotContains(t, key.SecretHash, secret)
+
+	created := key.CreateResponse().(CreatedAPIKey)
+	assert.Equal(t, key.key, created.Key)
+
+	var other APIKey
+	require.NoError(t, other.generate())
+	assert.NotEqual(t, key.Prefix, other.Prefix)
+}
+
+func TestParseAPIKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		prefix string
+		secret string
+		ok     bool
+	}{
+		{"pak_abc123_s3cr3t", "abc123", "s3cr3t", true},
+		{"pak_abc123_s3_cr3t", "abc123", "s3_cr3t", true},
+		{"abc123_s3cr3t", "", "", false},
+		{"pak_abc123", "", "", false},
+		{"pak__s3cr3t", "", "", false},
+		{"pak_abc123_", "", "", false},
+	}
+	for _, tt := range tests {
+		prefix, secret, ok := ParseAPIKey(tt.key)
+		assert.Equal(t, tt.ok, ok, tt.key)
+		assert.Equal(t, tt.prefix, prefix, tt.key)
+		assert.Equal(t, tt.secret, secret, tt.key)
+	}
+}
+
+func TestAPIKey_Expired(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Second), now.Add(time.Hour)
+
+	assert.False(t, (&APIKey{}).Expired(now))
+	assert.True(t, (&APIKey{ExpiresAt: &past}).Expired(now))
+	assert.True(t, (&APIKey{ExpiresAt: &now}).Expired(now))
+	assert.False(t, (&APIKey{ExpiresAt: &future}).Expired(now))
+}
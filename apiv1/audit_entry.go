@@ -0,0 +1,94 @@
+package apiv1
+
+import (
+	"time"
+
+	"my-embedded-api/meta"
+)
+
+// Verbs of audit entries, named after the change a request asks for
+const (
+	AuditVerbCreate = "create"
+	AuditVerbUpdate = "update"
+	AuditVerbPatch  = "patch"
+	AuditVerbDelete = "delete"
+)
+
+// AuditEntry records a mutating request to a resource: who made it, what
+// it asked to change and how it was answered. Entries are written by the
+// server and only read through the API.
+type AuditEntry struct {
+	meta.BaseResource `json:",inline"`
+
+	// Actor identifies the caller: the authenticated user, or the client
+	// IP of anonymous requests
+	Actor string `gorm:"size:255;index" json:"actor"`
+
+	// Verb is the change requested, see AuditVerbCreate
+	Verb string `gorm:"size:20;index" json:"verb"`
+
+	// ResourceKind is the kind of the resource the request was made to,
+	// e.g. User
+	ResourceKind string `gorm:"size:100;index" json:"resourceKind"`
+
+	// ResourceID and ResourceUID identify the resource changed, when the
+	// request concerned a single one
+	ResourceID  uint   `gorm:"index" json:"resourceId,omitempty"`
+	ResourceUID string `gorm:"size:36" json:"resourceUid,omitempty"`
+
+	// Method and Path are the HTTP method and route of the request, such
+	// as PUT /api/v1/users/:id
+	Method string `gorm:"size:10" json:"method"`
+	Path   string `gorm:"size:255" json:"path"`
+
+	// RequestDigest is the hex encoded SHA-256 hash of the request body
+	RequestDigest string `gorm:"size:64" json:"requestDigest,omitempty"`
+
+	// RequestBody is the JSON request body with its secrets redacted, only
+	// stored when the server is configured to
+	RequestBody string `json:"requestBody,omitempty"`
+
+	// Status is the HTTP status of the response
+	Status int `gorm:"index" json:"status"`
+
+	// Timestamp is when the response was sent
+	Timestamp time.Time `gorm:"index" json:"timestamp"`
+}
+
+// TableName specifies the table name for GORM
+func (AuditEntry) TableName() string {
+	return "audit_entries"
+}
+
+// Default sets the TypeMeta fields
+func (e *AuditEntry) Default() {
+	e.Kind = "AuditEntry"
+	e.APIVersion = "v1"
+}
+
+// DeepCopy returns an *AuditEntry copy of e, see meta.DeepCopy
+func (e *AuditEntry) DeepCopy() interface{} {
+	copied, err := meta.DeepCopy(e)
+	if err != nil || copied == nil {
+		return nil
+	}
+	return copied
+}
+
+// FilterOperators lists the fields list requests may filter audit entries
+// on and their operators
+func (AuditEntry) FilterOperators() map[string][]string {
+	ordered := []string{"eq", "ne", "gt", "gte", "lt", "lte", "in"}
+	return map[string][]string{
+		"id":           ordered,
+		"actor":        {"eq", "ne", "in", "like"},
+		"verb":         {"eq", "ne", "in"},
+		"resourceKind": {"eq", "ne", "in"},
+		"resourceId":   {"eq", "in"},
+		"resourceUid":  {"eq", "in"},
+		"method":       {"eq", "ne", "in"},
+		"path":         {"eq", "like"},
+		"status":       ordered,
+		"timestamp":    ordered,
+	}
+}
@@ -0,0 +1,171 @@
+package apiv1
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"my-embedded-api/meta"
+)
+
+// Password policy rules, reported in PasswordViolation.Rule
+const (
+	PasswordRuleMinLength = "minLength"
+	PasswordRuleUpper     = "upper"
+	PasswordRuleLower     = "lower"
+	PasswordRuleDigit     = "digit"
+	PasswordRuleSymbol    = "symbol"
+	PasswordRuleBanned    = "banned"
+)
+
+// DefaultMinPasswordLength is the minimum password length of the default
+// policy
+const DefaultMinPasswordLength = 8
+
+// CommonPasswords is a list of frequently used passwords that can be
+// banned through PasswordPolicy.BannedPasswords
+var CommonPasswords = []string{
+	"password", "password1", "password123", "12345678", "123456789",
+	"1234567890", "qwerty123", "qwertyuiop", "iloveyou", "sunshine",
+	"princess", "football", "baseball", "welcome1", "letmein1",
+	"admin123", "abc12345", "trustno1", "11111111", "00000000",
+}
+
+// PasswordPolicy describes what makes a password strong enough
+type PasswordPolicy struct {
+	// MinLength is the minimum number of characters
+	MinLength int `yaml:"minLength" default:"8"`
+
+	// Required character classes
+	RequireUpper  bool `yaml:"requireUpper"`
+	RequireLower  bool `yaml:"requireLower"`
+	RequireDigit  bool `yaml:"requireDigit"`
+	RequireSymbol bool `yaml:"requireSymbol"`
+
+	// BannedPasswords are rejected regardless of case
+	BannedPasswords []string `yaml:"bannedPasswords"`
+}
+
+// DefaultPasswordPolicy returns the policy used until SetPasswordPolicy is
+// called, which only requires DefaultMinPasswordLength characters
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{MinLength: DefaultMinPasswordLength}
+}
+
+// PasswordViolation is a rule of the password policy a password breaks
+type PasswordViolation struct {
+	// Rule is one of the PasswordRule constants
+	Rule string
+
+	// Message describes the rule for users
+	Message string
+}
+
+// PasswordPolicyError lists every rule of the password policy a password
+// breaks
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+// Error implements the error interface
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return "password does not meet the policy: " + strings.Join(messages, "; ")
+}
+
+// StatusCauses reports each violation as a cause of the password field, for
+// the details of the API error
+func (e *PasswordPolicyError) StatusCauses() []meta.StatusCause {
+	causes := make([]meta.StatusCause, len(e.Violations))
+	for i, violation := range e.Violations {
+		causes[i] = meta.StatusCause{Field: "password", Message: violation.Message}
+	}
+	return causes
+}
+
+// Check returns a *PasswordPolicyError listing every rule password breaks,
+// or nil if it meets the policy
+func (p PasswordPolicy) Check(password string) error {
+	var violations []PasswordViolation
+	violate := func(rule, message string) {
+		violations = append(violations, PasswordViolation{Rule: rule, Message: message})
+	}
+
+	if len([]rune(password)) < p.MinLength {
+		violate(PasswordRuleMinLength, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		violate(PasswordRuleUpper, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		violate(PasswordRuleLower, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		violate(PasswordRuleDigit, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violate(PasswordRuleSymbol, "must contain a symbol")
+	}
+
+	for _, banned := range p.BannedPasswords {
+		if strings.EqualFold(password, banned) {
+			violate(PasswordRuleBanned, "must not be a common password")
+			break
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// Validate checks that the policy can be met
+func (p PasswordPolicy) Validate() error {
+	if p.MinLength < 1 {
+		return fmt.Errorf("password minimum length must be positive")
+	}
+	return nil
+}
+
+// passwordPolicy is the policy enforced on new passwords
+var passwordPolicy atomic.Pointer[PasswordPolicy]
+
+func init() {
+	policy := DefaultPasswordPolicy()
+	passwordPolicy.Store(&policy)
+}
+
+// SetPasswordPolicy replaces the policy enforced on new passwords
+func SetPasswordPolicy(policy PasswordPolicy) {
+	passwordPolicy.Store(&policy)
+}
+
+// CurrentPasswordPolicy returns the policy enforced on new passwords
+func CurrentPasswordPolicy() PasswordPolicy {
+	return *passwordPolicy.Load()
+}
+
+// ValidatePassword checks a new plain text password against the current
+// password policy, returning a *PasswordPolicyError
+func ValidatePassword(password string) error {
+	return CurrentPasswordPolicy().Check(password)
+}
@@ -0,0 +1,88 @@
+package apiv1
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// violatedRules returns the rules listed by a *PasswordPolicyError
+func violatedRules(t *testing.T, err error) []string {
+	var policyErr *PasswordPolicyError
+	require.True(t, errors.As(err, &policyErr), "expected a *PasswordPolicyError, got %v", err)
+	rules := make([]string, len(policyErr.Violations))
+	for i, violation := range policyErr.Violations {
+		rules[i] = violation.Rule
+	}
+	return rules
+}
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		want     string
+	}{
+		{"min length", PasswordPolicy{MinLength: 8}, "short", PasswordRuleMinLength},
+		{"min length counts runes", PasswordPolicy{MinLength: 4}, "äöü", PasswordRuleMinLength},
+		{"upper", PasswordPolicy{RequireUpper: true}, "lowercase", PasswordRuleUpper},
+		{"lower", PasswordPolicy{RequireLower: true}, "UPPERCASE", PasswordRuleLower},
+		{"digit", PasswordPolicy{RequireDigit: true}, "nodigits", PasswordRuleDigit},
+		{"symbol", PasswordPolicy{RequireSymbol: true}, "nosymbols1", PasswordRuleSymbol},
+		{"banned", PasswordPolicy{BannedPasswords: CommonPasswords}, "Password123", PasswordRuleBanned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, []string{tt.want}, violatedRules(t, tt.policy.Check(tt.password)))
+		})
+	}
+
+	strict := PasswordPolicy{
+		MinLength:       8,
+		RequireUpper:    true,
+		RequireLower:    true,
+		RequireDigit:    true,
+		RequireSymbol:   true,
+		BannedPasswords: CommonPasswords,
+	}
+	assert.NoError(t, strict.Check("Str0ng&Secret"))
+	assert.NoError(t, DefaultPasswordPolicy().Check("password"))
+	assert.Error(t, DefaultPasswordPolicy().Check("seven77"))
+}
+
+func TestPasswordPolicy_CheckListsEveryRule(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:       12,
+		RequireUpper:    true,
+		RequireDigit:    true,
+		RequireSymbol:   true,
+		BannedPasswords: []string{"password"},
+	}
+
+	err := policy.Check("password")
+	assert.Equal(t, []string{
+		PasswordRuleMinLength, PasswordRuleUpper, PasswordRuleDigit, PasswordRuleSymbol, PasswordRuleBanned,
+	}, violatedRules(t, err))
+	assert.Equal(t, "password does not meet the policy: must be at least 12 characters long; "+
+		"must contain an uppercase letter; must contain a digit; must contain a symbol; "+
+		"must not be a common password", err.Error())
+}
+
+func TestUser_PasswordPolicy(t *testing.T) {
+	previous := CurrentPasswordPolicy()
+	SetPasswordPolicy(PasswordPolicy{MinLength: 8, RequireDigit: true})
+	defer SetPasswordPolicy(previous)
+
+	user := &User{Username: "testuser", Email: "test@example.com", Password: "nodigits"}
+	user.Default()
+	assert.Equal(t, []string{PasswordRuleDigit}, violatedRules(t, user.Validate()))
+
+	// Hashed passwords were checked when they were set
+	assert.Equal(t, []string{PasswordRuleDigit}, violatedRules(t, user.SetPassword("nodigits")))
+	assert.Equal(t, "nodigits", user.Password, "a rejected password must not be hashed")
+	require.NoError(t, user.SetPassword("digits123"))
+	assert.NoError(t, user.Validate())
+}
@@ -1,7 +1,9 @@
 package apiv1
 
 import (
+	"context"
 	"errors"
+	"net/http"
 	"regexp"
 	"strings"
 
@@ -11,24 +13,48 @@ import (
 	"my-embedded-api/meta"
 )
 
+// Roles a user can have
+const (
+	// RoleAdmin may perform every operation
+	RoleAdmin = "admin"
+
+	// RoleUser is the default role of new users
+	RoleUser = "user"
+)
+
+// IsValidRole reports whether role is one of the user roles
+func IsValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleUser
+}
+
 // User represents a user in the system
 type User struct {
 	meta.BaseResource `json:",inline"`
 
-	// Username is the unique username for the user
-	Username string `gorm:"size:100;not null;unique" json:"username" binding:"required"`
+	// Username is the unique username for the user. Deleted users do not
+	// hold their username, so it can be reused.
+	Username string `gorm:"size:100;not null;uniqueIndex:,where:deleted_at IS NULL" json:"username" binding:"required"`
+
+	// Email is the user's email address, unique among live users
+	Email string `gorm:"size:100;not null;uniqueIndex:,where:deleted_at IS NULL" json:"email" binding:"required,email"`
 
-	// Email is the user's email address
-	Email string `gorm:"size:100;not null;unique" json:"email" binding:"required,email"`
+	// Password is the password a request sets, required unless the user
+	// is stored already. It is write-only: the hooks hash it into
+	// PasswordHash and clear it, so it is never returned.
+	Password string `gorm:"-" json:"password,omitempty" binding:"required_without=PasswordHash" openapi:"writeOnly" export:"-"`
 
-	// Password is the hashed password (not exposed in JSON)
-	Password string `gorm:"size:100;not null" json:"password" binding:"required"`
+	// PasswordHash is the bcrypt hash of the password (not exposed in JSON
+	// or exports)
+	PasswordHash string `gorm:"column:password;size:100;not null" json:"-" export:"-"`
 
 	// FullName is the user's full name
 	FullName string `gorm:"size:100" json:"fullName,omitempty"`
 
 	// IsActive indicates whether the user account is active
 	IsActive bool `gorm:"default:true" json:"isActive"`
+
+	// Role decides what the user is authorized to do, RoleUser if empty
+	Role string `gorm:"size:20;not null;default:user" json:"role"`
 }
 
 // TableName specifies the table name for GORM
@@ -36,9 +62,60 @@ func (User) TableName() string {
 	return "users"
 }
 
-// isHashedPassword checks if a password is already hashed
+// FilterOperators lists the fields list requests may filter users on and
+// their operators. The password is left out so that its hash cannot be
+// probed.
+func (User) FilterOperators() map[string][]string {
+	ordered := []string{"eq", "ne", "gt", "gte", "lt", "lte", "in"}
+	text := append(ordered, "like")
+	return map[string][]string{
+		"id":        ordered,
+		"createdAt": ordered,
+		"updatedAt": ordered,
+		"username":  text,
+		"email":     text,
+		"fullName":  text,
+		"role":      {"eq", "ne", "in"},
+		"isActive":  {"eq", "ne"},
+	}
+}
+
+// SearchFields lists the fields searched by list requests
+func (User) SearchFields() []string {
+	return []string{"username", "email", "fullName"}
+}
+
+// BulkUpdateFields lists the fields bulk updates may set on users. Bulk
+// updates skip the hook hashing the password and ValidateUpdate, which
+// restricts password and role changes, so both are left out.
+func (User) BulkUpdateFields() []string {
+	return []string{"fullName", "isActive"}
+}
+
+// isHashedPassword checks if a password is already a bcrypt hash
 func isHashedPassword(password string) bool {
-	return strings.HasPrefix(password, "$2a$") || strings.HasPrefix(password, "$2b$")
+	return strings.HasPrefix(password, "$2a$") || strings.HasPrefix(password, "$2b$") ||
+		strings.HasPrefix(password, "$2y$")
+}
+
+// Default sets the TypeMeta fields so that a user decoded from a request
+// validates before it reaches the GORM hooks
+func (u *User) Default() {
+	u.Kind = "User"
+	u.APIVersion = "v1"
+	if u.Role == "" {
+		u.Role = RoleUser
+	}
+}
+
+// DeepCopy returns a *User copy of u, see meta.DeepCopy. The password hash
+// is not copied.
+func (u *User) DeepCopy() interface{} {
+	copied, err := meta.DeepCopy(u)
+	if err != nil || copied == nil {
+		return nil
+	}
+	return copied
 }
 
 // Validate implements ResourceValidator interface
@@ -67,27 +144,66 @@ func (u *User) Validate() error {
 		return errors.New("invalid email format")
 	}
 
-	// Validate password (only if it's not empty)
-	if u.Password == "" {
+	// Validate password, which is checked against the password policy
+	// until it is hashed. Stored users keep their hash.
+	if u.Password == "" && u.PasswordHash == "" {
 		return errors.New("password is required")
 	}
+	if u.Password != "" && !isHashedPassword(u.Password) {
+		if err := ValidatePassword(u.Password); err != nil {
+			return err
+		}
+	}
+
+	// Validate role (empty means the default role)
+	if u.Role != "" && !IsValidRole(u.Role) {
+		return errors.New("role must be one of: " + RoleAdmin + ", " + RoleUser)
+	}
 
 	return nil
 }
 
-// SetPassword hashes and sets the user's password
+// ValidateUpdate rejects updates changing the password, which must go
+// through the password change endpoint so that the current password is
+// verified. Leaving the password out, or sending the stored hash or the
+// current password, is not a change. Authenticated callers must be admins
+// to change the role, so that users cannot promote themselves.
+func (u *User) ValidateUpdate(ctx context.Context, old any) error {
+	previous, ok := old.(*User)
+	if !ok {
+		return nil
+	}
+	if _, authenticated := meta.RolesFromContext(ctx); authenticated && u.Role != previous.Role && !meta.HasRole(ctx, RoleAdmin) {
+		return &meta.Status{
+			Code:    http.StatusForbidden,
+			Reason:  meta.StatusReasonForbidden,
+			Message: "role can only be changed by an admin",
+		}
+	}
+	if u.Password == "" || u.Password == previous.PasswordHash || previous.ComparePassword(u.Password) == nil {
+		return nil
+	}
+	return errors.New("password cannot be changed by an update, use the password endpoint")
+}
+
+// SetPassword checks the password against the password policy, then
+// hashes and sets it
 func (u *User) SetPassword(password string) error {
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
-	u.Password = string(hashedPassword)
+	u.Password = ""
+	u.PasswordHash = string(hashedPassword)
 	return nil
 }
 
 // CheckPassword verifies if the provided password matches the user's password
 func (u *User) CheckPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
+	err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
 	return err == nil
 }
 
@@ -100,13 +216,16 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 	// Set initial status
 	u.SetStatus("Active", "User created successfully", "Created")
 
+	// Set default role
+	if u.Role == "" {
+		u.Role = RoleUser
+	}
+
 	// Hash password if not already hashed
-	if !strings.HasPrefix(u.Password, "$2a$") {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
-		if err != nil {
+	if u.Password != "" {
+		if err := u.hashPassword(""); err != nil {
 			return err
 		}
-		u.Password = string(hashedPassword)
 	}
 
 	// Call parent BeforeCreate
@@ -122,19 +241,57 @@ func (u *User) BeforeUpdate(tx *gorm.DB) error {
 	// Update status
 	u.SetStatus("Active", "User updated successfully", "Updated")
 
-	// Hash password if not already hashed
-	if !strings.HasPrefix(u.Password, "$2a$") {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+	// Hash password only if it changed, keeping the stored hash when the
+	// plain text password is the current one or was left out
+	if u.Password != "" || u.PasswordHash == "" {
+		stored, err := u.storedPassword(tx)
 		if err != nil {
 			return err
 		}
-		u.Password = string(hashedPassword)
+		if u.Password == "" {
+			u.PasswordHash = stored
+		} else if err := u.hashPassword(stored); err != nil {
+			return err
+		}
 	}
 
 	// Call parent BeforeUpdate
 	return u.BaseResource.BeforeUpdate(tx)
 }
 
+// hashPassword moves Password into PasswordHash, hashing it unless it is a
+// hash already or matches the stored hash, which is then kept
+func (u *User) hashPassword(stored string) error {
+	switch {
+	case isHashedPassword(u.Password):
+		u.PasswordHash = u.Password
+	case stored != "" && bcrypt.CompareHashAndPassword([]byte(stored), []byte(u.Password)) == nil:
+		u.PasswordHash = stored
+	default:
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		u.PasswordHash = string(hashedPassword)
+	}
+	u.Password = ""
+	return nil
+}
+
+// storedPassword returns the password hash stored for the user, or "" if
+// the user is not stored yet
+func (u *User) storedPassword(tx *gorm.DB) (string, error) {
+	if tx == nil || u.ID == 0 {
+		return "", nil
+	}
+	var stored User
+	err := tx.Session(&gorm.Session{NewDB: true}).Select("password").First(&stored, u.ID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	return stored.PasswordHash, err
+}
+
 // BeforeDelete is a GORM hook that runs before deleting a user
 func (u *User) BeforeDelete(tx *gorm.DB) error {
 	// Update status
@@ -144,7 +301,13 @@ func (u *User) BeforeDelete(tx *gorm.DB) error {
 	return u.BaseResource.BeforeDelete(tx)
 }
 
+// GetRoles returns the roles granted to the user, which tokens issued for
+// the user carry
+func (u *User) GetRoles() []string {
+	return []string{u.Role}
+}
+
 // ComparePassword compares the given password with the user's hashed password
 func (u *User) ComparePassword(password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
 }
@@ -55,9 +55,11 @@ func TestUser_Creation(t *testing.T) {
 	assert.NotEmpty(t, user.ID)
 	assert.Equal(t, "testuser", user.Username)
 	assert.Equal(t, "test@example.com", user.Email)
-	assert.NotEmpty(t, user.Password) // Password should be hashed
+	assert.NotEmpty(t, user.PasswordHash) // Password should be hashed
+	assert.Empty(t, user.Password)
 	assert.Equal(t, "User", user.BaseResource.TypeMeta.Kind)
 	assert.Equal(t, "v1", user.BaseResource.TypeMeta.APIVersion)
+	assert.Equal(t, RoleUser, user.Role)
 }
 
 func TestUser_Validate(t *testing.T) {
@@ -123,6 +125,38 @@ func TestUser_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "admin role",
+			user: User{
+				Username: "testuser",
+				Email:    "test@example.com",
+				Password: "password123",
+				Role:     RoleAdmin,
+				BaseResource: meta.BaseResource{
+					TypeMeta: meta.TypeMeta{
+						Kind:       "User",
+						APIVersion: "v1",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown role",
+			user: User{
+				Username: "testuser",
+				Email:    "test@example.com",
+				Password: "password123",
+				Role:     "superuser",
+				BaseResource: meta.BaseResource{
+					TypeMeta: meta.TypeMeta{
+						Kind:       "User",
+						APIVersion: "v1",
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid email format",
 			user: User{
@@ -306,3 +340,40 @@ func TestUser_EmailValidation(t *testing.T) {
 	err = user.Validate()
 	assert.Error(t, err)
 }
+
+func TestUser_Default(t *testing.T) {
+	user := &User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+
+	// TypeMeta is required by validation
+	assert.Error(t, user.Validate())
+
+	user.Default()
+	assert.Equal(t, "User", user.Kind)
+	assert.Equal(t, "v1", user.APIVersion)
+	assert.Equal(t, RoleUser, user.Role)
+	assert.NoError(t, user.Validate())
+}
+
+func TestUser_DeepCopy(t *testing.T) {
+	user := &User{Username: "testuser", Email: "test@example.com", FullName: "Test", Role: RoleAdmin}
+	user.Labels = map[string]string{"team": "ops"}
+	assert.NoError(t, user.SetPassword("password123"))
+
+	copied, ok := user.DeepCopy().(*User)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, "testuser", copied.Username)
+	assert.Equal(t, RoleAdmin, copied.Role)
+	assert.Empty(t, copied.PasswordHash)
+
+	// The copy shares no memory with the original
+	copied.Labels["team"] = "dev"
+	assert.Equal(t, "ops", user.Labels["team"])
+
+	assert.Nil(t, (*User)(nil).DeepCopy())
+}
@@ -0,0 +1,303 @@
+// Package client is a typed Go client for the resources served by play-api,
+// so that consumers do not have to hand-roll their HTTP requests.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"my-embedded-api/meta"
+)
+
+// MergePatchContentType is the media type of the bodies sent by Patch
+const MergePatchContentType = "application/merge-patch+json"
+
+var (
+	// ErrNotFound matches the errors of requests answered with 404
+	ErrNotFound = errors.New("resource not found")
+
+	// ErrConflict matches the errors of requests answered with 409
+	ErrConflict = errors.New("resource conflict")
+)
+
+// StatusError is returned for requests the server did not answer with a
+// 2xx status. Status holds the decoded meta.Status body, if any.
+type StatusError struct {
+	// StatusCode is the HTTP status code of the response
+	StatusCode int
+
+	// Status is the error body sent by the server
+	Status meta.Status
+}
+
+// Error implements the error interface
+func (e *StatusError) Error() string {
+	if e.Status.Message != "" {
+		return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Status.Reason, e.Status.Message)
+	}
+	return fmt.Sprintf("unexpected status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Is reports whether target is ErrNotFound or ErrConflict and matches the
+// status code
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	default:
+		return false
+	}
+}
+
+// Option configures a Client
+type Option func(*options)
+
+// options holds the settings applied by Option values
+type options struct {
+	httpClient    *http.Client
+	authorization string
+}
+
+// WithToken sends token as a bearer token with every request
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.authorization = "Bearer " + token
+	}
+}
+
+// WithAPIKey sends key as an API key with every request
+func WithAPIKey(key string) Option {
+	return func(o *options) {
+		o.authorization = "ApiKey " + key
+	}
+}
+
+// WithHTTPClient sends the requests through httpClient instead of
+// http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = httpClient
+	}
+}
+
+// Client manages the resources of type T served under a base URL
+type Client[T any] struct {
+	baseURL string
+	options options
+}
+
+// NewClient creates a client for the resources served at baseURL, the URL
+// of the collection such as http://localhost:8080/api/v1/users
+func NewClient[T any](baseURL string, opts ...Option) *Client[T] {
+	o := options{httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Client[T]{baseURL: strings.TrimSuffix(baseURL, "/"), options: o}
+}
+
+// ListOptions selects the resources returned by List
+type ListOptions struct {
+	// Page and Size select a page of Size resources, starting at 1
+	Page int
+	Size int
+
+	// Sort orders the resources, e.g. "username,-createdAt"
+	Sort string
+
+	// After requests the resources following the given cursor instead of
+	// a page
+	After string
+
+	// Cursor and Limit request Limit resources following the opaque Cursor
+	// of a previous ListResult, empty for the first page. They cannot be
+	// combined with Page and Size.
+	Cursor string
+	Limit  int
+
+	// Filter restricts the resources to those whose fields equal the given
+	// values, where the server supports it
+	Filter map[string]string
+}
+
+// values encodes the options as query parameters
+func (o *ListOptions) values() url.Values {
+	values := url.Values{}
+	if o == nil {
+		return values
+	}
+	for name, value := range o.Filter {
+		values.Set(name, value)
+	}
+	if o.Page > 0 {
+		values.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.Size > 0 {
+		values.Set("size", strconv.Itoa(o.Size))
+	}
+	if o.Sort != "" {
+		values.Set("sort", o.Sort)
+	}
+	if o.After != "" {
+		values.Set("after", o.After)
+	}
+	if o.Cursor != "" {
+		values.Set("cursor", o.Cursor)
+	}
+	if o.Limit > 0 {
+		values.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return values
+}
+
+// ListResult is a page of resources returned by List
+type ListResult[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int64  `json:"total"`
+	Page       int    `json:"page"`
+	Size       int    `json:"size"`
+	NextCursor string `json:"nextCursor"`
+}
+
+// Create creates resource and returns it as stored by the server
+func (c *Client[T]) Create(ctx context.Context, resource *T) (*T, error) {
+	var created T
+	if err := c.do(ctx, http.MethodPost, "", nil, "application/json", resource, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// Get returns the resource with the given ID
+func (c *Client[T]) Get(ctx context.Context, id uint) (*T, error) {
+	var resource T
+	if err := c.do(ctx, http.MethodGet, itemPath(id), nil, "", nil, &resource); err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+// List returns the resources selected by opts, which may be nil. Servers
+// answering with a plain array are supported as well as those answering
+// with a list envelope.
+func (c *Client[T]) List(ctx context.Context, opts *ListOptions) (*ListResult[T], error) {
+	var body json.RawMessage
+	resp, err := c.request(ctx, http.MethodGet, "", opts.values(), "", nil, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ListResult[T]
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &result.Items); err != nil {
+			return nil, err
+		}
+		result.Total, _ = strconv.ParseInt(resp.Header.Get("X-Total-Count"), 10, 64)
+		if opts != nil {
+			result.Page, result.Size = opts.Page, opts.Size
+		}
+		return &result, nil
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Update replaces the resource with the given ID and returns it as stored
+// by the server
+func (c *Client[T]) Update(ctx context.Context, id uint, resource *T) (*T, error) {
+	var updated T
+	if err := c.do(ctx, http.MethodPut, itemPath(id), nil, "application/json", resource, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// Patch applies a JSON Merge Patch to the resource with the given ID. patch
+// is encoded as JSON, e.g. map[string]any{"fullName": "Alice"}.
+func (c *Client[T]) Patch(ctx context.Context, id uint, patch any) (*T, error) {
+	var patched T
+	if err := c.do(ctx, http.MethodPatch, itemPath(id), nil, MergePatchContentType, patch, &patched); err != nil {
+		return nil, err
+	}
+	return &patched, nil
+}
+
+// Delete deletes the resource with the given ID. A resource with
+// finalizers is only marked for deletion by the server.
+func (c *Client[T]) Delete(ctx context.Context, id uint) error {
+	return c.do(ctx, http.MethodDelete, itemPath(id), nil, "", nil, nil)
+}
+
+// itemPath is the path of a resource relative to the collection
+func itemPath(id uint) string {
+	return "/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// do sends a request, see request
+func (c *Client[T]) do(ctx context.Context, method, path string, query url.Values, contentType string, body, out any) error {
+	_, err := c.request(ctx, method, path, query, contentType, body, out)
+	return err
+}
+
+// request sends a request with body encoded as JSON, if not nil, and
+// decodes a successful response into out, if not nil. Other responses are
+// returned as a *StatusError.
+func (c *Client[T]) request(ctx context.Context, method, path string, query url.Values, contentType string, body, out any) (*http.Response, error) {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.options.authorization != "" {
+		req.Header.Set("Authorization", c.options.authorization)
+	}
+
+	resp, err := c.options.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		statusErr := &StatusError{StatusCode: resp.StatusCode}
+		// The body is informative only, the status code is the error
+		_ = json.NewDecoder(resp.Body).Decode(&statusErr.Status)
+		return resp, statusErr
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return resp, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp, fmt.Errorf("decoding response: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/internal"
+	"my-embedded-api/meta"
+	"my-embedded-api/server"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSecret = []byte("test-secret")
+
+// setupTestServer serves users with a Router[apiv1.User] at /api/v1/users
+// and users with RegisterResource at /users, both requiring a bearer token
+func setupTestServer(t *testing.T) (*httptest.Server, string) {
+	gin.SetMode(gin.TestMode)
+	config := server.NewConfig()
+	config.Database.Path = filepath.Join(t.TempDir(), "test.db")
+	config.Logging.Level = "silent"
+	db, err := server.OpenDatabase(config)
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&apiv1.User{}))
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	engine := gin.New()
+	auth := internal.WithAuth(internal.NewJWTMiddleware(testSecret, ""))
+	internal.NewRouter[apiv1.User](engine, db, auth).Register("/api/v1/users")
+	internal.RegisterResource[apiv1.User](engine, db, "/users", auth)
+
+	admin := &apiv1.User{Username: "admin", Email: "admin@example.com", Password: "password123", Role: apiv1.RoleAdmin}
+	require.NoError(t, db.Create(admin).Error)
+	token, _, err := internal.IssueToken(testSecret, admin, time.Hour, time.Now())
+	require.NoError(t, err)
+
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+	return server, token
+}
+
+func TestClient_CRUD(t *testing.T) {
+	server, token := setupTestServer(t)
+	c := NewClient[apiv1.User](server.URL+"/api/v1/users", WithToken(token))
+	ctx := context.Background()
+
+	created, err := c.Create(ctx, &apiv1.User{Username: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+	assert.NotZero(t, created.ID)
+	assert.Equal(t, "alice", created.Username)
+
+	found, err := c.Get(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", found.Email)
+
+	found.FullName = "Alice"
+	updated, err := c.Update(ctx, found.ID, found)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", updated.FullName)
+
+	patched, err := c.Patch(ctx, found.ID, map[string]any{"fullName": "Alice Smith"})
+	require.NoError(t, err)
+	assert.Equal(t, "Alice Smith", patched.FullName)
+	assert.Equal(t, "alice", patched.Username)
+
+	page, err := c.List(ctx, &ListOptions{Page: 1, Size: 10, Sort: "-username"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, "alice", page.Items[0].Username)
+
+	require.NoError(t, c.Delete(ctx, found.ID))
+	_, err = c.Get(ctx, found.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	var statusErr *StatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	assert.Equal(t, meta.StatusReasonNotFound, statusErr.Status.Reason)
+}
+
+func TestClient_ListEnvelope(t *testing.T) {
+	server, token := setupTestServer(t)
+	c := NewClient[apiv1.User](server.URL+"/users", WithToken(token))
+	ctx := context.Background()
+
+	_, err := c.Create(ctx, &apiv1.User{Username: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	page, err := c.List(ctx, &ListOptions{Filter: map[string]string{"username": "alice"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), page.Total)
+	assert.Equal(t, 1, page.Page)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "alice", page.Items[0].Username)
+
+	page, err = c.List(ctx, &ListOptions{After: "0", Size: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.NotEmpty(t, page.NextCursor)
+
+	page, err = c.List(ctx, &ListOptions{Limit: 1, Sort: "username"})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "admin", page.Items[0].Username)
+	page, err = c.List(ctx, &ListOptions{Limit: 1, Sort: "username", Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "alice", page.Items[0].Username)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestClient_Errors(t *testing.T) {
+	server, token := setupTestServer(t)
+	ctx := context.Background()
+
+	c := NewClient[apiv1.User](server.URL+"/api/v1/users", WithToken(token))
+	_, err := c.Create(ctx, &apiv1.User{Username: "admin", Email: "other@example.com", Password: "password123"})
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.NotErrorIs(t, err, ErrNotFound)
+
+	_, err = c.Create(ctx, &apiv1.User{Username: "bob"})
+	var statusErr *StatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusUnprocessableEntity, statusErr.StatusCode)
+	assert.Equal(t, meta.StatusReasonInvalid, statusErr.Status.Reason)
+
+	// Requests without the token are rejected
+	_, err = NewClient[apiv1.User](server.URL+"/api/v1/users").Get(ctx, 1)
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+
+	// Cancelled contexts abort the request
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = c.Get(cancelled, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+}
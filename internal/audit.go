@@ -0,0 +1,272 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AuditAction is the kind of mutation an audit event records
+type AuditAction string
+
+const (
+	AuditActionCreate AuditAction = "CREATE"
+	AuditActionUpdate AuditAction = "UPDATE"
+	AuditActionDelete AuditAction = "DELETE"
+)
+
+// ErrAuditImmutable is returned when an audit event is updated or deleted
+var ErrAuditImmutable = errors.New("audit events are immutable")
+
+// AuditEvent records a single mutation of a resource
+type AuditEvent struct {
+	ID uint `json:"id" gorm:"primarykey"`
+
+	// Resource is the table of the resource, e.g. "users"
+	Resource string `json:"resource" gorm:"index:idx_audit_resource"`
+
+	// ResourceKind is the kind of the resource, e.g. "User"
+	ResourceKind string `json:"resourceKind"`
+
+	ResourceID  uint   `json:"resourceId" gorm:"index:idx_audit_resource"`
+	ResourceUID string `json:"resourceUid,omitempty" gorm:"type:char(36)"`
+
+	Action AuditAction `json:"action"`
+
+	// ActorID identifies who made the change
+	ActorID string `json:"actorId"`
+
+	Timestamp time.Time `json:"timestamp"`
+
+	// Before and After hold the JSON of the resource around the change
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// TableName stores audit events in the audit_log table
+func (AuditEvent) TableName() string {
+	return "audit_log"
+}
+
+// BeforeUpdate keeps audit events immutable
+func (AuditEvent) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditImmutable
+}
+
+// BeforeDelete keeps audit events immutable
+func (AuditEvent) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditImmutable
+}
+
+// AuditDAO stores and queries audit events. It deliberately offers no way
+// to change or remove recorded events.
+type AuditDAO struct {
+	db *gorm.DB
+}
+
+// NewAuditDAO creates a new AuditDAO instance
+func NewAuditDAO(db *gorm.DB) *AuditDAO {
+	return &AuditDAO{db: db}
+}
+
+// AutoMigrate creates the audit_log table
+func (a *AuditDAO) AutoMigrate() error {
+	return a.db.AutoMigrate(&AuditEvent{})
+}
+
+// Record stores an audit event
+func (a *AuditDAO) Record(event *AuditEvent) error {
+	return a.db.Create(event).Error
+}
+
+// List returns the audit events of a resource table, oldest first. An
+// empty resource matches every table and a zero id every resource.
+func (a *AuditDAO) List(resource string, id uint) ([]AuditEvent, error) {
+	return a.ListAfter(resource, id, 0, 0)
+}
+
+// ListAfter returns up to limit audit events following the event with ID
+// after, oldest first, filtered as in List. A limit of zero or less
+// returns every following event.
+func (a *AuditDAO) ListAfter(resource string, id uint, after uint, limit int) ([]AuditEvent, error) {
+	query := a.db.Order("id")
+	if after != 0 {
+		query = query.Where("id > ?", after)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if resource != "" {
+		query = query.Where("resource = ?", resource)
+	}
+	if id != 0 {
+		query = query.Where("resource_id = ?", id)
+	}
+
+	var events []AuditEvent
+	if err := query.Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ActorKey is the gin context key under which authentication middleware
+// stores the ID of the caller, used as the actor of audit events
+const ActorKey = "actor"
+
+// requestContext returns the context of a request carrying its actor
+func requestContext(c *gin.Context) context.Context {
+	ctx := WithActor(c.Request.Context(), requestActor(c))
+	if claims := GetClaims(c); claims != nil {
+		roles := make([]string, 0, 1)
+		for role := range claimRoles(claims) {
+			roles = append(roles, role)
+		}
+		ctx = meta.WithRoles(ctx, roles...)
+	}
+	return ctx
+}
+
+// requestActor identifies the caller of a request, falling back to the
+// client IP when it is not authenticated
+func requestActor(c *gin.Context) string {
+	if actor := c.GetString(ActorKey); actor != "" {
+		return actor
+	}
+	return c.ClientIP()
+}
+
+// actorContextKey is the context key holding the actor of a request
+type actorContextKey struct{}
+
+// WithActor returns a context recording actor as the one making changes
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored by WithActor
+func ActorFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	actor, _ := ctx.Value(actorContextKey{}).(string)
+	return actor
+}
+
+// newAuditEvent builds the audit event of a change to a resource stored in
+// the table of tx. before or after is nil for creates and deletes.
+func newAuditEvent[T any](tx *gorm.DB, action AuditAction, before, after *T) (*AuditEvent, error) {
+	subject := after
+	if subject == nil {
+		subject = before
+	}
+
+	event := &AuditEvent{
+		ResourceKind: resourceKind(subject),
+		ResourceID:   resourceID(subject),
+		Action:       action,
+		ActorID:      ActorFromContext(tx.Statement.Context),
+		Timestamp:    time.Now(),
+	}
+
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(subject); err != nil {
+		return nil, err
+	}
+	event.Resource = stmt.Schema.Table
+
+	if owned, ok := any(subject).(interface{ GetUID() string }); ok {
+		event.ResourceUID = owned.GetUID()
+	}
+
+	var err error
+	if before != nil {
+		if event.Before, err = redactJSON(before); err != nil {
+			return nil, err
+		}
+	}
+	if after != nil {
+		if event.After, err = redactJSON(after); err != nil {
+			return nil, err
+		}
+	}
+	return event, nil
+}
+
+// resourceKind returns the kind of a resource, falling back to its type
+// name when the kind is not set
+func resourceKind(resource any) string {
+	if kinded, ok := resource.(interface{ GetKind() string }); ok && kinded.GetKind() != "" {
+		return kinded.GetKind()
+	}
+	return reflect.Indirect(reflect.ValueOf(resource)).Type().Name()
+}
+
+const (
+	// DefaultAuditLimit is the number of audit events served when the
+	// limit query parameter is not set
+	DefaultAuditLimit = 100
+
+	// MaxAuditLimit is the largest limit accepted for audit events
+	MaxAuditLimit = 1000
+)
+
+// RegisterAudit registers the read-only audit log route at path. Events can
+// be filtered with the resource (table name) and id query parameters, e.g.
+// GET /audit?resource=users&id=42, and are served DefaultAuditLimit at a
+// time: the after parameter continues with the events following the ID of
+// the last one seen, and limit sets up to MaxAuditLimit events. The
+// authentication and role requirements of opts apply, as for resources.
+func RegisterAudit(engine *gin.Engine, audit *AuditDAO, path string, opts ...RouterOption) {
+	group := engine.Group(path)
+	newRouterOptions(opts...).use(group, "AuditEvent")
+	group.GET("", func(c *gin.Context) {
+		id, err := parseAuditID(c.Query("id"))
+		if err != nil {
+			writeBadRequest(c, "invalid id")
+			return
+		}
+		after, err := parseAuditID(c.Query("after"))
+		if err != nil {
+			writeBadRequest(c, "invalid after")
+			return
+		}
+		limit := DefaultAuditLimit
+		if raw := c.Query("limit"); raw != "" {
+			limit, err = strconv.Atoi(raw)
+			if err != nil || limit < 1 || limit > MaxAuditLimit {
+				writeBadRequest(c, fmt.Sprintf("limit must be between 1 and %d", MaxAuditLimit))
+				return
+			}
+		}
+
+		events, err := audit.ListAfter(c.Query("resource"), id, after, limit)
+		if err != nil {
+			writeInternalError(c, err)
+			return
+		}
+		if events == nil {
+			events = make([]AuditEvent, 0)
+		}
+		c.JSON(http.StatusOK, events)
+	})
+}
+
+// parseAuditID parses an optional event or resource ID, zero if raw is empty
+func parseAuditID(raw string) (uint, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	return uint(id), err
+}
@@ -0,0 +1,316 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// auditLogQueueSize is the number of entries waiting to be stored.
+	// Entries of requests finishing while the queue is full are dropped.
+	auditLogQueueSize = 1024
+
+	// auditBodyLimit is the size of the request and response bodies kept
+	// for an entry; larger request bodies are digested but not stored
+	auditBodyLimit = 64 << 10
+)
+
+// auditedMethods are the mutating HTTP methods recorded by an AuditRecorder
+var auditedMethods = map[string]bool{
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// redacted replaces the secrets of stored request bodies
+const redacted = "[REDACTED]"
+
+// secretKeys are the parts of JSON keys whose values are redacted from
+// stored request bodies, compared ignoring case
+var secretKeys = []string{"password", "secret", "token", "apikey"}
+
+// AuditRecord describes a mutating request to a resource: who made it, what
+// it asked to change and how it was answered
+type AuditRecord struct {
+	// Actor identifies the caller, see requestActor
+	Actor string
+
+	// Kind is the kind of the resource the request was made to
+	Kind string
+
+	// ResourceID and ResourceUID identify the resource changed, when the
+	// request concerned a single one
+	ResourceID  uint
+	ResourceUID string
+
+	// Method and Path are the HTTP method and route of the request
+	Method string
+	Path   string
+
+	// RequestDigest is the hex encoded SHA-256 hash of the request body
+	RequestDigest string
+
+	// RequestBody is the JSON request body with its secrets redacted, if
+	// the recorder stores bodies
+	RequestBody string
+
+	// Status is the HTTP status of the response
+	Status int
+
+	// Timestamp is when the response was sent
+	Timestamp time.Time
+}
+
+// AuditStore stores the records of an AuditRecorder. The server implements
+// it with the audit entries it serves.
+type AuditStore interface {
+	StoreAuditRecord(ctx context.Context, record *AuditRecord) error
+}
+
+// AuditRecorder records every mutating request to the resources it is
+// installed on, see WithAuditLog. Records are stored in the background
+// after the response was written, so that requests do not wait for them.
+// Shutdown stores the queued records.
+type AuditRecorder struct {
+	store       AuditStore
+	storeBodies bool
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan *AuditRecord
+	done   chan struct{}
+}
+
+// NewAuditRecorder starts a recorder storing records in store. When
+// storeBodies is set, JSON request bodies are stored with the values of
+// secret looking keys, such as password, redacted.
+func NewAuditRecorder(store AuditStore, storeBodies bool) *AuditRecorder {
+	r := &AuditRecorder{
+		store:       store,
+		storeBodies: storeBodies,
+		queue:       make(chan *AuditRecord, auditLogQueueSize),
+		done:        make(chan struct{}),
+	}
+	go r.work()
+	return r
+}
+
+// Shutdown stops recording and waits until the queued records are stored
+// or ctx is done, in which case ctx.Err() is returned
+func (r *AuditRecorder) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work stores queued records until the queue is closed and drained
+func (r *AuditRecorder) work() {
+	defer close(r.done)
+	for record := range r.queue {
+		if err := r.store.StoreAuditRecord(context.Background(), record); err != nil {
+			slog.Error("Audit entry failed", "method", record.Method, "path", record.Path, "error", err)
+		}
+	}
+}
+
+// record queues record without blocking
+func (r *AuditRecorder) record(record *AuditRecord) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		slog.Warn("Audit entry dropped after shutdown", "method", record.Method, "path", record.Path)
+		return
+	}
+	select {
+	case r.queue <- record:
+	default:
+		slog.Warn("Audit entry dropped, queue full", "method", record.Method, "path", record.Path)
+	}
+}
+
+// middleware records the mutating requests to resources of kind
+func (r *AuditRecorder) middleware(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auditedMethods[c.Request.Method] {
+			c.Next()
+			return
+		}
+
+		// The body is digested, and kept if it is to be stored, as the
+		// handler reads it
+		body := &auditBody{hash: sha256.New(), keep: r.storeBodies}
+		if c.Request.Body != nil {
+			body.ReadCloser = c.Request.Body
+			c.Request.Body = body
+		}
+		writer := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		record := &AuditRecord{
+			Actor:         requestActor(c),
+			Kind:          kind,
+			Method:        c.Request.Method,
+			Path:          c.FullPath(),
+			RequestDigest: hex.EncodeToString(body.hash.Sum(nil)),
+			Status:        writer.Status(),
+			Timestamp:     time.Now(),
+		}
+		if id, err := strconv.ParseUint(c.Param("id"), 10, 64); err == nil {
+			record.ResourceID = uint(id)
+		}
+		// Single resources in the response name the resource changed
+		var response struct {
+			Metadata struct {
+				ID  uint   `json:"id"`
+				UID string `json:"uid"`
+			} `json:"metadata"`
+		}
+		if json.Unmarshal(writer.body.Bytes(), &response) == nil {
+			if record.ResourceID == 0 {
+				record.ResourceID = response.Metadata.ID
+			}
+			record.ResourceUID = response.Metadata.UID
+		}
+		if r.storeBodies && !body.overflow && body.data.Len() > 0 {
+			record.RequestBody = redactBody(body.data.Bytes())
+		}
+		r.record(record)
+	}
+}
+
+// redactBody returns a JSON body with the values of secret keys replaced,
+// or an empty string if body is not JSON and cannot be redacted
+func redactBody(body []byte) string {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return ""
+	}
+	data, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// redactJSON returns the JSON of value with the values of secret keys
+// replaced, as in stored request bodies
+func redactJSON(value any) (json.RawMessage, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return json.Marshal(redactValue(decoded))
+}
+
+// redactValue replaces the values of secret keys in a decoded JSON value
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if isSecretKey(key) {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
+
+// isSecretKey reports whether a JSON key names a secret
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditBody digests a request body as it is read, keeping its first
+// auditBodyLimit bytes when keep is set
+type auditBody struct {
+	io.ReadCloser
+	hash     hash.Hash
+	keep     bool
+	data     bytes.Buffer
+	overflow bool
+}
+
+// Read reads from the body, digesting and keeping what was read
+func (b *auditBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	if b.keep && !b.overflow {
+		if b.data.Len()+n > auditBodyLimit {
+			b.overflow = true
+			b.data.Reset()
+		} else {
+			b.data.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+// auditWriter keeps the first auditBodyLimit bytes of a response, from
+// which the resource changed is read
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write writes data to the response, keeping it
+func (w *auditWriter) Write(data []byte) (int, error) {
+	w.keep(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString writes s to the response, keeping it
+func (w *auditWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// keep adds data to the kept body while it fits
+func (w *auditWriter) keep(data []byte) {
+	if w.body.Len()+len(data) <= auditBodyLimit {
+		w.body.Write(data)
+	}
+}
@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// auditRecords is an AuditStore keeping the records in memory
+type auditRecords struct {
+	mu      sync.Mutex
+	records []*AuditRecord
+}
+
+// StoreAuditRecord keeps record
+func (s *auditRecords) StoreAuditRecord(_ context.Context, record *AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	return nil
+}
+
+func TestAuditRecorder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	store := &auditRecords{}
+	recorder := NewAuditRecorder(store, true)
+
+	// The caller is authenticated as admin-1
+	authenticate := func(c *gin.Context) { c.Set(ActorKey, "admin-1") }
+	NewRouter[apiv1.User](engine, db, WithMiddleware(authenticate), WithAuditLog(recorder)).Register("/api/v1/users")
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	createBody := `{"username":"alice","email":"alice@example.com","password":"password123"}`
+	w := serve("POST", "/api/v1/users", createBody)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var user apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+
+	path := fmt.Sprintf("/api/v1/users/%d", user.ID)
+	w = serve("PUT", path, `{"username":"alice","email":"alice@example.com","password":"password123","fullName":"Alice"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, http.StatusNoContent, serve("DELETE", path, "").Code)
+	require.Equal(t, http.StatusOK, serve("GET", "/api/v1/users", "").Code)
+
+	require.NoError(t, recorder.Shutdown(context.Background()))
+
+	// One record per mutating request, reads are not recorded
+	recorded := store.records
+	require.Len(t, recorded, 3)
+
+	methods := []string{http.MethodPost, http.MethodPut, http.MethodDelete}
+	statuses := []int{http.StatusCreated, http.StatusOK, http.StatusNoContent}
+	for i, record := range recorded {
+		assert.Equal(t, methods[i], record.Method)
+		assert.Equal(t, statuses[i], record.Status)
+		assert.Equal(t, "admin-1", record.Actor)
+		assert.Equal(t, "User", record.Kind)
+		assert.Equal(t, user.ID, record.ResourceID)
+		assert.False(t, record.Timestamp.IsZero())
+	}
+	assert.Equal(t, "/api/v1/users", recorded[0].Path)
+	assert.Equal(t, "/api/v1/users/:id", recorded[2].Path)
+	assert.Equal(t, user.UID, recorded[0].ResourceUID)
+
+	// Bodies are digested as sent and stored without their secrets
+	digest := sha256.Sum256([]byte(createBody))
+	assert.Equal(t, hex.EncodeToString(digest[:]), recorded[0].RequestDigest)
+	assert.JSONEq(t, `{"username":"alice","email":"alice@example.com","password":"[REDACTED]"}`, recorded[0].RequestBody)
+	assert.Empty(t, recorded[2].RequestBody)
+}
+
+func TestRedactBody(t *testing.T) {
+	assert.JSONEq(t, `{"user":{"newPassword":"[REDACTED]","name":"a"},"keys":[{"apiKey":"[REDACTED]"}],"clientSecret":"[REDACTED]"}`,
+		redactBody([]byte(`{"user":{"newPassword":"x","name":"a"},"keys":[{"apiKey":"k"}],"clientSecret":"s"}`)))
+	assert.Empty(t, redactBody([]byte("{\"a\":1}\n{\"b\":2}")))
+}
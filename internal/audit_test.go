@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDAO_Audit(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	audit := NewAuditDAO(db)
+	assert.NoError(t, audit.AutoMigrate())
+	dao := NewDAO[apiv1.User](db, WithAudit(audit))
+	ctx := WithActor(context.Background(), "alice")
+
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	assert.NoError(t, dao.Create(ctx, user))
+
+	user.Email = "updated@example.com"
+	assert.NoError(t, dao.Update(ctx, user.ID, user, 0))
+	assert.NoError(t, dao.Delete(ctx, user.ID, 0))
+
+	events, err := audit.List("users", user.ID)
+	assert.NoError(t, err)
+	if !assert.Len(t, events, 3) {
+		return
+	}
+
+	assert.Equal(t, AuditActionCreate, events[0].Action)
+	assert.Equal(t, AuditActionUpdate, events[1].Action)
+	assert.Equal(t, AuditActionDelete, events[2].Action)
+
+	for _, event := range events {
+		assert.Equal(t, "users", event.Resource)
+		assert.Equal(t, user.ID, event.ResourceID)
+		assert.Equal(t, user.UID, event.ResourceUID)
+		assert.Equal(t, "alice", event.ActorID)
+		assert.False(t, event.Timestamp.IsZero())
+	}
+
+	var before, after apiv1.User
+	assert.Nil(t, events[0].Before)
+	assert.NoError(t, json.Unmarshal(events[1].Before, &before))
+	assert.NoError(t, json.Unmarshal(events[1].After, &after))
+	assert.Equal(t, "test@example.com", before.Email)
+	assert.Equal(t, "updated@example.com", after.Email)
+	assert.Nil(t, events[2].After)
+
+	// Recorded events cannot be changed or removed
+	assert.ErrorIs(t, db.Model(&events[0]).Update("actor_id", "mallory").Error, ErrAuditImmutable)
+	assert.ErrorIs(t, db.Delete(&events[0]).Error, ErrAuditImmutable)
+}
+
+func TestDAO_AuditDisabled(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	audit := NewAuditDAO(db)
+	assert.NoError(t, audit.AutoMigrate())
+	dao := NewDAO[apiv1.User](db)
+
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	assert.NoError(t, dao.Create(context.Background(), user))
+
+	events, err := audit.List("", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestRegisterAudit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	audit := NewAuditDAO(db)
+	assert.NoError(t, audit.AutoMigrate())
+	NewRouter[apiv1.User](engine, db, WithDAOOptions(WithAudit(audit))).Register("/api/v1/users")
+	RegisterAudit(engine, audit, "/audit")
+
+	// Create through the API so the client IP is the actor
+	body := `{"username":"testuser","email":"test@example.com","password":"password123"}`
+	req := httptest.NewRequest("POST", "/api/v1/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var user apiv1.User
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+
+	req = httptest.NewRequest("GET", fmt.Sprintf("/audit?resource=users&id=%d", user.ID), nil)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var events []AuditEvent
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &events))
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, AuditActionCreate, events[0].Action)
+		assert.Equal(t, "192.0.2.1", events[0].ActorID)
+
+		// Secrets are redacted from the snapshots
+		var after map[string]interface{}
+		assert.NoError(t, json.Unmarshal(events[0].After, &after))
+		assert.NotContains(t, after, "password")
+		assert.Equal(t, "testuser", after["username"])
+	}
+
+	// Events are served a page at a time
+	body = `{"username":"otheruser","email":"other@example.com","password":"password123"}`
+	req = httptest.NewRequest("POST", "/api/v1/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	req = httptest.NewRequest("GET", "/audit?limit=1", nil)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &events))
+	if assert.Len(t, events, 1) {
+		req = httptest.NewRequest("GET", fmt.Sprintf("/audit?limit=1&after=%d", events[0].ID), nil)
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		var next []AuditEvent
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &next))
+		if assert.Len(t, next, 1) {
+			assert.Greater(t, next[0].ID, events[0].ID)
+		}
+	}
+
+	for _, query := range []string{"limit=0", fmt.Sprintf("limit=%d", MaxAuditLimit+1), "after=abc"} {
+		req = httptest.NewRequest("GET", "/audit?"+query, nil)
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	// Other resources have no history
+	req = httptest.NewRequest("GET", "/audit?resource=users&id=999", nil)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, "[]", w.Body.String())
+
+	// Invalid IDs are rejected
+	req = httptest.NewRequest("GET", "/audit?id=abc", nil)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// The audit log is read-only
+	req = httptest.NewRequest("DELETE", "/audit", nil)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRegisterAudit_Auth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	audit := NewAuditDAO(db)
+	assert.NoError(t, audit.AutoMigrate())
+	secret := []byte("secret")
+	RegisterAudit(engine, audit, "/audit",
+		WithAuth(NewJWTMiddleware(secret, ClaimsKey)),
+		WithRoleRequirements(map[string][]string{"GET": {apiv1.RoleAdmin}}))
+
+	req := httptest.NewRequest("GET", "/audit", nil)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
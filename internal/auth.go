@@ -0,0 +1,243 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// UIDClaim is the JWT claim holding the UID of the authenticated user
+const UIDClaim = "uid"
+
+// ErrInvalidCredentials is the only login failure reported to clients, so
+// that they cannot tell unknown users from wrong passwords
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// ErrInvalidAPIKey is the only API key failure reported to clients
+var ErrInvalidAPIKey = errors.New("invalid or expired API key")
+
+// tokenParser accepts the HMAC algorithms used by IssueToken
+var tokenParser = jwt.NewParser(jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}))
+
+// TokenSubject is the user a token is issued for
+type TokenSubject interface {
+	GetID() uint
+	GetUID() string
+	GetRoles() []string
+}
+
+// Authenticator checks the credentials of the users of the API. It keeps
+// the user resources out of this package: the server implements it over
+// its users and API keys.
+type Authenticator interface {
+	// Authenticate returns the active user with the given credentials, or
+	// ErrInvalidCredentials
+	Authenticate(ctx context.Context, username, password string) (TokenSubject, error)
+
+	// AuthenticateAPIKey returns the active owner of key and the prefix
+	// identifying the key, or ErrInvalidAPIKey if the key is unknown, wrong
+	// or expired. The use of the key is recorded at now.
+	AuthenticateAPIKey(ctx context.Context, key string, now time.Time) (owner TokenSubject, prefix string, err error)
+}
+
+// LoginRequest is the body of a login request
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// LoginResponse is the body of a successful login
+type LoginResponse struct {
+	// Token is the signed JWT to send as Authorization: Bearer
+	Token string `json:"token"`
+
+	// ExpiresAt is when the token stops being accepted
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// IssueToken signs a JWT for user with HS256, valid for ttl from now. The
+// subject is the user ID, the uid claim its UID and the roles claim its
+// roles, as checked by RBACMiddleware.
+func IssueToken(secret []byte, user TokenSubject, ttl time.Duration, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(ttl)
+	claims := subjectClaims(user)
+	claims["iat"] = now.Unix()
+	claims["exp"] = expiresAt.Unix()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// subjectClaims returns the claims identifying user
+func subjectClaims(user TokenSubject) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":      strconv.FormatUint(uint64(user.GetID()), 10),
+		UIDClaim:   user.GetUID(),
+		RolesClaim: user.GetRoles(),
+	}
+}
+
+// ParseToken validates a token signed with secret, including its expiry,
+// and returns its claims
+func ParseToken(secret []byte, token string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := tokenParser.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return secret, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	return claims, nil
+}
+
+// ClaimsUserID returns the user ID in the subject of claims issued by
+// IssueToken
+func ClaimsUserID(claims jwt.MapClaims) (uint, error) {
+	subject, err := claims.GetSubject()
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(subject, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("subject %q is not a user ID", subject)
+	}
+	return uint(id), nil
+}
+
+// APIKeyClaim is the claim holding the prefix of the API key a request was
+// authenticated with
+const APIKeyClaim = "apiKey"
+
+// authenticateAPIKey returns the claims of the owner of key, like those
+// issued by IssueToken, or ErrInvalidAPIKey
+func authenticateAPIKey(ctx context.Context, auth Authenticator, key string, now time.Time) (jwt.MapClaims, error) {
+	owner, prefix, err := auth.AuthenticateAPIKey(ctx, key, now)
+	if err != nil {
+		return nil, err
+	}
+	claims := subjectClaims(owner)
+	claims[APIKeyClaim] = prefix
+	return claims, nil
+}
+
+// RegisterLogin adds a POST endpoint at path exchanging a username and
+// password checked by auth for a JWT signed with secret and valid for ttl.
+// Unknown users, wrong passwords and inactive users all get the same 401
+// response.
+func RegisterLogin(engine *gin.Engine, auth Authenticator, path string, secret []byte, ttl time.Duration) {
+	engine.POST(path, func(c *gin.Context) {
+		var req LoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeInvalid(c, err)
+			return
+		}
+
+		user, err := auth.Authenticate(c.Request.Context(), req.Username, req.Password)
+		if errors.Is(err, ErrInvalidCredentials) {
+			writeError(c, http.StatusUnauthorized, meta.StatusReasonUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			writeInternalError(c, err)
+			return
+		}
+
+		token, expiresAt, err := IssueToken(secret, user, ttl, time.Now())
+		if err != nil {
+			writeInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, LoginResponse{Token: token, ExpiresAt: expiresAt})
+	})
+}
+
+// PasswordChangeRequest is the body of a password change request
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required"`
+}
+
+// PasswordHolder is a resource with a password, such as a user
+type PasswordHolder interface {
+	// ComparePassword returns an error unless password is the current one
+	ComparePassword(password string) error
+
+	// SetPassword replaces the password, failing with an error listing
+	// its causes when password is too weak
+	SetPassword(password string) error
+}
+
+// RegisterPasswordChange serves PUT path/:id/password, which replaces the
+// password of a T after verifying the current one. A wrong current password
+// is rejected with 403 and a weak new password with 400. opts configure the
+// route as in RegisterResource. It panics unless *T is a PasswordHolder.
+func RegisterPasswordChange[T any](engine *gin.Engine, db *gorm.DB, path string, opts ...RouterOption) {
+	if _, ok := any(new(T)).(PasswordHolder); !ok {
+		panic(fmt.Sprintf("RegisterPasswordChange: %T has no password", new(T)))
+	}
+	options := newRouterOptions(opts...)
+	dao := NewDAO[T](db, options.daoOptions...)
+
+	group := engine.Group(path + "/:id/password")
+	options.use(group, resourceKind(new(T)))
+	group.PUT("", func(c *gin.Context) {
+		id, ok := parseIDParam(c, dao)
+		if !ok {
+			return
+		}
+
+		var req PasswordChangeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeInvalid(c, err)
+			return
+		}
+
+		obj, err := dao.Get(c.Request.Context(), id)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				writeNotFound(c)
+				return
+			}
+			writeInternalError(c, err)
+			return
+		}
+
+		user := any(obj).(PasswordHolder)
+		if err := user.ComparePassword(req.CurrentPassword); err != nil {
+			writeError(c, http.StatusForbidden, meta.StatusReasonForbidden, "current password is incorrect")
+			return
+		}
+		if err := user.SetPassword(req.NewPassword); err != nil {
+			var causes causesError
+			if errors.As(err, &causes) {
+				writeInvalid(c, err)
+				return
+			}
+			writeInternalError(c, err)
+			return
+		}
+
+		// The update hooks bump the resource version
+		if err := dao.Update(requestContext(c), id, obj, resourceVersion(obj)); err != nil {
+			if err == ErrConflict {
+				writeError(c, http.StatusConflict, meta.StatusReasonConflict, "user was modified concurrently, retry the request")
+				return
+			}
+			writeWriteError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	registerOptionsRoutes(engine, group)
+}
@@ -0,0 +1,377 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testAuthenticator accepts the password password123 and the API key
+// key-<username> for the users it holds by username
+type testAuthenticator map[string]*apiv1.User
+
+// Authenticate returns the user with username
+func (a testAuthenticator) Authenticate(_ context.Context, username, password string) (TokenSubject, error) {
+	user, ok := a[username]
+	if !ok || password != "password123" {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// AuthenticateAPIKey returns the user named by key, whose prefix is the
+// username. The key broken fails as if the users could not be read.
+func (a testAuthenticator) AuthenticateAPIKey(_ context.Context, key string, _ time.Time) (TokenSubject, string, error) {
+	if key == "broken" {
+		return nil, "", errors.New("database is down")
+	}
+	username, ok := strings.CutPrefix(key, "key-")
+	user, found := a[username]
+	if !ok || !found {
+		return nil, "", ErrInvalidAPIKey
+	}
+	return user, username, nil
+}
+
+// newTestUser returns a user with the given ID and role
+func newTestUser(id uint, role string) *apiv1.User {
+	user := &apiv1.User{Role: role}
+	user.ID = id
+	user.UID = "uid-" + strconv.FormatUint(uint64(id), 10)
+	return user
+}
+
+func TestRegisterLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	alice := newTestUser(7, apiv1.RoleUser)
+	RegisterLogin(router, testAuthenticator{"alice": alice}, "/login", testSecret, time.Hour)
+
+	login := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/login", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Valid credentials return a token for the user
+	w := login(`{"username":"alice","password":"password123"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp LoginResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.WithinDuration(t, time.Now().Add(time.Hour), resp.ExpiresAt, time.Minute)
+
+	claims, err := ParseToken(testSecret, resp.Token)
+	require.NoError(t, err)
+	id, err := ClaimsUserID(claims)
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, id)
+	assert.Equal(t, alice.UID, claims[UIDClaim])
+	assert.Equal(t, []interface{}{apiv1.RoleUser}, claims[RolesClaim])
+
+	// Every failure looks the same to the client
+	failures := []struct {
+		name string
+		body string
+	}{
+		{"wrong password", `{"username":"alice","password":"wrong"}`},
+		{"unknown user", `{"username":"nobody","password":"password123"}`},
+	}
+	for _, tt := range failures {
+		t.Run(tt.name, func(t *testing.T) {
+			w := login(tt.body)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			status := decodeStatus(t, w)
+			assert.Equal(t, meta.StatusReasonUnauthorized, status.Reason)
+			assert.Equal(t, "invalid username or password", status.Message)
+		})
+	}
+
+	w = login(`{"username":"alice"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestParseToken(t *testing.T) {
+	user := &apiv1.User{}
+	user.ID = 7
+	user.UID = "uid-7"
+
+	valid, _, err := IssueToken(testSecret, user, time.Hour, time.Now())
+	require.NoError(t, err)
+	expired, _, err := IssueToken(testSecret, user, time.Hour, time.Now().Add(-2*time.Hour))
+	require.NoError(t, err)
+
+	claims, err := ParseToken(testSecret, valid)
+	require.NoError(t, err)
+	assert.Equal(t, "7", claims["sub"])
+
+	_, err = ParseToken(testSecret, expired)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+
+	_, err = ParseToken([]byte("other"), valid)
+	assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+
+	// Expired tokens are refused by the middleware as well
+	router := gin.New()
+	router.Use(NewJWTMiddleware(testSecret, ""))
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+	for token, code := range map[string]int{valid: http.StatusOK, expired: http.StatusUnauthorized} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code)
+	}
+}
+
+func TestClaimsUserID(t *testing.T) {
+	tests := []struct {
+		claims jwt.MapClaims
+		id     uint
+		valid  bool
+	}{
+		{jwt.MapClaims{"sub": "42"}, 42, true},
+		{jwt.MapClaims{"sub": "alice"}, 0, false},
+		{jwt.MapClaims{"sub": 42}, 0, false},
+		{jwt.MapClaims{}, 0, false},
+	}
+
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			id, err := ClaimsUserID(tt.claims)
+			assert.Equal(t, tt.valid, err == nil, "%v", err)
+			assert.Equal(t, tt.id, id)
+		})
+	}
+}
+
+func TestRoleRequirements(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	NewRouter[apiv1.User](engine, db,
+		WithAuth(NewJWTMiddleware(testSecret, "")),
+		WithRoleRequirements(map[string][]string{
+			"GET":    {apiv1.RoleAdmin, apiv1.RoleUser},
+			"DELETE": {apiv1.RoleAdmin},
+		}),
+	).Register("/api/v1/users")
+	engine.GET("/admin", NewJWTMiddleware(testSecret, ""), RBACMiddleware(apiv1.RoleAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	admin := &apiv1.User{Username: "admin", Email: "admin@example.com", Password: "password123", Role: apiv1.RoleAdmin}
+	require.NoError(t, db.Create(admin).Error)
+	regular := &apiv1.User{Username: "regular", Email: "regular@example.com", Password: "password123"}
+	require.NoError(t, db.Create(regular).Error)
+	assert.Equal(t, apiv1.RoleUser, regular.Role)
+
+	tokenFor := func(user *apiv1.User) string {
+		token, _, err := IssueToken(testSecret, user, time.Hour, time.Now())
+		require.NoError(t, err)
+		return token
+	}
+	adminToken, userToken := tokenFor(admin), tokenFor(regular)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		code   int
+	}{
+		{"user gets", "GET", "/api/v1/users/1", userToken, http.StatusOK},
+		{"user deletes", "DELETE", "/api/v1/users/1", userToken, http.StatusForbidden},
+		{"user on admin route", "GET", "/admin", userToken, http.StatusForbidden},
+		{"admin on admin route", "GET", "/admin", adminToken, http.StatusOK},
+		{"admin deletes", "DELETE", "/api/v1/users/2", adminToken, http.StatusNoContent},
+		{"anonymous gets", "GET", "/api/v1/users/1", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestRegisterPasswordChange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	RegisterResource[apiv1.User](router, db, "/users")
+	RegisterPasswordChange[apiv1.User](router, db, "/users")
+
+	user := &apiv1.User{Username: "alice", Email: "alice@example.com", Password: "password123"}
+	require.NoError(t, db.Create(user).Error)
+	path := "/users/" + strconv.FormatUint(uint64(user.ID), 10) + "/password"
+
+	changePassword := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	storedUser := func() *apiv1.User {
+		var stored apiv1.User
+		require.NoError(t, db.First(&stored, user.ID).Error)
+		return &stored
+	}
+
+	t.Run("wrong current password", func(t *testing.T) {
+		w := changePassword(`{"currentPassword":"wrong-password","newPassword":"new-password"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, meta.StatusReasonForbidden, decodeStatus(t, w).Reason)
+		assert.NoError(t, storedUser().ComparePassword("password123"))
+	})
+
+	t.Run("weak new password", func(t *testing.T) {
+		w := changePassword(`{"currentPassword":"password123","newPassword":"short"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, meta.StatusReasonInvalid, decodeStatus(t, w).Reason)
+		assert.NoError(t, storedUser().ComparePassword("password123"))
+	})
+
+	t.Run("correct change", func(t *testing.T) {
+		before := storedUser()
+		w := changePassword(`{"currentPassword":"password123","newPassword":"new-password"}`)
+		require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+		after := storedUser()
+		assert.Error(t, after.ComparePassword("password123"))
+		assert.NoError(t, after.ComparePassword("new-password"))
+		assert.Greater(t, after.ResourceVersion, before.ResourceVersion)
+	})
+
+	t.Run("generic update cannot change the password", func(t *testing.T) {
+		before := storedUser()
+		body, err := json.Marshal(map[string]interface{}{
+			"username":        before.Username,
+			"email":           before.Email,
+			"password":        "another-password",
+			"resourceVersion": before.ResourceVersion,
+		})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("PUT", "/users/"+strconv.FormatUint(uint64(user.ID), 10), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, decodeStatus(t, w).Message, "password endpoint")
+		assert.NoError(t, storedUser().ComparePassword("new-password"))
+	})
+}
+
+func TestUserRoleChange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	auth := WithAuth(NewJWTMiddleware(testSecret, ""))
+	RegisterResource[apiv1.User](engine, db, "/users", auth)
+	NewRouter[apiv1.User](engine, db, auth).Register("/api/v1/users")
+
+	admin := &apiv1.User{Username: "admin", Email: "admin@example.com", Password: "password123", Role: apiv1.RoleAdmin}
+	require.NoError(t, db.Create(admin).Error)
+	regular := &apiv1.User{Username: "regular", Email: "regular@example.com", Password: "password123"}
+	require.NoError(t, db.Create(regular).Error)
+	tokenFor := func(user *apiv1.User) string {
+		token, _, err := IssueToken(testSecret, user, time.Hour, time.Now())
+		require.NoError(t, err)
+		return token
+	}
+	serve := func(method, path string, user *apiv1.User, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if method == "PATCH" {
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+		}
+		req.Header.Set("Authorization", "Bearer "+tokenFor(user))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+	storedRole := func() string {
+		var user apiv1.User
+		require.NoError(t, db.First(&user, regular.ID).Error)
+		return user.Role
+	}
+	path := "/" + strconv.FormatUint(uint64(regular.ID), 10)
+
+	// Users cannot promote themselves, whatever the route
+	w := serve("PUT", "/users"+path, regular, `{"role":"admin"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	assert.Equal(t, meta.StatusReasonForbidden, decodeStatus(t, w).Reason)
+	w = serve("PATCH", "/api/v1/users"+path, regular, `{"role":"admin"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	assert.Equal(t, apiv1.RoleUser, storedRole())
+
+	// They may change other fields, admins may change the role
+	w = serve("PATCH", "/api/v1/users"+path, regular, `{"fullName":"Regular"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = serve("PUT", "/users"+path, admin, `{"role":"admin"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, apiv1.RoleAdmin, storedRole())
+}
+
+func TestJWTMiddleware_APIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	auth := testAuthenticator{"admin": newTestUser(1, apiv1.RoleAdmin)}
+	router.GET("/", NewJWTMiddleware(testSecret, "", WithAPIKeys(auth)), func(c *gin.Context) {
+		c.JSON(http.StatusOK, GetClaims(c))
+	})
+
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Keys act as their owner and are named in the claims
+	w := serve("ApiKey key-admin")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &claims))
+	assert.Equal(t, "1", claims["sub"])
+	assert.Equal(t, "admin", claims[APIKeyClaim])
+	assert.Equal(t, []interface{}{apiv1.RoleAdmin}, claims[RolesClaim])
+
+	w = serve("ApiKey key-nobody")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, meta.StatusReasonUnauthorized, decodeStatus(t, w).Reason)
+	assert.Contains(t, w.Header().Values("WWW-Authenticate"), `ApiKey realm="play-api"`)
+
+	// Failing to check a key is not the caller's fault
+	assert.Equal(t, http.StatusInternalServerError, serve("ApiKey broken").Code)
+}
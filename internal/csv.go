@@ -0,0 +1,281 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// CSVContentType is the media type of lists requested as CSV
+const CSVContentType = "text/csv"
+
+// csvRequested reports whether a list request asks for CSV, with
+// format=csv or an Accept header preferring text/csv
+func csvRequested(c *gin.Context) bool {
+	if format := c.Query("format"); format != "" {
+		return format == "csv"
+	}
+	return c.NegotiateFormat(binding.MIMEJSON, CSVContentType) == CSVContentType
+}
+
+// csvColumn is a column of a CSV list: a JSON field, nested objects being
+// flattened into dotted names such as metadata.uid
+type csvColumn struct {
+	name string
+	path []string
+
+	// fieldType is the type of the field, only set for imports
+	fieldType reflect.Type
+}
+
+// csvColumns returns the columns of resources of type t in field order,
+// leaving out write-only fields, fields tagged export:"-" and, when fields
+// is not nil, those not selected. Structs with their own JSON encoding, maps and slices are a
+// single column holding their JSON.
+func csvColumns(t reflect.Type, fields map[string]bool) []csvColumn {
+	var columns []csvColumn
+	collectCSVColumns(t, nil, false, fields, &columns)
+	return columns
+}
+
+// collectCSVColumns adds the columns of the struct t found at path to
+// columns. selected reports whether a field of path is selected.
+func collectCSVColumns(t reflect.Type, path []string, selected bool, fields map[string]bool, columns *[]csvColumn) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || hasTagOption(field.Tag.Get("openapi"), "writeOnly") || field.Tag.Get("export") == "-" {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			collectCSVColumns(field.Type, path, selected, fields, columns)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldPath := append(append([]string(nil), path...), name)
+		fieldSelected := selected || fields == nil || fields[name]
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct && fieldType != timeType &&
+			!fieldType.Implements(jsonMarshalerType) && !reflect.PointerTo(fieldType).Implements(jsonMarshalerType) {
+			collectCSVColumns(fieldType, fieldPath, fieldSelected, fields, columns)
+			continue
+		}
+		if fieldSelected {
+			*columns = append(*columns, csvColumn{name: strings.Join(fieldPath, "."), path: fieldPath})
+		}
+	}
+}
+
+// csvRecord returns the values of the columns of resource, encoded as in
+// its JSON: strings as they are, null as an empty value and objects and
+// arrays as JSON
+func csvRecord(resource any, columns []csvColumn) ([]string, error) {
+	encoded, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, err
+	}
+
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		var value interface{} = object
+		for _, key := range column.path {
+			nested, ok := value.(map[string]interface{})
+			if !ok {
+				value = nil
+				break
+			}
+			value = nested[key]
+		}
+
+		switch v := value.(type) {
+		case nil:
+		case string:
+			record[i] = v
+		case json.Number:
+			record[i] = v.String()
+		case bool:
+			record[i] = strconv.FormatBool(v)
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			record[i] = string(data)
+		}
+	}
+	return record, nil
+}
+
+// readImportCSVHeader reads the header row of a CSV import of resources
+// of type T, naming a JSON field per column as csvColumns does. Unlike
+// exports, imports may set write-only fields such as passwords.
+func readImportCSVHeader[T any](reader *csv.Reader) ([]csvColumn, error) {
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	columns := make([]csvColumn, len(header))
+	for i, name := range header {
+		path := strings.Split(name, ".")
+		fieldType, ok := csvFieldType(reflect.TypeFor[T](), path)
+		if !ok {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		columns[i] = csvColumn{name: name, path: path, fieldType: fieldType}
+	}
+	return columns, nil
+}
+
+// csvFieldType returns the type of the field of the struct t found at the
+// JSON names of path, dereferenced if a pointer
+func csvFieldType(t reflect.Type, path []string) (reflect.Type, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || len(path) == 0 {
+		return nil, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			if fieldType, ok := csvFieldType(field.Type, path); ok {
+				return fieldType, true
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if name != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			fieldType := field.Type
+			for fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			return fieldType, true
+		}
+		return csvFieldType(field.Type, path[1:])
+	}
+	return nil, false
+}
+
+// readImportCSV calls fn with the JSON of every row of a CSV import after
+// its header, and the line the row starts at. Empty values are left out.
+// Values of string fields are taken as they are, others as JSON when they
+// are valid JSON, as csvRecord writes them.
+func readImportCSV(reader *csv.Reader, columns []csvColumn, fn func(line int, data []byte) error) error {
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		object := make(map[string]interface{})
+		for i, value := range record {
+			if value == "" {
+				continue
+			}
+			column := columns[i]
+			parent := object
+			for _, key := range column.path[:len(column.path)-1] {
+				nested, ok := parent[key].(map[string]interface{})
+				if !ok {
+					nested = make(map[string]interface{})
+					parent[key] = nested
+				}
+				parent = nested
+			}
+			var encoded interface{} = value
+			if column.fieldType.Kind() != reflect.String && json.Valid([]byte(value)) {
+				encoded = json.RawMessage(value)
+			}
+			parent[column.path[len(column.path)-1]] = encoded
+		}
+
+		data, err := json.Marshal(object)
+		if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+		if err := fn(line, data); err != nil {
+			return err
+		}
+	}
+}
+
+// writeCSV streams every resource of dao as CSV in the order of sort,
+// loading ExportBatchSize of them at a time, see CSVExporter. Errors after
+// the header can only be logged, cutting the list short.
+func writeCSV[T any](c *gin.Context, dao *DAO[T], fields map[string]bool, sort []SortClause) {
+	exporter := NewCSVExporter[T](c.Writer, fields)
+	var cursor Cursor
+	for started := false; ; {
+		items, next, err := dao.ListCursor(c.Request.Context(), ExportBatchSize, cursor, nil, sort...)
+		if err != nil {
+			if !started {
+				writeInternalError(c, err)
+				return
+			}
+			slog.Error("CSV list failed", "path", c.Request.URL.Path, "error", err)
+			return
+		}
+		if !started {
+			started = true
+			c.Header("Content-Type", CSVContentType+"; charset=utf-8")
+			c.Status(http.StatusOK)
+			if err := exporter.WriteHeader(); err != nil {
+				return
+			}
+		}
+
+		for i := range items {
+			if err := exporter.Write(&items[i]); err != nil {
+				slog.Error("CSV list failed", "path", c.Request.URL.Path, "error", err)
+				return
+			}
+		}
+		if exporter.Flush() != nil || next == nil {
+			return
+		}
+		cursor = *next
+	}
+}
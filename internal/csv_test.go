@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRouter_ListCSV(t *testing.T) {
+	r, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	// Hash once, hooks keep hashed passwords as they are
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	for i, fullName := range []string{`Smith, "Al"`, "Bob", "Carol"} {
+		name := fmt.Sprintf("user%d", i+1)
+		user := &apiv1.User{Username: name, Email: name + "@example.com", Password: string(hash), FullName: fullName}
+		require.NoError(t, db.Create(user).Error)
+	}
+	for i := 4; i <= 150; i++ {
+		name := fmt.Sprintf("user%d", i)
+		require.NoError(t, db.Create(&apiv1.User{Username: name, Email: name + "@example.org", Password: string(hash)}).Error)
+	}
+
+	list := func(query, accept string) [][]string {
+		req := httptest.NewRequest("GET", "/api/v1/users"+query, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		records, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		return records
+	}
+
+	// Every resource is listed rather than a page, flattened into columns
+	records := list("", "text/csv")
+	require.Len(t, records, 151)
+	header := records[0]
+	assert.Equal(t, []string{"kind", "apiVersion", "metadata.id", "metadata.uid", "metadata.name", "metadata.resourceVersion"}, header[:6])
+	assert.Equal(t, []string{"username", "email", "fullName", "isActive", "role"}, header[len(header)-5:])
+	assert.Contains(t, header, "metadata.status.phase")
+	assert.NotContains(t, header, "password")
+
+	row := records[1]
+	assert.Equal(t, "1", row[2])
+	assert.Equal(t, []string{"user1", "user1@example.com", `Smith, "Al"`, "true", "user"}, row[len(row)-5:])
+
+	// Selected fields, filters and sort apply
+	records = list("?format=csv&fields=username,fullName&email[like]=%25@example.com&sort=-username", "")
+	assert.Equal(t, [][]string{
+		{"metadata.id", "username", "fullName"},
+		{"3", "user3", "Carol"},
+		{"2", "user2", "Bob"},
+		{"1", "user1", `Smith, "Al"`},
+	}, records)
+
+	// JSON stays the default
+	req := httptest.NewRequest("GET", "/api/v1/users?format=json", nil)
+	req.Header.Set("Accept", "text/csv")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+func TestCSVExporter(t *testing.T) {
+	type account struct {
+		Name    string `json:"name"`
+		Token   string `json:"token" export:"-"`
+		Balance int    `json:"balance"`
+	}
+
+	var buf bytes.Buffer
+	exporter := NewCSVExporter[account](&buf, nil)
+	assert.Equal(t, []string{"name", "balance"}, exporter.Header())
+	require.NoError(t, exporter.WriteHeader())
+	require.NoError(t, exporter.Write(&account{Name: "alice", Token: "secret", Balance: 10}))
+	require.NoError(t, exporter.Flush())
+	assert.Equal(t, "name,balance\nalice,10\n", buf.String())
+
+	buf.Reset()
+	exporter = NewCSVExporter[account](&buf, map[string]bool{"balance": true})
+	assert.Equal(t, []string{"balance"}, exporter.Header())
+}
+
+func TestRouter_ExportCSV(t *testing.T) {
+	r, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	for i := 1; i <= 120; i++ {
+		name := fmt.Sprintf("user%03d", i)
+		require.NoError(t, db.Create(&apiv1.User{Username: name, Email: name + "@example.com", Password: string(hash)}).Error)
+	}
+
+	export := func(query string) (*httptest.ResponseRecorder, [][]string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/export.csv"+query, nil))
+		if w.Code != http.StatusOK {
+			return w, nil
+		}
+		records, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		return w, records
+	}
+
+	w, records := export("")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="User.csv"`, w.Header().Get("Content-Disposition"))
+	require.Len(t, records, 121)
+	assert.Equal(t, []string{"username", "email", "fullName", "isActive", "role"}, records[0][len(records[0])-5:])
+	assert.NotContains(t, records[0], "password")
+
+	// The filters, sort and fields of lists apply
+	w, records = export("?id[gt]=100&sort=-username&fields=username")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, records, 21)
+	assert.Equal(t, []string{"metadata.id", "username"}, records[0])
+	assert.Equal(t, []string{"120", "user120"}, records[1])
+	assert.Equal(t, []string{"101", "user101"}, records[20])
+
+	w, _ = export("?id[gt]=one")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = export("?sort=nickname")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
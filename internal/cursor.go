@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidCursor is returned for cursors that cannot be decoded or that
+// do not match the ordering they are used with
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in an ordered list of resources: the sort key and
+// ID of the last resource seen. The zero Cursor is the start of the list.
+type Cursor struct {
+	// ID is the ID of the last resource seen, breaking ties of the sort key
+	ID uint `json:"id"`
+
+	// Keys are the JSON encoded values of the sort columns of the last
+	// resource seen, one per SortClause
+	Keys []json.RawMessage `json:"keys,omitempty"`
+}
+
+// IsZero reports whether the cursor is the start of the list
+func (c Cursor) IsZero() bool {
+	return c.ID == 0 && len(c.Keys) == 0
+}
+
+// Encode returns the cursor as an opaque string for clients
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor returned by Encode. The empty string is the
+// zero Cursor.
+func DecodeCursor(raw string) (Cursor, error) {
+	var cursor Cursor
+	if raw == "" {
+		return cursor, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
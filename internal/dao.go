@@ -1,53 +1,674 @@
 package internal
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"my-embedded-api/meta"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 // DAO provides generic database operations for resources
 type DAO[T any] struct {
-	db *gorm.DB
+	db          *gorm.DB
+	broadcaster *Broadcaster[T]
+	options     daoOptions
+
+	// table and kind describe the resource in trace spans
+	table string
+	kind  string
+
+	// preloads are the associations eager-loaded by queries, see Preload
+	preloads []string
+
+	// selects are the columns loaded by queries, all if empty, see Select
+	selects []string
+
+	// scopes are applied to every read query, see Scope
+	scopes []func(*gorm.DB) *gorm.DB
+
+	// inTransaction reports whether db is a transaction of the caller, see
+	// WithTransaction
+	inTransaction bool
+
+	// parent restricts the DAO to the children of a resource, see
+	// BelongsTo
+	parent *parentKey
+}
+
+// parentKey is the foreign key column of a DAO restricted by BelongsTo and
+// the ID of the parent it must hold
+type parentKey struct {
+	field *schema.Field
+	id    uint
+}
+
+// scope restricts a query to the children of the parent
+func (p *parentKey) scope(db *gorm.DB) *gorm.DB {
+	return db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: p.field.DBName}, Value: p.id})
+}
+
+// set makes resource a child of the parent
+func (p *parentKey) set(ctx context.Context, resource any) error {
+	return p.field.Set(ctx, reflect.ValueOf(resource).Elem(), p.id)
+}
+
+// SortClause describes a single ordering applied to a list query
+type SortClause struct {
+	// Field is the database column to order by
+	Field string
+
+	// Desc reports whether the ordering is descending
+	Desc bool
 }
 
 // NewDAO creates a new DAO instance
-func NewDAO[T any](db *gorm.DB) *DAO[T] {
-	return &DAO[T]{db: db}
+func NewDAO[T any](db *gorm.DB, opts ...DAOOption) *DAO[T] {
+	d := &DAO[T]{
+		db:          db,
+		broadcaster: NewBroadcaster[T](),
+		options:     newDAOOptions(opts...),
+		kind:        resourceKind(new(T)),
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err == nil {
+		d.table = stmt.Schema.Table
+	}
+	return d
+}
+
+// Preload returns a copy of the DAO whose queries eager-load the given
+// associations, named as the struct fields (e.g. "Profile"). The copy
+// shares the database and the watchers of d.
+func (d *DAO[T]) Preload(associations ...string) *DAO[T] {
+	preloaded := *d
+	preloaded.preloads = append(slices.Clip(d.preloads), associations...)
+	return &preloaded
+}
+
+// Select returns a copy of the DAO whose queries only load the given
+// columns, leaving the other fields of the resources zero. Columns are not
+// validated, see Column. The copy shares the database and the watchers of
+// d.
+func (d *DAO[T]) Select(columns ...string) *DAO[T] {
+	selected := *d
+	selected.selects = columns
+	return &selected
+}
+
+// Scope returns a copy of the DAO whose read queries (Get, List, Count and
+// the like) apply fn, such as ActiveScope. Scopes of repeated calls
+// compose. The copy shares the database and the watchers of d.
+func (d *DAO[T]) Scope(fn func(*gorm.DB) *gorm.DB) *DAO[T] {
+	scoped := *d
+	scoped.scopes = append(slices.Clip(d.scopes), fn)
+	return &scoped
+}
+
+// WithTransaction returns a copy of the DAO whose operations run in tx, a
+// transaction begun by the caller, so that the writes of several DAOs
+// commit or roll back together. Operations needing a transaction of their
+// own use savepoints of tx and are not retried while the database is busy.
+// The copy never commits or rolls back tx, which is up to the caller.
+// Watchers are notified as operations return, before tx is committed. The
+// copy shares the watchers of d.
+func (d *DAO[T]) WithTransaction(tx *gorm.DB) *DAO[T] {
+	scoped := *d
+	scoped.db = tx
+	scoped.inTransaction = true
+	return &scoped
+}
+
+// BelongsTo returns a copy of the DAO restricted to the children of the
+// resource with ID parentID, those whose foreignKey field (its JSON or
+// column name) holds parentID. Reads only find children, writes by ID
+// treat other resources as missing, and Create and Update set parentID as
+// the foreign key, so children cannot be moved to another parent. The copy
+// shares the database and the watchers of d.
+func (d *DAO[T]) BelongsTo(foreignKey string, parentID uint) (*DAO[T], error) {
+	field, ok := d.field(foreignKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown foreign key %q", foreignKey)
+	}
+	parent := &parentKey{field: field, id: parentID}
+	scoped := d.Scope(parent.scope)
+	scoped.parent = parent
+	return scoped, nil
+}
+
+// children restricts tx to the resources of d: the children of its parent
+// for a DAO returned by BelongsTo, every resource otherwise. Writes use it
+// to find the resources they change.
+func (d *DAO[T]) children(tx *gorm.DB) *gorm.DB {
+	if d.parent == nil {
+		return tx
+	}
+	return tx.Scopes(d.parent.scope)
+}
+
+// Searchable is implemented by resources supporting search. SearchFields
+// returns the JSON or column names of the string fields searched.
+type Searchable interface {
+	SearchFields() []string
+}
+
+// Search returns a copy of the DAO whose read queries only return the
+// resources where any search field contains term, ignoring case. Resources
+// that do not implement Searchable and empty terms are not restricted. The
+// copy shares the database and the watchers of d.
+func (d *DAO[T]) Search(term string) *DAO[T] {
+	searchable, ok := any(new(T)).(Searchable)
+	if !ok || term == "" {
+		return d
+	}
+
+	var columns []string
+	for _, name := range searchable.SearchFields() {
+		if column, ok := d.Column(name); ok {
+			columns = append(columns, column)
+		}
+	}
+	if len(columns) == 0 {
+		return d
+	}
+	return d.Scope(searchScope(columns, term))
+}
+
+// SearchIn retrieves the resources, ordered by ID, where any of fields,
+// given by JSON or column name, contains query, ignoring case and matching
+// wildcards literally like Search. Unknown fields fail with
+// ErrUnknownField. An empty query, or no fields, retrieves every resource.
+func (d *DAO[T]) SearchIn(ctx context.Context, query string, fields []string) ([]T, error) {
+	ctx, end := d.startSpan(ctx, "SearchIn", 0)
+	defer end()
+
+	columns := make([]string, len(fields))
+	for i, name := range fields {
+		column, ok := d.Column(name)
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownField, name)
+		}
+		columns[i] = column
+	}
+
+	db := d.query(ctx).Scopes(d.load)
+	if query != "" && len(columns) > 0 {
+		db = db.Scopes(searchScope(columns, query))
+	}
+	var resources []T
+	if err := db.Order("id").Find(&resources).Error; err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// searchScope matches rows where any of columns contains term, ignoring
+// case, with ILIKE on PostgreSQL and LIKE on lower-cased values elsewhere
+func searchScope(columns []string, term string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		// Wildcards in the term match literally
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(term)) + "%"
+
+		var match string
+		switch db.Dialector.Name() {
+		case "postgres":
+			match = "%s ILIKE ?"
+		case "mysql":
+			match = "LOWER(%s) LIKE ?"
+		default:
+			match = `LOWER(%s) LIKE ? ESCAPE '\'`
+		}
+
+		conditions := make([]string, len(columns))
+		args := make([]interface{}, len(columns))
+		for i, column := range columns {
+			conditions[i] = fmt.Sprintf(match, column)
+			args[i] = pattern
+		}
+		return db.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// ActiveScope restricts queries to active resources, those whose is_active
+// column is true
+func ActiveScope(db *gorm.DB) *gorm.DB {
+	return db.Where("is_active = ?", true)
+}
+
+// query starts a read query applying the scopes of Scope
+func (d *DAO[T]) query(ctx context.Context) *gorm.DB {
+	return d.db.WithContext(ctx).Scopes(d.scopes...)
+}
+
+// load applies the associations of Preload and the columns of Select to a
+// query
+func (d *DAO[T]) load(db *gorm.DB) *gorm.DB {
+	for _, association := range d.preloads {
+		db = db.Preload(association)
+	}
+	if len(d.selects) > 0 {
+		db = db.Select(d.selects)
+	}
+	return db
+}
+
+// startSpan starts a trace span for a DAO operation, and times it when
+// WithMetrics is set. id is the resource the operation acts on, or 0 if
+// there is none.
+func (d *DAO[T]) startSpan(ctx context.Context, operation string, id uint) (context.Context, func()) {
+	ctx, end := startSpan(ctx, "dao."+operation, d.table, d.kind, id)
+	if d.options.queryDuration == nil {
+		return ctx, end
+	}
+	start := time.Now()
+	return ctx, func() {
+		d.options.queryDuration.WithLabelValues(operationLabel(operation)).Observe(time.Since(start).Seconds())
+		end()
+	}
+}
+
+// Watch returns a channel receiving an event for every change made through
+// this DAO until ctx is cancelled
+func (d *DAO[T]) Watch(ctx context.Context) <-chan WatchEvent[T] {
+	return d.broadcaster.Subscribe(ctx)
+}
+
+// publish notifies watchers and webhook receivers of a change to resource
+func (d *DAO[T]) publish(eventType EventType, resource T) {
+	d.broadcaster.Publish(WatchEvent[T]{Type: eventType, Object: resource})
+	if d.options.webhooks != nil {
+		d.options.webhooks.Dispatch(d.kind, webhookVerbs[eventType], &resource)
+	}
+}
+
+// recordAudit records a change in tx when auditing is enabled, so that the
+// audit event is committed together with the change
+func (d *DAO[T]) recordAudit(tx *gorm.DB, action AuditAction, before, after *T) error {
+	if d.options.audit == nil {
+		return nil
+	}
+	event, err := newAuditEvent(tx, action, before, after)
+	if err != nil {
+		return err
+	}
+	return tx.Create(event).Error
 }
 
 // Create creates a new resource
-func (d *DAO[T]) Create(resource *T) error {
-	return d.db.Create(resource).Error
+func (d *DAO[T]) Create(ctx context.Context, resource *T) error {
+	ctx, end := d.startSpan(ctx, "Create", 0)
+	defer end()
+
+	// A retried attempt starts again from the resource as given, before
+	// hooks modified it
+	original := *resource
+	err := d.transaction(ctx, func(tx *gorm.DB) error {
+		*resource = original
+		if d.parent != nil {
+			if err := d.parent.set(ctx, resource); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(resource).Error; err != nil {
+			return translateError(err)
+		}
+		return d.recordAudit(tx, AuditActionCreate, nil, resource)
+	})
+	if err != nil {
+		return err
+	}
+
+	d.publish(EventAdded, *resource)
+	return nil
+}
+
+// FindOrCreate loads the first resource matching condition into resource,
+// or creates resource if there is none. Like gorm's FirstOrCreate, the
+// values of condition are set on a created resource; only creating runs
+// the BeforeCreate hooks. It reports whether resource was created. When a
+// concurrent call creates a matching resource first, that one is returned.
+func (d *DAO[T]) FindOrCreate(ctx context.Context, condition interface{}, resource *T) (bool, error) {
+	ctx, end := d.startSpan(ctx, "FindOrCreate", 0)
+	defer end()
+
+	original := *resource
+	var created bool
+	err := d.transaction(ctx, func(tx *gorm.DB) error {
+		var found T
+		err := tx.Where(condition).First(&found).Error
+		if err == nil {
+			*resource = found
+			created = false
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		*resource = original
+		if err := tx.Where(condition).FirstOrCreate(resource).Error; err != nil {
+			return translateError(err)
+		}
+		created = true
+		return d.recordAudit(tx, AuditActionCreate, nil, resource)
+	})
+	if errors.Is(err, ErrConflict) {
+		// Lost the race against another call creating the same resource
+		var found T
+		if findErr := d.db.WithContext(ctx).Where(condition).First(&found).Error; findErr == nil {
+			*resource = found
+			return false, nil
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if created {
+		d.publish(EventAdded, *resource)
+	}
+	return created, nil
+}
+
+// Upsert creates resource or, if a resource with the same values in
+// conflictColumns exists, updates that one in the same statement. The
+// BeforeCreate hooks run in both cases. An update replaces every column but
+// the ID, UID, creation time and status, and increments the resource
+// version. conflictColumns are JSON or column names forming a unique key of
+// the resource, otherwise ErrUnknownField or ErrNotUniqueKey is returned.
+// resource is refreshed from the database.
+func (d *DAO[T]) Upsert(ctx context.Context, resource *T, conflictColumns []string) error {
+	_, err := d.upsert(ctx, resource, conflictColumns)
+	return err
+}
+
+// upsert implements Upsert and reports whether resource was created
+func (d *DAO[T]) upsert(ctx context.Context, resource *T, conflictColumns []string) (bool, error) {
+	ctx, end := d.startSpan(ctx, "Upsert", 0)
+	defer end()
+
+	key, where, err := d.uniqueKey(conflictColumns)
+	if err != nil {
+		return false, err
+	}
+	onConflict, err := d.onConflict(key, where)
+	if err != nil {
+		return false, err
+	}
+
+	// keyCondition matches the stored resource with the key of resource
+	keyCondition := func() map[string]interface{} {
+		condition := make(map[string]interface{}, len(key))
+		value := reflect.ValueOf(resource).Elem()
+		for _, field := range key {
+			condition[field.DBName], _ = field.ValueOf(ctx, value)
+		}
+		return condition
+	}
+
+	var stored T
+	var created bool
+	original := *resource
+	err = d.transaction(ctx, func(tx *gorm.DB) error {
+		*resource = original
+		var current T
+		err := tx.Where(keyCondition()).First(&current).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		created = err != nil
+
+		if err := tx.Clauses(onConflict).Create(resource).Error; err != nil {
+			return translateError(err)
+		}
+		if err := tx.Where(keyCondition()).First(&stored).Error; err != nil {
+			return err
+		}
+		if created {
+			return d.recordAudit(tx, AuditActionCreate, nil, &stored)
+		}
+		return d.recordAudit(tx, AuditActionUpdate, &current, &stored)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	*resource = stored
+	if created {
+		d.publish(EventAdded, stored)
+	} else {
+		d.publish(EventModified, stored)
+	}
+	return created, nil
+}
+
+// uniqueKey resolves names to the fields of a unique key of the resource:
+// its primary key, a unique column or the columns of a unique index. where
+// is the condition of a partial unique index, empty for the others.
+func (d *DAO[T]) uniqueKey(names []string) (key []*schema.Field, where string, err error) {
+	if len(names) == 0 {
+		return nil, "", fmt.Errorf("%w: no columns", ErrNotUniqueKey)
+	}
+	fields := make([]*schema.Field, len(names))
+	columns := make([]string, len(names))
+	for i, name := range names {
+		field, ok := d.field(name)
+		if !ok {
+			return nil, "", fmt.Errorf("%w %q", ErrUnknownField, name)
+		}
+		fields[i] = field
+		columns[i] = field.DBName
+	}
+	if len(fields) == 1 && (fields[0].PrimaryKey || fields[0].Unique) {
+		return fields, "", nil
+	}
+
+	for _, index := range fields[0].Schema.ParseIndexes() {
+		if index.Class != "UNIQUE" || len(index.Fields) != len(columns) {
+			continue
+		}
+		matches := true
+		for _, option := range index.Fields {
+			matches = matches && slices.Contains(columns, option.DBName)
+		}
+		if matches {
+			return fields, index.Where, nil
+		}
+	}
+	return nil, "", fmt.Errorf("%w: %s", ErrNotUniqueKey, strings.Join(columns, ", "))
+}
+
+// onConflict is the clause turning the creation of a resource with a
+// stored key into an update of the stored resource, see Upsert. where is
+// the condition of the partial unique index of key, if any, which the
+// conflict target must repeat.
+func (d *DAO[T]) onConflict(key []*schema.Field, where string) (clause.OnConflict, error) {
+	var obj T
+	stmt := &gorm.Statement{DB: d.db}
+	if err := stmt.Parse(&obj); err != nil {
+		return clause.OnConflict{}, err
+	}
+
+	onConflict := clause.OnConflict{}
+	var columns []string
+	for _, field := range key {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: field.DBName})
+	}
+	if where != "" {
+		onConflict.TargetWhere = clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: where}}}
+	}
+	for _, field := range stmt.Schema.Fields {
+		switch {
+		case field.DBName == "" || !field.Updatable || field.PrimaryKey || field.AutoCreateTime != 0:
+		case slices.Contains(key, field):
+		case field.DBName == "uid" || field.DBName == "resource_version" || slices.Contains(statusColumns, field.DBName):
+		default:
+			columns = append(columns, field.DBName)
+		}
+	}
+	onConflict.DoUpdates = clause.AssignmentColumns(columns)
+	if _, ok := stmt.Schema.FieldsByDBName["resource_version"]; ok {
+		onConflict.DoUpdates = append(onConflict.DoUpdates, clause.Assignment{
+			Column: clause.Column{Name: "resource_version"},
+			Value:  gorm.Expr("resource_version + 1"),
+		})
+	}
+	return onConflict, nil
 }
 
 // Get retrieves a resource by ID
-func (d *DAO[T]) Get(id uint) (*T, error) {
+func (d *DAO[T]) Get(ctx context.Context, id uint) (*T, error) {
+	ctx, end := d.startSpan(ctx, "Get", id)
+	defer end()
+
+	var resource T
+	err := d.query(ctx).Scopes(d.load).First(&resource, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+// GetByUID retrieves a resource by UID
+func (d *DAO[T]) GetByUID(ctx context.Context, uid string) (*T, error) {
+	ctx, end := d.startSpan(ctx, "GetByUID", 0)
+	defer end()
+
 	var resource T
-	err := d.db.First(&resource, id).Error
+	err := d.query(ctx).Scopes(d.load).Where("uid = ?", uid).First(&resource).Error
 	if err != nil {
 		return nil, err
 	}
 	return &resource, nil
 }
 
-// List retrieves all resources with pagination and filtering
-func (d *DAO[T]) List(page, pageSize int, filter map[string]interface{}) ([]T, int64, error) {
+// GetByName retrieves a resource by its name, see meta.ObjectMeta
+func (d *DAO[T]) GetByName(ctx context.Context, name string) (*T, error) {
+	ctx, end := d.startSpan(ctx, "GetByName", 0)
+	defer end()
+
+	var resource T
+	err := d.query(ctx).Scopes(d.load).Where("name = ?", name).First(&resource).Error
+	if err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+// GetByField retrieves the resource whose field equals value. field is a
+// JSON or column name, see Column; other names fail with ErrUnknownField
+// rather than reaching the query. Callers are responsible for field being
+// unique: when several resources match, the first by ID is returned.
+func (d *DAO[T]) GetByField(ctx context.Context, field string, value interface{}) (*T, error) {
+	ctx, end := d.startSpan(ctx, "GetByField", 0)
+	defer end()
+
+	column, ok := d.Column(field)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownField, field)
+	}
+
+	var resource T
+	err := d.query(ctx).Scopes(d.load).Where(fmt.Sprintf("%s = ?", column), value).First(&resource).Error
+	if err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+// Exists reports whether a resource with the given ID exists without
+// loading it. A failed query returns false together with the error.
+func (d *DAO[T]) Exists(ctx context.Context, id uint) (bool, error) {
+	ctx, end := d.startSpan(ctx, "Exists", id)
+	defer end()
+
+	var count int64
+	err := d.query(ctx).Model(new(T)).Where("id = ?", id).Limit(1).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ListByIDs retrieves the resources with the given IDs in the order of
+// ids, in a single query. IDs that do not exist are left out, and repeated
+// IDs are returned once.
+func (d *DAO[T]) ListByIDs(ctx context.Context, ids []uint) ([]T, error) {
+	if len(ids) == 0 {
+		return []T{}, nil
+	}
+
+	ctx, end := d.startSpan(ctx, "ListByIDs", 0)
+	defer end()
+
+	var resources []T
+	if err := d.query(ctx).Scopes(d.load).Where("id IN ?", ids).Find(&resources).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]T, len(resources))
+	for _, resource := range resources {
+		byID[resourceID(&resource)] = resource
+	}
+	ordered := make([]T, 0, len(resources))
+	for _, id := range ids {
+		if resource, ok := byID[id]; ok {
+			ordered = append(ordered, resource)
+			delete(byID, id)
+		}
+	}
+	return ordered, nil
+}
+
+// List retrieves all resources with pagination, filtering and optional
+// ordering. The resources match all filters, whose fields must be columns
+// of the resource, otherwise ErrUnknownField is returned.
+func (d *DAO[T]) List(ctx context.Context, page, pageSize int, filters []Filter, sort ...SortClause) ([]T, int64, error) {
+	ctx, end := d.startSpan(ctx, "List", 0)
+	defer end()
+
 	var resources []T
 	var total int64
 
+	filter, err := d.filterScope(filters)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	// Create a new instance of T to get the table name
 	var obj T
-	query := d.db.Model(&obj)
-	if filter != nil {
-		query = query.Where(filter)
-	}
+	query := d.query(ctx).Model(&obj).Scopes(filter)
 
-	err := query.Count(&total).Error
+	err = query.Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
+	for _, order := range sort {
+		query = query.Order(clause.OrderByColumn{
+			Column: clause.Column{Name: order.Field},
+			Desc:   order.Desc,
+		})
+	}
+
 	offset := (page - 1) * pageSize
-	err = query.Offset(offset).Limit(pageSize).Find(&resources).Error
+	err = query.Scopes(d.load).Offset(offset).Limit(pageSize).Find(&resources).Error
 	if err != nil {
 		return nil, 0, err
 	}
@@ -55,38 +676,1351 @@ func (d *DAO[T]) List(page, pageSize int, filter map[string]interface{}) ([]T, i
 	return resources, total, nil
 }
 
-// Update updates a resource by ID
-func (d *DAO[T]) Update(id uint, resource *T) error {
-	result := d.db.Model(resource).Where("id = ?", id).Updates(resource)
-	if result.Error != nil {
-		return result.Error
+// filterScope returns the scope applying filters after resolving their
+// fields to columns, see Column. Unknown fields fail with ErrUnknownField.
+func (d *DAO[T]) filterScope(filters []Filter) (func(*gorm.DB) *gorm.DB, error) {
+	resolved := make(Filters, len(filters))
+	for i, filter := range filters {
+		column, ok := d.Column(filter.Field)
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownField, filter.Field)
+		}
+		resolved[i] = filter
+		resolved[i].Field = column
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	return resolved.Scope, nil
+}
+
+// Count returns the number of resources matching filters without loading
+// them, see List
+func (d *DAO[T]) Count(ctx context.Context, filters []Filter) (int64, error) {
+	ctx, end := d.startSpan(ctx, "Count", 0)
+	defer end()
+
+	filter, err := d.filterScope(filters)
+	if err != nil {
+		return 0, err
+	}
+	query := d.query(ctx).Model(new(T)).Scopes(filter)
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// AggregateFunction is the SQL function of an Aggregation
+type AggregateFunction string
+
+const (
+	AggregateCount AggregateFunction = "COUNT"
+	AggregateSum   AggregateFunction = "SUM"
+	AggregateAvg   AggregateFunction = "AVG"
+	AggregateMin   AggregateFunction = "MIN"
+	AggregateMax   AggregateFunction = "MAX"
+)
+
+// aggregateFunctions are the functions Aggregate allows
+var aggregateFunctions = []AggregateFunction{AggregateCount, AggregateSum, AggregateAvg, AggregateMin, AggregateMax}
+
+// aggregateAlias matches the aliases of aggregations
+var aggregateAlias = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Aggregation computes Function over Field, a JSON or column name, for
+// every group of Aggregate. COUNT also accepts "*" or an empty Field to
+// count rows. The result is keyed by Alias, by default the lower-cased
+// function and the field joined by "_", such as count_id.
+type Aggregation struct {
+	Function AggregateFunction `json:"function"`
+	Field    string            `json:"field"`
+	Alias    string            `json:"alias,omitempty"`
+}
+
+// Aggregate groups the resources by the column of groupBy, a JSON or
+// column name, and computes aggregations for every group. Each result maps
+// groupBy to the value of the group and the alias of every aggregation to
+// its value; results are ordered by group. An empty groupBy aggregates
+// every resource into a single result. Unknown fields fail with
+// ErrUnknownField, and unknown functions and invalid or duplicate aliases
+// with ErrInvalidAggregation.
+func (d *DAO[T]) Aggregate(ctx context.Context, groupBy string, aggregations []Aggregation) ([]map[string]interface{}, error) {
+	ctx, end := d.startSpan(ctx, "Aggregate", 0)
+	defer end()
+
+	var selects []string
+	var args []interface{}
+	aliases := make(map[string]bool)
+	var group string
+	if groupBy != "" {
+		column, ok := d.Column(groupBy)
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownField, groupBy)
+		}
+		group = column
+		selects = append(selects, "? AS ?")
+		args = append(args, clause.Column{Name: column}, clause.Column{Name: groupBy})
+		aliases[groupBy] = true
+	}
+
+	for _, aggregation := range aggregations {
+		function := AggregateFunction(strings.ToUpper(string(aggregation.Function)))
+		if !slices.Contains(aggregateFunctions, function) {
+			return nil, fmt.Errorf("%w: unknown function %q", ErrInvalidAggregation, aggregation.Function)
+		}
+		var argument interface{} = clause.Expr{SQL: "*"}
+		name := ""
+		if aggregation.Field != "" && aggregation.Field != "*" {
+			column, ok := d.Column(aggregation.Field)
+			if !ok {
+				return nil, fmt.Errorf("%w %q", ErrUnknownField, aggregation.Field)
+			}
+			argument, name = clause.Column{Name: column}, aggregation.Field
+		} else if function != AggregateCount {
+			return nil, fmt.Errorf("%w: %s needs a field", ErrInvalidAggregation, function)
+		}
+
+		alias := aggregation.Alias
+		if alias == "" {
+			alias = strings.ToLower(string(function))
+			if name != "" {
+				alias += "_" + name
+			}
+		}
+		if !aggregateAlias.MatchString(alias) || aliases[alias] {
+			return nil, fmt.Errorf("%w: invalid or duplicate alias %q", ErrInvalidAggregation, alias)
+		}
+		aliases[alias] = true
+		selects = append(selects, string(function)+"(?) AS ?")
+		args = append(args, argument, clause.Column{Name: alias})
+	}
+	if len(selects) == 0 {
+		return nil, fmt.Errorf("%w: nothing to compute", ErrInvalidAggregation)
+	}
+
+	query := d.query(ctx).Model(new(T)).Select(strings.Join(selects, ", "), args...)
+	if group != "" {
+		query = query.Group(group).Order(clause.OrderByColumn{Column: clause.Column{Name: group}})
+	}
+	results := []map[string]interface{}{}
+	if err := query.Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ListAfter retrieves up to limit resources whose ID is greater than afterID,
+// ordered by ID. It is the keyset counterpart of List and avoids the full
+// scans that large offsets cause. filters apply as in List.
+func (d *DAO[T]) ListAfter(ctx context.Context, afterID uint, limit int, filters []Filter) ([]T, error) {
+	ctx, end := d.startSpan(ctx, "ListAfter", 0)
+	defer end()
+
+	var resources []T
+
+	filter, err := d.filterScope(filters)
+	if err != nil {
+		return nil, err
+	}
+	var obj T
+	query := d.query(ctx).Model(&obj).Scopes(filter)
+
+	err = query.Scopes(d.load).Where("id > ?", afterID).Order("id").Limit(limit).Find(&resources).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// ListCursor retrieves up to limit resources following cursor in the order
+// given by sort, with ties and unsorted lists ordered by ID. Unlike List it
+// neither skips nor repeats resources when others are created between
+// calls. The returned Cursor continues the list and is nil on the last
+// page. Sort columns should not be nullable, as NULL keys are not ordered.
+// filters apply as in List.
+func (d *DAO[T]) ListCursor(ctx context.Context, limit int, cursor Cursor, filters []Filter, sort ...SortClause) ([]T, *Cursor, error) {
+	ctx, end := d.startSpan(ctx, "ListCursor", 0)
+	defer end()
+
+	filter, err := d.filterScope(filters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fields := make([]*schema.Field, len(sort))
+	for i, order := range sort {
+		field, ok := d.field(order.Field)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w %q", ErrUnknownField, order.Field)
+		}
+		fields[i] = field
+	}
+
+	// The next cursor is made of the ID and the sort columns, which are
+	// loaded even when Select leaves them out
+	if len(d.selects) > 0 {
+		columns := slices.Clone(d.selects)
+		for _, order := range append([]SortClause{{Field: "id"}}, sort...) {
+			if !slices.Contains(columns, order.Field) {
+				columns = append(columns, order.Field)
+			}
+		}
+		d = d.Select(columns...)
+	}
+
+	query := d.query(ctx).Model(new(T)).Scopes(filter)
+	if !cursor.IsZero() {
+		condition, args, err := keysetCondition(sort, fields, cursor)
+		if err != nil {
+			return nil, nil, err
+		}
+		query = query.Where(condition, args...)
+	}
+	for _, order := range sort {
+		query = query.Order(clause.OrderByColumn{
+			Column: clause.Column{Name: order.Field},
+			Desc:   order.Desc,
+		})
+	}
+
+	// Fetch one extra row to find out whether another page exists
+	var resources []T
+	err = query.Scopes(d.load).Order("id").Limit(limit + 1).Find(&resources).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(resources) <= limit {
+		return resources, nil, nil
+	}
+
+	resources = resources[:limit]
+	last := reflect.ValueOf(&resources[limit-1]).Elem()
+	next := &Cursor{ID: resourceID(&resources[limit-1]), Keys: make([]json.RawMessage, len(fields))}
+	for i, field := range fields {
+		value, _ := field.ValueOf(ctx, last)
+		key, err := json.Marshal(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		next.Keys[i] = key
+	}
+	return resources, next, nil
+}
+
+// keysetCondition returns the condition selecting the rows ordered after
+// cursor: those with a greater sort key, or an equal one and a greater ID
+func keysetCondition(sort []SortClause, fields []*schema.Field, cursor Cursor) (string, []interface{}, error) {
+	if len(cursor.Keys) != len(sort) {
+		return "", nil, ErrInvalidCursor
+	}
+	keys := make([]interface{}, len(sort))
+	for i, field := range fields {
+		key := reflect.New(field.FieldType)
+		if err := json.Unmarshal(cursor.Keys[i], key.Interface()); err != nil {
+			return "", nil, ErrInvalidCursor
+		}
+		keys[i] = key.Elem().Interface()
+	}
+
+	var terms []string
+	var args []interface{}
+	var equal []string
+	var equalArgs []interface{}
+	for i, order := range sort {
+		op := ">"
+		if order.Desc {
+			op = "<"
+		}
+		terms = append(terms, "("+strings.Join(append(slices.Clone(equal), order.Field+" "+op+" ?"), " AND ")+")")
+		args = append(append(args, equalArgs...), keys[i])
+		equal = append(equal, order.Field+" = ?")
+		equalArgs = append(equalArgs, keys[i])
+	}
+	terms = append(terms, "("+strings.Join(append(equal, "id > ?"), " AND ")+")")
+	args = append(append(args, equalArgs...), cursor.ID)
+	return strings.Join(terms, " OR "), args, nil
+}
+
+// statusColumns hold the status of a resource. They are only written by
+// UpdateStatus so that spec updates cannot race with status updates.
+var statusColumns = []string{"phase", "message", "reason", "last_transition_time", "status_conditions"}
+
+// Update updates the non-zero fields of a resource by ID, except for its
+// status, and then refreshes resource from the database. The fields are
+// merged onto the stored resource first, so hooks see the whole record. When
+// expectedVersion is non-zero the stored resource version is checked inside
+// the same transaction and ErrConflict is returned if it no longer matches.
+// The deletion timestamp is never changed, but an update removing the last
+// finalizer of a resource whose deletion was requested deletes it. An
+// update changing nothing but the update time and resource version is not
+// written, leaving the version as it is, and watchers are not notified.
+func (d *DAO[T]) Update(ctx context.Context, id uint, resource *T, expectedVersion int) error {
+	ctx, end := d.startSpan(ctx, "Update", id)
+	defer end()
+
+	return d.update(ctx, id, resource, expectedVersion, false)
+}
+
+// Save updates all fields of a resource by ID, including zero values but
+// except for its status. expectedVersion and unchanged resources behave as
+// in Update.
+func (d *DAO[T]) Save(ctx context.Context, id uint, resource *T, expectedVersion int) error {
+	ctx, end := d.startSpan(ctx, "Save", id)
+	defer end()
+
+	return d.update(ctx, id, resource, expectedVersion, true)
+}
+
+// update implements Update and Save
+func (d *DAO[T]) update(ctx context.Context, id uint, resource *T, expectedVersion int, allFields bool) error {
+	var updated T
+	var deleted, unchanged bool
+	original := *resource
+	err := d.transaction(ctx, func(tx *gorm.DB) error {
+		*resource = original
+		var current T
+		if err := d.children(tx).First(&current, id).Error; err != nil {
+			return err
+		}
+
+		// Hooks see the whole record rather than the fields being changed
+		if !allFields {
+			merged := current
+			mergeFields(reflect.ValueOf(&merged).Elem(), reflect.ValueOf(resource).Elem())
+			*resource = merged
+		}
+		// Children stay with their parent
+		if d.parent != nil {
+			if err := d.parent.set(ctx, resource); err != nil {
+				return err
+			}
+		}
+
+		if expectedVersion != 0 && resourceVersion(&current) != expectedVersion {
+			return ErrConflict
+		}
+
+		// Writing what is stored would only bump the version
+		unchanged = d.unchanged(ctx, &current, resource)
+		if unchanged {
+			updated = current
+			return nil
+		}
+
+		// Deletion is only requested by Delete
+		query := tx.Model(resource).Where("id = ?", id)
+		if allFields {
+			query = query.Select("*")
+		}
+		query = query.Omit(append(slices.Clip(statusColumns), "deletion_timestamp")...)
+		if expectedVersion != 0 {
+			query = query.Where("resource_version = ?", expectedVersion)
+		}
+
+		result := query.Updates(resource)
+		if result.Error != nil {
+			return translateError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			if expectedVersion != 0 {
+				return ErrConflict
+			}
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.First(&updated, id).Error; err != nil {
+			return err
+		}
+		if err := d.recordAudit(tx, AuditActionUpdate, &current, &updated); err != nil {
+			return err
+		}
+
+		// Removing the last finalizer completes a requested deletion
+		deleted = false
+		if finalized, ok := any(&updated).(finalizable); ok && finalized.GetDeletionTimestamp() != nil && len(finalized.GetFinalizers()) == 0 {
+			deleted = true
+			if err := tx.Delete(&updated, id).Error; err != nil {
+				return err
+			}
+			return d.recordAudit(tx, AuditActionDelete, &updated, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	*resource = updated
+	if unchanged {
+		return nil
+	}
+	d.publish(EventModified, updated)
+	if deleted {
+		d.publish(EventDeleted, updated)
+	}
+	return nil
+}
+
+// unchanged reports whether resource holds the values of current, see
+// meta.DeepEqual, so that storing it would change nothing. The update time
+// and resource version are ignored, also for resources without a
+// meta.ObjectMeta.
+func (d *DAO[T]) unchanged(ctx context.Context, current, resource *T) bool {
+	x, y := *current, *resource
+	for _, name := range []string{"updated_at", "resource_version"} {
+		field, ok := d.field(name)
+		if !ok {
+			continue
+		}
+		zero := reflect.Zero(field.FieldType).Interface()
+		if field.Set(ctx, reflect.ValueOf(&x).Elem(), zero) != nil ||
+			field.Set(ctx, reflect.ValueOf(&y).Elem(), zero) != nil {
+			return false
+		}
+	}
+	return meta.DeepEqual(&x, &y)
+}
+
+// UpdateStatus replaces only the status of a resource and bumps its
+// resource version. Hooks are skipped so that spec validation does not run.
+func (d *DAO[T]) UpdateStatus(ctx context.Context, id uint, status meta.ResourceStatus) error {
+	ctx, end := d.startSpan(ctx, "UpdateStatus", id)
+	defer end()
+
+	if status.LastTransitionTime.IsZero() {
+		status.LastTransitionTime = time.Now()
+	}
+
+	// Map updates bypass the field serializer
+	conditions, err := json.Marshal(status.Conditions)
+	if err != nil {
+		return err
+	}
+
+	var current, updated T
+	err = d.transaction(ctx, func(tx *gorm.DB) error {
+		if err := d.children(tx).First(&current, id).Error; err != nil {
+			return err
+		}
+
+		result := tx.Session(&gorm.Session{SkipHooks: true}).Model(new(T)).Where("id = ?", id).Updates(map[string]interface{}{
+			"phase":                status.Phase,
+			"message":              status.Message,
+			"reason":               status.Reason,
+			"last_transition_time": status.LastTransitionTime,
+			"status_conditions":    string(conditions),
+			"resource_version":     gorm.Expr("resource_version + 1"),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.First(&updated, id).Error; err != nil {
+			return err
+		}
+		return d.recordAudit(tx, AuditActionUpdate, &current, &updated)
+	})
+	if err != nil {
+		return err
+	}
+
+	d.publish(EventModified, updated)
+	return nil
+}
+
+// Delete deletes a resource by ID. Resources with a gorm.DeletedAt field,
+// such as those embedding meta.BaseResource, are soft deleted: the field is
+// set and the row is hidden from queries until Restore or Purge. A resource
+// with finalizers is not removed; its deletion timestamp is set instead and
+// the row is deleted once RemoveFinalizer removes the last one. Deleting the
+// owner of a resource whose owner reference sets BlockOwnerDeletion fails
+// with ErrOwnerDeletionBlocked. When expectedVersion is non-zero the stored
+// resource version is checked first and ErrConflict is returned if it no
+// longer matches.
+func (d *DAO[T]) Delete(ctx context.Context, id uint, expectedVersion int) error {
+	ctx, end := d.startSpan(ctx, "Delete", id)
+	defer end()
+
+	var resource, updated T
+	var event EventType
+	err := d.transaction(ctx, func(tx *gorm.DB) error {
+		if err := d.children(tx).First(&resource, id).Error; err != nil {
+			return err
+		}
+		if expectedVersion != 0 && resourceVersion(&resource) != expectedVersion {
+			return ErrConflict
+		}
+
+		blocked, err := ownerDeletionBlocked(tx, &resource)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return ErrOwnerDeletionBlocked
+		}
+
+		if finalized, ok := any(&resource).(finalizable); ok && len(finalized.GetFinalizers()) > 0 {
+			if finalized.GetDeletionTimestamp() != nil {
+				return nil
+			}
+			event = EventModified
+			var err error
+			updated, err = d.markFinalizing(tx, &resource)
+			return err
+		}
+
+		result := tx.Delete(&resource, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		event = EventDeleted
+		return d.recordAudit(tx, AuditActionDelete, &resource, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	switch event {
+	case EventModified:
+		d.publish(EventModified, updated)
+	case EventDeleted:
+		d.publish(EventDeleted, resource)
 	}
 	return nil
 }
 
-// Delete deletes a resource by ID
-func (d *DAO[T]) Delete(id uint) error {
+// markFinalizing sets the deletion timestamp of a resource with finalizers
+// instead of deleting it, and returns the updated resource
+func (d *DAO[T]) markFinalizing(tx *gorm.DB, resource *T) (T, error) {
+	id := resourceID(resource)
+	var updated T
+	if err := tx.Model(new(T)).Where("id = ?", id).UpdateColumn("deletion_timestamp", time.Now()).Error; err != nil {
+		return updated, err
+	}
+	if err := tx.First(&updated, id).Error; err != nil {
+		return updated, err
+	}
+	return updated, d.recordAudit(tx, AuditActionUpdate, resource, &updated)
+}
+
+// RemoveFinalizer removes one finalizer from a resource. If deletion was
+// requested and no finalizers remain, the resource is deleted.
+func (d *DAO[T]) RemoveFinalizer(ctx context.Context, id uint, finalizer string) error {
+	ctx, end := d.startSpan(ctx, "RemoveFinalizer", id)
+	defer end()
+
+	var resource, updated T
+	var event EventType
+	err := d.transaction(ctx, func(tx *gorm.DB) error {
+		if err := d.children(tx).First(&resource, id).Error; err != nil {
+			return err
+		}
+
+		finalized, ok := any(&resource).(finalizable)
+		if !ok {
+			return nil
+		}
+
+		remaining := make([]string, 0, len(finalized.GetFinalizers()))
+		for _, f := range finalized.GetFinalizers() {
+			if f != finalizer {
+				remaining = append(remaining, f)
+			}
+		}
+
+		if len(remaining) == 0 && finalized.GetDeletionTimestamp() != nil {
+			event = EventDeleted
+			if err := tx.Delete(&resource, id).Error; err != nil {
+				return err
+			}
+			return d.recordAudit(tx, AuditActionDelete, &resource, nil)
+		}
+
+		// Column updates bypass the field serializer
+		encoded, err := json.Marshal(remaining)
+		if err != nil {
+			return err
+		}
+		event = EventModified
+		if err := tx.Model(new(T)).Where("id = ?", id).UpdateColumn("finalizers", string(encoded)).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&updated, id).Error; err != nil {
+			return err
+		}
+		return d.recordAudit(tx, AuditActionUpdate, &resource, &updated)
+	})
+	if err != nil {
+		return err
+	}
+
+	switch event {
+	case EventModified:
+		d.publish(EventModified, updated)
+	case EventDeleted:
+		d.publish(EventDeleted, resource)
+	}
+	return nil
+}
+
+// softDeletes reports whether the resources are soft deleted, that is
+// whether they have a gorm.DeletedAt field
+func (d *DAO[T]) softDeletes() bool {
+	field, ok := d.field("deleted_at")
+	return ok && field.FieldType == reflect.TypeFor[gorm.DeletedAt]()
+}
+
+// deletedScope restricts a query to the soft-deleted resources
+func deletedScope(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Where("deleted_at IS NOT NULL")
+}
+
+// ListDeleted retrieves the soft-deleted resources like List. It returns
+// no resources if they are not soft deleted.
+func (d *DAO[T]) ListDeleted(ctx context.Context, page, pageSize int, filters []Filter, sort ...SortClause) ([]T, int64, error) {
+	if !d.softDeletes() {
+		return nil, 0, nil
+	}
+	return d.Scope(deletedScope).List(ctx, page, pageSize, filters, sort...)
+}
+
+// GetDeleted retrieves a soft-deleted resource by ID. Resources that exist
+// but are not deleted are not found.
+func (d *DAO[T]) GetDeleted(ctx context.Context, id uint) (*T, error) {
+	ctx, end := d.startSpan(ctx, "GetDeleted", id)
+	defer end()
+
+	if !d.softDeletes() {
+		return nil, gorm.ErrRecordNotFound
+	}
 	var resource T
-	result := d.db.Delete(&resource, id)
-	if result.Error != nil {
-		return result.Error
+	err := d.query(ctx).Scopes(deletedScope, d.load).First(&resource, id).Error
+	if err != nil {
+		return nil, err
 	}
-	if result.RowsAffected == 0 {
+	return &resource, nil
+}
+
+// Restore undoes the soft delete of a resource, clearing its DeletedAt.
+// Resources that are not deleted are not found. Watchers see the restored
+// resource as added.
+func (d *DAO[T]) Restore(ctx context.Context, id uint) error {
+	ctx, end := d.startSpan(ctx, "Restore", id)
+	defer end()
+
+	if !d.softDeletes() {
 		return gorm.ErrRecordNotFound
 	}
+	var deleted, restored T
+	err := d.transaction(ctx, func(tx *gorm.DB) error {
+		if err := d.children(tx).Scopes(deletedScope).First(&deleted, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(new(T)).Where("id = ?", id).UpdateColumn("deleted_at", nil).Error; err != nil {
+			return translateError(err)
+		}
+		if err := tx.First(&restored, id).Error; err != nil {
+			return err
+		}
+		return d.recordAudit(tx, AuditActionUpdate, &deleted, &restored)
+	})
+	if err != nil {
+		return err
+	}
+
+	d.publish(EventAdded, restored)
 	return nil
 }
 
+// Purge removes a resource from storage, whether it is soft deleted or
+// not. Finalizers are bypassed, but purging the owner of a resource whose
+// owner reference sets BlockOwnerDeletion fails with
+// ErrOwnerDeletionBlocked.
+func (d *DAO[T]) Purge(ctx context.Context, id uint) error {
+	ctx, end := d.startSpan(ctx, "Purge", id)
+	defer end()
+
+	var resource T
+	var live int64
+	err := d.transaction(ctx, func(tx *gorm.DB) error {
+		if err := d.children(tx).Unscoped().First(&resource, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(new(T)).Where("id = ?", id).Count(&live).Error; err != nil {
+			return err
+		}
+
+		blocked, err := ownerDeletionBlocked(tx, &resource)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return ErrOwnerDeletionBlocked
+		}
+
+		if err := tx.Unscoped().Delete(&resource, id).Error; err != nil {
+			return err
+		}
+		return d.recordAudit(tx, AuditActionDelete, &resource, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watchers were told about soft-deleted resources already
+	if live > 0 {
+		d.publish(EventDeleted, resource)
+	}
+	return nil
+}
+
+// DeleteManyResult is the outcome of DeleteMany
+type DeleteManyResult struct {
+	// Deleted is the number of resources deleted
+	Deleted int64
+
+	// Finalizing lists the resources with finalizers, which only got a
+	// deletion timestamp as with Delete and are not deleted yet
+	Finalizing []uint
+
+	// NotFound lists the IDs that did not exist
+	NotFound []uint
+}
+
+// DeleteMany deletes the resources with the given IDs with a single DELETE
+// statement in one transaction. The BeforeDelete hook of every resource is
+// called first; if one fails, or a resource is an owner whose deletion is
+// blocked, nothing is deleted and a *BulkDeleteError naming the resource is
+// returned along with the IDs that did not exist. As with Delete, resources
+// with finalizers are not removed but get a deletion timestamp, and are
+// reported as finalizing. Repeated IDs count once.
+func (d *DAO[T]) DeleteMany(ctx context.Context, ids []uint) (DeleteManyResult, error) {
+	ctx, end := d.startSpan(ctx, "DeleteMany", 0)
+	defer end()
+
+	var result DeleteManyResult
+	var deleted, finalizing []T
+
+	err := d.transaction(ctx, func(tx *gorm.DB) error {
+		result = DeleteManyResult{}
+		deleted, finalizing = nil, nil
+
+		var resources []T
+		if err := d.children(tx).Where("id IN ?", ids).Find(&resources).Error; err != nil {
+			return err
+		}
+		byID := make(map[uint]*T, len(resources))
+		for i := range resources {
+			byID[resourceID(&resources[i])] = &resources[i]
+		}
+
+		var candidates []*T
+		seen := make(map[uint]bool, len(ids))
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if resource, ok := byID[id]; ok {
+				candidates = append(candidates, resource)
+			} else {
+				result.NotFound = append(result.NotFound, id)
+			}
+		}
+
+		var existing []uint
+		for _, resource := range candidates {
+			id := resourceID(resource)
+			blocked, err := ownerDeletionBlocked(tx, resource)
+			if err != nil {
+				return err
+			}
+			if blocked {
+				return &BulkDeleteError{ID: id, Err: ErrOwnerDeletionBlocked}
+			}
+			if finalized, ok := any(resource).(finalizable); ok && len(finalized.GetFinalizers()) > 0 {
+				result.Finalizing = append(result.Finalizing, id)
+				if finalized.GetDeletionTimestamp() == nil {
+					finalizing = append(finalizing, *resource)
+				}
+				continue
+			}
+			if hook, ok := any(resource).(callbacks.BeforeDeleteInterface); ok {
+				if err := hook.BeforeDelete(tx); err != nil {
+					return &BulkDeleteError{ID: id, Err: err}
+				}
+			}
+			existing = append(existing, id)
+			deleted = append(deleted, *resource)
+		}
+
+		for i := range finalizing {
+			updated, err := d.markFinalizing(tx, &finalizing[i])
+			if err != nil {
+				return err
+			}
+			finalizing[i] = updated
+		}
+		if len(existing) == 0 {
+			return nil
+		}
+
+		// The hooks already ran for each resource rather than the model
+		deletion := tx.Session(&gorm.Session{SkipHooks: true}).Where("id IN ?", existing).Delete(new(T))
+		if deletion.Error != nil {
+			return deletion.Error
+		}
+		result.Deleted = deletion.RowsAffected
+
+		for i := range deleted {
+			if err := d.recordAudit(tx, AuditActionDelete, &deleted[i], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return DeleteManyResult{NotFound: result.NotFound}, err
+	}
+
+	for _, resource := range deleted {
+		d.publish(EventDeleted, resource)
+	}
+	for _, resource := range finalizing {
+		d.publish(EventModified, resource)
+	}
+	return result, nil
+}
+
+// BulkUpdatableResource is implemented by resources restricting the
+// fields of bulk updates, which skip the hooks that would otherwise
+// process them. BulkUpdateFields returns the JSON names of the fields that
+// may be set.
+type BulkUpdatableResource interface {
+	BulkUpdateFields() []string
+}
+
+// BulkUpdate sets the fields of patch, keyed by JSON or column name, on the
+// resources with the given IDs in a single statement and increments their
+// resource version. IDs that do not exist are skipped, so the update may
+// succeed only in part; it returns the number of updated resources. Hooks
+// and validation do not run: identity, creation time, status and fields
+// left out by BulkUpdatableResource cannot be set, and values must have
+// the type of their field, otherwise ErrInvalidPatch is returned.
+func (d *DAO[T]) BulkUpdate(ctx context.Context, ids []uint, patch map[string]interface{}) (int64, error) {
+	ctx, end := d.startSpan(ctx, "BulkUpdate", 0)
+	defer end()
+
+	if len(ids) == 0 || len(patch) == 0 {
+		return 0, nil
+	}
+	updates, err := d.bulkUpdates(patch)
+	if err != nil {
+		return 0, err
+	}
+
+	var updated int64
+	var before, after []T
+	err = d.transaction(ctx, func(tx *gorm.DB) error {
+		if err := d.children(tx).Where("id IN ?", ids).Order("id").Find(&before).Error; err != nil {
+			return err
+		}
+		if len(before) == 0 {
+			updated = 0
+			return nil
+		}
+
+		result := d.children(tx).Session(&gorm.Session{SkipHooks: true}).Model(new(T)).Where("id IN ?", ids).Updates(updates)
+		if result.Error != nil {
+			return translateError(result.Error)
+		}
+		updated = result.RowsAffected
+
+		if err := d.children(tx).Where("id IN ?", ids).Order("id").Find(&after).Error; err != nil {
+			return err
+		}
+		for i := range after {
+			if err := d.recordAudit(tx, AuditActionUpdate, &before[i], &after[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, resource := range after {
+		d.publish(EventModified, resource)
+	}
+	return updated, nil
+}
+
+// bulkUpdates converts the patch of BulkUpdate to column values
+func (d *DAO[T]) bulkUpdates(patch map[string]interface{}) (map[string]interface{}, error) {
+	var allowed []string
+	if updatable, ok := any(new(T)).(BulkUpdatableResource); ok {
+		allowed = updatable.BulkUpdateFields()
+	}
+
+	updates := make(map[string]interface{}, len(patch)+2)
+	for name, value := range patch {
+		field, ok := d.field(name)
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownField, name)
+		}
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch {
+		case field.PrimaryKey || field.AutoCreateTime != 0 || !field.Updatable,
+			slices.Contains([]string{"uid", "resource_version", "deletion_timestamp"}, field.DBName),
+			slices.Contains(statusColumns, field.DBName),
+			allowed != nil && !slices.Contains(allowed, jsonName):
+			return nil, fmt.Errorf("%w: %q cannot be updated", ErrInvalidPatch, name)
+		}
+
+		// Values are decoded into the type of the field, and the columns
+		// of serialized fields hold JSON because maps bypass serializers
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %v", ErrInvalidPatch, name, err)
+		}
+		typed := reflect.New(field.FieldType)
+		if err := json.Unmarshal(encoded, typed.Interface()); err != nil {
+			return nil, fmt.Errorf("%w: invalid value for %q", ErrInvalidPatch, name)
+		}
+		if field.Serializer != nil {
+			updates[field.DBName] = string(encoded)
+		} else {
+			updates[field.DBName] = typed.Elem().Interface()
+		}
+	}
+
+	var obj T
+	stmt := &gorm.Statement{DB: d.db}
+	if err := stmt.Parse(&obj); err != nil {
+		return nil, err
+	}
+	if _, ok := stmt.Schema.FieldsByDBName["resource_version"]; ok {
+		updates["resource_version"] = gorm.Expr("resource_version + 1")
+	}
+	for _, field := range stmt.Schema.Fields {
+		if field.AutoUpdateTime != 0 {
+			updates[field.DBName] = time.Now()
+		}
+	}
+	return updates, nil
+}
+
+// Iterate calls fn with every resource in ID order, batchSize at a time,
+// so that all of them can be processed without loading them at once. An
+// error of fn stops the iteration and is returned.
+func (d *DAO[T]) Iterate(ctx context.Context, batchSize int, fn func([]T) error) error {
+	ctx, end := d.startSpan(ctx, "Iterate", 0)
+	defer end()
+
+	var lastID uint
+	for {
+		var batch []T
+		err := d.query(ctx).Scopes(d.load).Where("id > ?", lastID).Order("id").Limit(batchSize).Find(&batch).Error
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		lastID = resourceID(&batch[len(batch)-1])
+	}
+}
+
+// streamBatchSize is how many resources ListStream loads at a time
+const streamBatchSize = 100
+
+// ListStream calls fn with every resource matching filters, see List, in
+// ID order, loading streamBatchSize of them at a time so that large result
+// sets are never held in memory at once. An error of fn, or the
+// cancellation of ctx, stops the iteration and is returned.
+func (d *DAO[T]) ListStream(ctx context.Context, filters []Filter, fn func(*T) error) error {
+	ctx, end := d.startSpan(ctx, "ListStream", 0)
+	defer end()
+
+	filter, err := d.filterScope(filters)
+	if err != nil {
+		return err
+	}
+	var batch []T
+	return d.query(ctx).Scopes(d.load, filter).FindInBatches(&batch, streamBatchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// ImportStrategy decides what Import does with a resource whose UID is
+// already stored
+type ImportStrategy string
+
+const (
+	// ImportSkip leaves the stored resource unchanged
+	ImportSkip ImportStrategy = "skip"
+
+	// ImportOverwrite replaces the stored resource with the imported one
+	ImportOverwrite ImportStrategy = "overwrite"
+
+	// ImportFail fails the import of the resource
+	ImportFail ImportStrategy = "fail"
+)
+
+// ImportAction is what Import did with a resource
+type ImportAction string
+
+const (
+	ImportCreated ImportAction = "Created"
+	ImportUpdated ImportAction = "Updated"
+	ImportSkipped ImportAction = "Skipped"
+)
+
+// ImportResult is the outcome of importing one resource
+type ImportResult struct {
+	// Action is what was done with the resource, empty if Err is set
+	Action ImportAction
+
+	// Err is why the resource could not be imported
+	Err error
+}
+
+// Import stores resources exported from another database in one
+// transaction, matching them with stored resources by UID. Resources that
+// are not stored yet are created with a new ID; the others are handled as
+// strategy says, where ImportFail fails them with a UniqueViolationError
+// on the uid. A resource failing to import does not stop the others. The
+// results are in the order of resources, which are updated as stored.
+func (d *DAO[T]) Import(ctx context.Context, resources []T, strategy ImportStrategy) ([]ImportResult, error) {
+	ctx, end := d.startSpan(ctx, "Import", 0)
+	defer end()
+	return d.importAll(ctx, resources, strategy, false)
+}
+
+// ImportAll is Import, except that nothing is stored unless every resource
+// imports. When one fails, the results of all of them are returned with
+// ErrImportRolledBack.
+func (d *DAO[T]) ImportAll(ctx context.Context, resources []T, strategy ImportStrategy) ([]ImportResult, error) {
+	ctx, end := d.startSpan(ctx, "ImportAll", 0)
+	defer end()
+	return d.importAll(ctx, resources, strategy, true)
+}
+
+// importAll imports resources in one transaction, rolled back when one of
+// them fails if atomic is set
+func (d *DAO[T]) importAll(ctx context.Context, resources []T, strategy ImportStrategy, atomic bool) ([]ImportResult, error) {
+	stmt := &gorm.Statement{DB: d.db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	primaryKey := stmt.Schema.PrioritizedPrimaryField
+	version := stmt.Schema.LookUpField("resource_version")
+
+	results := make([]ImportResult, len(resources))
+	original := slices.Clone(resources)
+	err := d.transaction(ctx, func(tx *gorm.DB) error {
+		copy(resources, original)
+		for i := range resources {
+			resource := &resources[i]
+			value := reflect.ValueOf(resource).Elem()
+
+			var existing T
+			found := false
+			if owned, ok := any(resource).(ownedResource); ok && owned.GetUID() != "" {
+				err := tx.Where("uid = ?", owned.GetUID()).First(&existing).Error
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+				found = err == nil
+			}
+			if found && strategy == ImportSkip {
+				results[i] = ImportResult{Action: ImportSkipped}
+				continue
+			}
+			if found && strategy != ImportOverwrite {
+				results[i] = ImportResult{Err: &UniqueViolationError{Field: "uid"}}
+				continue
+			}
+
+			savepoint := fmt.Sprintf("import_%d", i)
+			if err := tx.SavePoint(savepoint).Error; err != nil {
+				return err
+			}
+			result, err := d.importResource(tx, resource, value, found, &existing, primaryKey, version)
+			if err != nil {
+				if rollbackErr := tx.RollbackTo(savepoint).Error; rollbackErr != nil {
+					return rollbackErr
+				}
+				results[i] = ImportResult{Err: translateError(err)}
+				continue
+			}
+			results[i] = result
+		}
+		if atomic && slices.ContainsFunc(results, func(result ImportResult) bool { return result.Err != nil }) {
+			return ErrImportRolledBack
+		}
+		return nil
+	})
+	if errors.Is(err, ErrImportRolledBack) {
+		return results, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range results {
+		switch result.Action {
+		case ImportCreated:
+			d.publish(EventAdded, resources[i])
+		case ImportUpdated:
+			d.publish(EventModified, resources[i])
+		}
+	}
+	return results, nil
+}
+
+// importResource creates resource, or overwrites existing with it if
+// found, and reloads it
+func (d *DAO[T]) importResource(tx *gorm.DB, resource *T, value reflect.Value, found bool, existing *T, primaryKey, version *schema.Field) (ImportResult, error) {
+	ctx := tx.Statement.Context
+	if !found {
+		if err := primaryKey.Set(ctx, value, 0); err != nil {
+			return ImportResult{}, err
+		}
+		if err := tx.Create(resource).Error; err != nil {
+			return ImportResult{}, err
+		}
+		if err := d.recordAudit(tx, AuditActionCreate, nil, resource); err != nil {
+			return ImportResult{}, err
+		}
+		return ImportResult{Action: ImportCreated}, nil
+	}
+
+	// Updates bump the stored resource version rather than the imported one
+	id := resourceID(existing)
+	if err := primaryKey.Set(ctx, value, id); err != nil {
+		return ImportResult{}, err
+	}
+	if version != nil {
+		if err := version.Set(ctx, value, resourceVersion(existing)); err != nil {
+			return ImportResult{}, err
+		}
+	}
+	err := tx.Model(resource).Where("id = ?", id).Select("*").Omit(statusColumns...).Updates(resource).Error
+	if err != nil {
+		return ImportResult{}, err
+	}
+	if err := tx.First(resource, id).Error; err != nil {
+		return ImportResult{}, err
+	}
+	if err := d.recordAudit(tx, AuditActionUpdate, existing, resource); err != nil {
+		return ImportResult{}, err
+	}
+	return ImportResult{Action: ImportUpdated}, nil
+}
+
 // AutoMigrate performs database migration for the resource
-func (d *DAO[T]) AutoMigrate() error {
+func (d *DAO[T]) AutoMigrate(ctx context.Context) error {
+	ctx, end := d.startSpan(ctx, "AutoMigrate", 0)
+	defer end()
+
 	var obj T
-	return d.db.AutoMigrate(&obj)
+	return d.db.WithContext(ctx).AutoMigrate(&obj)
+}
+
+// ReadOnlyDAO is the read-only part of a DAO, for code that must not
+// change resources such as reports. Raw and Exec are left out as well,
+// since raw statements can write.
+type ReadOnlyDAO[T any] interface {
+	Get(ctx context.Context, id uint) (*T, error)
+	GetByUID(ctx context.Context, uid string) (*T, error)
+	GetByField(ctx context.Context, field string, value interface{}) (*T, error)
+	Exists(ctx context.Context, id uint) (bool, error)
+	ListByIDs(ctx context.Context, ids []uint) ([]T, error)
+	List(ctx context.Context, page, pageSize int, filters []Filter, sort ...SortClause) ([]T, int64, error)
+	Count(ctx context.Context, filters []Filter) (int64, error)
+	Iterate(ctx context.Context, batchSize int, fn func([]T) error) error
+}
+
+// ReadOnly returns the DAO as a ReadOnlyDAO
+func (d *DAO[T]) ReadOnly() ReadOnlyDAO[T] {
+	return d
+}
+
+// Raw runs a raw SQL query for reports that the query builder cannot
+// express and scans the rows into dest. Values must be passed as args for
+// the ? placeholders of sql, never formatted into it. CRUD operations must
+// use the other methods, which keep the audit log and watchers up to date.
+func (d *DAO[T]) Raw(ctx context.Context, sql string, dest interface{}, args ...interface{}) error {
+	ctx, end := d.startSpan(ctx, "Raw", 0)
+	defer end()
+
+	slog.DebugContext(ctx, "Raw query", "kind", d.kind, "sql", sql)
+	return d.db.WithContext(ctx).Raw(sql, args...).Scan(dest).Error
 }
 
-// Transaction executes a function within a database transaction
-func (d *DAO[T]) Transaction(fc func(tx *gorm.DB) error) error {
-	return d.db.Transaction(fc)
+// Exec runs a raw SQL statement, such as DDL or a data migration, and
+// returns the number of affected rows. Values must be passed as args like
+// for Raw. Changes made by Exec are neither audited nor sent to watchers.
+func (d *DAO[T]) Exec(ctx context.Context, sql string, args ...interface{}) (int64, error) {
+	ctx, end := d.startSpan(ctx, "Exec", 0)
+	defer end()
+
+	slog.DebugContext(ctx, "Raw statement", "kind", d.kind, "sql", sql)
+	result := d.db.WithContext(ctx).Exec(sql, args...)
+	return result.RowsAffected, result.Error
+}
+
+// Transaction executes a function within a database transaction. fc runs
+// again when the database is busy, see transaction.
+func (d *DAO[T]) Transaction(ctx context.Context, fc func(tx *gorm.DB) error) error {
+	ctx, end := d.startSpan(ctx, "Transaction", 0)
+	defer end()
+
+	return d.transaction(ctx, fc)
+}
+
+// transaction runs fc in a transaction, retrying up to maxBusyRetries times
+// with a growing delay while the database reports it is busy. If it stays
+// busy the returned error matches ErrBusy. Within the transaction of
+// WithTransaction, fc runs once under a savepoint.
+func (d *DAO[T]) transaction(ctx context.Context, fc func(tx *gorm.DB) error) error {
+	if d.inTransaction {
+		return d.db.WithContext(ctx).Transaction(fc)
+	}
+	for attempt := 0; ; attempt++ {
+		err := d.db.WithContext(ctx).Transaction(fc)
+		if !isBusyError(err) {
+			return err
+		}
+		if attempt == maxBusyRetries {
+			return fmt.Errorf("%w: %v", ErrBusy, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrBusy, err)
+		case <-time.After(time.Duration(attempt+1) * busyRetryDelay):
+		}
+	}
+}
+
+// Column resolves a field name to its database column. Both the JSON name
+// (e.g. "createdAt") and the column name (e.g. "created_at") are accepted.
+// It reports false when the model has no such column.
+func (d *DAO[T]) Column(name string) (string, bool) {
+	field, ok := d.field(name)
+	if !ok {
+		return "", false
+	}
+	return field.DBName, true
+}
+
+// field resolves a field name to the schema of its column, see Column
+func (d *DAO[T]) field(name string) (*schema.Field, bool) {
+	var obj T
+	stmt := &gorm.Statement{DB: d.db}
+	if err := stmt.Parse(&obj); err != nil {
+		return nil, false
+	}
+
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == field.DBName || (jsonName != "" && name == jsonName) {
+			return field, true
+		}
+	}
+	return nil, false
+}
+
+// Association resolves a name to an association of the resource that can be
+// passed to Preload. Both the JSON name (e.g. "profile") and the field name
+// (e.g. "Profile") are accepted. It reports false when the model has no
+// such association.
+func (d *DAO[T]) Association(name string) (string, bool) {
+	var obj T
+	stmt := &gorm.Statement{DB: d.db}
+	if err := stmt.Parse(&obj); err != nil {
+		return "", false
+	}
+
+	for fieldName, relationship := range stmt.Schema.Relationships.Relations {
+		jsonName := strings.Split(relationship.Field.Tag.Get("json"), ",")[0]
+		if name == fieldName || (jsonName != "" && name == jsonName) {
+			return fieldName, true
+		}
+	}
+	return "", false
+}
+
+// finalizable is implemented by resources embedding meta.BaseResource
+type finalizable interface {
+	GetFinalizers() []string
+	GetDeletionTimestamp() *time.Time
+}
+
+// resourceVersion returns the resource version of a resource, or 0 if it
+// is not versioned
+func resourceVersion(resource any) int {
+	if versioned, ok := resource.(interface{ GetResourceVersion() int }); ok {
+		return versioned.GetResourceVersion()
+	}
+	return 0
+}
+
+// mergeFields copies the non-zero fields of src onto dst, descending into
+// embedded structs like the updates of GORM do
+func mergeFields(dst, src reflect.Value) {
+	for i := 0; i < src.NumField(); i++ {
+		field := src.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		value := src.Field(i)
+		if field.Anonymous && value.Kind() == reflect.Struct {
+			mergeFields(dst.Field(i), value)
+			continue
+		}
+		if !value.IsZero() {
+			dst.Field(i).Set(value)
+		}
+	}
+}
+
+// resourceID returns the ID field of a resource, or 0 if it has none
+func resourceID(resource any) uint {
+	v := reflect.Indirect(reflect.ValueOf(resource))
+	if v.Kind() != reflect.Struct {
+		return 0
+	}
+	field := v.FieldByName("ID")
+	if !field.IsValid() || !field.CanUint() {
+		return 0
+	}
+	return uint(field.Uint())
 }
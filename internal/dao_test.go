@@ -1,10 +1,18 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
+
+	"my-embedded-api/apiv1"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 )
 
@@ -17,36 +25,97 @@ func TestDAO_CRUD(t *testing.T) {
 
 	// Test Create
 	model := &TestModel{Name: "test"}
-	err = dao.Create(model)
+	err = dao.Create(context.Background(), model)
 	assert.NoError(t, err)
 	assert.NotZero(t, model.ID)
 
 	// Test Get
-	found, err := dao.Get(model.ID)
+	found, err := dao.Get(context.Background(), model.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, model.ID, found.ID)
 	assert.Equal(t, model.Name, found.Name)
 
 	// Test Update
 	model.Name = "updated"
-	err = dao.Update(model.ID, model)
+	err = dao.Update(context.Background(), model.ID, model, 0)
 	assert.NoError(t, err)
 
 	// Verify update
-	found, err = dao.Get(model.ID)
+	found, err = dao.Get(context.Background(), model.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, "updated", found.Name)
 
 	// Test Delete
-	err = dao.Delete(model.ID)
+	err = dao.Delete(context.Background(), model.ID, 0)
 	assert.NoError(t, err)
 
 	// Verify deletion
-	_, err = dao.Get(model.ID)
+	_, err = dao.Get(context.Background(), model.ID)
 	assert.Error(t, err)
 	assert.Equal(t, gorm.ErrRecordNotFound, err)
 }
 
+func TestDAO_FindOrCreate(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[apiv1.User](db)
+	ctx := context.Background()
+
+	user := &apiv1.User{Username: "alice", Password: "password123"}
+	created, err := dao.FindOrCreate(ctx, map[string]interface{}{"email": "alice@example.com"}, user)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.NotZero(t, user.ID)
+	assert.Equal(t, "alice@example.com", user.Email, "condition values are set on created resources")
+	assert.NotEmpty(t, user.PasswordHash, "BeforeCreate hashes the password")
+	assert.Empty(t, user.Password)
+
+	// The existing user is returned unchanged
+	other := &apiv1.User{Username: "other", Password: "password123"}
+	created, err = dao.FindOrCreate(ctx, map[string]interface{}{"email": "alice@example.com"}, other)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, user.ID, other.ID)
+	assert.Equal(t, "alice", other.Username)
+	assert.Equal(t, user.PasswordHash, other.PasswordHash)
+}
+
+func TestDAO_FindOrCreateConcurrent(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[apiv1.User](db)
+
+	const calls = 10
+	ids := make([]uint, calls)
+	created := make([]bool, calls)
+	errs := make([]error, calls)
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user := &apiv1.User{Username: "alice", Email: "alice@example.com", Password: "password123"}
+			created[i], errs[i] = dao.FindOrCreate(context.Background(), map[string]interface{}{"email": user.Email}, user)
+			ids[i] = user.ID
+		}(i)
+	}
+	wg.Wait()
+
+	creations := 0
+	for i := 0; i < calls; i++ {
+		require.NoError(t, errs[i], "call %d", i)
+		assert.Equal(t, ids[0], ids[i], "call %d", i)
+		if created[i] {
+			creations++
+		}
+	}
+	assert.Equal(t, 1, creations)
+
+	var count int64
+	require.NoError(t, db.Model(&apiv1.User{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
 func TestDAO_List(t *testing.T) {
 	db := setupTestDB(t)
 	err := db.AutoMigrate(&TestModel{})
@@ -57,24 +126,24 @@ func TestDAO_List(t *testing.T) {
 	// Create test data
 	for i := 0; i < 5; i++ {
 		model := &TestModel{Name: fmt.Sprintf("test%d", i)}
-		err := dao.Create(model)
+		err := dao.Create(context.Background(), model)
 		assert.NoError(t, err)
 	}
 
 	// Test pagination
-	items, total, err := dao.List(1, 2, nil)
+	items, total, err := dao.List(context.Background(), 1, 2, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(5), total)
 	assert.Len(t, items, 2)
 
 	// Test second page
-	items, total, err = dao.List(2, 2, nil)
+	items, total, err = dao.List(context.Background(), 2, 2, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(5), total)
 	assert.Len(t, items, 2)
 
 	// Test last page
-	items, total, err = dao.List(3, 2, nil)
+	items, total, err = dao.List(context.Background(), 3, 2, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(5), total)
 	assert.Len(t, items, 1)
@@ -85,7 +154,7 @@ func TestDAO_Transaction(t *testing.T) {
 	dao := NewDAO[TestModel](db)
 
 	// Test transaction
-	err := dao.Transaction(func(tx *gorm.DB) error {
+	err := dao.Transaction(context.Background(), func(tx *gorm.DB) error {
 		model1 := &TestModel{Name: "model1"}
 		if err := tx.Create(model1).Error; err != nil {
 			return err
@@ -106,3 +175,1153 @@ func TestDAO_Transaction(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, int64(2), count)
 }
+
+func TestDAO_ListAfter(t *testing.T) {
+	db := setupTestDB(t)
+	dao := NewDAO[TestModel](db)
+
+	// Create test data
+	for i := 0; i < 5; i++ {
+		model := &TestModel{Name: fmt.Sprintf("test%d", i)}
+		err := dao.Create(context.Background(), model)
+		assert.NoError(t, err)
+	}
+
+	// Start from the beginning
+	items, err := dao.ListAfter(context.Background(), 0, 2, nil)
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, uint(1), items[0].ID)
+	assert.Equal(t, uint(2), items[1].ID)
+
+	// Continue after the last seen ID
+	items, err = dao.ListAfter(context.Background(), items[1].ID, 2, nil)
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, uint(3), items[0].ID)
+
+	// Past the end
+	items, err = dao.ListAfter(context.Background(), 5, 2, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestDAO_ListCursor(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[TestModel](db)
+	ctx := context.Background()
+
+	// Pairs of equal names exercise the ID tiebreak
+	for i := 0; i < 25; i++ {
+		require.NoError(t, dao.Create(ctx, &TestModel{Name: fmt.Sprintf("item%02d", i/2)}))
+	}
+	sort := []SortClause{{Field: "name", Desc: true}}
+
+	seen := map[uint]bool{}
+	var names []string
+	var skipped, reached uint
+	cursor := Cursor{}
+	for pages := 1; ; pages++ {
+		require.LessOrEqual(t, pages, 3)
+		items, next, err := dao.ListCursor(ctx, 10, cursor, nil, sort...)
+		require.NoError(t, err)
+		for _, item := range items {
+			assert.False(t, seen[item.ID], "item %d repeated", item.ID)
+			seen[item.ID] = true
+			names = append(names, item.Name)
+		}
+		if next == nil {
+			break
+		}
+		cursor = *next
+
+		// Rows created behind the cursor are not listed, those ahead are
+		if pages == 1 {
+			behind := &TestModel{Name: "item11"}
+			ahead := &TestModel{Name: "item03"}
+			require.NoError(t, dao.Create(ctx, behind))
+			require.NoError(t, dao.Create(ctx, ahead))
+			skipped, reached = behind.ID, ahead.ID
+		}
+	}
+
+	assert.Len(t, seen, 26)
+	assert.False(t, seen[skipped])
+	assert.True(t, seen[reached])
+	assert.IsNonIncreasing(t, names)
+
+	// Cursors must match the sort they were created with
+	_, _, err := dao.ListCursor(ctx, 10, Cursor{ID: 1}, nil, sort...)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, _, err = dao.ListCursor(ctx, 10, Cursor{}, nil, SortClause{Field: "nickname"})
+	assert.ErrorIs(t, err, ErrUnknownField)
+}
+
+// testOwner and testItem are models with an association for Preload tests
+type testOwner struct {
+	gorm.Model
+	Name  string
+	Items []testItem `json:"items,omitempty" gorm:"foreignKey:OwnerID"`
+}
+
+type testItem struct {
+	gorm.Model
+	OwnerID uint
+	Name    string
+}
+
+func TestDAO_Preload(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	require.NoError(t, db.AutoMigrate(&testOwner{}, &testItem{}))
+	dao := NewDAO[testOwner](db)
+
+	owner := &testOwner{Name: "owner", Items: []testItem{{Name: "a"}, {Name: "b"}}}
+	require.NoError(t, dao.Create(context.Background(), owner))
+
+	association, ok := dao.Association("items")
+	require.True(t, ok)
+	assert.Equal(t, "Items", association)
+	_, ok = dao.Association("Items")
+	assert.True(t, ok)
+	_, ok = dao.Association("name")
+	assert.False(t, ok)
+
+	// Associations are only loaded by the preloading copy
+	found, err := dao.Get(context.Background(), owner.ID)
+	require.NoError(t, err)
+	assert.Empty(t, found.Items)
+
+	preloaded := dao.Preload(association)
+	found, err = preloaded.Get(context.Background(), owner.ID)
+	require.NoError(t, err)
+	assert.Len(t, found.Items, 2)
+
+	items, _, err := preloaded.List(context.Background(), 1, 10, nil)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Len(t, items[0].Items, 2)
+
+	items, err = preloaded.ListByIDs(context.Background(), []uint{owner.ID})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Len(t, items[0].Items, 2)
+}
+
+func TestDAO_Select(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[apiv1.User](db)
+
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	require.NoError(t, dao.Create(context.Background(), user))
+
+	selected := dao.Select("id", "username")
+	found, err := selected.Get(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+	assert.Equal(t, "testuser", found.Username)
+	assert.Empty(t, found.Email)
+
+	items, _, err := selected.List(context.Background(), 1, 10, nil)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "testuser", items[0].Username)
+	assert.Empty(t, items[0].Email)
+
+	// The original DAO still loads every column
+	found, err = dao.Get(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "test@example.com", found.Email)
+}
+
+func TestDAO_Scope(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[apiv1.User](db)
+	ctx := context.Background()
+
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user := &apiv1.User{Username: name, Email: name + "@example.com", Password: "password123"}
+		require.NoError(t, dao.Create(ctx, user))
+	}
+	require.NoError(t, db.Model(&apiv1.User{}).Where("username = ?", "carol").Update("is_active", false).Error)
+
+	active := dao.Scope(ActiveScope)
+	items, total, err := active.List(ctx, 1, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, items, 2)
+
+	// Scopes compose
+	notAlice := active.Scope(func(db *gorm.DB) *gorm.DB {
+		return db.Where("username <> ?", "alice")
+	})
+	items, total, err = notAlice.List(ctx, 1, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, items, 1)
+	assert.Equal(t, "bob", items[0].Username)
+
+	_, err = notAlice.GetByField(ctx, "username", "alice")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	_, err = notAlice.Get(ctx, 3)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	// Neither the base DAO nor the first copy are modified
+	_, total, err = dao.List(ctx, 1, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	_, total, err = active.List(ctx, 1, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+}
+
+func TestDAO_Search(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[apiv1.User](db)
+	ctx := context.Background()
+
+	for _, user := range []*apiv1.User{
+		{Username: "alice", Email: "alice@corp.com", Password: "password123"},
+		{Username: "bob", Email: "bob@Alice.org", Password: "password123"},
+		{Username: "carol", Email: "carol@example.com", Password: "password123", FullName: "Carol 100%"},
+	} {
+		require.NoError(t, dao.Create(ctx, user))
+	}
+
+	usernames := func(dao *DAO[apiv1.User]) []string {
+		items, _, err := dao.List(ctx, 1, 10, nil, SortClause{Field: "username"})
+		require.NoError(t, err)
+		var names []string
+		for _, item := range items {
+			names = append(names, item.Username)
+		}
+		return names
+	}
+
+	// Matches in any field are combined, ignoring case
+	assert.Equal(t, []string{"alice", "bob"}, usernames(dao.Search("ALI")))
+	assert.Equal(t, []string{"carol"}, usernames(dao.Search("example")))
+	assert.Equal(t, []string{"alice", "bob", "carol"}, usernames(dao.Search("")))
+
+	// Other restrictions still apply, and wildcards match literally
+	assert.Equal(t, []string{"bob"}, usernames(dao.Search("ali").Scope(func(db *gorm.DB) *gorm.DB {
+		return db.Where("username <> ?", "alice")
+	})))
+	assert.Equal(t, []string{"carol"}, usernames(dao.Search("0%")))
+	assert.Empty(t, usernames(dao.Search("a_ice")))
+
+	// Resources that are not searchable ignore the term
+	models := NewDAO[TestModel](db)
+	require.NoError(t, models.Create(ctx, &TestModel{Name: "test"}))
+	count, err := models.Search("nothing").Count(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestDAO_SearchIn(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[apiv1.User](db)
+	ctx := context.Background()
+
+	for _, user := range []*apiv1.User{
+		{Username: "alice", Email: "alice@corp.com", Password: "password123"},
+		{Username: "bob", Email: "bob@Alice.org", Password: "password123", FullName: "Bob_Smith"},
+		{Username: "carol", Email: "carol@example.com", Password: "password123", FullName: "Carol 100%"},
+	} {
+		require.NoError(t, dao.Create(ctx, user))
+	}
+
+	usernames := func(query string, fields ...string) []string {
+		items, err := dao.SearchIn(ctx, query, fields)
+		require.NoError(t, err)
+		var names []string
+		for _, item := range items {
+			names = append(names, item.Username)
+		}
+		return names
+	}
+
+	// Only the given fields are searched, ignoring case
+	assert.Equal(t, []string{"alice"}, usernames("ALI", "username"))
+	assert.Equal(t, []string{"alice", "bob"}, usernames("ali", "username", "email"))
+	assert.Equal(t, []string{"bob"}, usernames("alice.ORG", "email"))
+
+	// Wildcards and quotes match literally
+	assert.Equal(t, []string{"carol"}, usernames("0%", "fullName"))
+	assert.Equal(t, []string{"bob"}, usernames("b_s", "full_name"))
+	assert.Empty(t, usernames("b%s", "fullName"))
+	assert.Empty(t, usernames("' OR '1'='1", "username"))
+
+	// An empty query retrieves everything
+	assert.Equal(t, []string{"alice", "bob", "carol"}, usernames("", "username"))
+
+	_, err := dao.SearchIn(ctx, "alice", []string{"username", "nickname"})
+	assert.ErrorIs(t, err, ErrUnknownField)
+}
+
+func TestDAO_ListStream(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[TestModel](db)
+	ctx := context.Background()
+
+	models := make([]TestModel, 250)
+	for i := range models {
+		models[i] = TestModel{Name: fmt.Sprintf("test%03d", i+1)}
+	}
+	require.NoError(t, db.Create(&models).Error)
+
+	var ids []uint
+	filter := Filters{{Field: "id", Operator: FilterGt, Value: 20}}
+	err := dao.ListStream(ctx, filter, func(model *TestModel) error {
+		ids = append(ids, model.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, ids, 230)
+	assert.Equal(t, uint(21), ids[0])
+	assert.Equal(t, uint(250), ids[229])
+
+	// An error of fn stops the stream
+	stop := errors.New("stop")
+	seen := 0
+	err = dao.ListStream(ctx, nil, func(*TestModel) error {
+		seen++
+		if seen == 150 {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 150, seen)
+
+	// Cancelling the context stops it without scanning the rest
+	cancelled, cancel := context.WithCancel(ctx)
+	defer cancel()
+	seen = 0
+	err = dao.ListStream(cancelled, nil, func(*TestModel) error {
+		seen++
+		if seen == 10 {
+			cancel()
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 10, seen)
+}
+func TestDAO_Count(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[TestModel](db)
+	ctx := context.Background()
+
+	count, err := dao.Count(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	for _, name := range []string{"a", "a", "b"} {
+		require.NoError(t, dao.Create(ctx, &TestModel{Name: name}))
+	}
+	count, err = dao.Count(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	count, err = dao.Count(ctx, []Filter{{Field: "name", Operator: FilterEq, Value: "a"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	require.NoError(t, dao.Delete(ctx, 1, 0))
+	count, err = dao.Count(ctx, []Filter{{Field: "name", Operator: FilterEq, Value: "a"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestDAO_ListSorted(t *testing.T) {
+	db := setupTestDB(t)
+	dao := NewDAO[TestModel](db)
+
+	for _, name := range []string{"b", "c", "a"} {
+		err := dao.Create(context.Background(), &TestModel{Name: name})
+		assert.NoError(t, err)
+	}
+
+	// Ascending
+	items, _, err := dao.List(context.Background(), 1, 10, nil, SortClause{Field: "name"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", items[0].Name)
+	assert.Equal(t, "c", items[2].Name)
+
+	// Descending
+	items, _, err = dao.List(context.Background(), 1, 10, nil, SortClause{Field: "name", Desc: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "c", items[0].Name)
+	assert.Equal(t, "a", items[2].Name)
+}
+
+func TestDAO_UpdateConflict(t *testing.T) {
+	db := setupTestDB(t)
+	dao := NewDAO[apiv1.User](db)
+
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	err := dao.Create(context.Background(), user)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, user.ResourceVersion)
+
+	// Update with the current version
+	user.Email = "updated@example.com"
+	err = dao.Update(context.Background(), user.ID, user, 1)
+	assert.NoError(t, err)
+
+	// Update with a stale version
+	user.Email = "stale@example.com"
+	err = dao.Update(context.Background(), user.ID, user, 1)
+	assert.Equal(t, ErrConflict, err)
+
+	// Update a missing resource
+	err = dao.Update(context.Background(), 9999, user, 1)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestDAO_UpdateUnchanged(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[apiv1.User](db)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	require.NoError(t, dao.Create(ctx, user))
+	events := dao.Watch(ctx)
+
+	// Storing the same values writes nothing
+	same := *user
+	same.UpdatedAt = time.Time{}
+	require.NoError(t, dao.Update(ctx, user.ID, &same, user.ResourceVersion))
+	assert.Equal(t, 1, same.ResourceVersion)
+	require.NoError(t, dao.Save(ctx, user.ID, &same, 0))
+	assert.Equal(t, 1, same.ResourceVersion)
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected %s event", event.Type)
+	default:
+	}
+
+	// A field outside the base resource still counts as a change
+	changed := same
+	changed.FullName = "Test User"
+	require.NoError(t, dao.Update(ctx, user.ID, &changed, 0))
+	assert.Equal(t, 2, changed.ResourceVersion)
+	assert.Equal(t, EventModified, (<-events).Type)
+
+	labeled := changed
+	labeled.Labels = map[string]string{"team": "ops"}
+	require.NoError(t, dao.Update(ctx, user.ID, &labeled, 0))
+	assert.Equal(t, 3, labeled.ResourceVersion)
+
+	// So does a new password, which only changes the hidden hash
+	rehashed := labeled
+	rehashed.Password = "newpassword123"
+	require.NoError(t, dao.Update(ctx, user.ID, &rehashed, 0))
+	assert.Equal(t, 4, rehashed.ResourceVersion)
+	assert.NotEqual(t, labeled.PasswordHash, rehashed.PasswordHash)
+
+	// A stale version still conflicts
+	assert.Equal(t, ErrConflict, dao.Update(ctx, user.ID, &labeled, 1))
+}
+
+func TestDAO_PartialUpdate(t *testing.T) {
+	db := setupTestDB(t)
+	dao := NewDAO[apiv1.User](db)
+	ctx := context.Background()
+
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	assert.NoError(t, dao.Create(ctx, user))
+	hash := user.PasswordHash
+
+	// Only the email is set, the password must not be rehashed or cleared
+	update := &apiv1.User{Email: "updated@example.com"}
+	assert.NoError(t, dao.Update(ctx, user.ID, update, 0))
+
+	stored, err := dao.Get(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "updated@example.com", stored.Email)
+	assert.Equal(t, "testuser", stored.Username)
+	assert.Equal(t, hash, stored.PasswordHash)
+	assert.NoError(t, stored.ComparePassword("password123"))
+	assert.Equal(t, 2, stored.ResourceVersion)
+
+	// Sending the current password in plain text keeps the stored hash
+	update = &apiv1.User{Email: "again@example.com", Password: "password123"}
+	assert.NoError(t, dao.Update(ctx, user.ID, update, 0))
+	stored, err = dao.Get(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, stored.PasswordHash)
+}
+
+func TestDAO_PartialUpdateKeeps2yHash(t *testing.T) {
+	db := setupTestDB(t)
+	dao := NewDAO[apiv1.User](db)
+	ctx := context.Background()
+
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	assert.NoError(t, dao.Create(ctx, user))
+
+	// Hashes written by other bcrypt implementations use the $2y$ prefix
+	hash := "$2y$" + user.PasswordHash[len("$2a$"):]
+	assert.NoError(t, db.Model(&apiv1.User{}).Where("id = ?", user.ID).UpdateColumn("password", hash).Error)
+
+	assert.NoError(t, dao.Update(ctx, user.ID, &apiv1.User{FullName: "Test User"}, 0))
+
+	stored, err := dao.Get(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Test User", stored.FullName)
+	assert.Equal(t, hash, stored.PasswordHash)
+	assert.NoError(t, stored.ComparePassword("password123"))
+}
+
+func TestDAO_GetByUID(t *testing.T) {
+	db := setupTestDB(t)
+	dao := NewDAO[apiv1.User](db)
+
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	err := dao.Create(context.Background(), user)
+	assert.NoError(t, err)
+
+	found, err := dao.GetByUID(context.Background(), user.UID)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+
+	_, err = dao.GetByUID(context.Background(), "00000000-0000-0000-0000-000000000000")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestDAO_Upsert(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[apiv1.User](db)
+	ctx := context.Background()
+
+	// A new key creates the resource, running the create hooks
+	user := &apiv1.User{Username: "alice", Email: "alice@example.com", Password: "password123"}
+	require.NoError(t, dao.Upsert(ctx, user, []string{"email"}))
+	assert.NotZero(t, user.ID)
+	assert.NotEmpty(t, user.UID)
+	assert.Equal(t, 1, user.ResourceVersion)
+
+	// A stored key updates that resource and increments its version
+	update := &apiv1.User{Username: "alice2", Email: "alice@example.com", Password: "password123", FullName: "Alice"}
+	require.NoError(t, dao.Upsert(ctx, update, []string{"email"}))
+	assert.Equal(t, user.ID, update.ID)
+	assert.Equal(t, user.UID, update.UID)
+	assert.Equal(t, 2, update.ResourceVersion)
+	assert.Equal(t, "alice2", update.Username)
+	assert.Equal(t, "Alice", update.FullName)
+
+	var count int64
+	require.NoError(t, db.Model(&apiv1.User{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	// The key must be unique
+	err := dao.Upsert(ctx, &apiv1.User{Username: "bob", Email: "bob@example.com", Password: "password123"}, []string{"fullName"})
+	assert.ErrorIs(t, err, ErrNotUniqueKey)
+	err = dao.Upsert(ctx, &apiv1.User{Username: "bob", Email: "bob@example.com", Password: "password123"}, []string{"nickname"})
+	assert.ErrorIs(t, err, ErrUnknownField)
+
+	// Other unique columns still conflict
+	err = dao.Upsert(ctx, &apiv1.User{Username: "alice2", Email: "bob@example.com", Password: "password123"}, []string{"email"})
+	assert.ErrorIs(t, err, ErrConflict)
+}
+
+// testTagged is a model with a serialized field for BulkUpdate tests
+type testTagged struct {
+	gorm.Model
+	Tags []string `json:"tags" gorm:"serializer:json"`
+}
+
+func TestDAO_BulkUpdate(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[apiv1.User](db)
+	ctx := context.Background()
+
+	var ids []uint
+	for i := 0; i < 3; i++ {
+		user := &apiv1.User{Username: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i), Password: "password123"}
+		require.NoError(t, dao.Create(ctx, user))
+		ids = append(ids, user.ID)
+	}
+
+	// Missing IDs are skipped
+	updated, err := dao.BulkUpdate(ctx, append(ids[:2:2], 99), map[string]interface{}{"isActive": false, "full_name": "Bulk"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated)
+
+	for i, id := range ids {
+		user, err := dao.Get(ctx, id)
+		require.NoError(t, err)
+		if i < 2 {
+			assert.False(t, user.IsActive)
+			assert.Equal(t, "Bulk", user.FullName)
+			assert.Equal(t, 2, user.ResourceVersion)
+			assert.True(t, user.CheckPassword("password123"))
+		} else {
+			assert.True(t, user.IsActive)
+			assert.Equal(t, 1, user.ResourceVersion)
+		}
+	}
+
+	updated, err = dao.BulkUpdate(ctx, []uint{98, 99}, map[string]interface{}{"isActive": true})
+	require.NoError(t, err)
+	assert.Zero(t, updated)
+
+	for _, patch := range []map[string]interface{}{
+		{"password": "plain-text"},
+		{"role": "admin"},
+		{"id": 7},
+		{"resourceVersion": 7},
+		{"isActive": "no"},
+	} {
+		_, err := dao.BulkUpdate(ctx, ids, patch)
+		assert.ErrorIs(t, err, ErrInvalidPatch, patch)
+	}
+	_, err = dao.BulkUpdate(ctx, ids, map[string]interface{}{"nickname": "x"})
+	assert.ErrorIs(t, err, ErrUnknownField)
+
+	// Serialized fields are stored as their serializer would
+	require.NoError(t, db.AutoMigrate(&testTagged{}))
+	tagged := NewDAO[testTagged](db)
+	model := &testTagged{Tags: []string{"old"}}
+	require.NoError(t, tagged.Create(ctx, model))
+	_, err = tagged.BulkUpdate(ctx, []uint{model.ID}, map[string]interface{}{"tags": []string{"a", "b"}})
+	require.NoError(t, err)
+	found, err := tagged.Get(ctx, model.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, found.Tags)
+}
+
+// testGuarded is a model whose BeforeDelete hook refuses protected rows
+type testGuarded struct {
+	gorm.Model
+	Name string
+}
+
+// BeforeDelete implements the GORM hook
+func (g *testGuarded) BeforeDelete(tx *gorm.DB) error {
+	if g.Name == "protected" {
+		return errors.New("protected rows cannot be deleted")
+	}
+	return nil
+}
+
+func TestDAO_DeleteManyHooks(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[apiv1.User](db)
+	ctx := context.Background()
+
+	var ids []uint
+	for i := 0; i < 4; i++ {
+		user := &apiv1.User{Username: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i), Password: "password123"}
+		if i == 3 {
+			user.Finalizers = []string{"example.com/cleanup"}
+		}
+		require.NoError(t, dao.Create(ctx, user))
+		ids = append(ids, user.ID)
+	}
+
+	// Missing IDs are skipped and resources with finalizers are only marked
+	result, err := dao.DeleteMany(ctx, []uint{ids[0], ids[1], ids[3], 99, ids[0]})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Deleted)
+	assert.Equal(t, []uint{ids[3]}, result.Finalizing)
+	assert.Equal(t, []uint{99}, result.NotFound)
+
+	var remaining []apiv1.User
+	require.NoError(t, db.Order("id").Find(&remaining).Error)
+	require.Len(t, remaining, 2)
+	assert.Equal(t, ids[2], remaining[0].ID)
+	assert.Nil(t, remaining[0].DeletionTimestamp)
+	assert.NotNil(t, remaining[1].DeletionTimestamp)
+
+	// A failing hook rolls back the whole delete and names the resource
+	require.NoError(t, db.AutoMigrate(&testGuarded{}))
+	guarded := NewDAO[testGuarded](db)
+	var guardedIDs []uint
+	for _, name := range []string{"first", "protected", "last"} {
+		model := &testGuarded{Name: name}
+		require.NoError(t, guarded.Create(ctx, model))
+		guardedIDs = append(guardedIDs, model.ID)
+	}
+	result, err = guarded.DeleteMany(ctx, append(guardedIDs, 99))
+	var deleteErr *BulkDeleteError
+	require.ErrorAs(t, err, &deleteErr)
+	assert.Equal(t, guardedIDs[1], deleteErr.ID)
+	assert.Zero(t, result.Deleted)
+	assert.Equal(t, []uint{99}, result.NotFound)
+
+	count, err := guarded.Count(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestDAO_GetByField(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[apiv1.User](db)
+
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	require.NoError(t, dao.Create(context.Background(), user))
+
+	// Both the JSON and the column name are accepted
+	for _, field := range []string{"username", "fullName", "full_name"} {
+		_, err := dao.GetByField(context.Background(), field, "missing")
+		assert.Equal(t, gorm.ErrRecordNotFound, err, field)
+	}
+	found, err := dao.GetByField(context.Background(), "email", "test@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+
+	for _, field := range []string{"nickname", "email = email OR 1", "password_hash; DROP TABLE users"} {
+		_, err := dao.GetByField(context.Background(), field, "x")
+		assert.ErrorIs(t, err, ErrUnknownField, field)
+	}
+}
+
+func TestDAO_Exists(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[TestModel](db)
+
+	model := &TestModel{Name: "test"}
+	require.NoError(t, dao.Create(context.Background(), model))
+
+	exists, err := dao.Exists(context.Background(), model.ID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = dao.Exists(context.Background(), model.ID+1)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// A closed connection is an error rather than a missing resource
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+	exists, err = dao.Exists(context.Background(), model.ID)
+	assert.Error(t, err)
+	assert.False(t, exists)
+}
+
+func TestDAO_SoftDelete(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[apiv1.User](db)
+	ctx := context.Background()
+
+	alice := &apiv1.User{Username: "alice", Email: "alice@example.com", Password: "password123"}
+	bob := &apiv1.User{Username: "bob", Email: "bob@example.com", Password: "password123"}
+	require.NoError(t, dao.Create(ctx, alice))
+	require.NoError(t, dao.Create(ctx, bob))
+	events := dao.Watch(ctx)
+
+	// Deleted resources stay in storage but are hidden
+	require.NoError(t, dao.Delete(ctx, alice.ID, 0))
+	assert.Equal(t, EventDeleted, (<-events).Type)
+	_, err := dao.Get(ctx, alice.ID)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	users, total, err := dao.List(ctx, 1, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "bob", users[0].Username)
+
+	deleted, total, err := dao.ListDeleted(ctx, 1, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "alice", deleted[0].Username)
+	found, err := dao.GetDeleted(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.True(t, found.DeletedAt.Valid)
+	_, err = dao.GetDeleted(ctx, bob.ID)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	require.NoError(t, dao.Restore(ctx, alice.ID))
+	assert.Equal(t, EventAdded, (<-events).Type)
+	found, err = dao.Get(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.False(t, found.DeletedAt.Valid)
+	assert.Equal(t, gorm.ErrRecordNotFound, dao.Restore(ctx, alice.ID))
+
+	// Purging removes live and deleted resources for good
+	require.NoError(t, dao.Purge(ctx, alice.ID))
+	assert.Equal(t, EventDeleted, (<-events).Type)
+	require.NoError(t, dao.Delete(ctx, bob.ID, 0))
+	<-events
+	require.NoError(t, dao.Purge(ctx, bob.ID))
+	var count int64
+	require.NoError(t, db.Unscoped().Model(&apiv1.User{}).Count(&count).Error)
+	assert.Zero(t, count)
+	assert.Equal(t, gorm.ErrRecordNotFound, dao.Purge(ctx, bob.ID))
+	assert.Equal(t, gorm.ErrRecordNotFound, dao.Restore(ctx, bob.ID))
+}
+
+func TestDAO_RecreateAfterSoftDelete(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[apiv1.User](db)
+	ctx := context.Background()
+
+	newAlice := func() *apiv1.User {
+		user := &apiv1.User{Username: "alice", Email: "alice@example.com", Password: "password123"}
+		user.Name = "alice"
+		return user
+	}
+	deleted := newAlice()
+	require.NoError(t, dao.Create(ctx, deleted))
+	require.ErrorIs(t, dao.Create(ctx, newAlice()), ErrConflict)
+
+	// A deleted resource does not hold its unique values
+	require.NoError(t, dao.Delete(ctx, deleted.ID, 0))
+	recreated := newAlice()
+	require.NoError(t, dao.Create(ctx, recreated))
+	assert.NotEqual(t, deleted.ID, recreated.ID)
+	found, err := dao.GetByName(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, recreated.ID, found.ID)
+
+	// Upserts match the live resource only
+	update := newAlice()
+	update.FullName = "Alice"
+	require.NoError(t, dao.Upsert(ctx, update, []string{"email"}))
+	assert.Equal(t, recreated.ID, update.ID)
+
+	// Restoring the deleted one would duplicate the live values
+	assert.ErrorIs(t, dao.Restore(ctx, deleted.ID), ErrConflict)
+	require.NoError(t, dao.Delete(ctx, recreated.ID, 0))
+	require.NoError(t, dao.Restore(ctx, deleted.ID))
+}
+
+func TestDAO_WithTransaction(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	users := NewDAO[apiv1.User](db)
+	models := NewDAO[TestModel](db)
+	ctx := context.Background()
+
+	tx := db.Begin()
+	require.NoError(t, tx.Error)
+	require.NoError(t, users.WithTransaction(tx).Create(ctx, &apiv1.User{Username: "alice", Email: "alice@example.com", Password: "password123"}))
+	model := &TestModel{Name: "test"}
+	require.NoError(t, models.WithTransaction(tx).Create(ctx, model))
+
+	// The writes are only visible within the transaction
+	_, err := models.WithTransaction(tx).Get(ctx, model.ID)
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback().Error)
+
+	_, total, err := users.List(ctx, 1, 10, nil)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	_, err = models.Get(ctx, model.ID)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	// A failed operation only undoes its own writes
+	tx = db.Begin()
+	require.NoError(t, models.WithTransaction(tx).Create(ctx, &TestModel{Name: "kept"}))
+	require.NoError(t, users.WithTransaction(tx).Create(ctx, &apiv1.User{Username: "bob", Email: "bob@example.com", Password: "password123"}))
+	err = users.WithTransaction(tx).Create(ctx, &apiv1.User{Username: "bob", Email: "bob@example.com", Password: "password123"})
+	assert.ErrorIs(t, err, ErrConflict)
+	require.NoError(t, tx.Commit().Error)
+
+	items, total, err := models.List(ctx, 1, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "kept", items[0].Name)
+	_, total, err = users.List(ctx, 1, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+}
+
+func TestDAO_RawExec(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[TestModel](db)
+	ctx := context.Background()
+
+	for _, name := range []string{"a", "a", "b"} {
+		require.NoError(t, dao.Create(ctx, &TestModel{Name: name}))
+	}
+
+	var counts []struct {
+		Name  string
+		Total int
+	}
+	err := dao.Raw(ctx, "SELECT name, COUNT(*) AS total FROM test_models WHERE name IN ? GROUP BY name ORDER BY name", &counts, []string{"a", "b"})
+	require.NoError(t, err)
+	require.Len(t, counts, 2)
+	assert.Equal(t, "a", counts[0].Name)
+	assert.Equal(t, 2, counts[0].Total)
+
+	affected, err := dao.Exec(ctx, "UPDATE test_models SET name = ? WHERE name = ?", "c", "b")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	// Arguments are bound rather than spliced into the statement
+	var total int64
+	require.NoError(t, dao.Raw(ctx, "SELECT COUNT(*) FROM test_models WHERE name = ?", &total, "a' OR '1'='1"))
+	assert.Zero(t, total)
+
+	_, err = dao.Exec(ctx, "UPDATE missing SET name = ?", "x")
+	assert.Error(t, err)
+
+	// Read-only DAOs offer no raw statements
+	readOnly := reflect.TypeFor[ReadOnlyDAO[TestModel]]()
+	_, ok := readOnly.MethodByName("Raw")
+	assert.False(t, ok)
+	_, ok = readOnly.MethodByName("Exec")
+	assert.False(t, ok)
+	count, err := dao.ReadOnly().Count(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestDAO_ListByIDs(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[TestModel](db)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, dao.Create(context.Background(), &TestModel{Name: fmt.Sprintf("test%d", i)}))
+	}
+
+	items, err := dao.ListByIDs(context.Background(), []uint{3, 99, 1, 3})
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, uint(3), items[0].ID)
+	assert.Equal(t, uint(1), items[1].ID)
+
+	items, err = dao.ListByIDs(context.Background(), nil)
+	require.NoError(t, err)
+	assert.NotNil(t, items)
+	assert.Empty(t, items)
+}
+
+func TestDAO_DeleteMany(t *testing.T) {
+	db := setupTestDB(t)
+	dao := NewDAO[TestModel](db)
+
+	for i := 0; i < 3; i++ {
+		err := dao.Create(context.Background(), &TestModel{Name: fmt.Sprintf("test%d", i)})
+		assert.NoError(t, err)
+	}
+
+	result, err := dao.DeleteMany(context.Background(), []uint{1, 2, 99})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.Deleted)
+	assert.Equal(t, []uint{99}, result.NotFound)
+
+	// Only the unlisted resource remains
+	items, total, err := dao.List(context.Background(), 1, 10, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, uint(3), items[0].ID)
+}
+
+func TestDAO_DeleteManyWithFinalizers(t *testing.T) {
+	db := setupTestDB(t)
+	dao := NewDAO[apiv1.User](db)
+	ctx := context.Background()
+
+	finalized := &apiv1.User{Username: "finalized", Email: "finalized@example.com", Password: "password123"}
+	finalized.Finalizers = []string{"cleanup"}
+	require.NoError(t, dao.Create(ctx, finalized))
+	plain := &apiv1.User{Username: "plain", Email: "plain@example.com", Password: "password123"}
+	require.NoError(t, dao.Create(ctx, plain))
+
+	result, err := dao.DeleteMany(ctx, []uint{finalized.ID, plain.ID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Deleted)
+	assert.Equal(t, []uint{finalized.ID}, result.Finalizing)
+	assert.Empty(t, result.NotFound)
+
+	// The resource with finalizers is only marked
+	found, err := dao.Get(ctx, finalized.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, found.DeletionTimestamp)
+	_, err = dao.Get(ctx, plain.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// It is deleted once its last finalizer is removed
+	require.NoError(t, dao.RemoveFinalizer(ctx, finalized.ID, "cleanup"))
+	_, err = dao.Get(ctx, finalized.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestDAO_DeleteWithFinalizers(t *testing.T) {
+	db := setupTestDB(t)
+	dao := NewDAO[apiv1.User](db)
+
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	user.Finalizers = []string{"cleanup", "audit"}
+	err := dao.Create(context.Background(), user)
+	assert.NoError(t, err)
+
+	// Delete only marks the resource
+	err = dao.Delete(context.Background(), user.ID, 0)
+	assert.NoError(t, err)
+
+	found, err := dao.Get(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, found.DeletionTimestamp)
+
+	// Removing one finalizer keeps the resource
+	err = dao.RemoveFinalizer(context.Background(), user.ID, "cleanup")
+	assert.NoError(t, err)
+
+	found, err = dao.Get(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"audit"}, found.Finalizers)
+
+	// Removing the last finalizer deletes it
+	err = dao.RemoveFinalizer(context.Background(), user.ID, "audit")
+	assert.NoError(t, err)
+
+	_, err = dao.Get(context.Background(), user.ID)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestDAO_RemoveFinalizerWithoutDeletion(t *testing.T) {
+	db := setupTestDB(t)
+	dao := NewDAO[apiv1.User](db)
+
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	user.Finalizers = []string{"cleanup"}
+	err := dao.Create(context.Background(), user)
+	assert.NoError(t, err)
+
+	// Without a pending deletion the resource stays
+	err = dao.RemoveFinalizer(context.Background(), user.ID, "cleanup")
+	assert.NoError(t, err)
+
+	found, err := dao.Get(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, found.Finalizers)
+	assert.Nil(t, found.DeletionTimestamp)
+}
+
+func TestDAO_DeleteConflict(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	dao := NewDAO[apiv1.User](db)
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	assert.NoError(t, dao.Create(context.Background(), user))
+
+	// A stale expected version leaves the resource in place
+	assert.ErrorIs(t, dao.Delete(context.Background(), user.ID, 2), ErrConflict)
+	_, err := dao.Get(context.Background(), user.ID)
+	assert.NoError(t, err)
+
+	assert.NoError(t, dao.Delete(context.Background(), user.ID, 1))
+	_, err = dao.Get(context.Background(), user.ID)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestDAO_TransactionRetriesBusy(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[TestModel](db)
+	locked := errors.New("database is locked")
+
+	// Busy attempts are retried and only the successful one is committed
+	attempts := 0
+	err := dao.Transaction(context.Background(), func(tx *gorm.DB) error {
+		attempts++
+		if err := tx.Create(&TestModel{Name: fmt.Sprintf("attempt%d", attempts)}).Error; err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return locked
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	var names []string
+	assert.NoError(t, db.Model(&TestModel{}).Pluck("name", &names).Error)
+	assert.Equal(t, []string{"attempt3"}, names)
+
+	// A database that stays busy gives up after the retries
+	attempts = 0
+	err = dao.Transaction(context.Background(), func(tx *gorm.DB) error {
+		attempts++
+		return locked
+	})
+	assert.ErrorIs(t, err, ErrBusy)
+	assert.Equal(t, maxBusyRetries+1, attempts)
+
+	// Other errors are not retried
+	attempts = 0
+	err = dao.Transaction(context.Background(), func(tx *gorm.DB) error {
+		attempts++
+		return gorm.ErrRecordNotFound
+	})
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestDAO_BelongsTo(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&apiv1.APIKey{}))
+	ctx := context.Background()
+
+	dao := NewDAO[apiv1.APIKey](db)
+	_, err := dao.BelongsTo("owner", 1)
+	assert.Error(t, err)
+
+	first, err := dao.BelongsTo("userId", 1)
+	require.NoError(t, err)
+	second, err := dao.BelongsTo("user_id", 2)
+	require.NoError(t, err)
+
+	key := &apiv1.APIKey{Label: "first", UserID: 2}
+	require.NoError(t, first.Create(ctx, key))
+	assert.Equal(t, uint(1), key.UserID)
+	otherKey := &apiv1.APIKey{Label: "second"}
+	require.NoError(t, second.Create(ctx, otherKey))
+
+	// Reads only find the children of the parent
+	_, err = second.Get(ctx, key.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	count, err := second.Count(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// Writes treat the children of other parents as missing
+	err = second.Update(ctx, key.ID, &apiv1.APIKey{Label: "moved"}, 0)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, second.Delete(ctx, key.ID, 0), gorm.ErrRecordNotFound)
+	updated, err := second.BulkUpdate(ctx, []uint{key.ID, otherKey.ID}, map[string]interface{}{"label": "bulk"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), updated)
+	result, err := second.DeleteMany(ctx, []uint{key.ID})
+	require.NoError(t, err)
+	assert.Zero(t, result.Deleted)
+	assert.Equal(t, []uint{key.ID}, result.NotFound)
+
+	stored, err := dao.Get(ctx, key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "first", stored.Label)
+
+	// Updates keep children with their parent
+	require.NoError(t, first.Update(ctx, key.ID, &apiv1.APIKey{Label: "renamed", UserID: 2}, 0))
+	stored, err = dao.Get(ctx, key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", stored.Label)
+	assert.Equal(t, uint(1), stored.UserID)
+}
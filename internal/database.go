@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DatabaseDriver names a supported database
+type DatabaseDriver string
+
+const (
+	DriverSQLite   DatabaseDriver = "sqlite"
+	DriverPostgres DatabaseDriver = "postgres"
+	DriverMySQL    DatabaseDriver = "mysql"
+)
+
+// DatabaseConfig selects the database opened by OpenDatabase and tunes its
+// connections
+type DatabaseConfig struct {
+	// Driver selects the database, see DatabaseDriver
+	Driver DatabaseDriver `yaml:"driver" default:"sqlite"`
+
+	// Path is the SQLite file, or the DSN for the other drivers
+	Path string `yaml:"path" default:"app.db"`
+
+	// Connection pool settings; zero keeps the database/sql default
+	MaxOpenConns           int `yaml:"maxOpenConns" default:"0"`
+	MaxIdleConns           int `yaml:"maxIdleConns" default:"0"`
+	ConnMaxLifetimeSeconds int `yaml:"connMaxLifetimeSeconds" default:"0"`
+	ConnMaxIdleTimeSeconds int `yaml:"connMaxIdleTimeSeconds" default:"0"`
+
+	// SQLite settings; WAL lets readers run alongside a writer and the
+	// busy timeout makes concurrent writers wait for the lock
+	JournalMode       string `yaml:"journalMode" default:"WAL"`
+	BusyTimeoutMillis int    `yaml:"busyTimeoutMillis" default:"5000"`
+
+	// SlowQueryThresholdMs is the duration above which queries are
+	// logged as warnings; zero turns slow query warnings off
+	SlowQueryThresholdMs int `yaml:"slowQueryThresholdMs" default:"200"`
+}
+
+// OpenDatabase connects to the database selected by config and applies the
+// connection pool settings. Path is the file name for SQLite and the DSN
+// for the other drivers. Queries are logged through slog.Default at
+// logLevel, as warnings when slower than SlowQueryThresholdMs.
+func OpenDatabase(config DatabaseConfig, logLevel string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch config.Driver {
+	case DriverSQLite, "":
+		dialector = sqlite.Open(sqliteDSN(config))
+	case DriverPostgres:
+		dialector = postgres.Open(config.Path)
+	case DriverMySQL:
+		dialector = newMySQLDialector(config.Path)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q, supported drivers are: %s, %s, %s",
+			config.Driver, DriverSQLite, DriverPostgres, DriverMySQL)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger: NewGormLoggerWithConfig(slog.Default(), logger.Config{
+			SlowThreshold: time.Duration(config.SlowQueryThresholdMs) * time.Millisecond,
+			LogLevel:      gormLogLevel(logLevel),
+		}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := configurePool(db, config); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// sqliteDSN adds the journal mode and busy timeout to the SQLite file name.
+// They are passed as DSN parameters rather than PRAGMA statements so that
+// every connection of the pool applies them.
+func sqliteDSN(config DatabaseConfig) string {
+	params := url.Values{}
+	if config.JournalMode != "" {
+		params.Set("_journal_mode", strings.ToUpper(config.JournalMode))
+	}
+	if config.BusyTimeoutMillis > 0 {
+		params.Set("_busy_timeout", strconv.Itoa(config.BusyTimeoutMillis))
+	}
+	if len(params) == 0 {
+		return config.Path
+	}
+
+	separator := "?"
+	if strings.Contains(config.Path, "?") {
+		separator = "&"
+	}
+	return config.Path + separator + params.Encode()
+}
+
+// configurePool applies the connection pool settings to db. Zero values
+// leave the database/sql defaults in place.
+func configurePool(db *gorm.DB, pool DatabaseConfig) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	if pool.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetimeSeconds > 0 {
+		sqlDB.SetConnMaxLifetime(time.Duration(pool.ConnMaxLifetimeSeconds) * time.Second)
+	}
+	if pool.ConnMaxIdleTimeSeconds > 0 {
+		sqlDB.SetConnMaxIdleTime(time.Duration(pool.ConnMaxIdleTimeSeconds) * time.Second)
+	}
+	return nil
+}
+
+// gormLogLevel maps the configured log level to the GORM logger level. Only
+// the debug level traces every SQL statement; the others log slow and
+// failed queries.
+func gormLogLevel(level string) logger.LogLevel {
+	switch level {
+	case "silent":
+		return logger.Silent
+	case "error":
+		return logger.Error
+	case "debug":
+		return logger.Info
+	default:
+		return logger.Warn
+	}
+}
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/logger"
+)
+
+func TestOpenDatabase(t *testing.T) {
+	config := DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db"), MaxOpenConns: 7}
+
+	db, err := OpenDatabase(config, "silent")
+	assert.NoError(t, err)
+	defer cleanupTestDB(t, db)
+
+	assert.Equal(t, "sqlite", db.Dialector.Name())
+
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	assert.Equal(t, 7, sqlDB.Stats().MaxOpenConnections)
+}
+
+func TestOpenDatabase_SQLiteSettings(t *testing.T) {
+	config := DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db"), JournalMode: "WAL", BusyTimeoutMillis: 1234}
+
+	db, err := OpenDatabase(config, "silent")
+	require.NoError(t, err)
+	defer cleanupTestDB(t, db)
+
+	var journalMode string
+	require.NoError(t, db.Raw("PRAGMA journal_mode").Scan(&journalMode).Error)
+	assert.Equal(t, "wal", journalMode)
+
+	var busyTimeout int
+	require.NoError(t, db.Raw("PRAGMA busy_timeout").Scan(&busyTimeout).Error)
+	assert.Equal(t, 1234, busyTimeout)
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		journalMode string
+		busyTimeout int
+		want        string
+	}{
+		{"defaults", "app.db", "WAL", 5000, "app.db?_busy_timeout=5000&_journal_mode=WAL"},
+		{"existing parameters", "file:app.db?cache=shared", "wal", 100, "file:app.db?cache=shared&_busy_timeout=100&_journal_mode=WAL"},
+		{"nothing to add", "app.db", "", 0, "app.db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DatabaseConfig{Path: tt.path, JournalMode: tt.journalMode, BusyTimeoutMillis: tt.busyTimeout}
+			assert.Equal(t, tt.want, sqliteDSN(config))
+		})
+	}
+}
+
+func TestOpenDatabase_PoolSettings(t *testing.T) {
+	config := DatabaseConfig{
+		Path:                   filepath.Join(t.TempDir(), "test.db"),
+		MaxOpenConns:           3,
+		MaxIdleConns:           1,
+		ConnMaxLifetimeSeconds: 300,
+		ConnMaxIdleTimeSeconds: 60,
+	}
+
+	db, err := OpenDatabase(config, "silent")
+	require.NoError(t, err)
+	defer cleanupTestDB(t, db)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	assert.Equal(t, 3, sqlDB.Stats().MaxOpenConnections)
+
+	// Releasing more connections than the idle limit closes the surplus
+	ctx := context.Background()
+	first, err := sqlDB.Conn(ctx)
+	require.NoError(t, err)
+	second, err := sqlDB.Conn(ctx)
+	require.NoError(t, err)
+	require.NoError(t, first.Close())
+	require.NoError(t, second.Close())
+
+	stats := sqlDB.Stats()
+	assert.Equal(t, 1, stats.Idle)
+	assert.Equal(t, int64(1), stats.MaxIdleClosed)
+}
+
+func TestOpenDatabase_DefaultPool(t *testing.T) {
+	config := DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")}
+
+	db, err := OpenDatabase(config, "silent")
+	require.NoError(t, err)
+	defer cleanupTestDB(t, db)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+
+	// Zero values keep the database/sql defaults: unlimited open and two
+	// idle connections
+	assert.Equal(t, 0, sqlDB.Stats().MaxOpenConnections)
+
+	ctx := context.Background()
+	conns := make([]interface{ Close() error }, 3)
+	for i := range conns {
+		conn, err := sqlDB.Conn(ctx)
+		require.NoError(t, err)
+		conns[i] = conn
+	}
+	for _, conn := range conns {
+		require.NoError(t, conn.Close())
+	}
+	assert.Equal(t, 2, sqlDB.Stats().Idle)
+}
+
+func TestOpenDatabase_UnsupportedDriver(t *testing.T) {
+	db, err := OpenDatabase(DatabaseConfig{Driver: "oracle", Path: "app.db"}, "silent")
+	assert.Nil(t, db)
+	assert.ErrorContains(t, err, `unsupported database driver "oracle"`)
+}
+
+func TestGormLogLevel(t *testing.T) {
+	assert.Equal(t, logger.Info, gormLogLevel("debug"))
+	assert.Equal(t, logger.Warn, gormLogLevel("info"))
+	assert.Equal(t, logger.Warn, gormLogLevel("warn"))
+	assert.Equal(t, logger.Error, gormLogLevel("error"))
+	assert.Equal(t, logger.Silent, gormLogLevel("silent"))
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrConflict is returned when a write is rejected because the stored
+// resource has changed since the caller last read it, or because it would
+// duplicate a unique value of another resource
+var ErrConflict = errors.New("resource version conflict")
+
+// ErrUnknownField is returned when a field name given to the DAO is not a
+// column of the resource
+var ErrUnknownField = errors.New("unknown field")
+
+// ErrInvalidPatch is returned when a bulk update sets a field that cannot
+// be updated or a value of the wrong type
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// ErrInvalidAggregation is returned when an aggregation uses an unknown
+// function or an invalid alias
+var ErrInvalidAggregation = errors.New("invalid aggregation")
+
+// ErrNotUniqueKey is returned when the conflict columns given to Upsert
+// are not a unique key of the resource
+var ErrNotUniqueKey = errors.New("not a unique key")
+
+// ErrImportRolledBack is returned by DAO.ImportAll when a resource failed
+// to import, so that none was stored
+var ErrImportRolledBack = errors.New("import rolled back")
+
+// BulkDeleteError reports the resource that stopped a bulk delete, which
+// deleted nothing
+type BulkDeleteError struct {
+	// ID is the resource that could not be deleted
+	ID uint
+
+	// Err is the error of its BeforeDelete hook, or ErrOwnerDeletionBlocked
+	Err error
+}
+
+// Error implements the error interface
+func (e *BulkDeleteError) Error() string {
+	return fmt.Sprintf("resource %d cannot be deleted: %v", e.ID, e.Err)
+}
+
+// Unwrap returns the error of the resource
+func (e *BulkDeleteError) Unwrap() error {
+	return e.Err
+}
+
+// ErrBusy is returned when a write keeps failing because the database is
+// locked by other writers
+var ErrBusy = errors.New("database is busy, retry later")
+
+const (
+	// maxBusyRetries is how often a transaction is retried while the
+	// database is busy
+	maxBusyRetries = 3
+
+	// busyRetryDelay is the delay before the first retry, growing linearly
+	busyRetryDelay = 50 * time.Millisecond
+)
+
+// UniqueViolationError reports a write rejected by a unique constraint. It
+// matches ErrConflict with errors.Is.
+type UniqueViolationError struct {
+	// Field is the column holding the duplicated value
+	Field string
+
+	// Err is the original driver error
+	Err error
+}
+
+// Error implements the error interface
+func (e *UniqueViolationError) Error() string {
+	return fmt.Sprintf("a resource with this %s already exists", e.Field)
+}
+
+// Unwrap returns the original driver error
+func (e *UniqueViolationError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrConflict
+func (e *UniqueViolationError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+var (
+	// sqliteUniquePattern matches "UNIQUE constraint failed: users.email"
+	sqliteUniquePattern = regexp.MustCompile(`UNIQUE constraint failed: \w+\.(\w+)`)
+
+	// postgresUniquePattern matches `violates unique constraint "idx_users_email"`
+	postgresUniquePattern = regexp.MustCompile(`violates unique constraint "([^"]+)"`)
+
+	// mysqlUniquePattern matches "Duplicate entry 'x' for key 'users.idx_users_email'"
+	mysqlUniquePattern = regexp.MustCompile(`Duplicate entry .* for key '([^']+)'`)
+)
+
+// translateError converts driver specific unique constraint violations from
+// SQLite, PostgreSQL and MySQL into a UniqueViolationError. Other errors are
+// returned unchanged.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	message := err.Error()
+	if match := sqliteUniquePattern.FindStringSubmatch(message); match != nil {
+		return &UniqueViolationError{Field: match[1], Err: err}
+	}
+
+	// PostgreSQL and MySQL only name the index, which GORM names
+	// idx_<table>_<column> or uni_<table>_<column>
+	for _, pattern := range []*regexp.Regexp{postgresUniquePattern, mysqlUniquePattern} {
+		if match := pattern.FindStringSubmatch(message); match != nil {
+			index := match[1]
+			return &UniqueViolationError{Field: index[strings.LastIndex(index, "_")+1:], Err: err}
+		}
+	}
+	return err
+}
+
+// isBusyError reports whether err is a SQLite SQLITE_BUSY or SQLITE_LOCKED
+// failure, which succeeds when retried once other writers finish
+func isBusyError(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := err.Error()
+	return strings.Contains(message, "database is locked") ||
+		strings.Contains(message, "database table is locked") ||
+		strings.Contains(message, "SQLITE_BUSY")
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateError(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		field string
+	}{
+		{"sqlite", errors.New("UNIQUE constraint failed: users.email"), "email"},
+		{"postgres", errors.New(`ERROR: duplicate key value violates unique constraint "idx_users_username" (SQLSTATE 23505)`), "username"},
+		{"mysql", errors.New("Error 1062 (23000): Duplicate entry 'a@b.c' for key 'users.uni_users_email'"), "email"},
+		{"other", errors.New("disk I/O error"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := translateError(tt.err)
+
+			var unique *UniqueViolationError
+			if tt.field == "" {
+				assert.False(t, errors.As(err, &unique))
+				assert.Equal(t, tt.err, err)
+				return
+			}
+			assert.True(t, errors.As(err, &unique))
+			assert.Equal(t, tt.field, unique.Field)
+			assert.ErrorIs(t, err, ErrConflict)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestIsBusyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		busy bool
+	}{
+		{nil, false},
+		{errors.New("database is locked"), true},
+		{errors.New("database table is locked: users"), true},
+		{errors.New("SQLITE_BUSY: cannot commit"), true},
+		{errors.New("UNIQUE constraint failed: users.email"), false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.busy, isBusyError(tt.err), "%v", tt.err)
+	}
+}
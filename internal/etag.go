@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ETag returns the entity tag for a resource version, e.g. "v3"
+func ETag(version int) string {
+	return fmt.Sprintf(`"v%d"`, version)
+}
+
+// ListETag returns a weak entity tag for a page of a list response, hashed
+// from the ID and resource version of each item and the total number of
+// resources the list matches. It changes when a resource of the page is
+// updated, and when resources are created or deleted.
+func ListETag[T any](items []T, total int64) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d", total)
+	for i := range items {
+		fmt.Fprintf(hash, "|%d:%d", resourceID(&items[i]), resourceVersion(&items[i]))
+	}
+	return fmt.Sprintf(`W/"%s"`, hex.EncodeToString(hash.Sum(nil)[:8]))
+}
+
+// checkIfNoneMatch sets the ETag header and reports whether the request's
+// If-None-Match header matches it, in which case 304 Not Modified has been
+// written and the body must be omitted
+func checkIfNoneMatch(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	ifNoneMatch := c.GetHeader("If-None-Match")
+	if ifNoneMatch == "" || !etagMatches(ifNoneMatch, etag) {
+		return false
+	}
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header value
+// matches etag. The header may hold a comma separated list of tags or "*".
+// Weak tags compare equal to their strong counterparts.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch evaluates the If-Match header of an update or delete
+// request against the stored resource. It returns the version the change
+// must be made against, or 0 when the request has no If-Match header.
+// "If-Match: *" only requires the resource to exist. When the precondition
+// fails the response is written and ok is false.
+func (r *Router[T]) checkIfMatch(c *gin.Context, id uint) (version int, ok bool) {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		return 0, true
+	}
+
+	current, err := r.dao.Get(c.Request.Context(), id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			// No current representation can match any tag
+			writeStatus(c, meta.Status{
+				Code:    http.StatusPreconditionFailed,
+				Reason:  meta.StatusReasonPreconditionFailed,
+				Message: "precondition failed: resource not found",
+			})
+			return 0, false
+		}
+		writeInternalError(c, err)
+		return 0, false
+	}
+
+	if !etagMatches(ifMatch, ETag(resourceVersion(current))) {
+		writeStatus(c, meta.Status{
+			Code:    http.StatusPreconditionFailed,
+			Reason:  meta.StatusReasonPreconditionFailed,
+			Message: "precondition failed",
+			Current: current,
+		})
+		return 0, false
+	}
+	return resourceVersion(current), true
+}
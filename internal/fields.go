@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// alwaysSelectedFields are included in every sparse response so that
+// clients can still reference the objects
+var alwaysSelectedFields = []string{"id"}
+
+// jsonFields returns the JSON names of the fields of t that can be selected,
+// including the fields of nested objects such as metadata
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	collectJSONFields(t, fields, true)
+	return fields
+}
+
+// collectJSONFields adds the JSON field names of t to fields. Nested named
+// structs embedded in the top level, such as ObjectMeta, contribute their
+// own fields as well when descend is set.
+func collectJSONFields(t reflect.Type, fields map[string]bool, descend bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		if field.Anonymous && name == "" {
+			collectJSONFields(field.Type, fields, descend)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = true
+
+		if field.Anonymous && descend {
+			collectJSONFields(field.Type, fields, false)
+		}
+	}
+}
+
+// parseFields parses a comma separated field selection for resources of
+// type T. It returns nil when raw is empty.
+func parseFields[T any](raw string) (map[string]bool, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	valid := jsonFields(reflect.TypeOf(new(T)))
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if !valid[name] {
+			names := make([]string, 0, len(valid))
+			for field := range valid {
+				names = append(names, field)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown field %q, valid fields are: %s", name, strings.Join(names, ", "))
+		}
+		selected[name] = true
+	}
+	for _, name := range alwaysSelectedFields {
+		selected[name] = true
+	}
+	return selected, nil
+}
+
+// selectColumns returns the columns to load for a field selection, which
+// include the resource version used by ETags. It returns nil, loading every
+// column, when fields is nil or names a field not stored in a single
+// column, such as metadata.
+func selectColumns[T any](dao *DAO[T], fields map[string]bool) []string {
+	if fields == nil {
+		return nil
+	}
+
+	columns := make([]string, 0, len(fields)+1)
+	for name := range fields {
+		column, ok := dao.Column(name)
+		if !ok {
+			return nil
+		}
+		columns = append(columns, column)
+	}
+	if column, ok := dao.Column("resourceVersion"); ok && !slices.Contains(columns, column) {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// selectFields returns the JSON object of resource reduced to the selected
+// fields. Nested objects keep only their selected fields.
+func selectFields(resource any, fields map[string]bool) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(encoded, &object); err != nil {
+		return nil, err
+	}
+	return filterObject(object, fields), nil
+}
+
+// filterObject keeps the selected keys of object and the selected keys of
+// its nested objects
+func filterObject(object map[string]interface{}, fields map[string]bool) map[string]interface{} {
+	filtered := make(map[string]interface{})
+	for key, value := range object {
+		if fields[key] {
+			filtered[key] = value
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			if kept := filterObject(nested, fields); len(kept) > 0 {
+				filtered[key] = kept
+			}
+		}
+	}
+	return filtered
+}
+
+// selectFieldsList applies selectFields to every item
+func selectFieldsList[T any](items []T, fields map[string]bool) ([]map[string]interface{}, error) {
+	selected := make([]map[string]interface{}, 0, len(items))
+	for i := range items {
+		object, err := selectFields(&items[i], fields)
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, object)
+	}
+	return selected, nil
+}
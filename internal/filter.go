@@ -0,0 +1,265 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// FilterOperator compares a column with the value of a Filter
+type FilterOperator string
+
+const (
+	FilterEq   FilterOperator = "eq"
+	FilterNe   FilterOperator = "ne"
+	FilterGt   FilterOperator = "gt"
+	FilterGte  FilterOperator = "gte"
+	FilterLt   FilterOperator = "lt"
+	FilterLte  FilterOperator = "lte"
+	FilterLike FilterOperator = "like"
+	FilterIn   FilterOperator = "in"
+)
+
+// Filter restricts a list to the resources whose field compares with a
+// value
+type Filter struct {
+	// Field is the JSON or column name of the field to compare, see
+	// DAO.Column
+	Field string
+
+	// Operator is the comparison
+	Operator FilterOperator
+
+	// Value is compared with the field, a []interface{} for FilterIn
+	Value interface{}
+}
+
+// Filters restricts a list to the resources matching all its filters.
+// Pass it to DAO.List or apply it with DAO.Scope(filters.Scope), which
+// takes the fields for column names.
+type Filters []Filter
+
+// Scope adds the filters to a query as parameterized WHERE clauses
+func (f Filters) Scope(db *gorm.DB) *gorm.DB {
+	for _, filter := range f {
+		db = db.Where(filter.expression())
+	}
+	return db
+}
+
+// expression is the clause of the filter
+func (c Filter) expression() clause.Expression {
+	column := clause.Column{Name: c.Field}
+	switch c.Operator {
+	case FilterNe:
+		return clause.Neq{Column: column, Value: c.Value}
+	case FilterGt:
+		return clause.Gt{Column: column, Value: c.Value}
+	case FilterGte:
+		return clause.Gte{Column: column, Value: c.Value}
+	case FilterLt:
+		return clause.Lt{Column: column, Value: c.Value}
+	case FilterLte:
+		return clause.Lte{Column: column, Value: c.Value}
+	case FilterLike:
+		return clause.Like{Column: column, Value: c.Value}
+	case FilterIn:
+		values, _ := c.Value.([]interface{})
+		return clause.IN{Column: column, Values: values}
+	default:
+		return clause.Eq{Column: column, Value: c.Value}
+	}
+}
+
+// FilterableResource is implemented by resources restricting the fields
+// and operators of list filters. FilterOperators maps the JSON names of the
+// filterable fields to their operators, such as "eq" or "like"; fields left
+// out cannot be filtered on.
+type FilterableResource interface {
+	FilterOperators() map[string][]string
+}
+
+var (
+	// orderedFilterOperators apply to numbers and times
+	orderedFilterOperators = []FilterOperator{FilterEq, FilterNe, FilterGt, FilterGte, FilterLt, FilterLte, FilterIn}
+
+	// stringFilterOperators apply to strings
+	stringFilterOperators = append(slices.Clone(orderedFilterOperators), FilterLike)
+
+	// boolFilterOperators apply to booleans
+	boolFilterOperators = []FilterOperator{FilterEq, FilterNe}
+)
+
+// defaultFilterOperators are the operators allowed on a column of Go type t
+// of resources that do not implement FilterableResource
+func defaultFilterOperators(t reflect.Type) []FilterOperator {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeFor[time.Time]() {
+		return orderedFilterOperators
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return stringFilterOperators
+	case reflect.Bool:
+		return boolFilterOperators
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return orderedFilterOperators
+	default:
+		return nil
+	}
+}
+
+// FilterParameter is the query parameter holding filters written as
+// field__operator=value, such as filter=createdAt__gte=2024-01-01, which
+// may be repeated. It is an alternative to the operator syntax
+// createdAt[gte]=2024-01-01; the operator defaults to eq.
+const FilterParameter = "filter"
+
+// parseFilterOperators parses the query parameters using the operator
+// syntax, such as createdAt[gte]=2024-01-01T00:00:00Z or id[in]=1,2,3, and
+// those of FilterParameter into Filters. Other parameters are ignored.
+// Fields and operators must be allowed for the resource, and values are
+// converted to the type of the column; errors name the offending
+// parameter.
+func parseFilterOperators[T any](dao *DAO[T], values url.Values) (Filters, error) {
+	values, err := expandFilterParameter(values)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := resourceFilterOperators[T]()
+
+	var filter Filters
+	for _, param := range sortedKeys(values) {
+		name, op, ok := strings.Cut(param, "[")
+		if !ok {
+			continue
+		}
+		op, ok = strings.CutSuffix(op, "]")
+		if !ok {
+			return nil, fmt.Errorf("invalid filter parameter %q", param)
+		}
+		operator := FilterOperator(op)
+
+		field, ok := dao.field(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown filter field in parameter %q", param)
+		}
+		raw := []string{values.Get(param)}
+		if operator == FilterIn {
+			raw = strings.Split(raw[0], ",")
+		}
+		condition, err := newFilter(field, allowed, operator, raw, fmt.Sprintf("filter parameter %q", param))
+		if err != nil {
+			return nil, err
+		}
+		filter = append(filter, condition)
+	}
+	return filter, nil
+}
+
+// resourceFilterOperators returns the FilterOperators of T, nil if it does
+// not implement FilterableResource
+func resourceFilterOperators[T any]() map[string][]string {
+	if filterable, ok := any(new(T)).(FilterableResource); ok {
+		return filterable.FilterOperators()
+	}
+	return nil
+}
+
+// filterableOperators returns the operators allowed on field by allowed,
+// the FilterOperators of the resource, or by default for its type when
+// the resource does not restrict them
+func filterableOperators(field *schema.Field, allowed map[string][]string) []FilterOperator {
+	if allowed == nil {
+		return defaultFilterOperators(field.FieldType)
+	}
+	jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	var permitted []FilterOperator
+	for _, op := range allowed[jsonName] {
+		permitted = append(permitted, FilterOperator(op))
+	}
+	return permitted
+}
+
+// newFilter checks that operator is allowed on field and converts raw, the
+// values of a FilterIn filter or the single value of another one, to the
+// type of the column. Errors name the filter with source.
+func newFilter(field *schema.Field, allowed map[string][]string, operator FilterOperator, raw []string, source string) (Filter, error) {
+	if !slices.Contains(filterableOperators(field, allowed), operator) {
+		return Filter{}, fmt.Errorf("operator %q is not allowed in %s", operator, source)
+	}
+
+	var value interface{}
+	switch operator {
+	case FilterLike:
+		value = raw[0]
+	case FilterIn:
+		var items []interface{}
+		for _, item := range raw {
+			converted, err := filterValue(field.FieldType, strings.TrimSpace(item))
+			if err != nil {
+				return Filter{}, fmt.Errorf("invalid value %q for %s", item, source)
+			}
+			items = append(items, converted)
+		}
+		value = items
+	default:
+		converted, err := filterValue(field.FieldType, raw[0])
+		if err != nil {
+			return Filter{}, fmt.Errorf("invalid value %q for %s", raw[0], source)
+		}
+		value = converted
+	}
+	return Filter{Field: field.DBName, Operator: operator, Value: value}, nil
+}
+
+// expandFilterParameter rewrites the filters of FilterParameter in the
+// operator syntax, so that filter=id__lte=100 reads as id[lte]=100
+func expandFilterParameter(values url.Values) (url.Values, error) {
+	filters := values[FilterParameter]
+	if len(filters) == 0 {
+		return values, nil
+	}
+
+	expanded := make(url.Values, len(values)+len(filters))
+	for key, value := range values {
+		if key != FilterParameter {
+			expanded[key] = value
+		}
+	}
+	for _, filter := range filters {
+		expression, value, ok := strings.Cut(filter, "=")
+		if !ok || expression == "" {
+			return nil, fmt.Errorf("invalid filter %q, expected field__operator=value", filter)
+		}
+		name, op, ok := strings.Cut(expression, "__")
+		if !ok {
+			op = string(FilterEq)
+		}
+		expanded.Add(name+"["+op+"]", value)
+	}
+	return expanded, nil
+}
+
+// sortedKeys returns the parameter names of values in order, so that
+// queries are built deterministically
+func sortedKeys(values url.Values) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,467 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FilterExpressionParameter is the query parameter of list requests holding
+// a FilterExpression, either JSON encoded or in the DSL of
+// ExpressionParser.Parse
+const FilterExpressionParameter = "filterExpr"
+
+// MaxFilterExpressionDepth is how deeply the And and Or groups of a parsed
+// FilterExpression may nest, bounding the cost of building its query
+const MaxFilterExpressionDepth = 3
+
+// FilterExpression restricts a list to the resources matching a tree of
+// conditions combined with And and Or. A Filter is a leaf of the
+// tree. Apply it with DAO.Scope(expr.ToGORM).
+type FilterExpression interface {
+	// ToGORM adds the expression to a query as a parameterized WHERE
+	// clause
+	ToGORM(db *gorm.DB) *gorm.DB
+
+	// condition is the clause of the expression, nil if it matches every
+	// resource
+	condition() clause.Expression
+
+	// depth is the number of nested groups of the expression
+	depth() int
+}
+
+// ToGORM adds the condition to a query
+func (c Filter) ToGORM(db *gorm.DB) *gorm.DB {
+	return db.Where(c.expression())
+}
+
+func (c Filter) condition() clause.Expression {
+	return c.expression()
+}
+
+func (c Filter) depth() int {
+	return 0
+}
+
+// filterGroup combines expressions with AND, or with OR if or is set
+type filterGroup struct {
+	or    bool
+	exprs []FilterExpression
+}
+
+// And returns an expression matching the resources matching every one of
+// exprs, or every resource if exprs is empty
+func And(exprs ...FilterExpression) FilterExpression {
+	return filterGroup{exprs: exprs}
+}
+
+// Or returns an expression matching the resources matching any of exprs,
+// or every resource if exprs is empty
+func Or(exprs ...FilterExpression) FilterExpression {
+	return filterGroup{or: true, exprs: exprs}
+}
+
+// ToGORM adds the group to a query
+func (g filterGroup) ToGORM(db *gorm.DB) *gorm.DB {
+	if condition := g.condition(); condition != nil {
+		return db.Where(condition)
+	}
+	return db
+}
+
+func (g filterGroup) condition() clause.Expression {
+	var exprs []clause.Expression
+	for _, expr := range g.exprs {
+		if condition := expr.condition(); condition != nil {
+			exprs = append(exprs, condition)
+		}
+	}
+	if len(exprs) == 0 {
+		return nil
+	}
+	join := " AND "
+	if g.or {
+		join = " OR "
+	}
+	return groupClause{join: join, exprs: exprs}
+}
+
+func (g filterGroup) depth() int {
+	deepest := 0
+	for _, expr := range g.exprs {
+		deepest = max(deepest, expr.depth())
+	}
+	return deepest + 1
+}
+
+// groupClause builds the conditions of a group joined by join and always
+// in parentheses. Unlike clause.Or, which a WHERE clause may reorder, it
+// keeps the precedence of the tree.
+type groupClause struct {
+	join  string
+	exprs []clause.Expression
+}
+
+// Build writes the clause
+func (g groupClause) Build(builder clause.Builder) {
+	builder.WriteByte('(')
+	for i, expr := range g.exprs {
+		if i > 0 {
+			builder.WriteString(g.join)
+		}
+		expr.Build(builder)
+	}
+	builder.WriteByte(')')
+}
+
+// errFilterExpressionDepth is returned for expressions nested deeper than
+// MaxFilterExpressionDepth
+var errFilterExpressionDepth = fmt.Errorf("filter expression is nested deeper than %d levels", MaxFilterExpressionDepth)
+
+// ExpressionParser parses filter expressions on resources of type T from
+// JSON or from a query string DSL. Fields and operators must be allowed for
+// the resource, as in the operator syntax of list filters, and values are
+// converted to the type of their column.
+type ExpressionParser[T any] struct {
+	dao     *DAO[T]
+	allowed map[string][]string
+}
+
+// NewExpressionParser returns a parser naming the fields of dao
+func NewExpressionParser[T any](dao *DAO[T]) *ExpressionParser[T] {
+	return &ExpressionParser[T]{dao: dao, allowed: resourceFilterOperators[T]()}
+}
+
+// filterExpressionJSON is the JSON form of a FilterExpression: a group with
+// and or or, or a condition with field, op, defaulting to eq, and value,
+// an array for the in operator
+type filterExpressionJSON struct {
+	And   []json.RawMessage `json:"and"`
+	Or    []json.RawMessage `json:"or"`
+	Field string            `json:"field"`
+	Op    FilterOperator    `json:"op"`
+	Value json.RawMessage   `json:"value"`
+}
+
+// ParseJSON parses a JSON encoded expression, such as
+//
+//	{"and": [{"or": [{"field": "phase", "value": "Active"},
+//	                 {"field": "phase", "value": "Pending"}]},
+//	         {"field": "email", "op": "like", "value": "%@example.com"}]}
+func (p *ExpressionParser[T]) ParseJSON(data []byte) (FilterExpression, error) {
+	return p.parseJSON(data, 0)
+}
+
+// parseJSON parses an expression found inside level groups
+func (p *ExpressionParser[T]) parseJSON(data []byte, level int) (FilterExpression, error) {
+	var encoded filterExpressionJSON
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&encoded); err != nil {
+		return nil, fmt.Errorf("invalid filter expression: %w", err)
+	}
+
+	var members []json.RawMessage
+	var group filterGroup
+	switch {
+	case encoded.And != nil && encoded.Or == nil && encoded.Field == "":
+		members = encoded.And
+	case encoded.Or != nil && encoded.And == nil && encoded.Field == "":
+		members, group.or = encoded.Or, true
+	case encoded.And == nil && encoded.Or == nil && encoded.Field != "":
+		return p.parseJSONCondition(encoded)
+	default:
+		return nil, errors.New("invalid filter expression: expected one of and, or and field")
+	}
+
+	if level == MaxFilterExpressionDepth {
+		return nil, errFilterExpressionDepth
+	}
+	for _, member := range members {
+		expr, err := p.parseJSON(member, level+1)
+		if err != nil {
+			return nil, err
+		}
+		group.exprs = append(group.exprs, expr)
+	}
+	return group, nil
+}
+
+// parseJSONCondition converts the condition of a JSON expression
+func (p *ExpressionParser[T]) parseJSONCondition(encoded filterExpressionJSON) (FilterExpression, error) {
+	operator := encoded.Op
+	if operator == "" {
+		operator = FilterEq
+	}
+	var raw []string
+	if operator == FilterIn {
+		var items []json.RawMessage
+		if err := json.Unmarshal(encoded.Value, &items); err != nil {
+			return nil, fmt.Errorf("invalid value for filter expression field %q: expected an array", encoded.Field)
+		}
+		for _, item := range items {
+			value, err := jsonScalar(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for filter expression field %q: %w", encoded.Field, err)
+			}
+			raw = append(raw, value)
+		}
+	} else {
+		value, err := jsonScalar(encoded.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for filter expression field %q: %w", encoded.Field, err)
+		}
+		raw = []string{value}
+	}
+	return p.condition(encoded.Field, operator, raw)
+}
+
+// jsonScalar returns a JSON string, number or boolean as the text filter
+// values are parsed from
+func jsonScalar(data json.RawMessage) (string, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return "", errors.New("expected a string, number or boolean")
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64, bool:
+		return string(bytes.TrimSpace(data)), nil
+	default:
+		return "", errors.New("expected a string, number or boolean")
+	}
+}
+
+// condition checks and converts a condition on the field named name
+func (p *ExpressionParser[T]) condition(name string, operator FilterOperator, raw []string) (FilterExpression, error) {
+	field, ok := p.dao.field(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown filter field %q in filter expression", name)
+	}
+	condition, err := newFilter(field, p.allowed, operator, raw, fmt.Sprintf("filter expression field %q", name))
+	if err != nil {
+		return nil, err
+	}
+	return condition, nil
+}
+
+// dslOperators maps the comparisons of the DSL to their operators
+var dslOperators = map[string]FilterOperator{
+	"=":    FilterEq,
+	"!=":   FilterNe,
+	">":    FilterGt,
+	">=":   FilterGte,
+	"<":    FilterLt,
+	"<=":   FilterLte,
+	"LIKE": FilterLike,
+	"IN":   FilterIn,
+}
+
+// Parse parses an expression of the query string DSL, such as
+//
+//	(phase = Active OR phase = Pending) AND email LIKE %@example.com
+//
+// Conditions compare a field with =, !=, >, >=, <, <=, LIKE or IN, whose
+// value lists items separated by commas. Values with spaces, parentheses
+// or comparison characters are written as Go quoted strings. AND binds
+// more tightly than OR; keywords are not case sensitive.
+func (p *ExpressionParser[T]) Parse(dsl string) (FilterExpression, error) {
+	tokens, err := tokenizeFilterDSL(dsl)
+	if err != nil {
+		return nil, err
+	}
+	parser := &dslParser[T]{parser: p, tokens: tokens}
+	expr, err := parser.or(0)
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return nil, fmt.Errorf("invalid filter expression: unexpected %q", parser.tokens[parser.pos].text)
+	}
+	if expr.depth() > MaxFilterExpressionDepth {
+		return nil, errFilterExpressionDepth
+	}
+	return expr, nil
+}
+
+// dslToken is a token of the DSL; quoted tokens are always values
+type dslToken struct {
+	text   string
+	quoted bool
+}
+
+// tokenizeFilterDSL splits a DSL expression into parentheses, comparisons,
+// words and quoted strings
+func tokenizeFilterDSL(dsl string) ([]dslToken, error) {
+	var tokens []dslToken
+	for i := 0; i < len(dsl); {
+		switch ch := dsl[i]; {
+		case unicode.IsSpace(rune(ch)):
+			i++
+		case ch == '(' || ch == ')':
+			tokens = append(tokens, dslToken{text: string(ch)})
+			i++
+		case strings.ContainsRune("=!<>", rune(ch)):
+			end := i + 1
+			if end < len(dsl) && dsl[end] == '=' {
+				end++
+			}
+			if _, ok := dslOperators[dsl[i:end]]; !ok {
+				return nil, fmt.Errorf("invalid filter expression: unknown comparison %q", dsl[i:end])
+			}
+			tokens = append(tokens, dslToken{text: dsl[i:end]})
+			i = end
+		case ch == '"':
+			quoted, err := strconv.QuotedPrefix(dsl[i:])
+			if err != nil {
+				return nil, errors.New("invalid filter expression: unterminated string")
+			}
+			value, _ := strconv.Unquote(quoted)
+			tokens = append(tokens, dslToken{text: value, quoted: true})
+			i += len(quoted)
+		default:
+			end := i
+			for end < len(dsl) && !unicode.IsSpace(rune(dsl[end])) && !strings.ContainsRune("()=!<>\"", rune(dsl[end])) {
+				end++
+			}
+			tokens = append(tokens, dslToken{text: dsl[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// dslParser is a recursive descent parser of tokenized DSL expressions
+type dslParser[T any] struct {
+	parser *ExpressionParser[T]
+	tokens []dslToken
+	pos    int
+}
+
+// next returns the current token without consuming it, false at the end
+func (d *dslParser[T]) next() (dslToken, bool) {
+	if d.pos >= len(d.tokens) {
+		return dslToken{}, false
+	}
+	return d.tokens[d.pos], true
+}
+
+// keyword consumes the current token if it is the unquoted word
+func (d *dslParser[T]) keyword(word string) bool {
+	token, ok := d.next()
+	if ok && !token.quoted && strings.EqualFold(token.text, word) {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+// or parses terms joined by OR, inside level parentheses
+func (d *dslParser[T]) or(level int) (FilterExpression, error) {
+	return d.join(level, "OR", true, d.and)
+}
+
+// and parses operands joined by AND
+func (d *dslParser[T]) and(level int) (FilterExpression, error) {
+	return d.join(level, "AND", false, d.operand)
+}
+
+// join parses operands joined by keyword into a group, or returns the
+// only operand
+func (d *dslParser[T]) join(level int, keyword string, or bool, operand func(int) (FilterExpression, error)) (FilterExpression, error) {
+	first, err := operand(level)
+	if err != nil {
+		return nil, err
+	}
+	group := filterGroup{or: or, exprs: []FilterExpression{first}}
+	for d.keyword(keyword) {
+		expr, err := operand(level)
+		if err != nil {
+			return nil, err
+		}
+		group.exprs = append(group.exprs, expr)
+	}
+	if len(group.exprs) == 1 {
+		return first, nil
+	}
+	return group, nil
+}
+
+// operand parses a parenthesized expression or a condition
+func (d *dslParser[T]) operand(level int) (FilterExpression, error) {
+	token, ok := d.next()
+	if !ok {
+		return nil, errors.New("invalid filter expression: unexpected end")
+	}
+	if !token.quoted && token.text == "(" {
+		// Parentheses are bounded before parsing their content
+		if level == MaxFilterExpressionDepth {
+			return nil, errFilterExpressionDepth
+		}
+		d.pos++
+		expr, err := d.or(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		if token, ok := d.next(); !ok || token.quoted || token.text != ")" {
+			return nil, errors.New("invalid filter expression: missing )")
+		}
+		d.pos++
+		return expr, nil
+	}
+	return d.condition()
+}
+
+// condition parses field, comparison and value
+func (d *dslParser[T]) condition() (FilterExpression, error) {
+	if d.pos+3 > len(d.tokens) {
+		return nil, errors.New("invalid filter expression: expected field, comparison and value")
+	}
+	field, comparison, value := d.tokens[d.pos], d.tokens[d.pos+1], d.tokens[d.pos+2]
+	operator, ok := dslOperators[strings.ToUpper(comparison.text)]
+	if field.quoted || comparison.quoted || !ok {
+		return nil, fmt.Errorf("invalid filter expression: expected a comparison after %q", field.text)
+	}
+	if !value.quoted && (value.text == "(" || value.text == ")") {
+		return nil, fmt.Errorf("invalid filter expression: expected a value after %q", comparison.text)
+	}
+	d.pos += 3
+
+	raw := []string{value.text}
+	if operator == FilterIn {
+		raw = strings.Split(value.text, ",")
+	}
+	return d.parser.condition(field.text, operator, raw)
+}
+
+// parseFilterExpression parses the FilterExpressionParameter of a list
+// request into a scope, nil if expression is empty. JSON objects are
+// parsed with ExpressionParser.ParseJSON and other expressions with
+// ExpressionParser.Parse.
+func parseFilterExpression[T any](dao *DAO[T], expression string) (func(*gorm.DB) *gorm.DB, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return nil, nil
+	}
+	parser := NewExpressionParser(dao)
+	var expr FilterExpression
+	var err error
+	if strings.HasPrefix(expression, "{") {
+		expr, err = parser.ParseJSON([]byte(expression))
+	} else {
+		expr, err = parser.Parse(expression)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return expr.ToGORM, nil
+}
@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestFilterExpression_ToGORM(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[TestModel](db)
+	ctx := context.Background()
+
+	for i := 1; i <= 6; i++ {
+		require.NoError(t, dao.Create(ctx, &TestModel{Name: fmt.Sprintf("test%d", i)}))
+	}
+
+	names := func(expr FilterExpression) []string {
+		items, _, err := dao.Scope(expr.ToGORM).List(ctx, 1, 10, nil)
+		require.NoError(t, err)
+		var names []string
+		for _, item := range items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+	eq := func(column string, value interface{}) Filter {
+		return Filter{Field: column, Operator: FilterEq, Value: value}
+	}
+
+	// OR inside AND keeps its precedence
+	assert.Equal(t, []string{"test1", "test2"}, names(And(
+		Or(eq("id", 1), eq("id", 2), eq("id", 5)),
+		Filter{Field: "id", Operator: FilterLt, Value: 4},
+	)))
+	// AND inside OR, nested three levels
+	assert.Equal(t, []string{"test1", "test4", "test6"}, names(Or(
+		eq("name", "test1"),
+		And(
+			Filter{Field: "id", Operator: FilterGte, Value: 4},
+			Or(eq("id", 4), eq("id", 6)),
+		),
+	)))
+	// Empty groups match everything
+	assert.Len(t, names(And(Or(), eq("id", 3))), 1)
+	assert.Len(t, names(Or()), 6)
+}
+
+func TestExpressionParser(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[apiv1.User](db)
+	ctx := context.Background()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	users := []struct{ name, domain, role string }{
+		{"alice", "example.com", apiv1.RoleAdmin},
+		{"bob", "example.com", apiv1.RoleUser},
+		{"carol", "example.org", apiv1.RoleAdmin},
+		{"dave", "example.org", apiv1.RoleUser},
+	}
+	for _, u := range users {
+		user := &apiv1.User{Username: u.name, Email: u.name + "@" + u.domain, Password: string(hash), Role: u.role}
+		require.NoError(t, db.Create(user).Error)
+	}
+
+	parser := NewExpressionParser(dao)
+	usernames := func(expr FilterExpression) []string {
+		items, _, err := dao.Scope(expr.ToGORM).List(ctx, 1, 10, nil)
+		require.NoError(t, err)
+		var names []string
+		for _, item := range items {
+			names = append(names, item.Username)
+		}
+		return names
+	}
+
+	tests := []struct {
+		dsl      string
+		json     string
+		expected []string
+	}{
+		{
+			`(username = alice OR username = dave) AND email LIKE %@example.com`,
+			`{"and": [{"or": [{"field": "username", "value": "alice"}, {"field": "username", "value": "dave"}]},
+				{"field": "email", "op": "like", "value": "%@example.com"}]}`,
+			[]string{"alice"},
+		},
+		{
+			`role = admin AND email LIKE %.org OR username IN bob,dave`,
+			`{"or": [{"and": [{"field": "role", "value": "admin"}, {"field": "email", "op": "like", "value": "%.org"}]},
+				{"field": "username", "op": "in", "value": ["bob", "dave"]}]}`,
+			[]string{"bob", "carol", "dave"},
+		},
+		{
+			`id > 1 and (role != "admin" or (username = carol and id <= 3))`,
+			`{"and": [{"field": "id", "op": "gt", "value": 1},
+				{"or": [{"field": "role", "op": "ne", "value": "admin"},
+					{"and": [{"field": "username", "value": "carol"}, {"field": "id", "op": "lte", "value": 3}]}]}]}`,
+			[]string{"bob", "carol", "dave"},
+		},
+	}
+	for _, tt := range tests {
+		expr, err := parser.Parse(tt.dsl)
+		require.NoError(t, err, tt.dsl)
+		assert.Equal(t, tt.expected, usernames(expr), tt.dsl)
+
+		expr, err = parser.ParseJSON([]byte(tt.json))
+		require.NoError(t, err, tt.json)
+		assert.Equal(t, tt.expected, usernames(expr), tt.json)
+	}
+
+	invalid := []struct {
+		dsl      string
+		expected string
+	}{
+		{`((((username = alice))))`, "nested deeper than 3 levels"},
+		{`id = 1 OR (id = 2 AND (id = 3 OR (id = 4 AND id = 5)))`, "nested deeper than 3 levels"},
+		{`nickname = x`, `unknown filter field "nickname"`},
+		{`password = x`, `operator "eq" is not allowed in filter expression field "password"`},
+		{`isActive LIKE t%`, `operator "like" is not allowed in filter expression field "isActive"`},
+		{`id = one`, `invalid value "one" for filter expression field "id"`},
+		{`(username = alice`, "missing )"},
+		{`username alice bob`, `expected a comparison after "username"`},
+		{`username =`, "expected field, comparison and value"},
+		{`username == alice`, `unknown comparison "=="`},
+		{`username = alice bob`, `unexpected "bob"`},
+	}
+	for _, tt := range invalid {
+		_, err := parser.Parse(tt.dsl)
+		require.Error(t, err, tt.dsl)
+		assert.Contains(t, err.Error(), tt.expected, tt.dsl)
+	}
+
+	deep := `{"field": "id", "value": 1}`
+	for i := 0; i < MaxFilterExpressionDepth; i++ {
+		deep = `{"and": [` + deep + `]}`
+	}
+	_, err = parser.ParseJSON([]byte(deep))
+	require.NoError(t, err)
+	_, err = parser.ParseJSON([]byte(`{"or": [` + deep + `]}`))
+	assert.ErrorContains(t, err, "nested deeper than 3 levels")
+	_, err = parser.ParseJSON([]byte(`{"field": "id", "value": 1, "and": []}`))
+	assert.ErrorContains(t, err, "expected one of and, or and field")
+	_, err = parser.ParseJSON([]byte(`{"field": "id", "op": "in", "value": 1}`))
+	assert.ErrorContains(t, err, "expected an array")
+	_, err = parser.ParseJSON([]byte(`{"field": "id", "values": [1]}`))
+	assert.ErrorContains(t, err, "unknown field")
+}
+
+func TestRouter_ListFilterExpression(t *testing.T) {
+	r, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	for _, name := range []string{"alice", "bob", "carol"} {
+		require.NoError(t, db.Create(&apiv1.User{Username: name, Email: name + "@example.com", Password: string(hash)}).Error)
+	}
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	expression := url.QueryEscape(`(username = alice OR username = carol) AND email LIKE %@example.com`)
+
+	w := serve("/api/v1/users?filterExpr=" + expression)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var users []apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	require.Len(t, users, 2)
+	assert.Equal(t, "alice", users[0].Username)
+	assert.Equal(t, "carol", users[1].Username)
+
+	// It combines with the other filters
+	w = serve("/api/v1/users/count?id[gt]=1&filterExpr=" + expression)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "1", w.Header().Get("X-Total-Count"))
+
+	w = serve("/api/v1/users?filterExpr=" + url.QueryEscape(`{"or": [{"field": "username", "value": "bob"}]}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	require.Len(t, users, 1)
+	assert.Equal(t, "bob", users[0].Username)
+
+	w = serve("/api/v1/users?filterExpr=" + url.QueryEscape(strings.Repeat("(", 4)+"id = 1"+strings.Repeat(")", 4)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve("/api/v1/users?filterExpr=" + url.QueryEscape("password = x"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
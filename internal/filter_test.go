@@ -0,0 +1,231 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter_Scope(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[TestModel](db)
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, dao.Create(ctx, &TestModel{Name: fmt.Sprintf("test%d", i)}))
+	}
+
+	names := func(filters []Filter) []string {
+		items, _, err := dao.List(ctx, 1, 10, filters)
+		require.NoError(t, err)
+		var names []string
+		for _, item := range items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"test2", "test3", "test4"}, names(Filters{
+		{Field: "id", Operator: FilterGte, Value: 2},
+		{Field: "id", Operator: FilterLte, Value: 4},
+	}))
+	assert.Equal(t, []string{"test1", "test2", "test4", "test5"}, names(Filters{{Field: "name", Operator: FilterNe, Value: "test3"}}))
+	assert.Equal(t, []string{"test1", "test5"}, names(Filters{{Field: "id", Operator: FilterIn, Value: []interface{}{1, 5}}}))
+	assert.Equal(t, []string{"test4"}, names(Filters{{Field: "name", Operator: FilterLike, Value: "%4"}}))
+
+	// Fields must be columns of the resource
+	assert.Equal(t, []string{"test1", "test2"}, names([]Filter{{Field: "created_at", Operator: FilterLte, Value: time.Now()}, {Field: "id", Operator: FilterLt, Value: 3}}))
+	_, _, err := dao.List(ctx, 1, 10, []Filter{{Field: "nickname", Operator: FilterEq, Value: "a"}})
+	assert.ErrorIs(t, err, ErrUnknownField)
+	_, err = dao.Count(ctx, []Filter{{Field: "name; DROP TABLE test_models", Operator: FilterEq, Value: "a"}})
+	assert.ErrorIs(t, err, ErrUnknownField)
+}
+
+func TestParseFilterOperators(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[TestModel](db)
+
+	values := url.Values{
+		"name[like]": {"test%"},
+		"id[in]":     {"1, 2"},
+		"page":       {"2"},
+		"name":       {"plain"},
+	}
+	filter, err := parseFilterOperators(dao, values)
+	require.NoError(t, err)
+	assert.Equal(t, Filters{
+		{Field: "id", Operator: FilterIn, Value: []interface{}{uint64(1), uint64(2)}},
+		{Field: "name", Operator: FilterLike, Value: "test%"},
+	}, filter)
+
+	tests := []struct {
+		param   string
+		value   string
+		message string
+	}{
+		{"id[in]", "1,x", `invalid value "x" for filter parameter "id[in]"`},
+		{"created_at[gte]", "yesterday", `invalid value "yesterday" for filter parameter "created_at[gte]"`},
+		{"name[regex]", "a", `operator "regex" is not allowed in filter parameter "name[regex]"`},
+		{"id[like]", "1%", `operator "like" is not allowed in filter parameter "id[like]"`},
+		{"nickname[eq]", "a", `unknown filter field in parameter "nickname[eq]"`},
+		{"name[eq", "a", `invalid filter parameter "name[eq"`},
+		{"filter", "id__lte", `invalid filter "id__lte", expected field__operator=value`},
+		{"filter", "nickname__eq=a", `unknown filter field in parameter "nickname[eq]"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.param, func(t *testing.T) {
+			_, err := parseFilterOperators(dao, url.Values{tt.param: {tt.value}})
+			assert.EqualError(t, err, tt.message)
+		})
+	}
+
+	// Filter parameters read like the operator syntax, equality by default
+	filter, err = parseFilterOperators(dao, url.Values{"filter": {"id__gte=2", "id__lt=4", "name=test3"}})
+	require.NoError(t, err)
+	assert.Equal(t, Filters{
+		{Field: "id", Operator: FilterGte, Value: uint64(2)},
+		{Field: "id", Operator: FilterLt, Value: uint64(4)},
+		{Field: "name", Operator: FilterEq, Value: "test3"},
+	}, filter)
+}
+
+func TestRouter_ListFilterOperators(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, email := range []string{"alice@corp.com", "bob@corp.com", "carol@example.com"} {
+		user := &apiv1.User{Username: fmt.Sprintf("user%d", i+1), Email: email, Password: "password123"}
+		require.NoError(t, db.Create(user).Error)
+		require.NoError(t, db.Model(user).UpdateColumn("created_at", created.AddDate(0, i, 0)).Error)
+	}
+
+	list := func(values url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/users?"+values.Encode(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	usernames := func(values url.Values) []string {
+		w := list(values)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var users []apiv1.User
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+		var names []string
+		for _, user := range users {
+			names = append(names, user.Username)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"user1", "user2"}, usernames(url.Values{"email[like]": {"%@corp.com"}}))
+	assert.Equal(t, []string{"user2", "user3"}, usernames(url.Values{"createdAt[gte]": {"2024-02-01T00:00:00Z"}}))
+	assert.Equal(t, []string{"user1", "user2"}, usernames(url.Values{"createdAt[lte]": {"2024-02-01T00:00:00Z"}}))
+	assert.Equal(t, []string{"user1", "user3"}, usernames(url.Values{"id[in]": {"1,3"}}))
+	assert.Equal(t, []string{"user2"}, usernames(url.Values{"email[like]": {"%@corp.com"}, "username[ne]": {"user1"}}))
+
+	// Ranges can be given as filter parameters, dates standing for midnight
+	assert.Equal(t, []string{"user2"}, usernames(url.Values{"filter": {"createdAt__gte=2024-01-15", "id__lte=2"}}))
+	assert.Equal(t, []string{"user3"}, usernames(url.Values{"filter": {"username=user3"}}))
+
+	// Plain parameters match equal values
+	assert.Equal(t, []string{"user3"}, usernames(url.Values{"username": {"user3"}}))
+
+	// Bad values and fields left out by the resource are rejected, naming
+	// the parameter
+	for _, values := range []url.Values{
+		{"createdAt[gte]": {"2024-13-01"}},
+		{"id[in]": {"1,two"}},
+		{"password[eq]": {"secret"}},
+		{"role[like]": {"adm%"}},
+		{"bogus": {"1"}},
+		{"id": {"one"}},
+		{"password": {"secret"}},
+	} {
+		w := list(values)
+		assert.Equal(t, http.StatusBadRequest, w.Code, values.Encode())
+		for param := range values {
+			assert.Contains(t, decodeStatus(t, w).Message, param)
+		}
+	}
+}
+
+func TestRegisterResource_ListFilterOperators(t *testing.T) {
+	router, db := setupTestRegister(t)
+	defer cleanupTestDB(t, db)
+
+	for i := 1; i <= 3; i++ {
+		user := &apiv1.User{Username: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i), Password: "password123"}
+		require.NoError(t, db.Create(user).Error)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/users?id[gte]=2&username=user3", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response ListResponse[apiv1.User]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, int64(1), response.Total)
+	require.Len(t, response.Items, 1)
+	assert.Equal(t, "user3", response.Items[0].Username)
+
+	req = httptest.NewRequest("GET", "/api/v1/users?filter=id__gt%3D1&filter=id__lt%3D3", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Items, 1)
+	assert.Equal(t, "user2", response.Items[0].Username)
+
+	for _, query := range []string{"id[gte]=two", "bogus=1", "id=one"} {
+		req = httptest.NewRequest("GET", "/api/v1/users?"+query, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestRouter_ListSearch(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	for _, user := range []*apiv1.User{
+		{Username: "alice", Email: "alice@corp.com", Password: "password123"},
+		{Username: "bob", Email: "bob@alice.org", Password: "password123"},
+		{Username: "carol", Email: "carol@example.com", Password: "password123"},
+	} {
+		require.NoError(t, db.Create(user).Error)
+	}
+
+	usernames := func(values url.Values) []string {
+		req := httptest.NewRequest("GET", "/api/v1/users?"+values.Encode(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var users []apiv1.User
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+		var names []string
+		for _, user := range users {
+			names = append(names, user.Username)
+		}
+		return names
+	}
+
+	// Partial matches on the username and the email are combined
+	assert.Equal(t, []string{"alice", "bob"}, usernames(url.Values{"search": {"Alice"}}))
+	assert.Equal(t, []string{"bob"}, usernames(url.Values{"search": {"alice"}, "username[ne]": {"alice"}}))
+	assert.Empty(t, usernames(url.Values{"search": {"dave"}}))
+}
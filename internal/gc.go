@@ -0,0 +1,213 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"my-embedded-api/meta"
+
+	"gorm.io/gorm"
+)
+
+// ErrOwnerDeletionBlocked is returned when deleting a resource that is the
+// owner of another resource whose owner reference sets BlockOwnerDeletion
+var ErrOwnerDeletionBlocked = errors.New("owner deletion blocked by dependent resource")
+
+// garbageCollectorActor is the audit actor of garbage collector deletions
+const garbageCollectorActor = "system:garbage-collector"
+
+var (
+	ownerTablesMu sync.RWMutex
+	ownerTables   = make(map[string]bool)
+)
+
+// registerOwnerTable records the table of a resource that can own or be
+// owned by other resources, so that owner lookups cover it
+func registerOwnerTable(db *gorm.DB, model any) {
+	table, ok := ownerTable(db, model)
+	if !ok {
+		return
+	}
+	ownerTablesMu.Lock()
+	defer ownerTablesMu.Unlock()
+	ownerTables[table] = true
+}
+
+// ownerTable returns the table of model if it has uid and owner_references
+// columns
+func ownerTable(db *gorm.DB, model any) (string, bool) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return "", false
+	}
+	if stmt.Schema.LookUpField("uid") == nil || stmt.Schema.LookUpField("owner_references") == nil {
+		return "", false
+	}
+	return stmt.Schema.Table, true
+}
+
+// ownerTablesWith returns the registered owner tables plus the table of
+// model. The registry is shared by every database, so tables missing from
+// db are skipped.
+func ownerTablesWith(db *gorm.DB, model any) []string {
+	ownerTablesMu.RLock()
+	defer ownerTablesMu.RUnlock()
+
+	tables := make([]string, 0, len(ownerTables)+1)
+	for table := range ownerTables {
+		if db.Migrator().HasTable(table) {
+			tables = append(tables, table)
+		}
+	}
+	if table, ok := ownerTable(db, model); ok && !ownerTables[table] {
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+// ownerDeletionBlocked reports whether resource is the owner of a resource
+// whose owner reference sets BlockOwnerDeletion
+func ownerDeletionBlocked(tx *gorm.DB, resource any) (bool, error) {
+	owned, ok := resource.(ownedResource)
+	if !ok {
+		return false, nil
+	}
+	return hasBlockingDependents(tx, ownerTablesWith(tx, resource), owned.GetUID())
+}
+
+// hasBlockingDependents reports whether any resource in tables lists uid as
+// an owner with BlockOwnerDeletion set
+func hasBlockingDependents(tx *gorm.DB, tables []string, uid string) (bool, error) {
+	if uid == "" {
+		return false, nil
+	}
+
+	for _, table := range tables {
+		var encoded []string
+		err := liveRows(tx, table).Where("owner_references LIKE ?", "%"+uid+"%").Pluck("owner_references", &encoded).Error
+		if err != nil {
+			return false, err
+		}
+
+		for _, value := range encoded {
+			var owners []meta.OwnerReference
+			if err := json.Unmarshal([]byte(value), &owners); err != nil {
+				continue
+			}
+			for _, owner := range owners {
+				if owner.UID == uid && owner.BlockOwnerDeletion {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// uidExists reports whether a resource with the given UID exists in any of
+// tables
+func uidExists(db *gorm.DB, tables []string, uid string) (bool, error) {
+	for _, table := range tables {
+		var count int64
+		if err := liveRows(db, table).Where("uid = ?", uid).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// liveRows queries the rows of table, leaving out the soft-deleted ones
+func liveRows(db *gorm.DB, table string) *gorm.DB {
+	query := db.Table(table)
+	if db.Migrator().HasColumn(table, "deleted_at") {
+		query = query.Where("deleted_at IS NULL")
+	}
+	return query
+}
+
+// ownedResource is implemented by resources embedding meta.BaseResource
+type ownedResource interface {
+	GetUID() string
+	GetOwnerReferences() []meta.OwnerReference
+}
+
+// GarbageCollector deletes resources whose owners no longer exist
+type GarbageCollector[T any] struct {
+	dao      *DAO[T]
+	interval time.Duration
+}
+
+// NewGarbageCollector creates a garbage collector for the resources of dao
+// that runs every interval
+func NewGarbageCollector[T any](dao *DAO[T], interval time.Duration) *GarbageCollector[T] {
+	return &GarbageCollector[T]{dao: dao, interval: interval}
+}
+
+// Run collects garbage every interval until ctx is done
+func (g *GarbageCollector[T]) Run(ctx context.Context) {
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := g.Collect(ctx); err != nil {
+				slog.Error("Garbage collection failed", "error", err)
+			}
+		}
+	}
+}
+
+// Collect deletes every resource that has owner references but none of
+// whose owners exist any more. It returns the number of deleted resources.
+// Deletions are attributed to the garbage collector in the audit log.
+func (g *GarbageCollector[T]) Collect(ctx context.Context) (int, error) {
+	ctx = WithActor(ctx, garbageCollectorActor)
+	db := g.dao.db.WithContext(ctx)
+
+	var candidates []T
+	err := db.Where("owner_references IS NOT NULL AND owner_references NOT IN ?", []string{"", "null", "[]"}).
+		Find(&candidates).Error
+	if err != nil {
+		return 0, err
+	}
+
+	tables := ownerTablesWith(db, new(T))
+	deleted := 0
+	for i := range candidates {
+		owned, ok := any(&candidates[i]).(ownedResource)
+		if !ok || len(owned.GetOwnerReferences()) == 0 {
+			continue
+		}
+
+		orphaned := true
+		for _, owner := range owned.GetOwnerReferences() {
+			exists, err := uidExists(db, tables, owner.UID)
+			if err != nil {
+				return deleted, err
+			}
+			if exists {
+				orphaned = false
+				break
+			}
+		}
+		if !orphaned {
+			continue
+		}
+
+		if err := g.dao.Delete(ctx, resourceID(&candidates[i]), 0); err != nil && err != gorm.ErrRecordNotFound {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// createOwnedUser creates a user owned by the given owners
+func createOwnedUser(t *testing.T, dao *DAO[apiv1.User], name string, owners ...meta.OwnerReference) *apiv1.User {
+	user := &apiv1.User{Username: name, Email: name + "@example.com", Password: "password123"}
+	for _, owner := range owners {
+		user.SetOwnerReference(owner)
+	}
+	err := dao.Create(context.Background(), user)
+	assert.NoError(t, err)
+	return user
+}
+
+func ownerRef(owner *apiv1.User, block bool) meta.OwnerReference {
+	return meta.OwnerReference{Kind: "User", APIVersion: "v1", UID: owner.UID, Name: owner.Username, BlockOwnerDeletion: block}
+}
+
+func TestDAO_DeleteBlockedByDependent(t *testing.T) {
+	db := setupTestDB(t)
+	dao := NewDAO[apiv1.User](db)
+
+	owner := createOwnedUser(t, dao, "owner")
+	dependent := createOwnedUser(t, dao, "dependent", ownerRef(owner, true))
+
+	// The blocking dependent prevents deletion
+	err := dao.Delete(context.Background(), owner.ID, 0)
+	assert.Equal(t, ErrOwnerDeletionBlocked, err)
+
+	// Once the reference no longer blocks, deletion succeeds
+	dependent.SetOwnerReference(ownerRef(owner, false))
+	err = db.Save(dependent).Error
+	assert.NoError(t, err)
+
+	err = dao.Delete(context.Background(), owner.ID, 0)
+	assert.NoError(t, err)
+}
+
+func TestGarbageCollector_Collect(t *testing.T) {
+	db := setupTestDB(t)
+	dao := NewDAO[apiv1.User](db)
+
+	owner := createOwnedUser(t, dao, "owner")
+	dependent := createOwnedUser(t, dao, "dependent", ownerRef(owner, false))
+	orphan := createOwnedUser(t, dao, "orphan", meta.OwnerReference{Kind: "User", APIVersion: "v1", UID: "missing"})
+	createOwnedUser(t, dao, "unowned")
+
+	gc := NewGarbageCollector(dao, time.Minute)
+
+	// Only the orphan is collected
+	deleted, err := gc.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	_, err = dao.Get(context.Background(), orphan.ID)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	// Deleting the owner orphans its dependent
+	err = dao.Delete(context.Background(), owner.ID, 0)
+	assert.NoError(t, err)
+
+	deleted, err = gc.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	_, err = dao.Get(context.Background(), dependent.ID)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	// The unowned user is kept
+	_, total, err := dao.List(context.Background(), 1, 10, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+}
+
+func TestRegisterResource_GarbageCollector(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	db := setupTestDB(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	RegisterResource[apiv1.User](router, db, "/api/v1/users", WithGarbageCollector(ctx, 10*time.Millisecond))
+
+	dao := NewDAO[apiv1.User](db)
+	orphan := createOwnedUser(t, dao, "orphan", meta.OwnerReference{Kind: "User", APIVersion: "v1", UID: "missing"})
+
+	assert.Eventually(t, func() bool {
+		_, err := dao.Get(context.Background(), orphan.ID)
+		return err == gorm.ErrRecordNotFound
+	}, time.Second, 10*time.Millisecond, fmt.Sprintf("user %d was not collected", orphan.ID))
+}
+
+func TestDAO_DeleteManyBlockedByDependent(t *testing.T) {
+	db := setupTestDB(t)
+	dao := NewDAO[apiv1.User](db)
+
+	owner := createOwnedUser(t, dao, "owner")
+	createOwnedUser(t, dao, "dependent", ownerRef(owner, true))
+	other := createOwnedUser(t, dao, "other")
+
+	// Nothing is deleted while the owner is blocked
+	_, err := dao.DeleteMany(context.Background(), []uint{other.ID, owner.ID})
+	assert.ErrorIs(t, err, ErrOwnerDeletionBlocked)
+	var deleteErr *BulkDeleteError
+	if assert.ErrorAs(t, err, &deleteErr) {
+		assert.Equal(t, owner.ID, deleteErr.ID)
+	}
+
+	_, err = dao.Get(context.Background(), other.ID)
+	assert.NoError(t, err)
+	_, err = dao.Get(context.Background(), owner.ID)
+	assert.NoError(t, err)
+}
+
+func TestRouter_DeleteManyBlockedByDependent(t *testing.T) {
+	router, db := setupTestRouter(t)
+	dao := NewDAO[apiv1.User](db)
+
+	owner := createOwnedUser(t, dao, "owner")
+	createOwnedUser(t, dao, "dependent", ownerRef(owner, true))
+
+	req := httptest.NewRequest("DELETE", "/api/v1/users", strings.NewReader(fmt.Sprintf(`{"ids":[%d]}`, owner.ID)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), fmt.Sprintf("resource %d", owner.ID))
+}
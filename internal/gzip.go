@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GzipMiddleware compresses responses with the given gzip level for clients
+// sending Accept-Encoding: gzip. Output is buffered until it exceeds
+// minSizeBytes; smaller responses are sent uncompressed, since compression
+// would gain little. Responses that already set Content-Encoding are left
+// alone.
+func GzipMiddleware(level int, minSizeBytes int) gin.HandlerFunc {
+	if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+		panic(fmt.Sprintf("invalid gzip level %d", level))
+	}
+
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if c.Request.Method == "HEAD" || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		writer := &gzipWriter{ResponseWriter: c.Writer, level: level, minSize: minSizeBytes}
+		c.Writer = writer
+		defer func() {
+			writer.close()
+			c.Writer = writer.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		// A zero quality value refuses the coding
+		params = strings.TrimSpace(params)
+		if value, ok := strings.CutPrefix(params, "q="); ok {
+			q, err := strconv.ParseFloat(value, 64)
+			return err == nil && q > 0
+		}
+		return true
+	}
+	return false
+}
+
+// gzipWriter buffers the response until it knows whether the body is large
+// enough to compress, then streams it compressed or as is
+type gzipWriter struct {
+	gin.ResponseWriter
+	level   int
+	minSize int
+
+	// buf holds the output written before the decision
+	buf bytes.Buffer
+
+	// decided is set once the output goes to the client; gz is nil if it
+	// goes uncompressed
+	decided bool
+	gz      *gzip.Writer
+}
+
+// decide starts sending the response, compressed if compress is set and the
+// handler did not encode the body itself
+func (w *gzipWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.ResponseWriter.Header()
+	if compress && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.gz, _ = gzip.NewWriterLevel(w.ResponseWriter, w.level)
+	}
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.output().Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// output returns where the body goes after the decision
+func (w *gzipWriter) output() interface{ Write([]byte) (int, error) } {
+	if w.gz != nil {
+		return w.gz
+	}
+	return w.ResponseWriter
+}
+
+// Write implements http.ResponseWriter
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		return w.output().Write(data)
+	}
+
+	w.buf.Write(data)
+	if w.buf.Len() > w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// WriteString implements gin.ResponseWriter
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow implements gin.ResponseWriter. The headers are only sent
+// once it is known whether the body is compressed.
+func (w *gzipWriter) WriteHeaderNow() {
+	if w.decided {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Written implements gin.ResponseWriter
+func (w *gzipWriter) Written() bool {
+	return w.ResponseWriter.Written() || w.buf.Len() > 0
+}
+
+// Flush implements http.Flusher. Flushing before the threshold is reached
+// sends the response uncompressed, as streams need their data right away.
+func (w *gzipWriter) Flush() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// close sends any buffered output and ends the compressed stream
+func (w *gzipWriter) close() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
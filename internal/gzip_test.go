@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzipMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(GzipMiddleware(gzip.BestSpeed, 256))
+
+	items := make([]string, 100)
+	for i := range items {
+		items[i] = strings.Repeat("x", 10)
+	}
+	router.GET("/large", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"items": items}) })
+	router.GET("/small", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
+	router.GET("/encoded", func(c *gin.Context) {
+		c.Header("Content-Encoding", "br")
+		c.String(http.StatusOK, strings.Repeat("y", 1000))
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		compressed     bool
+	}{
+		{"large response", "/large", "gzip, deflate", true},
+		{"weighted gzip", "/large", "br;q=1.0, gzip;q=0.5", true},
+		{"small response", "/small", "gzip", false},
+		{"client without gzip", "/large", "", false},
+		{"gzip refused", "/large", "gzip;q=0", false},
+		{"already encoded", "/encoded", "gzip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+			if !tt.compressed {
+				assert.NotEqual(t, "gzip", w.Header().Get("Content-Encoding"))
+				if tt.path != "/encoded" {
+					assert.True(t, json.Valid(w.Body.Bytes()))
+				}
+				return
+			}
+
+			assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+			assert.Empty(t, w.Header().Get("Content-Length"))
+
+			reader, err := gzip.NewReader(w.Body)
+			require.NoError(t, err)
+			body, err := io.ReadAll(reader)
+			require.NoError(t, err)
+
+			var decoded struct {
+				Items []string `json:"items"`
+			}
+			require.NoError(t, json.Unmarshal(body, &decoded))
+			assert.Equal(t, items, decoded.Items)
+		})
+	}
+}
+
+func TestGzipMiddleware_Stream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(GzipMiddleware(gzip.DefaultCompression, 1024))
+	router.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.Status(http.StatusOK)
+		c.Writer.WriteString("data: {}\n\n")
+		c.Writer.Flush()
+	})
+
+	req := httptest.NewRequest("GET", "/stream", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Flushed output is sent as is
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "data: {}\n\n", w.Body.String())
+	assert.True(t, w.Flushed)
+}
+
+func TestGzipMiddleware_InvalidLevel(t *testing.T) {
+	assert.Panics(t, func() { GzipMiddleware(42, 0) })
+}
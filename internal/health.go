@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// HealthPaths are the probe endpoints registered by RegisterHealthRoutes.
+// They are excluded from request logging by NewSlogMiddleware.
+var HealthPaths = []string{"/healthz", "/livez", "/readyz"}
+
+// readyTimeout bounds the database ping of a readiness probe
+const readyTimeout = time.Second
+
+// RegisterHealthRoutes registers the probe endpoints on the engine, see
+// RegisterHealth
+func RegisterHealthRoutes(router *gin.Engine, db *gorm.DB) {
+	RegisterHealth(router, db)
+}
+
+// RegisterHealth registers the probe endpoints on router: /livez always
+// succeeds, /readyz fails with 503 when the database cannot be pinged and
+// /healthz reports the status of the app and the database. /healthz itself
+// succeeds as long as the process is running.
+func RegisterHealth(router gin.IRoutes, db *gorm.DB) {
+	router.GET("/livez", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	router.GET("/readyz", func(c *gin.Context) {
+		if err := pingDatabase(c.Request.Context(), db); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"status": "not ready",
+				"error":  "db ping failed",
+				"reason": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready"})
+	})
+
+	router.GET("/healthz", func(c *gin.Context) {
+		dbStatus := "ok"
+		if err := pingDatabase(c.Request.Context(), db); err != nil {
+			dbStatus = "unavailable"
+		}
+		c.JSON(http.StatusOK, gin.H{"app": "ok", "db": dbStatus})
+	})
+}
+
+// pingDatabase checks that the database is reachable within readyTimeout
+func pingDatabase(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
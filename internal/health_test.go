@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterHealthRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	db := setupTestDB(t)
+	RegisterHealthRoutes(router, db)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	// Healthy database
+	w := get("/livez")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = get("/readyz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ready"}`, w.Body.String())
+
+	w = get("/healthz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"app":"ok","db":"ok"}`, w.Body.String())
+
+	// Closing the connection makes the database unreachable
+	cleanupTestDB(t, db)
+
+	w = get("/livez")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var body map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "not ready", body["status"])
+	assert.Equal(t, "db ping failed", body["error"])
+	assert.Contains(t, body["reason"], "closed")
+
+	w = get("/healthz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"app":"ok","db":"unavailable"}`, w.Body.String())
+}
+
+func TestRegisterHealth_Group(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	RegisterHealth(router.Group("/internal"), db)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/internal/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+// LevelSilent is above every level used for records, so a logger at this
+// level writes nothing
+const LevelSilent = slog.Level(12)
+
+// DefaultSlowQueryThreshold is the duration above which queries are logged
+// as warnings unless configured otherwise
+const DefaultSlowQueryThreshold = 200 * time.Millisecond
+
+// LevelFromString parses a configured log level. Unknown values default to
+// Info.
+func LevelFromString(s string) slog.Level {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	case "silent":
+		return LevelSilent
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// NewLogger creates a logger writing records at level and above to out,
+// one JSON object per line
+func NewLogger(out io.Writer, level string) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: LevelFromString(level)}))
+}
+
+// ConfigureGin stops gin from writing its own log format. Below the debug
+// level gin runs in release mode, which prints nothing; at the debug level
+// the registered routes are logged through logger.
+func ConfigureGin(logger *slog.Logger, level string) {
+	if LevelFromString(level) > slog.LevelDebug {
+		gin.SetMode(gin.ReleaseMode)
+		return
+	}
+	gin.DebugPrintRouteFunc = func(method, path, handler string, handlers int) {
+		logger.Debug("route",
+			slog.String("method", method),
+			slog.String("path", path),
+			slog.String("handler", handler),
+			slog.Int("handlers", handlers),
+		)
+	}
+}
+
+// NewSlogMiddleware logs every request except the health probes with its
+// method, route, status, latency and the request ID set by
+// RequestIDMiddleware
+func NewSlogMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	skip := make(map[string]bool, len(HealthPaths))
+	for _, path := range HealthPaths {
+		skip[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if skip[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		logger.LogAttrs(c.Request.Context(), level, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("request_id", c.GetString(RequestIDKey)),
+		)
+	}
+}
+
+// gormLogger emits GORM log records through slog
+type gormLogger struct {
+	logger        *slog.Logger
+	level         gormlogger.LogLevel
+	slowThreshold time.Duration
+}
+
+// NewGormLogger returns a GORM logger writing to logger. Queries are logged
+// at debug level, those slower than DefaultSlowQueryThreshold as warnings
+// and failed queries as errors.
+func NewGormLogger(logger *slog.Logger) gormlogger.Interface {
+	return NewGormLoggerWithConfig(logger, gormlogger.Config{
+		SlowThreshold: DefaultSlowQueryThreshold,
+		LogLevel:      gormlogger.Info,
+	})
+}
+
+// NewGormLoggerWithConfig returns a GORM logger writing to logger with the
+// level and slow query threshold of config; a zero threshold turns slow
+// query warnings off. The other settings of config only apply to GORM's
+// own writer and are ignored.
+func NewGormLoggerWithConfig(logger *slog.Logger, config gormlogger.Config) gormlogger.Interface {
+	return &gormLogger{logger: logger, level: config.LogLevel, slowThreshold: config.SlowThreshold}
+}
+
+// LogMode returns a copy of the logger with the given GORM level
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	copied := *l
+	copied.level = level
+	return &copied
+}
+
+// Info logs an informational message
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		l.logger.InfoContext(ctx, msg, "args", args)
+	}
+}
+
+// Warn logs a warning
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		l.logger.WarnContext(ctx, msg, "args", args)
+	}
+}
+
+// Error logs an error
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		l.logger.ErrorContext(ctx, msg, "args", args)
+	}
+}
+
+// Trace logs a query after it ran
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	var level slog.Level
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error:
+		level = slog.LevelError
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= gormlogger.Warn:
+		level = slog.LevelWarn
+	case l.level >= gormlogger.Info:
+		level = slog.LevelDebug
+	default:
+		return
+	}
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Duration("elapsed", elapsed),
+	}
+	if id := RequestIDFromContext(ctx); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	if level > slog.LevelDebug {
+		// The code that ran the query, to find slow and failed ones
+		attrs = append(attrs, slog.String("caller", utils.FileWithLineNum()))
+	}
+	l.logger.LogAttrs(ctx, level, "query", attrs...)
+}
@@ -0,0 +1,218 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestLevelFromString(t *testing.T) {
+	tests := []struct {
+		input string
+		want  slog.Level
+	}{
+		{"debug", slog.LevelDebug},
+		{"info", slog.LevelInfo},
+		{"warn", slog.LevelWarn},
+		{"WARNING", slog.LevelWarn},
+		{"error", slog.LevelError},
+		{"silent", LevelSilent},
+		{"", slog.LevelInfo},
+		{"verbose", slog.LevelInfo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			assert.Equal(t, tt.want, LevelFromString(tt.input))
+		})
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, "warn")
+	logger.Info("dropped")
+	logger.Warn("kept", "key", "value")
+
+	records := decodeRecords(t, &buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "WARN", records[0]["level"])
+	assert.Equal(t, "kept", records[0]["msg"])
+	assert.Equal(t, "value", records[0]["key"])
+}
+
+func TestNewLogger_AccessLogLevels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tt := range []struct {
+		level   string
+		records int
+	}{
+		{"info", 2},
+		{"warn", 1},
+		{"error", 0},
+	} {
+		t.Run(tt.level, func(t *testing.T) {
+			var buf bytes.Buffer
+			router := gin.New()
+			router.Use(NewSlogMiddleware(NewLogger(&buf, tt.level)))
+			router.GET("/items", func(c *gin.Context) { c.Status(200) })
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items", nil))
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+			assert.Len(t, decodeRecords(t, &buf), tt.records)
+		})
+	}
+}
+
+func TestConfigureGin(t *testing.T) {
+	t.Cleanup(func() {
+		gin.SetMode(gin.TestMode)
+		gin.DebugPrintRouteFunc = nil
+	})
+
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, "info")
+	ConfigureGin(logger, "info")
+	assert.Equal(t, gin.ReleaseMode, gin.Mode())
+
+	gin.SetMode(gin.DebugMode)
+	logger = NewLogger(&buf, "debug")
+	ConfigureGin(logger, "debug")
+	gin.New().GET("/items", func(c *gin.Context) {})
+
+	records := decodeRecords(t, &buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "route", records[0]["msg"])
+	assert.Equal(t, "GET", records[0]["method"])
+	assert.Equal(t, "/items", records[0]["path"])
+}
+
+// decodeRecords parses the JSON lines written by a slog JSON handler
+func decodeRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var record map[string]interface{}
+		require.NoError(t, decoder.Decode(&record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestNewSlogMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	router := gin.New()
+	router.Use(NewSlogMiddleware(logger), RequestIDMiddleware())
+	RegisterHealthRoutes(router, setupTestDB(t))
+	router.GET("/items", func(c *gin.Context) { c.Status(200) })
+	router.GET("/broken", func(c *gin.Context) { c.Status(500) })
+
+	for _, path := range append([]string{"/items", "/broken"}, HealthPaths...) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Request-ID", "req-"+path)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	records := decodeRecords(t, &buf)
+	require.Len(t, records, 2, "health probes must not be logged")
+
+	assert.Equal(t, "INFO", records[0]["level"])
+	assert.Equal(t, "request", records[0]["msg"])
+	assert.Equal(t, "GET", records[0]["method"])
+	assert.Equal(t, "/items", records[0]["path"])
+	assert.Equal(t, float64(200), records[0]["status"])
+	assert.Equal(t, "req-/items", records[0]["request_id"])
+	assert.Contains(t, records[0], "latency")
+
+	assert.Equal(t, "ERROR", records[1]["level"])
+	assert.Equal(t, float64(500), records[1]["status"])
+}
+
+func TestGormLogger_Trace(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	query := func() (string, int64) { return "SELECT 1", 1 }
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		mode    gormlogger.LogLevel
+		elapsed time.Duration
+		err     error
+		level   string
+	}{
+		{"query", gormlogger.Info, 0, nil, "DEBUG"},
+		{"slow query", gormlogger.Warn, 2 * DefaultSlowQueryThreshold, nil, "WARN"},
+		{"failed query", gormlogger.Error, 0, errors.New("boom"), "ERROR"},
+		{"query below mode", gormlogger.Warn, 0, nil, ""},
+		{"silent", gormlogger.Silent, 0, errors.New("boom"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			NewGormLogger(logger).LogMode(tt.mode).Trace(ctx, time.Now().Add(-tt.elapsed), query, tt.err)
+
+			records := decodeRecords(t, &buf)
+			if tt.level == "" {
+				assert.Empty(t, records)
+				return
+			}
+			require.Len(t, records, 1)
+			assert.Equal(t, tt.level, records[0]["level"])
+			assert.Equal(t, "SELECT 1", records[0]["sql"])
+			assert.Equal(t, float64(1), records[0]["rows"])
+			assert.NotContains(t, records[0], "request_id")
+			if tt.err != nil {
+				assert.Equal(t, tt.err.Error(), records[0]["error"])
+			}
+		})
+	}
+}
+
+func TestGormLogger_RequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := WithRequestID(context.Background(), "req-1")
+
+	NewGormLogger(logger).Trace(ctx, time.Now(), func() (string, int64) { return "SELECT 1", 1 }, nil)
+
+	records := decodeRecords(t, &buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "req-1", records[0]["request_id"])
+}
+
+func TestGormLogger_SlowThreshold(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	query := func() (string, int64) { return "SELECT * FROM users WHERE id = 1", 1 }
+	ctx := context.Background()
+
+	strict := NewGormLoggerWithConfig(logger, gormlogger.Config{SlowThreshold: time.Millisecond, LogLevel: gormlogger.Warn})
+	strict.Trace(ctx, time.Now().Add(-5*time.Millisecond), query, nil)
+	records := decodeRecords(t, &buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "WARN", records[0]["level"])
+	assert.Equal(t, "SELECT * FROM users WHERE id = 1", records[0]["sql"])
+	assert.GreaterOrEqual(t, records[0]["elapsed"], float64(5*time.Millisecond))
+	assert.Contains(t, records[0]["caller"], "logging_test.go:")
+
+	// Queries below the threshold, or with the threshold off, are not logged
+	buf.Reset()
+	strict.Trace(ctx, time.Now(), query, nil)
+	NewGormLoggerWithConfig(logger, gormlogger.Config{LogLevel: gormlogger.Warn}).Trace(ctx, time.Now().Add(-time.Hour), query, nil)
+	assert.Empty(t, decodeRecords(t, &buf))
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute is the path label of requests that matched no route
+const unmatchedRoute = "unmatched"
+
+// registerCollector registers c with the default registry. If an identical
+// collector is already registered, for example by another router or DAO,
+// that one is returned instead.
+func registerCollector[C prometheus.Collector](c C) C {
+	if err := prometheus.Register(c); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			if existing, ok := registered.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+// NewMetricsMiddleware returns a middleware recording the number and
+// duration of requests. Requests are labelled with the route pattern, e.g.
+// /api/v1/users/:id, rather than the URL to keep cardinality low.
+func NewMetricsMiddleware(namespace string) gin.HandlerFunc {
+	requests := registerCollector(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by method, route and status code.",
+	}, []string{"method", "path", "status"}))
+
+	duration := registerCollector(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "path"}))
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		path := c.FullPath()
+		if path == "" {
+			path = unmatchedRoute
+		}
+		requests.WithLabelValues(c.Request.Method, path, strconv.Itoa(c.Writer.Status())).Inc()
+		duration.WithLabelValues(c.Request.Method, path).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterMetricsRoute serves the default registry in the Prometheus text
+// format at GET /metrics
+func RegisterMetricsRoute(router *gin.Engine) {
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+}
+
+// InstrumentedDAO is a DAO recording the duration of its operations
+type InstrumentedDAO[T any] struct {
+	*DAO[T]
+	duration *prometheus.HistogramVec
+}
+
+// NewDAOMetrics returns a copy of dao recording every operation, from Get
+// to Import, in the db_query_duration_seconds histogram, see WithMetrics
+func NewDAOMetrics[T any](dao *DAO[T], namespace string) *InstrumentedDAO[T] {
+	instrumented := *dao
+	WithMetrics(namespace)(&instrumented.options)
+	return &InstrumentedDAO[T]{DAO: &instrumented, duration: instrumented.options.queryDuration}
+}
+
+// newQueryDuration returns the db_query_duration_seconds histogram of
+// namespace, labelled by operation
+func newQueryDuration(namespace string) *prometheus.HistogramVec {
+	return registerCollector(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Duration of database operations by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"}))
+}
+
+// operationLabel returns the metric label of a DAO operation, its name in
+// snake case such as get_by_uid for GetByUID
+func operationLabel(operation string) string {
+	var label strings.Builder
+	for i, r := range operation {
+		if i > 0 && unicode.IsUpper(r) && unicode.IsLower(rune(operation[i-1])) {
+			label.WriteByte('_')
+		}
+		label.WriteRune(unicode.ToLower(r))
+	}
+	return label.String()
+}
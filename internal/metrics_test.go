@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewMetricsMiddleware("test_middleware"))
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	NewRouter[apiv1.User](router, db).Register("/api/v1/users")
+	RegisterMetricsRoute(router)
+
+	for _, path := range []string{"/api/v1/users/1", "/api/v1/users/2", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+
+	// Requests are labelled by route pattern, not URL
+	assert.Contains(t, body, `test_middleware_http_requests_total{method="GET",path="/api/v1/users/:id",status="404"} 2`)
+	assert.Contains(t, body, `test_middleware_http_requests_total{method="GET",path="unmatched",status="404"} 1`)
+	assert.Contains(t, body, `test_middleware_http_request_duration_seconds_count{method="GET",path="/api/v1/users/:id"} 2`)
+	assert.NotContains(t, body, "/api/v1/users/1")
+
+	// Registering the middleware again reuses the collectors
+	assert.NotPanics(t, func() { NewMetricsMiddleware("test_middleware") })
+}
+
+func TestDAOMetrics(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	dao := NewDAOMetrics(NewDAO[apiv1.User](db), "test_dao")
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	assert.NoError(t, dao.Create(context.Background(), user))
+	_, err := dao.Get(context.Background(), user.ID)
+	assert.NoError(t, err)
+	_, err = dao.Get(context.Background(), user.ID)
+	assert.NoError(t, err)
+
+	// One series per operation
+	assert.Equal(t, 2, testutil.CollectAndCount(dao.duration, "test_dao_db_query_duration_seconds"))
+	assert.Equal(t, uint64(1), observations(t, dao, "create"))
+	assert.Equal(t, uint64(2), observations(t, dao, "get"))
+
+	// Every operation is recorded, also through the DAOs derived from it
+	_, err = dao.Count(context.Background(), nil)
+	assert.NoError(t, err)
+	_, err = dao.Search("test").ListByIDs(context.Background(), []uint{user.ID})
+	assert.NoError(t, err)
+	_, err = dao.Import(context.Background(), []apiv1.User{*user}, ImportSkip)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), observations(t, dao, "count"))
+	assert.Equal(t, uint64(1), observations(t, dao, "list_by_ids"))
+	assert.Equal(t, uint64(1), observations(t, dao, "import"))
+}
+
+func TestOperationLabel(t *testing.T) {
+	for operation, label := range map[string]string{
+		"Get":       "get",
+		"GetByUID":  "get_by_uid",
+		"ListByIDs": "list_by_ids",
+		"ImportAll": "import_all",
+	} {
+		assert.Equal(t, label, operationLabel(operation))
+	}
+}
+
+// observations returns how often an operation was recorded
+func observations(t *testing.T, dao *InstrumentedDAO[apiv1.User], operation string) uint64 {
+	var metric dto.Metric
+	err := dao.duration.WithLabelValues(operation).(prometheus.Histogram).Write(&metric)
+	assert.NoError(t, err)
+	return metric.GetHistogram().GetSampleCount()
+}
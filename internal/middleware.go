@@ -0,0 +1,550 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// RequestIDKey is the gin context key holding the ID of a request
+	RequestIDKey = "requestID"
+
+	// RequestIDHeader is the header carrying request IDs in both directions
+	RequestIDHeader = "X-Request-ID"
+
+	// maxRequestIDLength bounds client supplied IDs, longer ones are replaced
+	maxRequestIDLength = 128
+)
+
+// requestIDContextKey is the context key holding the ID of a request
+type requestIDContextKey struct{}
+
+// WithRequestID returns a context carrying the request ID id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestIDFromContext returns the ID stored by WithRequestID, or "" if
+// there is none
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// RequestIDMiddleware assigns every request an ID, taken from the
+// X-Request-ID header or generated as a UUID v4. The ID is stored in the gin
+// context under RequestIDKey and in the request context, so that database
+// query logs include it, and is echoed in the X-Request-ID response header.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = uuid.New().String()
+		}
+
+		c.Set(RequestIDKey, id)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// ClaimsKey is the default gin context key for the claims of a validated
+// JWT
+const ClaimsKey = "claims"
+
+// jwtClaimsKey is where NewJWTMiddleware also stores the claims for
+// GetClaims, whatever claims key it was given
+const jwtClaimsKey = "internal/jwtClaims"
+
+// errMissingToken is returned for requests without a bearer token
+var errMissingToken = errors.New("missing bearer token")
+
+// AuthOption configures NewJWTMiddleware
+type AuthOption func(*authOptions)
+
+// authOptions holds the settings applied by AuthOption values
+type authOptions struct {
+	// apiKeys checks the API keys accepted, if any
+	apiKeys Authenticator
+}
+
+// WithAPIKeys also accepts requests with an Authorization: ApiKey header
+// holding a key accepted by auth. Such requests get the claims of the owner
+// of the key, see authenticateAPIKey.
+func WithAPIKeys(auth Authenticator) AuthOption {
+	return func(o *authOptions) {
+		o.apiKeys = auth
+	}
+}
+
+// NewJWTMiddleware authenticates requests with an HMAC signed JWT in the
+// Authorization: Bearer header. The claims of a valid token are stored in
+// the gin context under claimsKey, or ClaimsKey if empty, and the subject
+// claim under ActorKey. Other requests fail with 401 Unauthorized.
+func NewJWTMiddleware(secret []byte, claimsKey string, opts ...AuthOption) gin.HandlerFunc {
+	if claimsKey == "" {
+		claimsKey = ClaimsKey
+	}
+	var options authOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		var claims jwt.MapClaims
+		var err error
+		if key, ok := cutAuthScheme(header, "ApiKey"); ok && options.apiKeys != nil {
+			claims, err = authenticateAPIKey(c.Request.Context(), options.apiKeys, key, time.Now())
+			if err != nil && !errors.Is(err, ErrInvalidAPIKey) {
+				writeInternalError(c, err)
+				c.Abort()
+				return
+			}
+		} else {
+			claims, err = parseBearerToken(secret, header)
+		}
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="play-api"`)
+			if options.apiKeys != nil {
+				c.Writer.Header().Add("WWW-Authenticate", `ApiKey realm="play-api"`)
+			}
+			writeError(c, http.StatusUnauthorized, meta.StatusReasonUnauthorized, err.Error())
+			c.Abort()
+			return
+		}
+
+		c.Set(claimsKey, claims)
+		c.Set(jwtClaimsKey, claims)
+		if subject, err := claims.GetSubject(); err == nil && subject != "" {
+			c.Set(ActorKey, subject)
+		}
+		c.Next()
+	}
+}
+
+// cutAuthScheme returns the credentials of an Authorization header value
+// using scheme
+func cutAuthScheme(header, scheme string) (string, bool) {
+	name, credentials, ok := strings.Cut(header, " ")
+	credentials = strings.TrimSpace(credentials)
+	if !ok || !strings.EqualFold(name, scheme) || credentials == "" {
+		return "", false
+	}
+	return credentials, true
+}
+
+// parseBearerToken validates the token of an Authorization header value
+func parseBearerToken(secret []byte, header string) (jwt.MapClaims, error) {
+	token, ok := cutAuthScheme(header, "Bearer")
+	if !ok {
+		return nil, errMissingToken
+	}
+	return ParseToken(secret, token)
+}
+
+// GetClaims returns the claims of the token validated by NewJWTMiddleware,
+// or nil if the request was not authenticated
+func GetClaims(c *gin.Context) jwt.MapClaims {
+	claims, _ := c.Get(jwtClaimsKey)
+	mapClaims, _ := claims.(jwt.MapClaims)
+	return mapClaims
+}
+
+// RolesClaim is the JWT claim listing the roles of the caller
+const RolesClaim = "roles"
+
+// RBACMiddleware allows requests whose JWT, validated by NewJWTMiddleware,
+// grants at least one of requiredRoles in its roles claim. Other requests
+// fail with 403 Forbidden.
+func RBACMiddleware(requiredRoles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil {
+			writeError(c, http.StatusForbidden, meta.StatusReasonForbidden, "no token claims to authorize the request")
+			c.Abort()
+			return
+		}
+
+		granted := claimRoles(claims)
+		for _, role := range requiredRoles {
+			if granted[role] {
+				c.Next()
+				return
+			}
+		}
+
+		writeError(c, http.StatusForbidden, meta.StatusReasonForbidden,
+			fmt.Sprintf("requires one of the roles: %s", strings.Join(requiredRoles, ", ")))
+		c.Abort()
+	}
+}
+
+// claimRoles returns the set of roles in the roles claim, which may be a
+// list or a single string
+func claimRoles(claims jwt.MapClaims) map[string]bool {
+	roles := make(map[string]bool)
+	switch value := claims[RolesClaim].(type) {
+	case string:
+		roles[value] = true
+	case []string:
+		for _, role := range value {
+			roles[role] = true
+		}
+	case []interface{}:
+		for _, role := range value {
+			if name, ok := role.(string); ok {
+				roles[name] = true
+			}
+		}
+	}
+	return roles
+}
+
+var (
+	// defaultCORSMethods are allowed when CORSConfig.AllowedMethods is empty
+	defaultCORSMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+	// defaultCORSHeaders are allowed when CORSConfig.AllowedHeaders is empty
+	defaultCORSHeaders = []string{"Authorization", "Content-Type", "If-Match", "If-None-Match", RequestIDHeader}
+
+	// corsExposedHeaders are the response headers the API sets for clients
+	corsExposedHeaders = []string{"ETag", "Link", "Retry-After", "X-Total-Count", RequestIDHeader}
+)
+
+// CORSConfig configures NewCORSMiddleware
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to call the API; "*" allows
+	// every origin
+	AllowedOrigins []string `yaml:"allowedOrigins"`
+
+	// AllowedMethods lists the methods allowed in cross-origin requests,
+	// defaulting to the methods used by the resource routes
+	AllowedMethods []string `yaml:"allowedMethods"`
+
+	// AllowedHeaders lists the request headers allowed in cross-origin
+	// requests, defaulting to those the API reads
+	AllowedHeaders []string `yaml:"allowedHeaders"`
+
+	// AllowCredentials lets browsers send cookies and authorization headers
+	AllowCredentials bool `yaml:"allowCredentials"`
+
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration `yaml:"maxAge"`
+}
+
+// allowsAllOrigins reports whether AllowedOrigins contains the wildcard
+func (c CORSConfig) allowsAllOrigins() bool {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate reports settings that browsers would reject
+func (c CORSConfig) Validate() error {
+	if c.allowsAllOrigins() && c.AllowCredentials {
+		return errors.New(`cors: the "*" origin cannot be combined with allowCredentials`)
+	}
+	if c.MaxAge < 0 {
+		return errors.New("cors: maxAge must not be negative")
+	}
+	return nil
+}
+
+// NewCORSMiddleware answers cross-origin requests from the allowed origins.
+// Preflight OPTIONS requests are answered with 204 No Content without
+// running later handlers. It panics if config is invalid, so check
+// CORSConfig.Validate first when the settings come from the user.
+func NewCORSMiddleware(config CORSConfig) gin.HandlerFunc {
+	if err := config.Validate(); err != nil {
+		panic(err)
+	}
+
+	allowAll := config.allowsAllOrigins()
+	origins := make(map[string]bool, len(config.AllowedOrigins))
+	for _, origin := range config.AllowedOrigins {
+		origins[strings.ToLower(origin)] = true
+	}
+
+	methods := config.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	headers := config.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(headers, ", ")
+	exposeHeaders := strings.Join(corsExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		c.Writer.Header().Add("Vary", "Origin")
+		if !allowAll && !origins[strings.ToLower(origin)] {
+			if preflight {
+				writeError(c, http.StatusForbidden, meta.StatusReasonForbidden, "origin not allowed")
+				c.Abort()
+				return
+			}
+			// Without CORS headers the browser hides the response
+			c.Next()
+			return
+		}
+
+		if allowAll {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			c.Header("Access-Control-Expose-Headers", exposeHeaders)
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Methods", allowMethods)
+		c.Header("Access-Control-Allow-Headers", allowHeaders)
+		if config.MaxAge > 0 {
+			c.Header("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// DeprecationMiddleware marks the responses of a deprecated API version
+// with the Deprecation and Sunset headers, sunsetDate being an HTTP date.
+// A warning is logged the first time each client IP uses the version.
+func DeprecationMiddleware(version, sunsetDate string) gin.HandlerFunc {
+	var warned sync.Map
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Sunset", sunsetDate)
+
+		if _, seen := warned.LoadOrStore(c.ClientIP(), true); !seen {
+			slog.WarnContext(c.Request.Context(), "Deprecated API version used",
+				"version", version, "sunset", sunsetDate, "client_ip", c.ClientIP())
+		}
+		c.Next()
+	}
+}
+
+// MaxBodySizeMiddleware rejects requests whose body is larger than maxBytes
+// with 413 Request Entity Too Large. Bodies within the limit are read into
+// memory before the handler runs, so that an oversized body is detected
+// even without a Content-Length header.
+func MaxBodySizeMiddleware(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxBytes {
+			writeBodyTooLarge(c, maxBytes)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBytes+1))
+		c.Request.Body.Close()
+		if err != nil {
+			writeBadRequest(c, "failed to read request body")
+			c.Abort()
+			return
+		}
+		if int64(len(body)) > maxBytes {
+			writeBodyTooLarge(c, maxBytes)
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
+
+// writeBodyTooLarge writes a 413 and stops the handler chain
+func writeBodyTooLarge(c *gin.Context, maxBytes int64) {
+	c.Header("Connection", "close")
+	writeError(c, http.StatusRequestEntityTooLarge, meta.StatusReasonRequestEntityTooLarge,
+		fmt.Sprintf("request body exceeds the limit of %d bytes", maxBytes))
+	c.Abort()
+}
+
+// DefaultTimeout is the handler timeout of resource routes
+const DefaultTimeout = 30 * time.Second
+
+// TimeoutMiddleware cancels the request context after d and responds 503
+// Service Unavailable if the handler has not started its response by then.
+// The handler runs in its own goroutine; the middleware still waits for it
+// to return, so handlers should stop once their context is done.
+func TimeoutMiddleware(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		writer := &timeoutWriter{ResponseWriter: c.Writer, header: c.Writer.Header().Clone()}
+		c.Writer = writer
+
+		done := make(chan struct{})
+		var panicked interface{}
+		go func() {
+			defer close(done)
+			defer func() { panicked = recover() }()
+			c.Next()
+		}()
+
+		select {
+		case <-done:
+			// Responses without a body still need their status and headers
+			writer.claim()
+		case <-ctx.Done():
+			writer.timeout()
+			<-done
+		}
+
+		c.Writer = writer.ResponseWriter
+		if panicked != nil {
+			panic(panicked)
+		}
+	}
+}
+
+// timeoutWriter lets either the handler or the timeout write the response,
+// whichever starts first. Headers set by the handler are kept aside until it
+// writes, so that they never mix with the timeout response.
+type timeoutWriter struct {
+	gin.ResponseWriter
+
+	// header collects the headers set by the handler
+	header http.Header
+
+	// code is the status set by the handler, written with the headers once
+	// the handler claims the response. Renderers set their Content-Type
+	// after the status, so writing it right away would lose the header.
+	code int
+
+	// respond decides who writes the response
+	respond  sync.Once
+	timedOut bool
+}
+
+// Header returns the headers of the handler response
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+// claim reports whether the handler may write, copying its headers to the
+// response the first time
+func (w *timeoutWriter) claim() bool {
+	w.respond.Do(func() {
+		target := w.ResponseWriter.Header()
+		for key := range target {
+			if _, ok := w.header[key]; !ok {
+				target.Del(key)
+			}
+		}
+		for key, values := range w.header {
+			target[key] = values
+		}
+		if w.code != 0 {
+			w.ResponseWriter.WriteHeader(w.code)
+		}
+	})
+	return !w.timedOut
+}
+
+// timeout writes the 503 response unless the handler already started its
+// own
+func (w *timeoutWriter) timeout() {
+	w.respond.Do(func() {
+		w.timedOut = true
+		body, _ := json.Marshal(meta.Status{
+			Code:    http.StatusServiceUnavailable,
+			Reason:  meta.StatusReasonServiceUnavailable,
+			Message: "request timed out",
+		})
+		header := w.ResponseWriter.Header()
+		header.Set("Content-Type", "application/json; charset=utf-8")
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+		w.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+		w.ResponseWriter.Write(body)
+		w.ResponseWriter.Flush()
+	})
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+// Status implements gin.ResponseWriter
+func (w *timeoutWriter) Status() int {
+	if w.code != 0 {
+		return w.code
+	}
+	return w.ResponseWriter.Status()
+}
+
+// WriteHeaderNow implements gin.ResponseWriter
+func (w *timeoutWriter) WriteHeaderNow() {
+	if w.claim() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Write implements http.ResponseWriter
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	if !w.claim() {
+		return 0, http.ErrHandlerTimeout
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString implements gin.ResponseWriter
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	if !w.claim() {
+		return 0, http.ErrHandlerTimeout
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Flush implements http.Flusher
+func (w *timeoutWriter) Flush() {
+	if w.claim() {
+		w.ResponseWriter.Flush()
+	}
+}
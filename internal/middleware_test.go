@@ -0,0 +1,684 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestIDMiddleware())
+
+	var fromGin, fromContext string
+	router.GET("/", func(c *gin.Context) {
+		fromGin = c.GetString(RequestIDKey)
+		fromContext = RequestIDFromContext(c.Request.Context())
+	})
+
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{"propagates incoming ID", "abc-123", "abc-123"},
+		{"generates missing ID", "", ""},
+		{"replaces oversized ID", strings.Repeat("x", maxRequestIDLength+1), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if tt.expected != "" {
+				assert.Equal(t, tt.expected, id)
+			} else {
+				parsed, err := uuid.Parse(id)
+				assert.NoError(t, err)
+				assert.Equal(t, uuid.Version(4), parsed.Version())
+			}
+			assert.Equal(t, id, fromGin)
+			assert.Equal(t, id, fromContext)
+		})
+	}
+}
+
+var testSecret = []byte("test-secret")
+
+// signToken returns a token for claims signed with method and secret
+func signToken(t *testing.T, method jwt.SigningMethod, secret interface{}, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(method, claims).SignedString(secret)
+	require.NoError(t, err)
+	return token
+}
+
+func TestNewJWTMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewJWTMiddleware(testSecret, "auth"))
+
+	var claims, fromKey interface{}
+	var actor string
+	router.GET("/", func(c *gin.Context) {
+		claims = GetClaims(c)
+		fromKey, _ = c.Get("auth")
+		actor = c.GetString(ActorKey)
+	})
+
+	valid := signToken(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{
+		"sub": "alice",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	expired := signToken(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{
+		"sub": "alice",
+		"exp": time.Now().Add(-time.Hour).Unix(),
+	})
+	wrongSecret := signToken(t, jwt.SigningMethodHS256, []byte("other"), jwt.MapClaims{"sub": "alice"})
+	unsigned := signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, jwt.MapClaims{"sub": "alice"})
+
+	tests := []struct {
+		name   string
+		header string
+		code   int
+	}{
+		{"valid token", "Bearer " + valid, http.StatusOK},
+		{"lowercase scheme", "bearer " + valid, http.StatusOK},
+		{"missing header", "", http.StatusUnauthorized},
+		{"basic auth", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{"empty token", "Bearer ", http.StatusUnauthorized},
+		{"malformed token", "Bearer not-a-jwt", http.StatusUnauthorized},
+		{"expired token", "Bearer " + expired, http.StatusUnauthorized},
+		{"wrong secret", "Bearer " + wrongSecret, http.StatusUnauthorized},
+		{"unsigned token", "Bearer " + unsigned, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, fromKey, actor = nil, nil, ""
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code != http.StatusOK {
+				assert.Equal(t, meta.StatusReasonUnauthorized, decodeStatus(t, w).Reason)
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+				assert.Nil(t, claims)
+				return
+			}
+
+			require.IsType(t, jwt.MapClaims{}, claims)
+			assert.Equal(t, "alice", claims.(jwt.MapClaims)["sub"])
+			assert.Equal(t, claims, fromKey)
+			assert.Equal(t, "alice", actor)
+		})
+	}
+}
+
+func TestGetClaims_Unauthenticated(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	assert.Nil(t, GetClaims(c))
+}
+
+func TestWithAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	NewRouter[apiv1.User](engine, db, WithAuth(NewJWTMiddleware(testSecret, ""), "GET", "GET /:id")).
+		Register("/api/v1/users")
+
+	token := signToken(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{"sub": "alice"})
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		code   int
+	}{
+		{"skipped list", "GET", "/api/v1/users", "", http.StatusOK},
+		{"skipped get", "GET", "/api/v1/users/1", "", http.StatusNotFound},
+		{"protected status", "GET", "/api/v1/users/1/status", "", http.StatusUnauthorized},
+		{"protected delete", "DELETE", "/api/v1/users/1", "", http.StatusUnauthorized},
+		{"authenticated delete", "DELETE", "/api/v1/users/1", token, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+}
+
+func TestRBACMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		code   int
+	}{
+		{"missing claims", nil, http.StatusForbidden},
+		{"no roles claim", jwt.MapClaims{"sub": "alice"}, http.StatusForbidden},
+		{"empty roles", jwt.MapClaims{"roles": []interface{}{}}, http.StatusForbidden},
+		{"other role", jwt.MapClaims{"roles": []interface{}{"viewer"}}, http.StatusForbidden},
+		{"first required role", jwt.MapClaims{"roles": []interface{}{"viewer", "editor"}}, http.StatusOK},
+		{"second required role", jwt.MapClaims{"roles": []interface{}{"admin"}}, http.StatusOK},
+		{"single role string", jwt.MapClaims{"roles": "admin"}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.claims != nil {
+					c.Set(jwtClaimsKey, tt.claims)
+				}
+			})
+			router.Use(RBACMiddleware("editor", "admin"))
+			router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == http.StatusForbidden {
+				assert.Equal(t, meta.StatusReasonForbidden, decodeStatus(t, w).Reason)
+			}
+		})
+	}
+}
+
+func TestWithRoleRequirements(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	NewRouter[apiv1.User](engine, db,
+		WithAuth(NewJWTMiddleware(testSecret, "")),
+		WithRoleRequirements(map[string][]string{
+			"DELETE": {"admin"},
+			"POST":   {"editor", "admin"},
+		}),
+	).Register("/api/v1/users")
+
+	tokenWith := func(roles ...interface{}) string {
+		return signToken(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{"sub": "alice", "roles": roles})
+	}
+	viewer, editor, admin := tokenWith("viewer"), tokenWith("editor"), tokenWith("admin")
+	user := `{"username":"alice","email":"alice@example.com","password":"password123"}`
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		token  string
+		code   int
+	}{
+		{"list without requirement", "GET", "/api/v1/users", "", viewer, http.StatusOK},
+		{"create as viewer", "POST", "/api/v1/users", user, viewer, http.StatusForbidden},
+		{"create as editor", "POST", "/api/v1/users", user, editor, http.StatusCreated},
+		{"delete as editor", "DELETE", "/api/v1/users/1", "", editor, http.StatusForbidden},
+		{"delete as admin", "DELETE", "/api/v1/users/1", "", admin, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestWithRoleRequirements_AnyMethod(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	NewRouter[apiv1.User](engine, db,
+		WithAuth(NewJWTMiddleware(testSecret, "")),
+		WithRoleRequirements(map[string][]string{
+			AnyMethod: {"admin"},
+			"GET":     {"viewer", "admin"},
+		}),
+	).Register("/api/v1/users")
+
+	viewer := signToken(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{"sub": "alice", "roles": []interface{}{"viewer"}})
+	serve := func(method string) int {
+		req := httptest.NewRequest(method, "/api/v1/users/1", strings.NewReader(`{"fullName":"Alice"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+viewer)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Methods without requirements of their own fall back to AnyMethod
+	assert.Equal(t, http.StatusNotFound, serve("GET"))
+	for _, method := range []string{"HEAD", "PATCH", "PUT", "DELETE"} {
+		assert.Equal(t, http.StatusForbidden, serve(method), method)
+	}
+}
+
+func TestCORSConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config CORSConfig
+		valid  bool
+	}{
+		{"empty", CORSConfig{}, true},
+		{"origins with credentials", CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true}, true},
+		{"wildcard", CORSConfig{AllowedOrigins: []string{"*"}}, true},
+		{"wildcard with credentials", CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}, false},
+		{"negative max age", CORSConfig{MaxAge: -time.Second}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.Panics(t, func() { NewCORSMiddleware(tt.config) })
+			}
+		})
+	}
+}
+
+func TestNewCORSMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	restricted := CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	tests := []struct {
+		name        string
+		config      CORSConfig
+		method      string
+		origin      string
+		preflight   bool
+		code        int
+		allowOrigin string
+	}{
+		{"same origin", restricted, "GET", "", false, http.StatusOK, ""},
+		{"allowed origin", restricted, "GET", "https://app.example.com", false, http.StatusOK, "https://app.example.com"},
+		{"disallowed origin", restricted, "GET", "https://evil.example.com", false, http.StatusOK, ""},
+		{"allowed preflight", restricted, "OPTIONS", "https://APP.example.com", true, http.StatusNoContent, "https://APP.example.com"},
+		{"disallowed preflight", restricted, "OPTIONS", "https://evil.example.com", true, http.StatusForbidden, ""},
+		{"wildcard", CORSConfig{AllowedOrigins: []string{"*"}}, "GET", "https://any.example.com", false, http.StatusOK, "*"},
+		{"wildcard preflight", CORSConfig{AllowedOrigins: []string{"*"}}, "OPTIONS", "https://any.example.com", true, http.StatusNoContent, "*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(NewCORSMiddleware(tt.config))
+			router.Handle(tt.method, "/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", "POST")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.allowOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+			if tt.allowOrigin == "" {
+				return
+			}
+
+			if tt.config.AllowCredentials {
+				assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+			} else {
+				assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+			}
+			if !tt.preflight {
+				assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "ETag")
+				return
+			}
+			if tt.config.AllowedMethods != nil {
+				assert.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+				assert.Equal(t, "Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+				assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+			} else {
+				assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "DELETE")
+				assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+				assert.Empty(t, w.Header().Get("Access-Control-Max-Age"))
+			}
+		})
+	}
+}
+
+func TestRegister_OptionsRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(NewCORSMiddleware(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}))
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	NewRouter[apiv1.User](engine, db, WithAuth(NewJWTMiddleware(testSecret, ""))).Register("/api/v1/users")
+	RegisterResource[TestModel](engine, db, "/models")
+
+	tests := []struct {
+		path  string
+		allow []string
+	}{
+		{"/api/v1/users", []string{"GET", "HEAD", "POST", "DELETE", "OPTIONS"}},
+		{"/api/v1/users/1", []string{"GET", "HEAD", "PUT", "PATCH", "DELETE", "OPTIONS"}},
+		{"/api/v1/users/1/status", []string{"GET", "PUT", "OPTIONS"}},
+		{"/models/1", []string{"GET", "PUT", "DELETE", "OPTIONS"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest("OPTIONS", tt.path, nil))
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.ElementsMatch(t, tt.allow, strings.Split(w.Header().Get("Allow"), ", "))
+
+			// Preflight requests are answered before authentication
+			req := httptest.NewRequest("OPTIONS", tt.path, nil)
+			req.Header.Set("Origin", "https://app.example.com")
+			req.Header.Set("Access-Control-Request-Method", "DELETE")
+			w = httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		})
+	}
+}
+
+func TestMaxBodySizeMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const limit = 16
+
+	router := gin.New()
+	router.Use(MaxBodySizeMiddleware(limit))
+	var received []byte
+	router.POST("/", func(c *gin.Context) {
+		received, _ = io.ReadAll(c.Request.Body)
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		size          int
+		contentLength bool
+		code          int
+	}{
+		{"empty", 0, true, http.StatusOK},
+		{"at limit", limit, true, http.StatusOK},
+		{"over limit", limit + 1, true, http.StatusRequestEntityTooLarge},
+		{"at limit without length", limit, false, http.StatusOK},
+		{"over limit without length", limit + 1, false, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = nil
+			body := bytes.Repeat([]byte("x"), tt.size)
+			req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+			if !tt.contentLength {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == http.StatusOK {
+				assert.Equal(t, body, append([]byte{}, received...))
+			} else {
+				assert.Nil(t, received)
+				assert.Equal(t, meta.StatusReasonRequestEntityTooLarge, decodeStatus(t, w).Reason)
+			}
+		})
+	}
+}
+
+func TestWithMaxBodySize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	RegisterResource[apiv1.User](engine, db, "/default")
+	RegisterResource[apiv1.User](engine, db, "/small", WithMaxBodySize(128))
+	RegisterResource[apiv1.User](engine, db, "/unlimited", WithMaxBodySize(0))
+
+	// Padding the full name gives a valid user of any size
+	body := func(name string, size int) string {
+		prefix := fmt.Sprintf(`{"username":"%s","email":"%s@example.com","password":"password123","fullName":"`, name, name)
+		return prefix + strings.Repeat("x", size-len(prefix)-2) + `"}`
+	}
+
+	tests := []struct {
+		path string
+		name string
+		size int
+		code int
+	}{
+		{"/default", "alice", DefaultMaxBodySize + 1, http.StatusRequestEntityTooLarge},
+		{"/small", "bob", 128, http.StatusCreated},
+		{"/small", "carol", 129, http.StatusRequestEntityTooLarge},
+		{"/unlimited", "dave", DefaultMaxBodySize + 1, http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+"/"+tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(body(tt.name, tt.size)))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+		})
+	}
+
+	// Streaming routes are exempt from the limit
+	group := engine.Group("/stream")
+	newRouterOptions(WithMaxBodySize(4)).use(group, "TestModel")
+	group.POST("/import", func(c *gin.Context) { c.Status(http.StatusOK) })
+	group.POST("/other", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for path, code := range map[string]int{"/stream/import": http.StatusOK, "/stream/other": http.StatusRequestEntityTooLarge} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader("too large")))
+		assert.Equal(t, code, w.Code, path)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestIDMiddleware(), TimeoutMiddleware(50*time.Millisecond))
+
+	gate := make(chan struct{})
+	var writeErr error
+	var ctxErr error
+	router.GET("/slow", func(c *gin.Context) {
+		// Block until the test opens the gate, well after the deadline
+		<-gate
+		ctxErr = c.Request.Context().Err()
+		c.Header("X-Handler", "slow")
+		c.Status(http.StatusOK)
+		_, writeErr = c.Writer.WriteString("late")
+	})
+	router.GET("/fast", func(c *gin.Context) {
+		c.Header("X-Handler", "fast")
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	router.GET("/empty", func(c *gin.Context) {
+		c.Header("X-Handler", "empty")
+		c.Status(http.StatusNoContent)
+	})
+
+	t.Run("slow handler", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		served := make(chan struct{})
+		go func() {
+			defer close(served)
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+		}()
+
+		// The middleware waits for the handler even after the deadline
+		select {
+		case <-served:
+			t.Fatal("ServeHTTP returned before the handler")
+		case <-time.After(200 * time.Millisecond):
+		}
+		close(gate)
+		<-served
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, meta.StatusReasonServiceUnavailable, decodeStatus(t, w).Reason)
+		assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+		assert.Empty(t, w.Header().Get("X-Handler"))
+		assert.ErrorIs(t, ctxErr, context.DeadlineExceeded)
+		assert.ErrorIs(t, writeErr, http.ErrHandlerTimeout)
+	})
+
+	t.Run("fast handler", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/fast", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "ok", w.Body.String())
+		assert.Equal(t, "fast", w.Header().Get("X-Handler"))
+		assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+	})
+
+	t.Run("handler without body", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/empty", nil))
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "empty", w.Header().Get("X-Handler"))
+	})
+
+	t.Run("panicking handler", func(t *testing.T) {
+		assert.PanicsWithValue(t, "boom", func() {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+		})
+	})
+}
+
+func TestWithTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+
+	// slow waits for its context, so it only returns once timed out
+	slow := func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+		c.Status(http.StatusOK)
+	}
+	for path, opts := range map[string][]RouterOption{
+		"/limited":   {WithTimeout(20 * time.Millisecond)},
+		"/unlimited": {WithTimeout(0)},
+	} {
+		group := engine.Group(path)
+		newRouterOptions(opts...).use(group, "TestModel")
+		group.GET("", slow)
+	}
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/limited", http.StatusServiceUnavailable},
+		{"/limited?watch=true", http.StatusOK},
+		{"/unlimited", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+	assert.Equal(t, DefaultTimeout, newRouterOptions().timeout)
+}
+
+func TestDeprecationMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(previous)
+
+	router := gin.New()
+	router.Use(DeprecationMiddleware("v1", "Wed, 31 Dec 2025 23:59:59 GMT"))
+	router.GET("/items", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, addr := range []string{"192.0.2.1:1000", "192.0.2.1:1001", "192.0.2.2:1000"} {
+		req := httptest.NewRequest("GET", "/items", nil)
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "true", w.Header().Get("Deprecation"))
+		assert.Equal(t, "Wed, 31 Dec 2025 23:59:59 GMT", w.Header().Get("Sunset"))
+	}
+
+	// One warning per client IP
+	records := decodeRecords(t, &buf)
+	require.Len(t, records, 2)
+	for i, ip := range []string{"192.0.2.1", "192.0.2.2"} {
+		assert.Equal(t, "WARN", records[i]["level"])
+		assert.Equal(t, "v1", records[i]["version"])
+		assert.Equal(t, ip, records[i]["client_ip"])
+	}
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// errPreviewRollback rolls back the transaction of MigratePreview
+var errPreviewRollback = errors.New("migration preview rolled back")
+
+// MigratePreview returns the SQL statements that AutoMigrate would execute
+// for models, without changing the database: the migration runs in a
+// transaction that is rolled back, and the statements it executed are
+// collected rather than logged. Queries that only read, such as those
+// inspecting the existing tables, are left out. The preview relies on
+// transactional DDL, which SQLite and PostgreSQL support; MySQL commits
+// DDL statements implicitly, so it must not be previewed against a
+// database that matters.
+func MigratePreview(db *gorm.DB, models ...interface{}) ([]string, error) {
+	collector := &sqlCollector{}
+	session := db.Session(&gorm.Session{Logger: collector})
+
+	err := session.Transaction(func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(models...); err != nil {
+			return err
+		}
+		return errPreviewRollback
+	})
+	if !errors.Is(err, errPreviewRollback) {
+		return nil, err
+	}
+	return collector.statements, nil
+}
+
+// readOnlyStatements are the leading keywords of statements left out of a
+// migration preview
+var readOnlyStatements = []string{"SELECT", "PRAGMA", "SHOW", "WITH", "DESCRIBE", "EXPLAIN"}
+
+// sqlCollector is a GORM logger collecting the statements that change the
+// database instead of writing anything
+type sqlCollector struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+// LogMode returns the collector, which ignores levels
+func (c *sqlCollector) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return c
+}
+
+// Info discards the message
+func (c *sqlCollector) Info(context.Context, string, ...interface{}) {}
+
+// Warn discards the message
+func (c *sqlCollector) Warn(context.Context, string, ...interface{}) {}
+
+// Error discards the message
+func (c *sqlCollector) Error(context.Context, string, ...interface{}) {}
+
+// Trace collects a statement that ran successfully and does not only read
+func (c *sqlCollector) Trace(_ context.Context, _ time.Time, fc func() (string, int64), err error) {
+	if err != nil {
+		return
+	}
+	sql, _ := fc()
+	keyword, _, _ := strings.Cut(strings.TrimSpace(sql), " ")
+	for _, readOnly := range readOnlyStatements {
+		if strings.EqualFold(keyword, readOnly) {
+			return
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.statements = append(c.statements, sql)
+}
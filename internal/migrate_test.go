@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMigratePreview(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "preview.db")), &gorm.Config{})
+	require.NoError(t, err)
+	defer cleanupTestDB(t, db)
+
+	statements, err := MigratePreview(db, &apiv1.User{})
+	require.NoError(t, err)
+	require.NotEmpty(t, statements)
+	assert.True(t, strings.HasPrefix(statements[0], "CREATE TABLE `users`"), statements[0])
+	for _, statement := range statements {
+		assert.False(t, strings.HasPrefix(statement, "SELECT"), statement)
+	}
+
+	// Nothing was executed
+	assert.False(t, db.Migrator().HasTable(&apiv1.User{}))
+
+	// Only the missing parts of migrated tables are previewed
+	require.NoError(t, db.AutoMigrate(&apiv1.User{}))
+	statements, err = MigratePreview(db, &apiv1.User{}, &TestModel{})
+	require.NoError(t, err)
+	for _, statement := range statements {
+		assert.NotContains(t, statement, "`users`")
+	}
+	assert.Contains(t, strings.Join(statements, "\n"), "CREATE TABLE `test_models`")
+}
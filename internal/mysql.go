@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"strings"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// mysqlDialector is the MySQL dialector with a migrator that understands
+// partial unique indexes
+type mysqlDialector struct {
+	*mysql.Dialector
+}
+
+// newMySQLDialector returns the MySQL dialector for dsn
+func newMySQLDialector(dsn string) gorm.Dialector {
+	return mysqlDialector{mysql.Open(dsn).(*mysql.Dialector)}
+}
+
+// Migrator returns a mysqlMigrator. Indexes are created after their table
+// so that partial ones go through mysqlMigrator.CreateIndex.
+func (d mysqlDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return mysqlMigrator{mysql.Migrator{
+		Migrator: migrator.Migrator{
+			Config: migrator.Config{
+				CreateIndexAfterCreateTable: true,
+				DB:                          db,
+				Dialector:                   d,
+			},
+		},
+		Dialector: *d.Dialector,
+	}}
+}
+
+// mysqlMigrator creates the unique indexes declared with a where: condition,
+// such as uniqueIndex:,where:deleted_at IS NULL. MySQL has no partial
+// indexes and GORM would drop the condition, making a deleted row hold its
+// value forever. Instead each column is indexed as the expression
+// CASE WHEN <condition> THEN <column> END, which is NULL for the rows
+// outside the condition, and MySQL does not compare NULLs in unique indexes.
+// Functional key parts need MySQL 8.0.13 or later.
+//
+// Databases migrated before this keep their plain unique index until it is
+// dropped, after which the next migration creates the partial one.
+type mysqlMigrator struct {
+	mysql.Migrator
+}
+
+// CreateIndex creates the index name, partial unique indexes on expressions
+func (m mysqlMigrator) CreateIndex(value interface{}, name string) error {
+	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		idx := partialUniqueIndex(stmt.Schema, name)
+		if idx == nil {
+			return m.Migrator.CreateIndex(value, name)
+		}
+
+		parts := make([]string, 0, len(idx.Fields))
+		for _, field := range idx.Fields {
+			parts = append(parts, "(CASE WHEN "+idx.Where+" THEN "+stmt.Quote(field.DBName)+" END)")
+		}
+		sql := "CREATE UNIQUE INDEX ? ON ? (" + strings.Join(parts, ",") + ")"
+		return m.DB.Exec(sql, clause.Column{Name: idx.Name}, m.CurrentTable(stmt)).Error
+	})
+}
+
+// MigrateColumnUnique leaves the columns of partial unique indexes alone:
+// they are not unique themselves, and the driver would otherwise try to
+// create their index again on every migration
+func (m mysqlMigrator) MigrateColumnUnique(value interface{}, field *schema.Field, columnType gorm.ColumnType) error {
+	if partialUniqueIndex(field.Schema, field.UniqueIndex) != nil {
+		return nil
+	}
+	return m.Migrator.MigrateColumnUnique(value, field, columnType)
+}
+
+// partialUniqueIndex returns the index of s called name when it is unique
+// and has a where: condition
+func partialUniqueIndex(s *schema.Schema, name string) *schema.Index {
+	if s == nil || name == "" {
+		return nil
+	}
+	idx := s.LookIndex(name)
+	if idx == nil || idx.Where == "" || !strings.EqualFold(idx.Class, "UNIQUE") {
+		return nil
+	}
+	return idx
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"testing"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// openMySQLDryRun returns a MySQL database that records its statements in
+// collector instead of connecting
+func openMySQLDryRun(t *testing.T, collector *sqlCollector) *gorm.DB {
+	dialector := mysqlDialector{mysql.New(mysql.Config{
+		DSN:                       "user:password@tcp(127.0.0.1:3306)/test",
+		SkipInitializeWithVersion: true,
+	}).(*mysql.Dialector)}
+	db, err := gorm.Open(dialector, &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               collector,
+	})
+	require.NoError(t, err)
+	return db
+}
+
+func TestMySQLMigrator_PartialUniqueIndex(t *testing.T) {
+	collector := &sqlCollector{}
+	db := openMySQLDryRun(t, collector)
+	migrator := db.Migrator()
+
+	// MySQL has no partial indexes: the condition selects the values that
+	// are indexed, other rows index NULL and never conflict
+	require.NoError(t, migrator.CreateIndex(&apiv1.User{}, "idx_users_username"))
+	require.NoError(t, migrator.CreateIndex(&apiv1.User{}, "idx_users_name"))
+
+	// Plain indexes are unchanged
+	require.NoError(t, migrator.CreateIndex(&apiv1.User{}, "idx_users_uid"))
+
+	assert.Equal(t, []string{
+		"CREATE UNIQUE INDEX `idx_users_username` ON `users` ((CASE WHEN deleted_at IS NULL THEN `username` END))",
+		"CREATE UNIQUE INDEX `idx_users_name` ON `users` ((CASE WHEN name <> '' AND deleted_at IS NULL THEN `name` END))",
+		"CREATE INDEX `idx_users_uid` ON `users`(`uid`)",
+	}, collector.statements)
+}
+
+func TestMySQLMigrator_CreateTable(t *testing.T) {
+	collector := &sqlCollector{}
+	db := openMySQLDryRun(t, collector)
+
+	require.NoError(t, db.Migrator().CreateTable(&apiv1.User{}))
+
+	// The indexes follow the table so that partial ones keep their condition
+	require.NotEmpty(t, collector.statements)
+	assert.NotContains(t, collector.statements[0], "INDEX")
+	assert.Contains(t, collector.statements,
+		"CREATE UNIQUE INDEX `idx_users_email` ON `users` ((CASE WHEN deleted_at IS NULL THEN `email` END))")
+}
+
+func TestNewMySQLDialector(t *testing.T) {
+	dialector := newMySQLDialector("user:password@tcp(127.0.0.1:3306)/test")
+	assert.Equal(t, "mysql", dialector.Name())
+	_, ok := dialector.Migrator(&gorm.DB{Config: &gorm.Config{}}).(mysqlMigrator)
+	assert.True(t, ok)
+}
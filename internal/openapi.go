@@ -0,0 +1,500 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"my-embedded-api/meta"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// OpenAPIPath is where RegisterOpenAPI serves the spec
+const OpenAPIPath = "/openapi.json"
+
+// DocsPath is where RegisterOpenAPI serves a Swagger UI of the spec
+const DocsPath = "/docs"
+
+// docsPage loads Swagger UI from a CDN and points it at OpenAPIPath
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>API documentation</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "` + OpenAPIPath + `", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+// OpenAPIGenerator builds an OpenAPI 3.0 spec of the resources registered
+// with the WithOpenAPI option. Schemas are derived from the resource
+// structs: JSON tags name the properties, binding tags mark them required
+// and gorm tags add constraints such as maxLength. An openapi tag can mark
+// a property readOnly or writeOnly. Named structs become components
+// referenced with $ref.
+type OpenAPIGenerator struct {
+	mu   sync.Mutex
+	spec *openapi3.T
+
+	// components maps each struct type to its component name
+	components map[reflect.Type]string
+}
+
+// openAPIRoutes describes the routes documented for a resource
+type openAPIRoutes struct {
+	// paginated lists return a ListResponse rather than an array
+	paginated bool
+
+	// patch documents the JSON Merge Patch route
+	patch bool
+
+	// filters documents filtering lists by column values
+	filters bool
+
+	// fields documents the fields and include list parameters, and watch
+	// lists
+	fields bool
+
+	// readOnly leaves out the routes creating, changing and deleting
+	readOnly bool
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	deletedAtType     = reflect.TypeOf(gorm.DeletedAt{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	namedResourceType = reflect.TypeOf((*namedResource)(nil)).Elem()
+)
+
+// NewOpenAPIGenerator creates a generator for an API with the given title
+// and version
+func NewOpenAPIGenerator(title, version string) *OpenAPIGenerator {
+	g := &OpenAPIGenerator{
+		spec: &openapi3.T{
+			OpenAPI:    "3.0.3",
+			Info:       &openapi3.Info{Title: title, Version: version},
+			Paths:      openapi3.NewPaths(),
+			Components: &openapi3.Components{Schemas: openapi3.Schemas{}},
+		},
+		components: make(map[reflect.Type]string),
+	}
+	// Every error response is a meta.Status
+	g.schemaRef(reflect.TypeOf(meta.Status{}))
+	return g
+}
+
+// Spec returns the generated spec. It must not be modified, and is only
+// consistent once every resource is registered.
+func (g *OpenAPIGenerator) Spec() *openapi3.T {
+	return g.spec
+}
+
+// MarshalJSON encodes the generated spec
+func (g *OpenAPIGenerator) MarshalJSON() ([]byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return json.Marshal(g.spec)
+}
+
+// RegisterOpenAPI serves the spec of gen at OpenAPIPath, and a Swagger UI
+// of it at DocsPath
+func RegisterOpenAPI(engine *gin.Engine, gen *OpenAPIGenerator) {
+	engine.GET(OpenAPIPath, func(c *gin.Context) {
+		data, err := gen.MarshalJSON()
+		if err != nil {
+			writeInternalError(c, err)
+			return
+		}
+		c.Data(http.StatusOK, "application/json", data)
+	})
+	engine.GET(DocsPath, func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+	})
+}
+
+// addResource documents the CRUD routes of the resource type t served
+// under path
+func (g *OpenAPIGenerator) addResource(path string, t reflect.Type, routes openAPIRoutes) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	resource := g.schemaRef(t)
+	name := operationName(path)
+
+	list := openapi3.NewArraySchema()
+	list.Items = resource
+	if routes.paginated {
+		list = openapi3.NewObjectSchema().
+			WithProperty("items", list).
+			WithProperty("total", openapi3.NewInt64Schema()).
+			WithProperty("page", openapi3.NewIntegerSchema()).
+			WithProperty("size", openapi3.NewIntegerSchema()).
+			WithProperty("nextCursor", openapi3.NewStringSchema())
+	}
+
+	collection := &openapi3.PathItem{}
+	listOp := g.operation("list"+name, nil,
+		openAPIResponse(http.StatusOK, list.NewRef()),
+		g.errorResponse(http.StatusBadRequest))
+	listOp.Parameters = listParameters(routes)
+	collection.SetOperation(http.MethodGet, listOp)
+	if !routes.readOnly {
+		collection.SetOperation(http.MethodPost, g.operation("create"+name, resource,
+			openAPIResponse(http.StatusCreated, resource),
+			g.errorResponse(http.StatusBadRequest),
+			g.errorResponse(http.StatusConflict)))
+	}
+	g.spec.Paths.Set(path, collection)
+
+	// Named resources are also found by name, see parseIDParam
+	id := openapi3.NewIntegerSchema().WithMin(1)
+	if reflect.PointerTo(t).Implements(namedResourceType) {
+		id = openapi3.NewOneOfSchema(id, openapi3.NewStringSchema().WithPattern(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`))
+	}
+	item := &openapi3.PathItem{
+		Parameters: openapi3.Parameters{{Value: openapi3.NewPathParameter("id").WithSchema(id)}},
+	}
+	item.SetOperation(http.MethodGet, g.operation("get"+name, nil,
+		openAPIResponse(http.StatusOK, resource),
+		g.errorResponse(http.StatusNotFound)))
+	if routes.readOnly {
+		g.spec.Paths.Set(path+"/{id}", item)
+		return
+	}
+	item.SetOperation(http.MethodPut, g.operation("update"+name, resource,
+		openAPIResponse(http.StatusOK, resource),
+		g.errorResponse(http.StatusBadRequest),
+		g.errorResponse(http.StatusNotFound),
+		g.errorResponse(http.StatusConflict)))
+	if routes.patch {
+		patch := g.operation("patch"+name, nil,
+			openAPIResponse(http.StatusOK, resource),
+			g.errorResponse(http.StatusBadRequest),
+			g.errorResponse(http.StatusNotFound),
+			g.errorResponse(http.StatusUnsupportedMediaType))
+		patch.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).
+			WithContent(openapi3.NewContentWithSchema(openapi3.NewObjectSchema(), []string{MergePatchContentType}))}
+		item.SetOperation(http.MethodPatch, patch)
+	}
+	item.SetOperation(http.MethodDelete, g.operation("delete"+name, nil,
+		openAPIStatusResponse{http.StatusNoContent, &openapi3.ResponseRef{
+			Value: openapi3.NewResponse().WithDescription(http.StatusText(http.StatusNoContent)),
+		}},
+		g.errorResponse(http.StatusNotFound)))
+	g.spec.Paths.Set(path+"/{id}", item)
+}
+
+// listParameters documents the query parameters of a list operation
+func listParameters(routes openAPIRoutes) openapi3.Parameters {
+	query := func(name, description string, schema *openapi3.Schema) *openapi3.ParameterRef {
+		param := openapi3.NewQueryParameter(name).WithSchema(schema)
+		param.Description = description
+		return &openapi3.ParameterRef{Value: param}
+	}
+
+	params := openapi3.Parameters{
+		query("page", "Page number, starting at 1",
+			openapi3.NewIntegerSchema().WithMin(1).WithDefault(1)),
+		query("size", "Number of items per page",
+			openapi3.NewIntegerSchema().WithMin(1).WithDefault(10)),
+		query("sort", `Comma separated fields to order by, descending when prefixed with "-"`,
+			openapi3.NewStringSchema()),
+		query("search", "Text the searchable fields of the items must contain, ignoring case",
+			openapi3.NewStringSchema()),
+		query("annotationSelector", "Comma separated key=value annotations the items must have, such as backup=true",
+			openapi3.NewStringSchema()),
+		query("expand", "Comma separated associations to embed, among those the resource allows",
+			openapi3.NewStringSchema()),
+		query(FilterExpressionParameter, "Conditions combined with AND and OR, JSON encoded or such as (phase = Active OR phase = Pending) AND email LIKE %@example.com",
+			openapi3.NewStringSchema()),
+		query("after", "Cursor of keyset pagination, the ID of the last item of the previous page",
+			openapi3.NewIntegerSchema().WithMin(0)),
+		query("cursor", "Opaque cursor of the next page, as returned in nextCursor; not combinable with page and size",
+			openapi3.NewStringSchema()),
+		query("limit", "Number of items per page of cursor pagination",
+			openapi3.NewIntegerSchema().WithMin(1).WithDefault(10)),
+	}
+	if routes.fields {
+		params = append(params,
+			query("fields", "Comma separated fields to return", openapi3.NewStringSchema()),
+			query("include", "Comma separated associations to load", openapi3.NewStringSchema()),
+			query("watch", "Stream changes instead of listing", openapi3.NewBoolSchema()))
+	}
+	if routes.filters {
+		filter := query("filter", "Column values the items must equal, such as ?username=alice, or compare with "+
+			"using an operator, such as ?createdAt[gte]=2024-01-01T00:00:00Z or ?filter=createdAt__gte=2024-01-01; "+
+			"operators are ne, gt, gte, lt, lte, like and in",
+			openapi3.NewObjectSchema().WithAdditionalProperties(openapi3.NewStringSchema()))
+		filter.Value.Style = openapi3.SerializationForm
+		explode := true
+		filter.Value.Explode = &explode
+		params = append(params, filter)
+	}
+	return params
+}
+
+// openAPIStatusResponse is a response documented for a status code
+type openAPIStatusResponse struct {
+	code int
+	ref  *openapi3.ResponseRef
+}
+
+// openAPIResponse documents a JSON response with the given schema
+func openAPIResponse(code int, schema *openapi3.SchemaRef) openAPIStatusResponse {
+	return openAPIStatusResponse{code, &openapi3.ResponseRef{
+		Value: openapi3.NewResponse().WithDescription(http.StatusText(code)).WithJSONSchemaRef(schema),
+	}}
+}
+
+// errorResponse documents a meta.Status response
+func (g *OpenAPIGenerator) errorResponse(code int) openAPIStatusResponse {
+	return openAPIResponse(code, g.schemaRef(reflect.TypeOf(meta.Status{})))
+}
+
+// responsesWithStatus builds the responses of an operation
+func responsesWithStatus(responses map[int]*openapi3.ResponseRef) *openapi3.Responses {
+	result := openapi3.NewResponsesWithCapacity(len(responses))
+	for code, ref := range responses {
+		result.Set(strconv.Itoa(code), ref)
+	}
+	return result
+}
+
+// operation documents an operation taking an optional JSON body
+func (g *OpenAPIGenerator) operation(id string, body *openapi3.SchemaRef, responses ...openAPIStatusResponse) *openapi3.Operation {
+	op := openapi3.NewOperation()
+	op.OperationID = id
+	if body != nil {
+		op.RequestBody = &openapi3.RequestBodyRef{
+			Value: openapi3.NewRequestBody().WithRequired(true).WithJSONSchemaRef(body),
+		}
+	}
+	refs := make(map[int]*openapi3.ResponseRef, len(responses))
+	for _, response := range responses {
+		refs[response.code] = response.ref
+	}
+	op.Responses = responsesWithStatus(refs)
+	return op
+}
+
+// operationName turns a path like /api/v1/users into ApiV1Users, so that
+// operation IDs stay unique across paths
+func operationName(path string) string {
+	var name strings.Builder
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "{") {
+			continue
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			name.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return name.String()
+}
+
+// schemaRef returns the schema of t, a $ref for named structs
+func (g *OpenAPIGenerator) schemaRef(t reflect.Type) *openapi3.SchemaRef {
+	switch {
+	case t == timeType:
+		return openapi3.NewDateTimeSchema().NewRef()
+	case t == deletedAtType:
+		ref := openapi3.NewDateTimeSchema().NewRef()
+		ref.Value.Nullable = true
+		return ref
+	case t.Kind() == reflect.Pointer:
+		ref := g.schemaRef(t.Elem())
+		if ref.Ref != "" {
+			return ref
+		}
+		ref.Value.Nullable = true
+		return ref
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// Custom encodings cannot be derived from the fields
+		return openapi3.NewSchema().NewRef()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t).NewRef()
+		}
+		return openapi3.NewSchemaRef("#/components/schemas/"+g.component(t), nil)
+	case reflect.String:
+		return openapi3.NewStringSchema().NewRef()
+	case reflect.Bool:
+		return openapi3.NewBoolSchema().NewRef()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return openapi3.NewIntegerSchema().NewRef()
+	case reflect.Int64:
+		return openapi3.NewInt64Schema().NewRef()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return openapi3.NewIntegerSchema().WithMin(0).NewRef()
+	case reflect.Float32, reflect.Float64:
+		return openapi3.NewFloat64Schema().NewRef()
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return openapi3.NewBytesSchema().NewRef()
+		}
+		schema := openapi3.NewArraySchema()
+		schema.Items = g.schemaRef(t.Elem())
+		return schema.NewRef()
+	case reflect.Map:
+		schema := openapi3.NewObjectSchema()
+		schema.AdditionalProperties = openapi3.AdditionalProperties{Schema: g.schemaRef(t.Elem())}
+		return schema.NewRef()
+	default:
+		return openapi3.NewSchema().NewRef()
+	}
+}
+
+// component registers the struct t as a component schema and returns its
+// name. The package name is prepended if another type has the same name.
+func (g *OpenAPIGenerator) component(t reflect.Type) string {
+	if name, ok := g.components[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := g.spec.Components.Schemas[name]; taken {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	// Register the name first so that recursive types terminate
+	g.components[t] = name
+	g.spec.Components.Schemas[name] = openapi3.NewObjectSchema().NewRef()
+	g.spec.Components.Schemas[name] = g.structSchema(t).NewRef()
+	return name
+}
+
+// structSchema builds the schema of a struct following the rules of
+// encoding/json: embedded structs without a JSON name are flattened, which
+// is expressed with allOf
+func (g *OpenAPIGenerator) structSchema(t reflect.Type) *openapi3.Schema {
+	schema := openapi3.NewObjectSchema()
+	var embedded openapi3.SchemaRefs
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			embedded = append(embedded, g.schemaRef(fieldType))
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schemaRef(field.Type)
+		if property.Ref == "" {
+			applyFieldConstraints(property.Value, field)
+		}
+		schema.Properties[name] = property
+		if hasTagOption(field.Tag.Get("binding"), "required") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	if len(embedded) == 0 {
+		return schema
+	}
+	if len(schema.Properties) == 0 {
+		return &openapi3.Schema{AllOf: embedded}
+	}
+	return &openapi3.Schema{AllOf: append(embedded, schema.NewRef())}
+}
+
+// applyFieldConstraints documents the binding and gorm constraints of a
+// field on its schema
+func applyFieldConstraints(schema *openapi3.Schema, field reflect.StructField) {
+	if hasTagOption(field.Tag.Get("binding"), "email") {
+		schema.Format = "email"
+	}
+	if hasTagOption(field.Tag.Get("openapi"), "readOnly") {
+		schema.ReadOnly = true
+	}
+	if hasTagOption(field.Tag.Get("openapi"), "writeOnly") {
+		schema.WriteOnly = true
+	}
+
+	for _, setting := range strings.Split(field.Tag.Get("gorm"), ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(setting), ":")
+		switch strings.ToLower(key) {
+		case "size":
+			if n, err := strconv.ParseUint(value, 10, 64); err == nil && schema.Type.Is(openapi3.TypeString) {
+				schema.MaxLength = &n
+			}
+		case "primarykey", "autoincrement":
+			schema.ReadOnly = true
+		case "unique", "uniqueindex":
+			// a named unique index may span several columns
+			if name, _, _ := strings.Cut(value, ","); name != "" {
+				continue
+			}
+			if schema.Extensions == nil {
+				schema.Extensions = make(map[string]any)
+			}
+			schema.Extensions["x-unique"] = true
+		case "default":
+			schema.Default = defaultValue(schema, value)
+		}
+	}
+}
+
+// defaultValue converts a gorm default to the type of schema
+func defaultValue(schema *openapi3.Schema, value string) any {
+	switch {
+	case schema.Type.Is(openapi3.TypeBoolean):
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case schema.Type.Is(openapi3.TypeInteger):
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case schema.Type.Is(openapi3.TypeNumber):
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case schema.Type.Is(openapi3.TypeString):
+		return strings.Trim(value, "'")
+	}
+	return nil
+}
+
+// hasTagOption reports whether the comma separated tag contains option
+func hasTagOption(tag, option string) bool {
+	for _, o := range strings.Split(tag, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
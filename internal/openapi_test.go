@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadOpenAPI fetches the spec served by engine and validates it against
+// the OpenAPI 3.0 specification
+func loadOpenAPI(t *testing.T, engine *gin.Engine) *openapi3.T {
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest("GET", OpenAPIPath, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	loader := openapi3.NewLoader()
+	spec, err := loader.LoadFromData(w.Body.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, spec.Validate(context.Background()))
+	return spec
+}
+
+func TestOpenAPIGenerator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	gen := NewOpenAPIGenerator("test", "1.0.0")
+	RegisterOpenAPI(engine, gen)
+	NewRouter[apiv1.User](engine, db, WithOpenAPI(gen)).Register("/api/v1/users")
+
+	spec := loadOpenAPI(t, engine)
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+
+	// Routes
+	collection := spec.Paths.Find("/api/v1/users")
+	require.NotNil(t, collection)
+	assert.NotNil(t, collection.Get)
+	require.NotNil(t, collection.Post)
+	assert.Equal(t, "createApiV1Users", collection.Post.OperationID)
+	assert.Equal(t, "#/components/schemas/User",
+		collection.Post.RequestBody.Value.Content.Get("application/json").Schema.Ref)
+	assert.NotNil(t, collection.Post.Responses.Status(http.StatusCreated))
+
+	item := spec.Paths.Find("/api/v1/users/{id}")
+	require.NotNil(t, item)
+	for method, op := range map[string]*openapi3.Operation{
+		"GET": item.Get, "PUT": item.Put, "PATCH": item.Patch, "DELETE": item.Delete,
+	} {
+		require.NotNil(t, op, method)
+		assert.NotNil(t, op.Responses.Status(http.StatusNotFound), method)
+	}
+	assert.NotNil(t, item.Patch.RequestBody.Value.Content.Get(MergePatchContentType))
+
+	// List parameters
+	params := collection.Get.Parameters
+	for _, name := range []string{"page", "size", "sort", "search", "after", "cursor", "limit", "fields", "include", "watch"} {
+		assert.NotNil(t, params.GetByInAndName(openapi3.ParameterInQuery, name), name)
+	}
+	assert.Equal(t, float64(10), params.GetByInAndName(openapi3.ParameterInQuery, "size").Schema.Value.Default)
+	assert.Nil(t, params.GetByInAndName(openapi3.ParameterInQuery, "filter"))
+
+	// The embedded metadata types are components
+	schemas := spec.Components.Schemas
+	for _, name := range []string{"User", "BaseResource", "TypeMeta", "ObjectMeta", "ResourceStatus", "Status"} {
+		require.Contains(t, schemas, name)
+	}
+
+	user := schemas["User"].Value
+	require.Len(t, user.AllOf, 2)
+	assert.Equal(t, "#/components/schemas/BaseResource", user.AllOf[0].Ref)
+	fields := user.AllOf[1].Value
+	// The password is only required until a hash is stored
+	assert.ElementsMatch(t, []string{"username", "email"}, fields.Required)
+	assert.True(t, fields.Properties["password"].Value.WriteOnly)
+	assert.False(t, fields.Properties["username"].Value.WriteOnly)
+	assert.Equal(t, "email", fields.Properties["email"].Value.Format)
+	assert.Equal(t, uint64(100), *fields.Properties["username"].Value.MaxLength)
+	assert.Equal(t, true, fields.Properties["username"].Value.Extensions["x-unique"])
+	assert.Equal(t, true, fields.Properties["isActive"].Value.Default)
+	assert.NotContains(t, fields.Properties, "BaseResource")
+
+	base := schemas["BaseResource"].Value
+	require.Len(t, base.AllOf, 2)
+	assert.Equal(t, "#/components/schemas/TypeMeta", base.AllOf[0].Ref)
+	assert.Equal(t, "#/components/schemas/ObjectMeta", base.AllOf[1].Value.Properties["metadata"].Ref)
+
+	objectMeta := schemas["ObjectMeta"].Value
+	assert.Equal(t, "#/components/schemas/ResourceStatus", objectMeta.Properties["status"].Ref)
+	assert.True(t, objectMeta.Properties["id"].Value.ReadOnly)
+	assert.Equal(t, "date-time", objectMeta.Properties["createdAt"].Value.Format)
+	assert.True(t, objectMeta.Properties["deletionTimestamp"].Value.Nullable)
+	assert.True(t, objectMeta.Properties["deletedAt"].Value.Nullable)
+	assert.Equal(t, "date-time", objectMeta.Properties["deletedAt"].Value.Format)
+	assert.True(t, objectMeta.Properties["labels"].Value.Type.Is(openapi3.TypeObject))
+}
+
+func TestOpenAPIGenerator_RegisterResource(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	gen := NewOpenAPIGenerator("test", "1.0.0")
+	RegisterOpenAPI(engine, gen)
+	RegisterResource[apiv1.User](engine, db, "/users", WithOpenAPI(gen))
+	NewVersionedRouter[apiv1.User](engine, db, "v2", WithOpenAPI(gen)).Register("/users")
+
+	spec := loadOpenAPI(t, engine)
+
+	// Paginated lists are documented as a ListResponse
+	list := spec.Paths.Find("/users").Get.Responses.Status(http.StatusOK).Value.Content.Get("application/json").Schema.Value
+	assert.Contains(t, list.Properties, "items")
+	assert.Contains(t, list.Properties, "nextCursor")
+	assert.Nil(t, spec.Paths.Find("/users/{id}").Patch)
+
+	// Their filters are documented as exploded query parameters
+	params := spec.Paths.Find("/users").Get.Parameters
+	filter := params.GetByInAndName(openapi3.ParameterInQuery, "filter")
+	require.NotNil(t, filter)
+	assert.Equal(t, openapi3.SerializationForm, filter.Style)
+	assert.Nil(t, params.GetByInAndName(openapi3.ParameterInQuery, "fields"))
+
+	// Both versions share the User component
+	assert.NotNil(t, spec.Paths.Find("/api/v2/users/{id}").Patch)
+	assert.NotContains(t, spec.Components.Schemas, "Apiv1User")
+}
+
+func TestRegisterOpenAPI_Docs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	RegisterOpenAPI(engine, NewOpenAPIGenerator("test", "1.0.0"))
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest("GET", DocsPath, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "SwaggerUIBundle")
+	assert.Contains(t, w.Body.String(), `url: "`+OpenAPIPath+`"`)
+}
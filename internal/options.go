@@ -0,0 +1,317 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+// RouterOption configures optional Router behavior
+type RouterOption func(*routerOptions)
+
+// routerOptions holds the settings applied by RouterOption values
+type routerOptions struct {
+	// requireResourceVersion rejects updates that carry no resource version
+	requireResourceVersion bool
+
+	// batchPartialSuccess keeps the valid items of a batch when others fail
+	batchPartialSuccess bool
+
+	// gcInterval is how often RegisterResource runs the garbage collector
+	gcInterval time.Duration
+
+	// gcContext stops the garbage collector when done
+	gcContext context.Context
+
+	// daoOptions configure the DAO created for the resource
+	daoOptions []DAOOption
+
+	// defaultScopes are applied to every read of the Router, see
+	// WithDefaultScope
+	defaultScopes []func(*gorm.DB) *gorm.DB
+
+	// middleware runs before every handler of the resource
+	middleware []gin.HandlerFunc
+
+	// rateLimiter, if set, runs before every handler of the resource
+	rateLimiter gin.HandlerFunc
+
+	// auth, if set, runs before every handler of the resource except the
+	// routes in authSkip
+	auth     gin.HandlerFunc
+	authSkip map[string]bool
+
+	// roleCheckers hold an RBACMiddleware per HTTP method with required
+	// roles
+	roleCheckers map[string]gin.HandlerFunc
+
+	// maxBodySize is the largest accepted request body, unlimited if not
+	// positive
+	maxBodySize int64
+
+	// timeout bounds the handling of a request, unlimited if not positive
+	timeout time.Duration
+
+	// openAPI, if set, documents the routes of the resource
+	openAPI *OpenAPIGenerator
+
+	// auditLog, if set, records the mutating requests to the resource
+	auditLog *AuditRecorder
+}
+
+// AnyMethod is the WithRoleRequirements key whose roles apply to every
+// method without requirements of its own
+const AnyMethod = "*"
+
+// DefaultMaxBodySize is the request body limit of resource routes
+const DefaultMaxBodySize = 1 << 20
+
+// streamingRoutes are relative paths whose bodies are streamed rather than
+// buffered, so neither the body size limit nor the timeout apply to them
+var streamingRoutes = map[string]bool{"/export": true, "/export.csv": true, "/import": true}
+
+// newRouterOptions applies opts over the default settings
+func newRouterOptions(opts ...RouterOption) routerOptions {
+	o := routerOptions{maxBodySize: DefaultMaxBodySize, timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithRequireResourceVersion makes updates without a resource version, in
+// either the body or an If-Match header, fail with 428 Precondition Required
+// instead of falling back to last-write-wins.
+func WithRequireResourceVersion() RouterOption {
+	return func(o *routerOptions) {
+		o.requireResourceVersion = true
+	}
+}
+
+// WithBatchPartialSuccess makes batch creates keep every item that could be
+// created when other items fail. By default a single failure rolls back the
+// whole batch.
+func WithBatchPartialSuccess() RouterOption {
+	return func(o *routerOptions) {
+		o.batchPartialSuccess = true
+	}
+}
+
+// WithGarbageCollector makes RegisterResource start a GarbageCollector that
+// deletes resources whose owners no longer exist every interval, until ctx
+// is done
+func WithGarbageCollector(ctx context.Context, interval time.Duration) RouterOption {
+	return func(o *routerOptions) {
+		o.gcContext = ctx
+		o.gcInterval = interval
+	}
+}
+
+// WithDAOOptions applies opts to the DAO the router creates for the resource
+func WithDAOOptions(opts ...DAOOption) RouterOption {
+	return func(o *routerOptions) {
+		o.daoOptions = append(o.daoOptions, opts...)
+	}
+}
+
+// WithDefaultScope applies fn to every read of a Router's resources, such
+// as ActiveScope, so that resources outside the scope are neither listed
+// nor found by ID. Repeated options compose.
+func WithDefaultScope(fn func(*gorm.DB) *gorm.DB) RouterOption {
+	return func(o *routerOptions) {
+		o.defaultScopes = append(o.defaultScopes, fn)
+	}
+}
+
+// WithMiddleware runs handlers before every handler of the resource, ahead
+// of rate limiting and authentication, e.g. a DeprecationMiddleware
+func WithMiddleware(handlers ...gin.HandlerFunc) RouterOption {
+	return func(o *routerOptions) {
+		o.middleware = append(o.middleware, handlers...)
+	}
+}
+
+// WithOpenAPI documents the routes of the resource in the spec built by gen
+func WithOpenAPI(gen *OpenAPIGenerator) RouterOption {
+	return func(o *routerOptions) {
+		o.openAPI = gen
+	}
+}
+
+// WithRateLimit limits the requests to the resource endpoints to rps per
+// second with bursts of up to burst requests, either in total or per client
+// IP depending on mode. Each registered resource gets its own limiter.
+func WithRateLimit(rps int, burst int, mode RateLimitMode) RouterOption {
+	return func(o *routerOptions) {
+		o.rateLimiter = NewRateLimiter(rps, burst, mode)
+	}
+}
+
+// WithAuth runs the authentication middleware before every handler of the
+// resource, except OPTIONS requests and the routes listed in skip. A route
+// is written as its method and its path relative to the resource, such as
+// "GET" for the list endpoint or "GET /:id" for a single resource.
+func WithAuth(middleware gin.HandlerFunc, skip ...string) RouterOption {
+	return func(o *routerOptions) {
+		o.auth = middleware
+		o.authSkip = make(map[string]bool, len(skip))
+		for _, route := range skip {
+			o.authSkip[strings.TrimSpace(route)] = true
+		}
+	}
+}
+
+// WithRoleRequirements restricts the methods in requirements to callers
+// holding at least one of the listed roles, for example
+// {"DELETE": {"admin"}, "POST": {"editor", "admin"}}. The AnyMethod entry
+// applies to the methods without one of their own except OPTIONS; methods
+// without requirements stay public. The roles are read from the JWT claims,
+// so WithAuth must be set as well.
+func WithRoleRequirements(requirements map[string][]string) RouterOption {
+	return func(o *routerOptions) {
+		o.roleCheckers = make(map[string]gin.HandlerFunc, len(requirements))
+		for method, roles := range requirements {
+			if len(roles) > 0 {
+				o.roleCheckers[strings.ToUpper(method)] = RBACMiddleware(roles...)
+			}
+		}
+	}
+}
+
+// WithMaxBodySize limits request bodies to n bytes instead of
+// DefaultMaxBodySize. A value of zero or less removes the limit.
+func WithMaxBodySize(n int64) RouterOption {
+	return func(o *routerOptions) {
+		o.maxBodySize = n
+	}
+}
+
+// WithTimeout cancels requests still running after d instead of
+// DefaultTimeout, see TimeoutMiddleware. A value of zero or less removes the
+// timeout. Watch requests are never timed out.
+func WithTimeout(d time.Duration) RouterOption {
+	return func(o *routerOptions) {
+		o.timeout = d
+	}
+}
+
+// WithAuditLog records through recorder every POST, PUT, PATCH and DELETE
+// request to the resource that passed authentication
+func WithAuditLog(recorder *AuditRecorder) RouterOption {
+	return func(o *routerOptions) {
+		o.auditLog = recorder
+	}
+}
+
+// use installs the middleware selected by the options on the group of a
+// resource of the given kind
+func (o routerOptions) use(group *gin.RouterGroup, kind string) {
+	group.Use(TracingMiddleware())
+	group.Use(o.middleware...)
+	if o.timeout > 0 {
+		base := group.BasePath()
+		timeout := TimeoutMiddleware(o.timeout)
+		group.Use(func(c *gin.Context) {
+			if c.Query("watch") == "true" || streamingRoutes[strings.TrimPrefix(c.FullPath(), base)] {
+				c.Next()
+				return
+			}
+			timeout(c)
+		})
+	}
+	if o.rateLimiter != nil {
+		group.Use(o.rateLimiter)
+	}
+	if o.auth != nil {
+		base := group.BasePath()
+		group.Use(func(c *gin.Context) {
+			// OPTIONS only describes the routes, so it stays public
+			route := strings.TrimSpace(c.Request.Method + " " + strings.TrimPrefix(c.FullPath(), base))
+			if o.authSkip[route] || c.Request.Method == http.MethodOptions {
+				c.Next()
+				return
+			}
+			o.auth(c)
+		})
+	}
+	if len(o.roleCheckers) > 0 {
+		group.Use(func(c *gin.Context) {
+			check, ok := o.roleCheckers[c.Request.Method]
+			if !ok && c.Request.Method != http.MethodOptions {
+				check, ok = o.roleCheckers[AnyMethod]
+			}
+			if ok {
+				check(c)
+			}
+		})
+	}
+	if o.maxBodySize > 0 {
+		base := group.BasePath()
+		limit := MaxBodySizeMiddleware(o.maxBodySize)
+		group.Use(func(c *gin.Context) {
+			if streamingRoutes[strings.TrimPrefix(c.FullPath(), base)] {
+				c.Next()
+				return
+			}
+			limit(c)
+		})
+	}
+	group.Use(YAMLBodyMiddleware())
+	if o.auditLog != nil {
+		group.Use(o.auditLog.middleware(kind))
+	}
+}
+
+// DAOOption configures optional DAO behavior
+type DAOOption func(*daoOptions)
+
+// daoOptions holds the settings applied by DAOOption values
+type daoOptions struct {
+	// audit records an audit event for every mutation
+	audit *AuditDAO
+
+	// webhooks notify external receivers of every change
+	webhooks *WebhookDispatcher
+
+	// queryDuration records the duration of every operation
+	queryDuration *prometheus.HistogramVec
+}
+
+// newDAOOptions applies opts over the default settings
+func newDAOOptions(opts ...DAOOption) daoOptions {
+	var o daoOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithAudit makes the DAO record an AuditEvent for every create, update and
+// delete, in the same transaction as the change. The audit table must live
+// in the same database as the resource.
+func WithAudit(audit *AuditDAO) DAOOption {
+	return func(o *daoOptions) {
+		o.audit = audit
+	}
+}
+
+// WithMetrics makes the DAO record the duration of every operation in the
+// db_query_duration_seconds histogram of namespace, labelled by operation
+func WithMetrics(namespace string) DAOOption {
+	return func(o *daoOptions) {
+		o.queryDuration = newQueryDuration(namespace)
+	}
+}
+
+// WithWebhooks makes the DAO send a webhook through dispatcher for every
+// create, update and delete, once the change is stored
+func WithWebhooks(dispatcher *WebhookDispatcher) DAOOption {
+	return func(o *daoOptions) {
+		o.webhooks = dispatcher
+	}
+}
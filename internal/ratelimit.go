@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitMode selects how NewRateLimiter shares its token buckets
+type RateLimitMode int
+
+const (
+	// Global shares a single bucket between all clients
+	Global RateLimitMode = iota
+
+	// PerIP gives every client IP its own bucket
+	PerIP
+)
+
+// limiterTTL is how long a per-IP limiter is kept after its last request
+const limiterTTL = 10 * time.Minute
+
+// NewRateLimiter returns a token bucket rate limiting middleware allowing rps
+// requests per second with bursts of up to burst requests. Requests over the
+// limit fail with 429 Too Many Requests and a Retry-After header.
+func NewRateLimiter(rps int, burst int, mode RateLimitMode) gin.HandlerFunc {
+	var limiterFor func(c *gin.Context) *rate.Limiter
+	switch mode {
+	case Global:
+		limiter := rate.NewLimiter(rate.Limit(rps), burst)
+		limiterFor = func(*gin.Context) *rate.Limiter { return limiter }
+	case PerIP:
+		limiters := newIPLimiters(rate.Limit(rps), burst, limiterTTL)
+		limiterFor = func(c *gin.Context) *rate.Limiter { return limiters.get(c.ClientIP(), time.Now()) }
+	default:
+		panic(fmt.Sprintf("unknown rate limit mode %d", mode))
+	}
+
+	return func(c *gin.Context) {
+		if delay, ok := reserve(limiterFor(c), time.Now()); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			writeError(c, http.StatusTooManyRequests, meta.StatusReasonTooManyRequests, "rate limit exceeded")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// reserve takes a token from limiter if one is available at now. Otherwise
+// it reports how long until one will be, without consuming it.
+func reserve(limiter *rate.Limiter, now time.Time) (time.Duration, bool) {
+	reservation := limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		// The burst is zero, so no request can ever be allowed
+		return time.Second, false
+	}
+	delay := reservation.DelayFrom(now)
+	if delay > 0 {
+		reservation.CancelAt(now)
+		return delay, false
+	}
+	return 0, true
+}
+
+// ipLimiter is a limiter with the time of the last request it served
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen atomic.Int64
+}
+
+// ipLimiters holds a limiter per client IP, evicting those unused for ttl
+type ipLimiters struct {
+	limit rate.Limit
+	burst int
+	ttl   time.Duration
+
+	limiters sync.Map // string -> *ipLimiter
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// newIPLimiters creates an empty set of per-IP limiters
+func newIPLimiters(limit rate.Limit, burst int, ttl time.Duration) *ipLimiters {
+	return &ipLimiters{limit: limit, burst: burst, ttl: ttl, lastSweep: time.Now()}
+}
+
+// get returns the limiter for ip, creating it if needed. Expired limiters
+// are swept at most once per ttl.
+func (l *ipLimiters) get(ip string, now time.Time) *rate.Limiter {
+	l.sweep(now)
+
+	entry, ok := l.limiters.Load(ip)
+	if !ok {
+		entry, _ = l.limiters.LoadOrStore(ip, &ipLimiter{limiter: rate.NewLimiter(l.limit, l.burst)})
+	}
+	e := entry.(*ipLimiter)
+	e.lastSeen.Store(now.UnixNano())
+	return e.limiter
+}
+
+// sweep deletes the limiters that have not been used for ttl
+func (l *ipLimiters) sweep(now time.Time) {
+	l.mu.Lock()
+	if now.Sub(l.lastSweep) < l.ttl {
+		l.mu.Unlock()
+		return
+	}
+	l.lastSweep = now
+	l.mu.Unlock()
+
+	cutoff := now.Add(-l.ttl).UnixNano()
+	l.limiters.Range(func(key, value any) bool {
+		if value.(*ipLimiter).lastSeen.Load() < cutoff {
+			l.limiters.Delete(key)
+		}
+		return true
+	})
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// serveFrom sends a GET for path to router from the client address addr
+func serveFrom(router http.Handler, path, addr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	req.RemoteAddr = addr
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		mode RateLimitMode
+		// otherClientAllowed reports whether a second IP still gets through
+		// once the first has used up its burst
+		otherClientAllowed bool
+	}{
+		{"global", Global, false},
+		{"per IP", PerIP, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(NewRateLimiter(1, 2, tt.mode))
+			router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			for i := 0; i < 2; i++ {
+				assert.Equal(t, http.StatusOK, serveFrom(router, "/", "10.0.0.1:1234").Code)
+			}
+
+			w := serveFrom(router, "/", "10.0.0.1:1234")
+			assert.Equal(t, http.StatusTooManyRequests, w.Code)
+			assert.Equal(t, "1", w.Header().Get("Retry-After"))
+			status := decodeStatus(t, w)
+			assert.Equal(t, meta.StatusReasonTooManyRequests, status.Reason)
+
+			w = serveFrom(router, "/", "10.0.0.2:1234")
+			if tt.otherClientAllowed {
+				assert.Equal(t, http.StatusOK, w.Code)
+			} else {
+				assert.Equal(t, http.StatusTooManyRequests, w.Code)
+			}
+		})
+	}
+}
+
+func TestNewRateLimiter_ZeroBurst(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewRateLimiter(10, 0, Global))
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := serveFrom(router, "/", "10.0.0.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestIPLimiters_Eviction(t *testing.T) {
+	now := time.Now()
+	limiters := newIPLimiters(rate.Limit(1), 1, time.Minute)
+	limiters.lastSweep = now
+
+	first := limiters.get("10.0.0.1", now)
+	limiters.get("10.0.0.2", now.Add(30*time.Second))
+	assert.Same(t, first, limiters.get("10.0.0.1", now.Add(30*time.Second)))
+
+	// Sweeping after the TTL drops only the limiters idle for longer
+	limiters.get("10.0.0.3", now.Add(80*time.Second))
+	_, ok := limiters.limiters.Load("10.0.0.1")
+	assert.True(t, ok)
+
+	limiters.get("10.0.0.3", now.Add(150*time.Second))
+	for ip, kept := range map[string]bool{"10.0.0.1": false, "10.0.0.2": false, "10.0.0.3": true} {
+		_, ok := limiters.limiters.Load(ip)
+		assert.Equal(t, kept, ok, ip)
+	}
+}
+
+func TestRegisterResource_RateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	RegisterResource[apiv1.User](router, db, "/limited", WithRateLimit(1, 1, PerIP))
+	RegisterResource[TestModel](router, db, "/unlimited")
+
+	require.Equal(t, http.StatusOK, serveFrom(router, "/limited", "10.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serveFrom(router, "/limited", "10.0.0.1:1234").Code)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serveFrom(router, "/unlimited", "10.0.0.1:1234").Code)
+	}
+}
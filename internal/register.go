@@ -1,70 +1,140 @@
 package internal
 
 import (
+	"context"
 	"net/http"
+	"reflect"
 	"strconv"
 
+	"my-embedded-api/meta"
+
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 // ListResponse represents a paginated list response
 type ListResponse[T any] struct {
-	Items []T   `json:"items"`
-	Total int64 `json:"total"`
-	Page  int   `json:"page"`
-	Size  int   `json:"size"`
+	Items      []T    `json:"items"`
+	Total      int64  `json:"total"`
+	Page       int    `json:"page"`
+	Size       int    `json:"size"`
+	NextCursor string `json:"nextCursor"`
 }
 
+// listParameterNames are the query parameters of list requests that are
+// not filters
+var listParameterNames = []string{"page", "size", "after", "sort", "limit", "cursor", "search", "fields", "include", "expand", "watch", "format", "annotationSelector", FilterParameter, FilterExpressionParameter}
+
 // RegisterResource registers CRUD routes for a resource
-func RegisterResource[T any](router *gin.Engine, db *gorm.DB, path string) {
-	dao := NewDAO[T](db)
+func RegisterResource[T any](router *gin.Engine, db *gorm.DB, path string, opts ...RouterOption) {
+	options := newRouterOptions(opts...)
+	dao := NewDAO[T](db, options.daoOptions...)
 
 	// Auto-migrate the resource
-	if err := dao.AutoMigrate(); err != nil {
+	if err := dao.AutoMigrate(context.Background()); err != nil {
 		panic(err)
 	}
+	registerOwnerTable(db, new(T))
+
+	// Start the garbage collector if requested
+	if options.gcInterval > 0 {
+		go NewGarbageCollector(dao, options.gcInterval).Run(options.gcContext)
+	}
 
 	// Create routes group
 	group := router.Group(path)
+	options.use(group, resourceKind(new(T)))
 	{
 		// Create resource
 		group.POST("", func(c *gin.Context) {
 			var obj T
 			if err := c.ShouldBindJSON(&obj); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				writeInvalid(c, err)
 				return
 			}
 
-			// Use transaction for create operation
-			if err := dao.Transaction(func(tx *gorm.DB) error {
-				return tx.Create(&obj).Error
-			}); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			if err := validateResource(&obj); err != nil {
+				writeInvalid(c, err)
 				return
 			}
 
-			c.JSON(http.StatusCreated, obj)
+			if err := dao.Create(requestContext(c), &obj); err != nil {
+				writeWriteError(c, err)
+				return
+			}
+
+			writeResource(c, http.StatusCreated, createResponse(&obj))
 		})
 
 		// Get resource by ID
 		group.GET("/:id", func(c *gin.Context) {
-			id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+			id, ok := parseIDParam(c, dao)
+			if !ok {
+				return
+			}
+			expand, err := parseExpand(dao, c.Query("expand"))
+			if err != nil {
+				writeBadRequest(c, err.Error())
+				return
+			}
+
+			obj, err := dao.Preload(expand...).Get(c.Request.Context(), id)
+			if err != nil {
+				if err == gorm.ErrRecordNotFound {
+					writeNotFound(c)
+					return
+				}
+				writeInternalError(c, err)
+				return
+			}
+			writeResource(c, http.StatusOK, obj)
+		})
+
+		// Get resource by UID
+		group.GET("/uid/:uid", func(c *gin.Context) {
+			uid, err := uuid.Parse(c.Param("uid"))
 			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+				writeBadRequest(c, "invalid uid")
 				return
 			}
 
-			obj, err := dao.Get(uint(id))
+			obj, err := dao.GetByUID(c.Request.Context(), uid.String())
 			if err != nil {
 				if err == gorm.ErrRecordNotFound {
-					c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+					writeNotFound(c)
 					return
 				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				writeInternalError(c, err)
 				return
 			}
-			c.JSON(http.StatusOK, obj)
+			c.Header("ETag", ETag(resourceVersion(obj)))
+			writeResource(c, http.StatusOK, obj)
+		})
+
+		// Count resources matching the filter of the query parameters
+		group.GET("/count", func(c *gin.Context) {
+			countResources(c, dao)
+		})
+		group.HEAD("", func(c *gin.Context) {
+			countResources(c, dao)
+		})
+
+		// Aggregate resources by group
+		group.GET("/stats", func(c *gin.Context) {
+			statsResources(c, dao)
+		})
+
+		// Export and import every resource as JSON Lines
+		group.GET("/export", func(c *gin.Context) {
+			exportResources(c, dao)
+		})
+		group.GET("/export.csv", func(c *gin.Context) {
+			exportCSVResources(c, dao)
+		})
+		validator := NewStructTagValidator()
+		group.POST("/import", func(c *gin.Context) {
+			importResources(c, dao, validator)
 		})
 
 		// List all resources with pagination and filtering
@@ -73,80 +143,160 @@ func RegisterResource[T any](router *gin.Engine, db *gorm.DB, path string) {
 			page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 			pageSize, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
 
-			// Parse filters from query parameters
-			filters := make(map[string]interface{})
-			for key, values := range c.Request.URL.Query() {
-				if key != "page" && key != "size" {
-					filters[key] = values[0]
-				}
+			// Parse filters from query parameters: plain ones match equal
+			// values, those such as id[in]=1,2 use an operator
+			filters, err := parsePlainFilter(dao, c.Request.URL.Query())
+			if err != nil {
+				writeBadRequest(c, err.Error())
+				return
+			}
+			operators, err := parseFilterOperators(dao, c.Request.URL.Query())
+			if err != nil {
+				writeBadRequest(c, err.Error())
+				return
+			}
+			annotations, err := parseAnnotationSelector(dao, c.Query("annotationSelector"))
+			if err != nil {
+				writeBadRequest(c, err.Error())
+				return
+			}
+			expression, err := parseFilterExpression(dao, c.Query(FilterExpressionParameter))
+			if err != nil {
+				writeBadRequest(c, err.Error())
+				return
+			}
+			expand, err := parseExpand(dao, c.Query("expand"))
+			if err != nil {
+				writeBadRequest(c, err.Error())
+				return
+			}
+			filters = append(filters, operators...)
+			filtered := dao.Search(c.Query("search")).Preload(expand...)
+			if annotations != nil {
+				filtered = filtered.Scope(annotations)
+			}
+			if expression != nil {
+				filtered = filtered.Scope(expression)
+			}
+
+			// Use keyset pagination when a cursor is given
+			if cursorRequested(c) {
+				listCursor(c, filtered, filters, nil)
+				return
+			}
+			if _, ok := c.GetQuery("after"); ok {
+				listAfter(c, filtered, pageSize, filters, nil)
+				return
 			}
 
-			items, total, err := dao.List(page, pageSize, filters)
+			sort, err := parseSort(dao, c.Query("sort"))
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				writeBadRequest(c, err.Error())
 				return
 			}
 
+			items, total, err := filtered.List(c.Request.Context(), page, pageSize, filters, sort...)
+			if err != nil {
+				writeInternalError(c, err)
+				return
+			}
+
+			setPaginationHeaders(c, page, pageSize, total)
+
 			response := ListResponse[T]{
 				Items: items,
 				Total: total,
 				Page:  page,
 				Size:  pageSize,
 			}
-			c.JSON(http.StatusOK, response)
+			writeResource(c, http.StatusOK, response)
+		})
+
+		// Set the same fields on many resources
+		group.PATCH("/bulk", func(c *gin.Context) {
+			bulkUpdateResources(c, dao)
 		})
 
 		// Update resource
 		group.PUT("/:id", func(c *gin.Context) {
-			id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+			id, ok := parseIDParam(c, dao)
+			if !ok {
+				return
+			}
+			expand, err := parseExpand(dao, c.Query("expand"))
 			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+				writeBadRequest(c, err.Error())
 				return
 			}
 
-			var obj T
-			if err := db.First(&obj, id).Error; err != nil {
+			obj, err := dao.Preload(expand...).Get(c.Request.Context(), id)
+			if err != nil {
 				if err == gorm.ErrRecordNotFound {
-					c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+					writeNotFound(c)
 					return
 				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				writeInternalError(c, err)
 				return
 			}
 
-			if err := c.ShouldBindJSON(&obj); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			old := *obj
+			if err := c.ShouldBindJSON(obj); err != nil {
+				writeInvalid(c, err)
+				return
+			}
+
+			if err := validateResource(obj); err != nil {
+				writeInvalid(c, err)
+				return
+			}
+			if err := validateUpdate(requestContext(c), obj, &old); err != nil {
+				writeInvalid(c, err)
 				return
 			}
 
-			// Use transaction for update operation
-			if err := dao.Transaction(func(tx *gorm.DB) error {
-				return tx.Save(&obj).Error
-			}); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			if err := dao.Save(requestContext(c), id, obj, 0); err != nil {
+				if err == gorm.ErrRecordNotFound {
+					writeNotFound(c)
+					return
+				}
+				writeWriteError(c, err)
 				return
 			}
 
-			c.JSON(http.StatusOK, obj)
+			writeResource(c, http.StatusOK, obj)
+		})
+
+		// Delete many resources at once
+		group.DELETE("/bulk", func(c *gin.Context) {
+			bulkDeleteResources(c, dao)
 		})
 
 		// Delete resource
 		group.DELETE("/:id", func(c *gin.Context) {
-			id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+			id, ok := parseIDParam(c, dao)
+			if !ok {
 				return
 			}
 
-			// Use transaction for delete operation
-			if err := dao.Transaction(func(tx *gorm.DB) error {
-				return tx.Delete(new(T), id).Error
-			}); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			if err := dao.Delete(requestContext(c), id, 0); err != nil {
+				if err == gorm.ErrRecordNotFound {
+					writeNotFound(c)
+					return
+				}
+				if err == ErrOwnerDeletionBlocked {
+					writeError(c, http.StatusConflict, meta.StatusReasonConflict, err.Error())
+					return
+				}
+				writeInternalError(c, err)
 				return
 			}
 
-			c.JSON(http.StatusNoContent, nil)
+			writeDeleted(c, dao, id)
 		})
 	}
+	registerOptionsRoutes(router, group)
+
+	if options.openAPI != nil {
+		options.openAPI.addResource(group.BasePath(), reflect.TypeFor[T](), openAPIRoutes{paginated: true, filters: true})
+	}
 }
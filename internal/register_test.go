@@ -0,0 +1,292 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupTestRegister(t *testing.T) (*gin.Engine, *gorm.DB) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	db := setupTestDB(t)
+
+	// Register routes
+	RegisterResource[apiv1.User](router, db, "/api/v1/users")
+
+	return router, db
+}
+
+func TestRegisterResource_UpdateDelete(t *testing.T) {
+	router, db := setupTestRegister(t)
+	defer cleanupTestDB(t, db)
+
+	// Create test user
+	user := &apiv1.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	err := db.Create(user).Error
+	assert.NoError(t, err)
+
+	// Update user
+	user.Email = "updated@example.com"
+	body, _ := json.Marshal(user)
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/api/v1/users/%d", user.ID), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Delete user
+	req = httptest.NewRequest("DELETE", fmt.Sprintf("/api/v1/users/%d", user.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Verify deletion
+	var found apiv1.User
+	err = db.First(&found, user.ID).Error
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestRegisterResource_Nonexistent(t *testing.T) {
+	router, db := setupTestRegister(t)
+	defer cleanupTestDB(t, db)
+
+	user := &apiv1.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	body, _ := json.Marshal(user)
+
+	// Update nonexistent user
+	req := httptest.NewRequest("PUT", "/api/v1/users/99999", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Delete nonexistent user
+	req = httptest.NewRequest("DELETE", "/api/v1/users/99999", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, meta.StatusReasonNotFound, decodeStatus(t, w).Reason)
+}
+
+func TestRegisterResource_Validation(t *testing.T) {
+	router, db := setupTestRegister(t)
+	defer cleanupTestDB(t, db)
+
+	// Create with a malformed email is rejected before reaching the DB
+	body := []byte(`{"username":"testuser","email":"invalid-email","password":"password123"}`)
+	req := httptest.NewRequest("POST", "/api/v1/users", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, meta.StatusReasonInvalid, decodeStatus(t, w).Reason)
+
+	// Create a valid user
+	body = []byte(`{"username":"testuser","email":"test@example.com","password":"password123"}`)
+	req = httptest.NewRequest("POST", "/api/v1/users", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created apiv1.User
+	err := json.Unmarshal(w.Body.Bytes(), &created)
+	assert.NoError(t, err)
+
+	// Update with a too short username is rejected
+	body = []byte(`{"username":"ab","email":"test@example.com","password":"password123"}`)
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/api/v1/users/%d", created.ID), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRegisterResource_CreateDuplicate(t *testing.T) {
+	router, db := setupTestRegister(t)
+	defer cleanupTestDB(t, db)
+
+	body := []byte(`{"username":"testuser","email":"test@example.com","password":"password123"}`)
+	for _, code := range []int{http.StatusCreated, http.StatusConflict} {
+		req := httptest.NewRequest("POST", "/api/v1/users", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, code, w.Code)
+	}
+}
+
+func TestRegisterResource_ConcurrentCreates(t *testing.T) {
+	router, db := setupTestRegister(t)
+	defer cleanupTestDB(t, db)
+
+	const requests = 50
+	codes := make([]int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body, _ := json.Marshal(apiv1.User{
+				Username: fmt.Sprintf("user%d", i),
+				Email:    fmt.Sprintf("user%d@example.com", i),
+				Password: "password123",
+			})
+			req := httptest.NewRequest("POST", "/api/v1/users", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			codes[i] = w.Code
+		}(i)
+	}
+	wg.Wait()
+
+	for i, code := range codes {
+		assert.Less(t, code, 500, "request %d", i)
+	}
+
+	var count int64
+	assert.NoError(t, db.Model(&apiv1.User{}).Count(&count).Error)
+	assert.Equal(t, int64(requests), count)
+}
+
+func TestRegisterResource_Count(t *testing.T) {
+	router, db := setupTestRegister(t)
+	defer cleanupTestDB(t, db)
+
+	count := func(query string) (int, int64) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/count"+query, nil))
+		var resp CountResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, strconv.FormatInt(resp.Count, 10), w.Header().Get("X-Total-Count"))
+		}
+		return w.Code, resp.Count
+	}
+
+	code, n := count("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(0), n)
+
+	var users []*apiv1.User
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user := &apiv1.User{Username: name, Email: name + "@example.com", Password: "password123"}
+		require.NoError(t, db.Create(user).Error)
+		users = append(users, user)
+	}
+	require.NoError(t, db.Model(users[2]).Update("is_active", false).Error)
+
+	_, n = count("")
+	assert.Equal(t, int64(3), n)
+	_, n = count("?isActive=true")
+	assert.Equal(t, int64(2), n)
+	_, n = count("?is_active=false&username=carol")
+	assert.Equal(t, int64(1), n)
+
+	// Deleted users are no longer counted
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", fmt.Sprintf("/api/v1/users/%d", users[0].ID), nil))
+	require.Equal(t, http.StatusNoContent, w.Code)
+	_, n = count("?isActive=true")
+	assert.Equal(t, int64(1), n)
+
+	code, _ = count("?nickname=x")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = count("?isActive=maybe")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// List parameters are ignored, operators and search apply
+	_, n = count("?page=2&size=1&sort=username&username[ne]=bob")
+	assert.Equal(t, int64(1), n)
+	_, n = count("?search=CAR")
+	assert.Equal(t, int64(1), n)
+	code, _ = count("?id[gte]=two")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// HEAD on the collection only sets the header
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("HEAD", "/api/v1/users?isActive=false", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Total-Count"))
+	assert.Empty(t, w.Body.String())
+}
+
+func TestRegisterResource_BulkUpdate(t *testing.T) {
+	router, db := setupTestRegister(t)
+	defer cleanupTestDB(t, db)
+
+	for _, name := range []string{"alice", "bob"} {
+		user := &apiv1.User{Username: name, Email: name + "@example.com", Password: "password123"}
+		require.NoError(t, db.Create(user).Error)
+	}
+
+	req := httptest.NewRequest("PATCH", "/api/v1/users/bulk", bytes.NewBufferString(`{"ids":[1,2],"patch":{"isActive":false}}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response BulkUpdateResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, int64(2), response.Updated)
+
+	var active int64
+	require.NoError(t, db.Model(&apiv1.User{}).Where("is_active = ?", true).Count(&active).Error)
+	assert.Zero(t, active)
+}
+
+func TestRegisterResource_GetByUID(t *testing.T) {
+	router, db := setupTestRegister(t)
+	defer cleanupTestDB(t, db)
+
+	user := &apiv1.User{Username: "alice", Email: "alice@example.com", Password: "password123"}
+	require.NoError(t, db.Create(user).Error)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/uid/"+user.UID, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, ETag(user.ResourceVersion), w.Header().Get("ETag"))
+
+	var found apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, user.ID, found.ID)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/uid/00000000-0000-0000-0000-000000000000", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/uid/not-a-uuid", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
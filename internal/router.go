@@ -1,10 +1,23 @@
 package internal
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+
+	"my-embedded-api/meta"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -13,66 +26,388 @@ type Validator interface {
 	Validate() error
 }
 
+// Defaulter interface for resources that fill in default values, such as
+// their TypeMeta, before being validated
+type Defaulter interface {
+	Default()
+}
+
+// CreateResponder interface for resources answering their creation with
+// more than their stored representation, such as a one-time secret. Batch
+// creation responds with the stored representation.
+type CreateResponder interface {
+	CreateResponse() any
+}
+
+// createResponse returns the body answering the creation of resource
+func createResponse(resource any) any {
+	if responder, ok := resource.(CreateResponder); ok {
+		return responder.CreateResponse()
+	}
+	return resource
+}
+
+// UpdateValidator interface for resources that restrict how they change.
+// ValidateUpdate receives a pointer to the stored version of the resource
+// and the request context, which holds the roles of the caller, see
+// meta.RolesFromContext. Returning a *meta.Status answers with its code.
+type UpdateValidator interface {
+	ValidateUpdate(ctx context.Context, old any) error
+}
+
+// validateResource applies defaults and runs validation if the resource
+// supports them
+func validateResource(resource any) error {
+	if defaulter, ok := resource.(Defaulter); ok {
+		defaulter.Default()
+	}
+	if validator, ok := resource.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// validateUpdate checks an update of old to resource if the resource
+// restricts its updates
+func validateUpdate(ctx context.Context, resource, old any) error {
+	if validator, ok := resource.(UpdateValidator); ok {
+		return validator.ValidateUpdate(ctx, old)
+	}
+	return nil
+}
+
+// keepHiddenFields copies the exported fields tagged json:"-" of the struct
+// src to dst, including those of embedded structs
+func keepHiddenFields(dst, src reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Type().Field(i)
+		switch {
+		case !field.IsExported():
+		case field.Anonymous && field.Type.Kind() == reflect.Struct:
+			keepHiddenFields(dst.Field(i), src.Field(i))
+		case field.Tag.Get("json") == "-":
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
+
 // Router handles HTTP routing for a resource
 type Router[T any] struct {
-	engine *gin.Engine
-	db     *gorm.DB
-	dao    *DAO[T]
+	engine  *gin.Engine
+	db      *gorm.DB
+	dao     *DAO[T]
+	options routerOptions
+
+	// apiVersion prefixes the registered paths with /api/{apiVersion}
+	apiVersion string
+
+	// validator checks the binding struct tags of request bodies
+	validator *StructTagValidator
 }
 
 // NewRouter creates a new router for the given resource
-func NewRouter[T any](engine *gin.Engine, db *gorm.DB) *Router[T] {
+func NewRouter[T any](engine *gin.Engine, db *gorm.DB, opts ...RouterOption) *Router[T] {
+	options := newRouterOptions(opts...)
+	registerOwnerTable(db, new(T))
+	dao := NewDAO[T](db, options.daoOptions...)
+	for _, scope := range options.defaultScopes {
+		dao = dao.Scope(scope)
+	}
 	return &Router[T]{
-		engine: engine,
-		db:     db,
-		dao:    NewDAO[T](db),
+		engine:    engine,
+		db:        db,
+		dao:       dao,
+		options:   options,
+		validator: NewStructTagValidator(),
 	}
 }
 
-// Register registers all CRUD routes for the resource
+// RegisterCustomValidator adds a custom tag usable in the binding struct
+// tags of the resource. It must be called before serving requests.
+func (r *Router[T]) RegisterCustomValidator(tag string, fn validator.Func) error {
+	return r.validator.RegisterValidation(tag, fn)
+}
+
+// bindResource decodes the JSON body into resource and checks its struct
+// tags, then its Validate method. Malformed bodies are answered with 400,
+// failed struct tags with 422 and a failed Validate with 400.
+func (r *Router[T]) bindResource(c *gin.Context, resource *T) bool {
+	return r.decodeResource(c, resource) && r.checkResource(c, resource)
+}
+
+// decodeResource decodes the JSON body into resource, setting the parent
+// of nested routes, and answers 422 if it cannot be decoded
+func (r *Router[T]) decodeResource(c *gin.Context, resource *T) bool {
+	if err := json.NewDecoder(c.Request.Body).Decode(resource); err != nil {
+		writeInvalid(c, err)
+		return false
+	}
+	// Resources of nested routes belong to the parent named by the path
+	if r.dao.parent != nil {
+		if err := r.dao.parent.set(c.Request.Context(), resource); err != nil {
+			writeInternalError(c, err)
+			return false
+		}
+	}
+	return true
+}
+
+// checkResource checks the struct tags of a decoded resource, then its
+// Validate method, answering failures like bindResource
+func (r *Router[T]) checkResource(c *gin.Context, resource *T) bool {
+	if fields := r.validator.Validate(resource); fields != nil {
+		writeUnprocessable(c, fields)
+		return false
+	}
+	if err := validateResource(resource); err != nil {
+		writeInvalid(c, err)
+		return false
+	}
+	return true
+}
+
+// NewVersionedRouter creates a router serving the resource under
+// /api/{version}. Routers of several versions can share the same database.
+func NewVersionedRouter[T any](engine *gin.Engine, db *gorm.DB, version string, opts ...RouterOption) *Router[T] {
+	router := NewRouter[T](engine, db, opts...)
+	router.apiVersion = version
+	return router
+}
+
+// APIVersion returns the API version of the router, empty if it was not
+// created by NewVersionedRouter
+func (r *Router[T]) APIVersion() string {
+	return r.apiVersion
+}
+
+// Register registers all CRUD routes for the resource. Versioned routers
+// register them under /api/{version}/path.
 func (r *Router[T]) Register(path string) {
+	if r.apiVersion != "" {
+		path = "/api/" + r.apiVersion + path
+	}
 	group := r.engine.Group(path)
+	r.options.use(group, resourceKind(new(T)))
 	{
 		group.POST("", r.Create)
+		group.POST("/batch", r.BatchCreate)
+		group.GET("/batch", r.BatchGet)
+		group.POST("/findOrCreate", r.FindOrCreate)
+		group.POST("/upsert", r.Upsert)
 		group.GET("", r.List)
+		group.HEAD("", r.Count)
+		group.GET("/count", r.Count)
+		group.GET("/stats", r.Stats)
+		group.GET("/export", r.Export)
+		group.GET("/export.csv", r.ExportCSV)
+		group.POST("/import", r.Import)
 		group.GET("/:id", r.Get)
+		group.HEAD("/:id", r.Head)
+		group.GET("/uid/:uid", r.GetByUID)
 		group.PUT("/:id", r.Update)
+		group.PATCH("/bulk", r.BulkUpdate)
+		group.PATCH("/:id", r.Patch)
+		group.DELETE("/bulk", r.BulkDelete)
 		group.DELETE("/:id", r.Delete)
+		group.DELETE("", r.DeleteMany)
+		group.GET("/:id/status", r.GetStatus)
+		group.PUT("/:id/status", r.UpdateStatus)
+		group.POST("/:id/restore", r.Restore)
+		group.DELETE("/:id/purge", r.Purge)
+	}
+	registerOptionsRoutes(r.engine, group)
+
+	if r.options.openAPI != nil {
+		r.options.openAPI.addResource(group.BasePath(), reflect.TypeFor[T](), openAPIRoutes{patch: true, fields: true})
+	}
+}
+
+// RegisterReadOnly registers the routes reading the resource under path:
+// list, count, stats, export and get by ID or UID. It suits resources
+// written by the server itself, such as audit entries.
+func (r *Router[T]) RegisterReadOnly(path string) {
+	if r.apiVersion != "" {
+		path = "/api/" + r.apiVersion + path
+	}
+	group := r.engine.Group(path)
+	r.options.use(group, resourceKind(new(T)))
+	{
+		group.GET("", r.List)
+		group.HEAD("", r.Count)
+		group.GET("/count", r.Count)
+		group.GET("/stats", r.Stats)
+		group.GET("/export", r.Export)
+		group.GET("/export.csv", r.ExportCSV)
+		group.GET("/:id", r.Get)
+		group.HEAD("/:id", r.Head)
+		group.GET("/uid/:uid", r.GetByUID)
+	}
+	registerOptionsRoutes(r.engine, group)
+
+	if r.options.openAPI != nil {
+		r.options.openAPI.addResource(group.BasePath(), reflect.TypeFor[T](), openAPIRoutes{fields: true, readOnly: true})
+	}
+}
+
+// registerOptionsRoutes answers OPTIONS requests for every route under the
+// base path of group with 204 and an Allow header listing its methods.
+// Registering the routes lets group middleware such as NewCORSMiddleware
+// see preflight requests. Paths that already handle OPTIONS are skipped.
+func registerOptionsRoutes(engine *gin.Engine, group *gin.RouterGroup) {
+	base := group.BasePath()
+	methods := make(map[string][]string)
+	var paths []string
+	for _, route := range engine.Routes() {
+		if route.Path != base && !strings.HasPrefix(route.Path, strings.TrimSuffix(base, "/")+"/") {
+			continue
+		}
+		if _, ok := methods[route.Path]; !ok {
+			paths = append(paths, route.Path)
+		}
+		methods[route.Path] = append(methods[route.Path], route.Method)
+	}
+
+	for _, path := range paths {
+		if slices.Contains(methods[path], http.MethodOptions) {
+			continue
+		}
+		allow := strings.Join(append(methods[path], http.MethodOptions), ", ")
+		group.OPTIONS(strings.TrimPrefix(path, base), func(c *gin.Context) {
+			c.Header("Allow", allow)
+			c.Status(http.StatusNoContent)
+		})
 	}
 }
 
 // Create handles POST requests to create a new resource
 func (r *Router[T]) Create(c *gin.Context) {
 	var resource T
-	if err := c.ShouldBindJSON(&resource); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !r.bindResource(c, &resource) {
 		return
 	}
 
-	// Check if resource implements Validator interface
-	if validator, ok := any(&resource).(Validator); ok {
-		if err := validator.Validate(); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-	}
-
-	if err := r.dao.Create(&resource); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := r.dao.Create(requestContext(c), &resource); err != nil {
+		writeWriteError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, resource)
+	writeResource(c, http.StatusCreated, createResponse(&resource))
 }
 
-// List handles GET requests to list resources
+// List handles GET requests to list resources, filtered by the parameters
+// using the operator syntax of parseFilterOperators, by the
+// FilterExpression of filterExpr and by search for Searchable resources.
+// The associations of expand are embedded, see Expandable. With watch=true
+// the request is served by Watch instead, and with format=csv or Accept:
+// text/csv every matching resource is streamed as CSV, see writeCSV.
 func (r *Router[T]) List(c *gin.Context) {
+	if c.Query("watch") == "true" {
+		r.Watch(c)
+		return
+	}
+
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
 
-	items, _, err := r.dao.List(page, pageSize, nil)
+	fields, err := parseFields[T](c.Query("fields"))
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	// Eager-load the associations named by include and load only the
+	// selected columns
+	include, err := parseInclude(r.dao, c.Query("include"))
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	expand, err := parseExpand(r.dao, c.Query("expand"))
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	dao := r.dao
+	if include != nil {
+		dao = dao.Preload(include...)
+	}
+	if expand != nil {
+		dao = dao.Preload(expand...)
+	}
+	if columns := selectColumns(r.dao, fields); columns != nil {
+		dao = dao.Select(columns...)
+	}
+
+	// Restrict the list with the plain filters matching equal values, such
+	// as role=admin, and the operator filters, such as email[like]=%a%
+	plain, err := parsePlainFilter(r.dao, c.Request.URL.Query())
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	operators, err := parseFilterOperators(r.dao, c.Request.URL.Query())
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	filters := append(plain, operators...)
+	dao = dao.Search(c.Query("search"))
+
+	// Restrict it to the annotations of annotationSelector, e.g. backup=true
+	annotations, err := parseAnnotationSelector(r.dao, c.Query("annotationSelector"))
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	if annotations != nil {
+		dao = dao.Scope(annotations)
+	}
+
+	// Restrict it to filterExpr, e.g. (phase = Active OR phase = Pending)
+	expression, err := parseFilterExpression(r.dao, c.Query(FilterExpressionParameter))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeBadRequest(c, err.Error())
+		return
+	}
+	if expression != nil {
+		dao = dao.Scope(expression)
+	}
+
+	// Spreadsheets get every matching resource rather than a page
+	if csvRequested(c) {
+		sort, err := parseSort(r.dao, c.Query("sort"))
+		if err != nil {
+			writeBadRequest(c, err.Error())
+			return
+		}
+		writeCSV(c, dao.Scope(filters.Scope), fields, sort)
+		return
+	}
+
+	// Use keyset pagination when a cursor is given
+	if cursorRequested(c) {
+		listCursor(c, dao, filters, fields)
+		return
+	}
+	if _, ok := c.GetQuery("after"); ok {
+		listAfter(c, dao, pageSize, filters, fields)
+		return
+	}
+
+	sort, err := parseSort(r.dao, c.Query("sort"))
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	items, total, err := dao.List(c.Request.Context(), page, pageSize, filters, sort...)
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	setPaginationHeaders(c, page, pageSize, total)
+	if checkIfNoneMatch(c, ListETag(items, total)) {
 		return
 	}
 
@@ -81,73 +416,917 @@ func (r *Router[T]) List(c *gin.Context) {
 		items = make([]T, 0)
 	}
 
+	if fields != nil {
+		selected, err := selectFieldsList(items, fields)
+		if err != nil {
+			writeInternalError(c, err)
+			return
+		}
+		writeResource(c, http.StatusOK, selected)
+		return
+	}
+
 	// Return items directly for backward compatibility
-	c.JSON(http.StatusOK, items)
+	writeResource(c, http.StatusOK, items)
+}
+
+// CountResponse is the body of a count request
+type CountResponse struct {
+	Count int64 `json:"count"`
+}
+
+// Count handles GET requests counting the resources matching the query
+// parameters, e.g. ?isActive=true&createdAt[gte]=2024-01-01T00:00:00Z, and
+// HEAD requests on the collection, answered with X-Total-Count only
+func (r *Router[T]) Count(c *gin.Context) {
+	countResources(c, r.dao)
+}
+
+// countResources responds with the number of resources of dao matching the
+// query parameters, also set as the X-Total-Count header. Plain parameters
+// must equal the column they name, those using the operator syntax of
+// parseFilterOperators, search, annotationSelector and filterExpr apply as
+// in List, and the other list parameters are ignored.
+func countResources[T any](c *gin.Context, dao *DAO[T]) {
+	query := c.Request.URL.Query()
+	filter, err := parsePlainFilter(dao, query)
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	operators, err := parseFilterOperators(dao, query)
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	annotations, err := parseAnnotationSelector(dao, query.Get("annotationSelector"))
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	expression, err := parseFilterExpression(dao, query.Get(FilterExpressionParameter))
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	dao = dao.Search(query.Get("search"))
+	if annotations != nil {
+		dao = dao.Scope(annotations)
+	}
+	if expression != nil {
+		dao = dao.Scope(expression)
+	}
+
+	count, err := dao.Count(c.Request.Context(), append(filter, operators...))
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(count, 10))
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusOK)
+		return
+	}
+	writeResource(c, http.StatusOK, CountResponse{Count: count})
+}
+
+// parseFilter turns query parameters into a filter matching equal column
+// values, as if every name=value were name[eq]=value. Every parameter must
+// resolve to a field the resource allows eq on, and its first value is
+// converted to the type of the column.
+func parseFilter[T any](dao *DAO[T], values url.Values) (Filters, error) {
+	allowed := resourceFilterOperators[T]()
+
+	var filter Filters
+	for _, name := range sortedKeys(values) {
+		field, ok := dao.field(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown filter field %q", name)
+		}
+		condition, err := newFilter(field, allowed, FilterEq, []string{values.Get(name)}, fmt.Sprintf("filter parameter %q", name))
+		if err != nil {
+			return nil, err
+		}
+		filter = append(filter, condition)
+	}
+	return filter, nil
+}
+
+// parsePlainFilter parses the plain filter parameters of a list or count
+// request, such as role=admin, with parseFilter. The other list parameters,
+// and filters with an operator such as id[in]=1,2, are left to their own
+// parsers.
+func parsePlainFilter[T any](dao *DAO[T], query url.Values) (Filters, error) {
+	plain := url.Values{}
+	for key, values := range query {
+		if !slices.Contains(listParameterNames, key) && !strings.Contains(key, "[") {
+			plain[key] = values
+		}
+	}
+	return parseFilter(dao, plain)
+}
+
+// filterValue converts a query parameter to a value comparable with a
+// column of Go type t
+func filterValue(t reflect.Type, raw string) (interface{}, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeFor[time.Time]() {
+		// Dates stand for midnight UTC
+		if parsed, err := time.Parse(time.DateOnly, raw); err == nil {
+			return parsed, nil
+		}
+		return time.Parse(time.RFC3339Nano, raw)
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(raw, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(raw, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(raw, 64)
+	default:
+		return raw, nil
+	}
+}
+
+// parseInclude parses a comma separated list of associations to
+// eager-load, such as "profile,permissions". Every name must resolve to an
+// association of the resource.
+func parseInclude[T any](dao *DAO[T], raw string) ([]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var associations []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		association, ok := dao.Association(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown association %q", name)
+		}
+		associations = append(associations, association)
+	}
+	return associations, nil
+}
+
+// Expandable is implemented by resources whose associations may be
+// embedded with the expand query parameter. ExpandableAssociations
+// returns the Go field names of the associations allowed.
+type Expandable interface {
+	ExpandableAssociations() []string
+}
+
+// parseExpand parses the comma separated associations of the expand query
+// parameter, such as "Keys", which name the association by its field or
+// JSON name. Unlike include, only the associations listed by Expandable
+// may be expanded.
+func parseExpand[T any](dao *DAO[T], raw string) ([]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var allowed []string
+	if expandable, ok := any(new(T)).(Expandable); ok {
+		allowed = expandable.ExpandableAssociations()
+	}
+	var associations []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		association, ok := dao.Association(name)
+		if !ok || !slices.Contains(allowed, association) {
+			return nil, fmt.Errorf("unknown expansion %q", name)
+		}
+		if !slices.Contains(associations, association) {
+			associations = append(associations, association)
+		}
+	}
+	return associations, nil
+}
+
+// parseSort parses a sort expression such as "username,-createdAt" into
+// sort clauses. A leading "-" marks a field as descending. Every field must
+// resolve to a column of the resource.
+func parseSort[T any](dao *DAO[T], raw string) ([]SortClause, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var clauses []SortClause
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		desc := strings.HasPrefix(part, "-")
+		name := strings.TrimPrefix(part, "-")
+
+		column, ok := dao.Column(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid sort field %q", name)
+		}
+		clauses = append(clauses, SortClause{Field: column, Desc: desc})
+	}
+	return clauses, nil
+}
+
+// writeWriteError writes the response for a failed create or update,
+// reporting unique constraint violations as 409 with the offending field
+// and a database that stayed busy as 503
+func writeWriteError(c *gin.Context, err error) {
+	if errors.Is(err, ErrBusy) {
+		writeUnavailable(c, err)
+		return
+	}
+	writeStatus(c, writeErrorStatus(err))
+}
+
+// writeErrorStatus converts a failed create or update into a meta.Status
+func writeErrorStatus(err error) meta.Status {
+	var unique *UniqueViolationError
+	if errors.As(err, &unique) {
+		return meta.Status{
+			Code:    http.StatusConflict,
+			Reason:  meta.StatusReasonConflict,
+			Message: unique.Error(),
+			Details: []meta.StatusCause{{Field: unique.Field, Message: "duplicate value"}},
+		}
+	}
+	if errors.Is(err, ErrBusy) {
+		return meta.Status{
+			Code:    http.StatusServiceUnavailable,
+			Reason:  meta.StatusReasonServiceUnavailable,
+			Message: err.Error(),
+		}
+	}
+	return meta.Status{
+		Code:    http.StatusInternalServerError,
+		Reason:  meta.StatusReasonInternalError,
+		Message: err.Error(),
+	}
+}
+
+// setPaginationHeaders sets the X-Total-Count header and RFC 5988 Link
+// headers for the first, last, next and previous pages. Links are built from
+// the request host and path and keep all other query parameters.
+func setPaginationHeaders(c *gin.Context, page, pageSize int, total int64) {
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	if page < 1 || pageSize < 1 {
+		return
+	}
+
+	lastPage := int((total + int64(pageSize) - 1) / int64(pageSize))
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+
+	link := func(target int, rel string) string {
+		query := c.Request.URL.Query()
+		query.Set("page", strconv.Itoa(target))
+		query.Set("size", strconv.Itoa(pageSize))
+		return fmt.Sprintf(`<%s://%s%s?%s>; rel="%s"`, scheme, c.Request.Host, c.Request.URL.Path, query.Encode(), rel)
+	}
+
+	links := []string{link(1, "first"), link(lastPage, "last")}
+	if page < lastPage {
+		links = append(links, link(page+1, "next"))
+	}
+	if page > 1 {
+		links = append(links, link(page-1, "prev"))
+	}
+	c.Header("Link", strings.Join(links, ", "))
 }
 
-// Get handles GET requests to retrieve a resource by ID
+// listAfter writes a keyset-paginated ListResponse starting after the
+// ID given in the "after" query parameter, reduced to fields if given
+func listAfter[T any](c *gin.Context, dao *DAO[T], limit int, filters []Filter, fields map[string]bool) {
+	afterID, err := strconv.ParseUint(c.Query("after"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "invalid cursor")
+		return
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	// Fetch one extra row to find out whether another page exists
+	items, err := dao.ListAfter(c.Request.Context(), uint(afterID), limit+1, filters)
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	nextCursor := ""
+	if len(items) > limit {
+		items = items[:limit]
+		nextCursor = strconv.FormatUint(uint64(resourceID(&items[len(items)-1])), 10)
+	}
+	writeKeysetPage(c, items, limit, nextCursor, fields)
+}
+
+// cursorRequested reports whether the request asks for cursor pagination
+// with the "cursor" or "limit" query parameters
+func cursorRequested(c *gin.Context) bool {
+	query := c.Request.URL.Query()
+	return query.Has("cursor") || query.Has("limit")
+}
+
+// listCursor writes a ListResponse of up to "limit" resources following the
+// opaque "cursor" query parameter in the order given by "sort", reduced to
+// fields if given. The cursor of the next page is empty on the last one.
+func listCursor[T any](c *gin.Context, dao *DAO[T], filters []Filter, fields map[string]bool) {
+	query := c.Request.URL.Query()
+	if query.Has("page") || query.Has("size") || query.Has("after") {
+		writeBadRequest(c, "cursor and limit cannot be combined with page, size or after")
+		return
+	}
+
+	limit := 10
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			writeBadRequest(c, "invalid limit")
+			return
+		}
+		limit = parsed
+	}
+	cursor, err := DecodeCursor(query.Get("cursor"))
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	sort, err := parseSort(dao, query.Get("sort"))
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	items, next, err := dao.ListCursor(c.Request.Context(), limit, cursor, filters, sort...)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCursor) {
+			writeBadRequest(c, err.Error())
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	nextCursor := ""
+	if next != nil {
+		nextCursor = next.Encode()
+	}
+	writeKeysetPage(c, items, limit, nextCursor, fields)
+}
+
+// writeKeysetPage writes a page of keyset pagination as a ListResponse,
+// reduced to fields if given
+func writeKeysetPage[T any](c *gin.Context, items []T, limit int, nextCursor string, fields map[string]bool) {
+	if checkIfNoneMatch(c, ListETag(items, int64(len(items)))) {
+		return
+	}
+	if items == nil {
+		items = make([]T, 0)
+	}
+
+	if fields != nil {
+		selected, err := selectFieldsList(items, fields)
+		if err != nil {
+			writeInternalError(c, err)
+			return
+		}
+		writeResource(c, http.StatusOK, ListResponse[map[string]interface{}]{
+			Items:      selected,
+			Total:      int64(len(selected)),
+			Size:       limit,
+			NextCursor: nextCursor,
+		})
+		return
+	}
+
+	writeResource(c, http.StatusOK, ListResponse[T]{
+		Items:      items,
+		Total:      int64(len(items)),
+		Size:       limit,
+		NextCursor: nextCursor,
+	})
+}
+
+// namedResource is implemented by resources embedding meta.BaseResource,
+// which can be named in URLs
+type namedResource interface {
+	GetName() string
+}
+
+// parseIDParam returns the ID of the resource named by the id path
+// parameter: its ID or, for resources with a meta.ObjectMeta Name, its
+// name when the parameter is not a number. It answers 400 or 404 and
+// returns false when the parameter names no resource.
+func parseIDParam[T any](c *gin.Context, dao *DAO[T]) (uint, bool) {
+	param := c.Param("id")
+	if id, err := strconv.ParseUint(param, 10, 64); err == nil {
+		return uint(id), true
+	}
+	if _, ok := any(new(T)).(namedResource); !ok || meta.ValidateName(param) != nil {
+		writeBadRequest(c, "invalid id")
+		return 0, false
+	}
+
+	resource, err := dao.GetByName(c.Request.Context(), param)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			writeNotFound(c)
+			return 0, false
+		}
+		writeInternalError(c, err)
+		return 0, false
+	}
+	return resourceID(resource), true
+}
+
+// Get handles GET requests to retrieve a resource by ID or name, embedding
+// the associations of expand
 func (r *Router[T]) Get(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	id, ok := parseIDParam(c, r.dao)
+	if !ok {
+		return
+	}
+
+	fields, err := parseFields[T](c.Query("fields"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		writeBadRequest(c, err.Error())
 		return
 	}
+	expand, err := parseExpand(r.dao, c.Query("expand"))
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	dao := r.dao
+	if columns := selectColumns(r.dao, fields); columns != nil {
+		dao = dao.Select(columns...)
+	}
+	if expand != nil {
+		dao = dao.Preload(expand...)
+	}
 
-	resource, err := r.dao.Get(uint(id))
+	resource, err := dao.Get(c.Request.Context(), id)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+			writeNotFound(c)
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	if checkIfNoneMatch(c, ETag(resourceVersion(resource))) {
+		return
+	}
+
+	if fields != nil {
+		selected, err := selectFields(resource, fields)
+		if err != nil {
+			writeInternalError(c, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeResource(c, http.StatusOK, selected)
 		return
 	}
 
-	c.JSON(http.StatusOK, resource)
+	writeResource(c, http.StatusOK, resource)
 }
 
-// Update handles PUT requests to update a resource
-func (r *Router[T]) Update(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+// GetByUID handles GET requests to retrieve a resource by UID
+func (r *Router[T]) GetByUID(c *gin.Context) {
+	uid, err := uuid.Parse(c.Param("uid"))
+	if err != nil {
+		writeBadRequest(c, "invalid uid")
+		return
+	}
+
+	resource, err := r.dao.GetByUID(c.Request.Context(), uid.String())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		if err == gorm.ErrRecordNotFound {
+			writeNotFound(c)
+			return
+		}
+		writeInternalError(c, err)
 		return
 	}
 
+	c.Header("ETag", ETag(resourceVersion(resource)))
+	writeResource(c, http.StatusOK, resource)
+}
+
+// Head handles HEAD requests to check whether a resource exists
+func (r *Router[T]) Head(c *gin.Context) {
+	id, ok := parseIDParam(c, r.dao)
+	if !ok {
+		return
+	}
+
+	resource, err := r.dao.Get(c.Request.Context(), id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("ETag", ETag(resourceVersion(resource)))
+	c.Status(http.StatusOK)
+}
+
+// Update handles PUT requests to update a resource by ID or name
+func (r *Router[T]) Update(c *gin.Context) {
+	id, ok := parseIDParam(c, r.dao)
+	if !ok {
+		return
+	}
+
+	// Answer 404 before reading the body of an update that cannot succeed.
+	// With If-Match, a missing resource fails the precondition instead.
+	if c.GetHeader("If-Match") == "" {
+		exists, err := r.dao.Exists(c.Request.Context(), id)
+		if err != nil {
+			writeInternalError(c, err)
+			return
+		}
+		if !exists {
+			writeNotFound(c)
+			return
+		}
+	}
+
 	var resource T
-	if err := c.ShouldBindJSON(&resource); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !r.decodeResource(c, &resource) {
+		return
+	}
+
+	// Only update if the client saw the latest version
+	expectedVersion, ok := r.checkIfMatch(c, id)
+	if !ok {
+		return
+	}
+
+	// Fields left out of the JSON, such as password hashes, cannot be sent
+	// and keep their stored value
+	current, err := r.dao.Get(c.Request.Context(), id)
+	switch {
+	case err == nil:
+		keepHiddenFields(reflect.ValueOf(&resource).Elem(), reflect.ValueOf(current).Elem())
+	case err != gorm.ErrRecordNotFound:
+		writeInternalError(c, err)
+		return
+	}
+	if !r.checkResource(c, &resource) {
+		return
+	}
+	preconditioned := expectedVersion != 0
+	if !preconditioned {
+		expectedVersion = resourceVersion(&resource)
+	}
+	if expectedVersion == 0 && r.options.requireResourceVersion {
+		writeError(c, http.StatusPreconditionRequired, meta.StatusReasonPreconditionRequired, "resourceVersion is required")
+		return
+	}
+
+	if _, ok := any(&resource).(UpdateValidator); ok {
+		if current == nil {
+			r.writeUpdateError(c, id, gorm.ErrRecordNotFound, preconditioned)
+			return
+		}
+		if err := validateUpdate(requestContext(c), &resource, current); err != nil {
+			writeInvalid(c, err)
+			return
+		}
+	}
+
+	if err := r.dao.Update(requestContext(c), id, &resource, expectedVersion); err != nil {
+		r.writeUpdateError(c, id, err, preconditioned)
+		return
+	}
+
+	writeResource(c, http.StatusOK, resource)
+}
+
+// writeUpdateError writes the response for a failed update or
+// preconditioned delete. Version conflicts are reported as 412 when the
+// client sent If-Match and as 409 otherwise, together with the current
+// object.
+func (r *Router[T]) writeUpdateError(c *gin.Context, id uint, err error, preconditioned bool) {
+	if err == gorm.ErrRecordNotFound {
+		writeNotFound(c)
+		return
+	}
+	if err != ErrConflict {
+		writeWriteError(c, err)
+		return
+	}
+
+	current, getErr := r.dao.Get(c.Request.Context(), id)
+	if getErr != nil {
+		writeInternalError(c, getErr)
+		return
+	}
+	if preconditioned {
+		writeStatus(c, meta.Status{
+			Code:    http.StatusPreconditionFailed,
+			Reason:  meta.StatusReasonPreconditionFailed,
+			Message: "precondition failed",
+			Current: current,
+		})
+		return
+	}
+	c.Header("Retry-After", "0")
+	writeStatus(c, meta.Status{
+		Code:    http.StatusConflict,
+		Reason:  meta.StatusReasonConflict,
+		Message: err.Error(),
+		Current: current,
+	})
+}
+
+// statusGetter is implemented by resources embedding meta.BaseResource
+type statusGetter interface {
+	GetStatus() meta.ResourceStatus
+}
+
+// GetStatus handles GET requests for the status sub-resource
+func (r *Router[T]) GetStatus(c *gin.Context) {
+	id, ok := parseIDParam(c, r.dao)
+	if !ok {
+		return
+	}
+
+	resource, err := r.dao.Get(c.Request.Context(), id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			writeNotFound(c)
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	getter, ok := any(resource).(statusGetter)
+	if !ok {
+		writeNotFound(c)
+		return
+	}
+
+	writeResource(c, http.StatusOK, getter.GetStatus())
+}
+
+// UpdateStatus handles PUT requests for the status sub-resource. Only the
+// status is written; the rest of the resource is left untouched.
+func (r *Router[T]) UpdateStatus(c *gin.Context) {
+	if _, ok := any(new(T)).(statusGetter); !ok {
+		writeNotFound(c)
+		return
+	}
+	id, ok := parseIDParam(c, r.dao)
+	if !ok {
+		return
+	}
+
+	var status meta.ResourceStatus
+	if err := c.ShouldBindJSON(&status); err != nil {
+		writeInvalid(c, err)
 		return
 	}
 
-	if err := r.dao.Update(uint(id), &resource); err != nil {
+	if err := r.dao.UpdateStatus(requestContext(c), id, status); err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+			writeNotFound(c)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeInternalError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, resource)
+	resource, err := r.dao.Get(c.Request.Context(), id)
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	writeResource(c, http.StatusOK, resource)
 }
 
-// Delete handles DELETE requests to delete a resource
+// Delete handles DELETE requests to delete a resource by ID or name
 func (r *Router[T]) Delete(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	id, ok := parseIDParam(c, r.dao)
+	if !ok {
+		return
+	}
+
+	expectedVersion, ok := r.checkIfMatch(c, id)
+	if !ok {
+		return
+	}
+
+	if err := r.dao.Delete(requestContext(c), id, expectedVersion); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			writeNotFound(c)
+			return
+		}
+		if err == ErrConflict {
+			// The resource changed after the If-Match check
+			r.writeUpdateError(c, id, err, true)
+			return
+		}
+		if err == ErrOwnerDeletionBlocked {
+			writeError(c, http.StatusConflict, meta.StatusReasonConflict, err.Error())
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	writeDeleted(c, r.dao, id)
+}
+
+// writeDeleted writes the response for a successful delete: 204 when the
+// resource is gone, or 202 with the resource while finalizers hold it back
+func writeDeleted[T any](c *gin.Context, dao *DAO[T], id uint) {
+	resource, err := dao.Get(c.Request.Context(), id)
+	if err == gorm.ErrRecordNotFound {
+		c.Status(http.StatusNoContent)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		writeInternalError(c, err)
+		return
+	}
+	writeResource(c, http.StatusAccepted, resource)
+}
+
+// Restore handles POST requests restoring a soft-deleted resource,
+// answering with the restored resource. A name rather than an ID names the
+// first deleted resource with that name.
+func (r *Router[T]) Restore(c *gin.Context) {
+	deleted := r.dao
+	if r.dao.softDeletes() {
+		deleted = r.dao.Scope(deletedScope)
+	}
+	id, ok := parseIDParam(c, deleted)
+	if !ok {
 		return
 	}
 
-	if err := r.dao.Delete(uint(id)); err != nil {
+	if err := r.dao.Restore(requestContext(c), id); err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+			writeNotFound(c)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeWriteError(c, err)
 		return
 	}
 
+	resource, err := r.dao.Get(c.Request.Context(), id)
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+	writeResource(c, http.StatusOK, resource)
+}
+
+// Purge handles DELETE requests removing a resource from storage, whether
+// it is soft deleted or not. Names only match live resources, as deleted
+// ones may share them; deleted resources are purged by ID.
+func (r *Router[T]) Purge(c *gin.Context) {
+	id, ok := parseIDParam(c, r.dao)
+	if !ok {
+		return
+	}
+
+	if err := r.dao.Purge(requestContext(c), id); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			writeNotFound(c)
+			return
+		}
+		if err == ErrOwnerDeletionBlocked {
+			writeError(c, http.StatusConflict, meta.StatusReasonConflict, err.Error())
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
 	c.Status(http.StatusNoContent)
 }
+
+// BulkUpdateRequest is the body of a bulk update request
+type BulkUpdateRequest struct {
+	IDs   []uint                 `json:"ids" binding:"required"`
+	Patch map[string]interface{} `json:"patch" binding:"required"`
+}
+
+// BulkUpdateResponse reports the outcome of a bulk update request
+type BulkUpdateResponse struct {
+	Updated int64 `json:"updated"`
+}
+
+// BulkUpdate handles PATCH requests setting the same fields on all
+// resources listed in the body, see DAO.BulkUpdate. IDs that do not exist
+// are skipped; it responds 404 only when none of the resources existed.
+func (r *Router[T]) BulkUpdate(c *gin.Context) {
+	bulkUpdateResources(c, r.dao)
+}
+
+// bulkUpdateResources applies the bulk update request of c to resources
+// of dao
+func bulkUpdateResources[T any](c *gin.Context, dao *DAO[T]) {
+	var request BulkUpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeInvalid(c, err)
+		return
+	}
+	if len(request.IDs) == 0 {
+		writeBadRequest(c, "ids must not be empty")
+		return
+	}
+	if len(request.Patch) == 0 {
+		writeBadRequest(c, "patch must not be empty")
+		return
+	}
+
+	updated, err := dao.BulkUpdate(requestContext(c), request.IDs, request.Patch)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUnknownField):
+			writeBadRequest(c, err.Error())
+		case errors.Is(err, ErrInvalidPatch):
+			writeInvalid(c, err)
+		default:
+			writeWriteError(c, err)
+		}
+		return
+	}
+	if updated == 0 {
+		writeNotFound(c)
+		return
+	}
+
+	writeResource(c, http.StatusOK, BulkUpdateResponse{Updated: updated})
+}
+
+// DeleteManyRequest is the body of a bulk delete request
+type DeleteManyRequest struct {
+	IDs []uint `json:"ids" binding:"required"`
+}
+
+// DeleteManyResponse reports the outcome of a bulk delete request.
+// Finalizing lists the resources with finalizers, which are not deleted
+// yet.
+type DeleteManyResponse struct {
+	Deleted    int64  `json:"deleted"`
+	Finalizing []uint `json:"finalizing"`
+	NotFound   []uint `json:"notFound"`
+}
+
+// DeleteMany handles DELETE requests removing all resources listed in the
+// body. It responds 404 only when none of the resources existed, and 409
+// without deleting any when one is an owner whose deletion is blocked.
+func (r *Router[T]) DeleteMany(c *gin.Context) {
+	var request DeleteManyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeInvalid(c, err)
+		return
+	}
+	if len(request.IDs) == 0 {
+		writeBadRequest(c, "ids must not be empty")
+		return
+	}
+
+	result, err := r.dao.DeleteMany(requestContext(c), request.IDs)
+	if errors.Is(err, ErrOwnerDeletionBlocked) {
+		writeError(c, http.StatusConflict, meta.StatusReasonConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+	if result.Deleted == 0 && len(result.Finalizing) == 0 {
+		writeNotFound(c)
+		return
+	}
+
+	response := DeleteManyResponse{Deleted: result.Deleted, Finalizing: result.Finalizing, NotFound: result.NotFound}
+	if response.Finalizing == nil {
+		response.Finalizing = make([]uint, 0)
+	}
+	if response.NotFound == nil {
+		response.NotFound = make([]uint, 0)
+	}
+	writeResource(c, http.StatusOK, response)
+}
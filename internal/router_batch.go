@@ -0,0 +1,288 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Batch item and overall outcomes
+const (
+	BatchStatusCreated    = "Created"
+	BatchStatusFailed     = "Failed"
+	BatchStatusRolledBack = "RolledBack"
+
+	BatchStatusDeleted    = "Deleted"
+	BatchStatusFinalizing = "Finalizing"
+	BatchStatusNotFound   = "NotFound"
+
+	BatchStatusSuccess        = "Success"
+	BatchStatusPartialSuccess = "PartialSuccess"
+	BatchStatusFailure        = "Failure"
+)
+
+// errBatchFailed aborts the batch transaction when an item fails
+var errBatchFailed = errors.New("batch item failed")
+
+// BatchResult reports the outcome for a single item of a batch
+type BatchResult[T any] struct {
+	Index  int          `json:"index"`
+	Status string       `json:"status"`
+	Object *T           `json:"object,omitempty"`
+	Error  *meta.Status `json:"error,omitempty"`
+}
+
+// BatchResponse reports the outcome of a batch request
+type BatchResponse[T any] struct {
+	Status  string           `json:"status"`
+	Results []BatchResult[T] `json:"results"`
+}
+
+// MaxBatchGetIDs is the most IDs a single BatchGet request may ask for
+const MaxBatchGetIDs = 100
+
+// BatchCreate handles POST requests creating many resources in one
+// transaction. It responds 201 when every item was created, 207 when only
+// some were and 422 when none were.
+func (r *Router[T]) BatchCreate(c *gin.Context) {
+	var items []json.RawMessage
+	if err := c.ShouldBindJSON(&items); err != nil {
+		writeBadRequest(c, "batch must be a JSON array")
+		return
+	}
+
+	results := make([]BatchResult[T], len(items))
+	failed := 0
+	err := r.dao.Transaction(requestContext(c), func(tx *gorm.DB) error {
+		failed = 0
+		for i, item := range items {
+			results[i] = r.createBatchItem(tx, i, item)
+			if results[i].Status == BatchStatusFailed {
+				failed++
+			}
+		}
+		if failed > 0 && !r.options.batchPartialSuccess {
+			return errBatchFailed
+		}
+		return nil
+	})
+	if err != nil && err != errBatchFailed {
+		writeWriteError(c, err)
+		return
+	}
+
+	// Without partial success a failure discards every created item
+	if err == errBatchFailed {
+		for i := range results {
+			if results[i].Status == BatchStatusCreated {
+				results[i].Status = BatchStatusRolledBack
+				results[i].Object = nil
+			}
+		}
+	}
+
+	// Items are only visible to watchers once the transaction committed
+	if err == nil {
+		for _, result := range results {
+			if result.Status == BatchStatusCreated {
+				r.dao.publish(EventAdded, *result.Object)
+			}
+		}
+	}
+
+	created := len(items) - failed
+	if err == errBatchFailed {
+		created = 0
+	}
+
+	switch {
+	case created == len(items):
+		writeResource(c, http.StatusCreated, BatchResponse[T]{Status: BatchStatusSuccess, Results: results})
+	case created > 0:
+		writeResource(c, http.StatusMultiStatus, BatchResponse[T]{Status: BatchStatusPartialSuccess, Results: results})
+	default:
+		writeResource(c, http.StatusUnprocessableEntity, BatchResponse[T]{Status: BatchStatusFailure, Results: results})
+	}
+}
+
+// createBatchItem decodes, validates and creates one batch item inside tx.
+// Each item runs under its own savepoint so a failed insert does not abort
+// the surrounding transaction.
+func (r *Router[T]) createBatchItem(tx *gorm.DB, index int, item json.RawMessage) BatchResult[T] {
+	failure := func(status meta.Status) BatchResult[T] {
+		return BatchResult[T]{Index: index, Status: BatchStatusFailed, Error: &status}
+	}
+	invalid := func(err error) BatchResult[T] {
+		return failure(meta.Status{
+			Code:    http.StatusBadRequest,
+			Reason:  meta.StatusReasonInvalid,
+			Message: err.Error(),
+		})
+	}
+
+	var obj T
+	if err := json.Unmarshal(item, &obj); err != nil {
+		return invalid(err)
+	}
+	if fields := r.validator.Validate(&obj); fields != nil {
+		return failure(unprocessableStatus(fields))
+	}
+	if err := validateResource(&obj); err != nil {
+		return invalid(err)
+	}
+
+	savepoint := fmt.Sprintf("batch_item_%d", index)
+	if err := tx.SavePoint(savepoint).Error; err != nil {
+		return failure(writeErrorStatus(err))
+	}
+	if err := tx.Create(&obj).Error; err != nil {
+		tx.RollbackTo(savepoint)
+		return failure(writeErrorStatus(translateError(err)))
+	}
+	if err := r.dao.recordAudit(tx, AuditActionCreate, nil, &obj); err != nil {
+		tx.RollbackTo(savepoint)
+		return failure(writeErrorStatus(err))
+	}
+
+	return BatchResult[T]{Index: index, Status: BatchStatusCreated, Object: &obj}
+}
+
+// BatchGet handles GET requests fetching the resources listed in the ids
+// query parameter, such as ?ids=1,2,3, in that order. IDs that do not exist
+// are left out of the response.
+func (r *Router[T]) BatchGet(c *gin.Context) {
+	ids, err := parseIDList(c.Query("ids"))
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	if len(ids) > MaxBatchGetIDs {
+		writeBadRequest(c, fmt.Sprintf("at most %d ids may be requested at once", MaxBatchGetIDs))
+		return
+	}
+
+	items, err := r.dao.ListByIDs(c.Request.Context(), ids)
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+	writeResource(c, http.StatusOK, items)
+}
+
+// parseIDList parses a comma separated list of resource IDs
+func parseIDList(raw string) ([]uint, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, errors.New("ids must not be empty")
+	}
+
+	parts := strings.Split(raw, ",")
+	ids := make([]uint, len(parts))
+	for i, part := range parts {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 0)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("invalid id %q", part)
+		}
+		ids[i] = uint(id)
+	}
+	return ids, nil
+}
+
+// BulkDeleteResult reports the outcome for one ID of a bulk delete
+type BulkDeleteResult struct {
+	ID     uint         `json:"id"`
+	Status string       `json:"status"`
+	Error  *meta.Status `json:"error,omitempty"`
+}
+
+// BulkDeleteResponse reports the outcome of a bulk delete request
+type BulkDeleteResponse struct {
+	Status  string             `json:"status"`
+	Results []BulkDeleteResult `json:"results"`
+}
+
+// BulkDelete handles DELETE requests deleting the resources listed in the
+// body with DAO.DeleteMany. It responds 207 with the outcome per ID:
+// Deleted, Finalizing for resources with finalizers, NotFound, or Failed
+// for the resource that could not be deleted and RolledBack for the others.
+func (r *Router[T]) BulkDelete(c *gin.Context) {
+	bulkDeleteResources(c, r.dao)
+}
+
+// bulkDeleteResources applies the bulk delete request of c to resources of
+// dao
+func bulkDeleteResources[T any](c *gin.Context, dao *DAO[T]) {
+	var request DeleteManyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeInvalid(c, err)
+		return
+	}
+	if len(request.IDs) == 0 {
+		writeBadRequest(c, "ids must not be empty")
+		return
+	}
+
+	result, err := dao.DeleteMany(requestContext(c), request.IDs)
+	var deleteErr *BulkDeleteError
+	if err != nil && !errors.As(err, &deleteErr) {
+		writeWriteError(c, err)
+		return
+	}
+
+	// The IDs neither missing nor finalizing were deleted
+	statuses := make(map[uint]string, len(request.IDs))
+	for _, id := range request.IDs {
+		statuses[id] = BatchStatusDeleted
+	}
+	for _, id := range result.NotFound {
+		statuses[id] = BatchStatusNotFound
+	}
+	for _, id := range result.Finalizing {
+		statuses[id] = BatchStatusFinalizing
+	}
+
+	response := BulkDeleteResponse{Status: BatchStatusSuccess, Results: []BulkDeleteResult{}}
+	succeeded := 0
+	seen := make(map[uint]bool, len(request.IDs))
+	for _, id := range request.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		item := BulkDeleteResult{ID: id, Status: statuses[id]}
+		switch {
+		case item.Status == BatchStatusNotFound:
+		case deleteErr != nil && id == deleteErr.ID:
+			item.Status = BatchStatusFailed
+			item.Error = bulkDeleteErrorStatus(deleteErr)
+		case deleteErr != nil:
+			item.Status = BatchStatusRolledBack
+		default:
+			succeeded++
+		}
+		response.Results = append(response.Results, item)
+	}
+
+	switch {
+	case deleteErr != nil || succeeded == 0:
+		response.Status = BatchStatusFailure
+	case succeeded < len(response.Results):
+		response.Status = BatchStatusPartialSuccess
+	}
+	writeResource(c, http.StatusMultiStatus, response)
+}
+
+// bulkDeleteErrorStatus describes why a resource stopped a bulk delete
+func bulkDeleteErrorStatus(err *BulkDeleteError) *meta.Status {
+	if errors.Is(err, ErrOwnerDeletionBlocked) {
+		return &meta.Status{Code: http.StatusConflict, Reason: meta.StatusReasonConflict, Message: err.Err.Error()}
+	}
+	return &meta.Status{Code: http.StatusUnprocessableEntity, Reason: meta.StatusReasonInvalid, Message: err.Err.Error()}
+}
@@ -0,0 +1,248 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const duplicateEmailBatch = `[
+	{"username":"user1","email":"user1@example.com","password":"password123"},
+	{"username":"user2","email":"user1@example.com","password":"password123"},
+	{"username":"user3","email":"user3@example.com","password":"password123"}
+]`
+
+func TestRouter_BatchCreate(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	body := `[
+		{"username":"user1","email":"user1@example.com","password":"password123"},
+		{"username":"user2","email":"user2@example.com","password":"password123"}
+	]`
+	req := httptest.NewRequest("POST", "/api/v1/users/batch", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response BatchResponse[apiv1.User]
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchStatusSuccess, response.Status)
+	assert.Len(t, response.Results, 2)
+	assert.NotZero(t, response.Results[1].Object.ID)
+
+	var count int64
+	db.Model(&apiv1.User{}).Count(&count)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestRouter_BatchCreateRollback(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	req := httptest.NewRequest("POST", "/api/v1/users/batch", bytes.NewBufferString(duplicateEmailBatch))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var response BatchResponse[apiv1.User]
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchStatusFailure, response.Status)
+	assert.Equal(t, BatchStatusRolledBack, response.Results[0].Status)
+	assert.Equal(t, BatchStatusFailed, response.Results[1].Status)
+	assert.Equal(t, meta.StatusReasonConflict, response.Results[1].Error.Reason)
+	assert.Equal(t, BatchStatusRolledBack, response.Results[2].Status)
+
+	// Nothing was created
+	var count int64
+	db.Model(&apiv1.User{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestRouter_BatchCreatePartialSuccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	NewRouter[apiv1.User](router, db, WithBatchPartialSuccess()).Register("/api/v1/users")
+
+	req := httptest.NewRequest("POST", "/api/v1/users/batch", bytes.NewBufferString(duplicateEmailBatch))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var response BatchResponse[apiv1.User]
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchStatusPartialSuccess, response.Status)
+	assert.Equal(t, BatchStatusCreated, response.Results[0].Status)
+	assert.Equal(t, BatchStatusFailed, response.Results[1].Status)
+	assert.Contains(t, response.Results[1].Error.Message, "email")
+	assert.Equal(t, BatchStatusCreated, response.Results[2].Status)
+
+	// The valid items were kept
+	var count int64
+	db.Model(&apiv1.User{}).Count(&count)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestRouter_BatchCreateInvalid(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	// Not an array
+	req := httptest.NewRequest("POST", "/api/v1/users/batch", bytes.NewBufferString(`{"username":"user1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// An item failing validation
+	req = httptest.NewRequest("POST", "/api/v1/users/batch", bytes.NewBufferString(`[{"username":"user1","email":"bad"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var response BatchResponse[apiv1.User]
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, meta.StatusReasonInvalid, response.Results[0].Error.Reason)
+}
+
+func TestRouter_BatchGet(t *testing.T) {
+	r, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	req := httptest.NewRequest("POST", "/api/v1/users/batch", bytes.NewBufferString(`[
+		{"username":"user1","email":"user1@example.com","password":"password123"},
+		{"username":"user2","email":"user2@example.com","password":"password123"},
+		{"username":"user3","email":"user3@example.com","password":"password123"}
+	]`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	tests := []struct {
+		name      string
+		query     string
+		code      int
+		usernames []string
+	}{
+		{"input order", "?ids=3,1,2", http.StatusOK, []string{"user3", "user1", "user2"}},
+		{"missing ids left out", "?ids=2,%2099,1", http.StatusOK, []string{"user2", "user1"}},
+		{"none found", "?ids=99", http.StatusOK, []string{}},
+		{"no ids", "", http.StatusBadRequest, nil},
+		{"invalid id", "?ids=1,abc", http.StatusBadRequest, nil},
+		{"zero id", "?ids=0", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/batch"+tt.query, nil))
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.code != http.StatusOK {
+				assert.Equal(t, meta.StatusReasonBadRequest, decodeStatus(t, w).Reason)
+				return
+			}
+
+			var users []apiv1.User
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+			usernames := make([]string, len(users))
+			for i, user := range users {
+				usernames[i] = user.Username
+			}
+			assert.Equal(t, tt.usernames, usernames)
+		})
+	}
+
+	t.Run("too many ids", func(t *testing.T) {
+		ids := strings.TrimSuffix(strings.Repeat("1,", MaxBatchGetIDs+1), ",")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/batch?ids="+ids, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestRouter_BulkDelete(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	for i := 1; i <= 3; i++ {
+		user := &apiv1.User{Username: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i), Password: "password123"}
+		if i == 3 {
+			user.Finalizers = []string{"example.com/cleanup"}
+		}
+		require.NoError(t, db.Create(user).Error)
+	}
+	require.NoError(t, db.AutoMigrate(&testGuarded{}))
+	NewRouter[testGuarded](router, db).Register("/guarded")
+	for _, name := range []string{"first", "protected"} {
+		require.NoError(t, db.Create(&testGuarded{Name: name}).Error)
+	}
+
+	bulkDelete := func(path, body string) (int, BulkDeleteResponse) {
+		req := httptest.NewRequest("DELETE", path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response BulkDeleteResponse
+		if w.Code == http.StatusMultiStatus {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		return w.Code, response
+	}
+
+	code, response := bulkDelete("/api/v1/users/bulk", `{"ids":[1,3,99,1]}`)
+	require.Equal(t, http.StatusMultiStatus, code)
+	assert.Equal(t, BatchStatusPartialSuccess, response.Status)
+	assert.Equal(t, []BulkDeleteResult{
+		{ID: 1, Status: BatchStatusDeleted},
+		{ID: 3, Status: BatchStatusFinalizing},
+		{ID: 99, Status: BatchStatusNotFound},
+	}, response.Results)
+
+	code, response = bulkDelete("/api/v1/users/bulk", `{"ids":[2]}`)
+	require.Equal(t, http.StatusMultiStatus, code)
+	assert.Equal(t, BatchStatusSuccess, response.Status)
+
+	// One failing hook fails the whole request
+	code, response = bulkDelete("/guarded/bulk", `{"ids":[1,2,5]}`)
+	require.Equal(t, http.StatusMultiStatus, code)
+	assert.Equal(t, BatchStatusFailure, response.Status)
+	require.Len(t, response.Results, 3)
+	assert.Equal(t, BatchStatusRolledBack, response.Results[0].Status)
+	assert.Equal(t, BatchStatusFailed, response.Results[1].Status)
+	require.NotNil(t, response.Results[1].Error)
+	assert.Equal(t, "protected rows cannot be deleted", response.Results[1].Error.Message)
+	assert.Equal(t, BatchStatusNotFound, response.Results[2].Status)
+
+	var guarded int64
+	require.NoError(t, db.Model(&testGuarded{}).Count(&guarded).Error)
+	assert.Equal(t, int64(2), guarded)
+
+	code, _ = bulkDelete("/api/v1/users/bulk", `{"ids":[]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
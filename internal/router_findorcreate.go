@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FindOrCreateRequest is the body of a find-or-create request
+type FindOrCreateRequest struct {
+	// Match holds the fields identifying the resource, by JSON or column
+	// name, such as {"email": "alice@example.com"}
+	Match map[string]interface{} `json:"match"`
+
+	// Object is the resource to create if none matches. The values of
+	// Match are set on it.
+	Object json.RawMessage `json:"object"`
+}
+
+// FindOrCreate handles POST requests returning the resource matching the
+// given fields with 200, or creating it with 201 if there is none
+func (r *Router[T]) FindOrCreate(c *gin.Context) {
+	var req FindOrCreateRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		writeInvalid(c, err)
+		return
+	}
+	if len(req.Match) == 0 {
+		writeBadRequest(c, "match must name at least one field")
+		return
+	}
+
+	condition := make(map[string]interface{}, len(req.Match))
+	for name, value := range req.Match {
+		column, ok := r.dao.Column(name)
+		if !ok {
+			writeBadRequest(c, fmt.Sprintf("unknown match field %q", name))
+			return
+		}
+		condition[column] = value
+	}
+
+	// Decode the object, then the match on top of it so that a created
+	// resource is found by the same request again
+	var resource T
+	if len(req.Object) > 0 {
+		if err := json.Unmarshal(req.Object, &resource); err != nil {
+			writeInvalid(c, err)
+			return
+		}
+	}
+	// Match was decoded from JSON, so it always encodes
+	match, _ := json.Marshal(req.Match)
+	if err := json.Unmarshal(match, &resource); err != nil {
+		writeInvalid(c, err)
+		return
+	}
+	if !r.checkResource(c, &resource) {
+		return
+	}
+
+	created, err := r.dao.FindOrCreate(requestContext(c), condition, &resource)
+	if err != nil {
+		writeWriteError(c, err)
+		return
+	}
+
+	if created {
+		writeResource(c, http.StatusCreated, createResponse(&resource))
+		return
+	}
+	writeResource(c, http.StatusOK, resource)
+}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/meta"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_FindOrCreate(t *testing.T) {
+	r, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	findOrCreate := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/users/findOrCreate", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	const body = `{"match":{"email":"alice@example.com"},"object":{"username":"alice","password":"password123"}}`
+
+	w := findOrCreate(body)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "alice", created.Username)
+	assert.Equal(t, "alice@example.com", created.Email)
+
+	w = findOrCreate(`{"match":{"email":"alice@example.com"},"object":{"username":"bob","password":"password123"}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var found apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, created.ID, found.ID)
+	assert.Equal(t, "alice", found.Username)
+
+	tests := []struct {
+		name   string
+		body   string
+		code   int
+		reason meta.StatusReason
+	}{
+		{"malformed body", `{"match":`, http.StatusBadRequest, meta.StatusReasonInvalid},
+		{"missing match", `{"object":{"username":"carol"}}`, http.StatusBadRequest, meta.StatusReasonBadRequest},
+		{"unknown field", `{"match":{"nickname":"carol"}}`, http.StatusBadRequest, meta.StatusReasonBadRequest},
+		{"invalid object", `{"match":{"email":"carol@example.com"},"object":{"username":"carol"}}`,
+			http.StatusUnprocessableEntity, meta.StatusReasonInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := findOrCreate(tt.body)
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+			assert.Equal(t, tt.reason, decodeStatus(t, w).Reason)
+		})
+	}
+}
+
+func TestRouter_FindOrCreateConcurrent(t *testing.T) {
+	r, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	const requests = 10
+	codes := make([]int, requests)
+	ids := make([]uint, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := `{"match":{"email":"alice@example.com"},"object":{"username":"alice","password":"password123"}}`
+			req := httptest.NewRequest("POST", "/api/v1/users/findOrCreate", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			codes[i] = w.Code
+
+			var user apiv1.User
+			if json.Unmarshal(w.Body.Bytes(), &user) == nil {
+				ids[i] = user.ID
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	createdCount := 0
+	for i, code := range codes {
+		if code == http.StatusCreated {
+			createdCount++
+		} else {
+			assert.Equal(t, http.StatusOK, code, "request %d", i)
+		}
+		assert.Equal(t, ids[0], ids[i], "request %d", i)
+	}
+	assert.Equal(t, 1, createdCount)
+
+	var count int64
+	require.NoError(t, db.Model(&apiv1.User{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
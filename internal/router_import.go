@@ -0,0 +1,351 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	// ExportBatchSize is how many lines an export writes between flushes
+	ExportBatchSize = 100
+
+	// ImportBatchSize is how many lines an import stores per transaction
+	ImportBatchSize = 100
+)
+
+// NDJSONContentType is the media type of exports and imports, one JSON
+// resource per line
+const NDJSONContentType = "application/x-ndjson"
+
+// Statuses of the resources of an import
+const (
+	ImportStatusCreated    = "created"
+	ImportStatusUpdated    = "updated"
+	ImportStatusSkipped    = "skipped"
+	ImportStatusFailed     = "failed"
+	ImportStatusRolledBack = "rolledBack"
+)
+
+// importStatuses are the import statuses of the DAO import actions
+var importStatuses = map[ImportAction]string{
+	ImportCreated: ImportStatusCreated,
+	ImportUpdated: ImportStatusUpdated,
+	ImportSkipped: ImportStatusSkipped,
+}
+
+// ImportLineResult reports the outcome for one resource of an import.
+// Index is its position in the body, counting from 0, and Line the line
+// it starts at in JSON Lines and CSV bodies. A failed resource has the
+// message and HTTP status code of its failure in Error and Code.
+type ImportLineResult struct {
+	Index   int                `json:"index"`
+	Line    int                `json:"line,omitempty"`
+	Status  string             `json:"status"`
+	UID     string             `json:"uid,omitempty"`
+	Error   string             `json:"error,omitempty"`
+	Code    int                `json:"code,omitempty"`
+	Details []meta.StatusCause `json:"details,omitempty"`
+}
+
+// ImportResponse reports the outcome of an import
+type ImportResponse struct {
+	Results []ImportLineResult `json:"results"`
+}
+
+// Export handles GET requests streaming every resource as JSON Lines
+func (r *Router[T]) Export(c *gin.Context) {
+	exportResources(c, r.dao)
+}
+
+// Import handles POST requests storing the resources of a JSON Lines body,
+// such as one written by Export, see importResources
+func (r *Router[T]) Import(c *gin.Context) {
+	importResources(c, r.dao, r.validator)
+}
+
+// exportResources streams the resources of dao as JSON Lines, see
+// DAO.ListStream, flushing every ExportBatchSize lines. Filters using the
+// operator syntax of parseFilterOperators, search and filterExpr restrict
+// the resources exported as in List. Errors after the first line can only
+// be logged, cutting the export short.
+func exportResources[T any](c *gin.Context, dao *DAO[T]) {
+	filter, err := parseFilterOperators(dao, c.Request.URL.Query())
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	expression, err := parseFilterExpression(dao, c.Query(FilterExpressionParameter))
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	dao = dao.Search(c.Query("search"))
+	if expression != nil {
+		dao = dao.Scope(expression)
+	}
+
+	written := 0
+	err = dao.ListStream(c.Request.Context(), filter, func(resource *T) error {
+		if written == 0 {
+			c.Header("Content-Type", NDJSONContentType)
+			c.Status(http.StatusOK)
+		}
+		written++
+		data, err := json.Marshal(resource)
+		if err != nil {
+			return err
+		}
+		if _, err := c.Writer.Write(append(data, '\n')); err != nil {
+			return err
+		}
+		if written%ExportBatchSize == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	switch {
+	case err != nil && written > 0:
+		slog.Error("Export failed", "path", c.Request.URL.Path, "error", err)
+	case err != nil:
+		writeInternalError(c, err)
+	case written == 0:
+		c.Header("Content-Type", NDJSONContentType)
+		c.Status(http.StatusOK)
+	}
+}
+
+// Import modes, choosing what a failed resource does to the others
+const (
+	// ImportModeBestEffort stores every resource that imports
+	ImportModeBestEffort = "bestEffort"
+
+	// ImportModeAtomic stores nothing unless every resource imports
+	ImportModeAtomic = "atomic"
+)
+
+// importResources stores the resources of the body, matching stored
+// resources by UID as the strategy query parameter says: skip, overwrite or
+// fail, the default. The body is JSON Lines, where blank lines are ignored,
+// a JSON array with Content-Type application/json, or a CSV file uploaded
+// as the file field of a multipart form, see readImportCSV. In the default
+// bestEffort mode resources are stored in transactions of ImportBatchSize,
+// and a failed one does not stop the others; in atomic mode the body is
+// stored in one transaction, rolled back when any resource fails. It
+// responds 201 when every resource was imported, 207 when only some were
+// and 422 when none were, with a result per resource.
+func importResources[T any](c *gin.Context, dao *DAO[T], validator *StructTagValidator) {
+	strategy := ImportStrategy(c.DefaultQuery("strategy", string(ImportFail)))
+	switch strategy {
+	case ImportSkip, ImportOverwrite, ImportFail:
+	default:
+		writeBadRequest(c, "strategy must be one of skip, overwrite or fail")
+		return
+	}
+	mode := c.DefaultQuery("mode", ImportModeBestEffort)
+	if mode != ImportModeBestEffort && mode != ImportModeAtomic {
+		writeBadRequest(c, "mode must be one of bestEffort or atomic")
+		return
+	}
+
+	// read calls fn with every resource of the body and the line it
+	// starts at, 0 in a JSON array
+	var read func(fn func(line int, data []byte) error) error
+	switch c.ContentType() {
+	case binding.MIMEJSON:
+		decoder := json.NewDecoder(c.Request.Body)
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			writeBadRequest(c, "import must be a JSON array")
+			return
+		}
+		read = func(fn func(int, []byte) error) error {
+			return readImportJSON(decoder, fn)
+		}
+	case binding.MIMEMultipartPOSTForm:
+		header, err := c.FormFile("file")
+		if err != nil {
+			writeBadRequest(c, "import form must upload a CSV file as file")
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			writeBadRequest(c, err.Error())
+			return
+		}
+		defer file.Close()
+		reader := csv.NewReader(file)
+		columns, err := readImportCSVHeader[T](reader)
+		if err != nil {
+			writeBadRequest(c, err.Error())
+			return
+		}
+		read = func(fn func(int, []byte) error) error {
+			return readImportCSV(reader, columns, fn)
+		}
+	default:
+		read = func(fn func(int, []byte) error) error {
+			return readImportLines(c.Request.Body, fn)
+		}
+	}
+
+	var results []ImportLineResult
+	failed := 0
+	fail := func(index int, status meta.Status) {
+		results[index].Status = ImportStatusFailed
+		results[index].Error = status.Message
+		results[index].Code = status.Code
+		results[index].Details = status.Details
+		failed++
+	}
+
+	// pending are the decoded resources of the current batch, stored at
+	// the results of indexes
+	var pending []T
+	var indexes []int
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		var outcomes []ImportResult
+		var err error
+		if mode == ImportModeAtomic {
+			outcomes, err = dao.ImportAll(requestContext(c), pending, strategy)
+		} else {
+			outcomes, err = dao.Import(requestContext(c), pending, strategy)
+		}
+		if err != nil && !errors.Is(err, ErrImportRolledBack) {
+			return err
+		}
+		for i, outcome := range outcomes {
+			index := indexes[i]
+			if owned, ok := any(&pending[i]).(ownedResource); ok {
+				results[index].UID = owned.GetUID()
+			}
+			if outcome.Err != nil {
+				fail(index, writeErrorStatus(outcome.Err))
+				continue
+			}
+			results[index].Status = importStatuses[outcome.Action]
+		}
+		pending, indexes = nil, nil
+		return nil
+	}
+
+	// Best effort imports are stored batch by batch while the body is read
+	var writeErr error
+	err := read(func(line int, data []byte) error {
+		index := len(results)
+		results = append(results, ImportLineResult{Index: index, Line: line})
+
+		var obj T
+		if err := json.Unmarshal(data, &obj); err != nil {
+			fail(index, meta.Status{Code: http.StatusBadRequest, Reason: meta.StatusReasonInvalid, Message: err.Error()})
+			return nil
+		}
+		if fields := validator.Validate(&obj); fields != nil {
+			fail(index, unprocessableStatus(fields))
+			return nil
+		}
+		if err := validateResource(&obj); err != nil {
+			fail(index, meta.Status{Code: http.StatusBadRequest, Reason: meta.StatusReasonInvalid, Message: err.Error()})
+			return nil
+		}
+
+		pending = append(pending, obj)
+		indexes = append(indexes, index)
+		if mode == ImportModeBestEffort && len(pending) == ImportBatchSize {
+			writeErr = flush()
+			return writeErr
+		}
+		return nil
+	})
+	if writeErr != nil {
+		writeWriteError(c, writeErr)
+		return
+	}
+	if err != nil {
+		// The rest of the body cannot be read, so it is not imported
+		result := ImportLineResult{Index: len(results)}
+		if c.ContentType() != binding.MIMEJSON {
+			result.Line = 1
+			if len(results) > 0 {
+				result.Line = results[len(results)-1].Line + 1
+			}
+		}
+		results = append(results, result)
+		fail(result.Index, meta.Status{Code: http.StatusBadRequest, Reason: meta.StatusReasonBadRequest, Message: err.Error()})
+	}
+
+	// Atomic imports store nothing once a resource failed to decode
+	if mode == ImportModeAtomic && failed > 0 {
+		pending, indexes = nil, nil
+	}
+	if err := flush(); err != nil {
+		writeWriteError(c, err)
+		return
+	}
+	if mode == ImportModeAtomic && failed > 0 {
+		for i := range results {
+			if results[i].Status != ImportStatusFailed {
+				results[i].Status = ImportStatusRolledBack
+			}
+		}
+		writeResource(c, http.StatusUnprocessableEntity, ImportResponse{Results: results})
+		return
+	}
+
+	response := ImportResponse{Results: results}
+	if response.Results == nil {
+		response.Results = []ImportLineResult{}
+	}
+	switch {
+	case failed == 0:
+		writeResource(c, http.StatusCreated, response)
+	case failed < len(results):
+		writeResource(c, http.StatusMultiStatus, response)
+	default:
+		writeResource(c, http.StatusUnprocessableEntity, response)
+	}
+}
+
+// readImportLines calls fn with every non-blank line of a JSON Lines body
+func readImportLines(body io.Reader, fn func(line int, data []byte) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), DefaultMaxBodySize)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if err := fn(line, data); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// readImportJSON calls fn with every item of a JSON array whose opening
+// bracket decoder has read. Items have no line.
+func readImportJSON(decoder *json.Decoder, fn func(line int, data []byte) error) error {
+	for decoder.More() {
+		var item json.RawMessage
+		if err := decoder.Decode(&item); err != nil {
+			return err
+		}
+		if err := fn(0, item); err != nil {
+			return err
+		}
+	}
+	_, err := decoder.Token()
+	return err
+}
@@ -0,0 +1,284 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// exportUsers returns the lines exported by router
+func exportUsers(t *testing.T, router *gin.Engine) []string {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/export", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, NDJSONContentType, w.Header().Get("Content-Type"))
+
+	var lines []string
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
+
+// withPassword sets the password of exported users, which exports leave out
+func withPassword(t *testing.T, lines []string, password string) []string {
+	with := make([]string, len(lines))
+	for i, line := range lines {
+		var object map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &object))
+		assert.NotContains(t, object, "password")
+		object["password"] = password
+		data, err := json.Marshal(object)
+		require.NoError(t, err)
+		with[i] = string(data)
+	}
+	return with
+}
+
+// importUsers posts lines to the import route of router
+func importUsers(t *testing.T, router *gin.Engine, strategy string, lines []string) (int, ImportResponse) {
+	body := strings.Join(lines, "\n") + "\n"
+	req := httptest.NewRequest("POST", "/api/v1/users/import?strategy="+strategy, strings.NewReader(body))
+	req.Header.Set("Content-Type", NDJSONContentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response ImportResponse
+	if w.Code != http.StatusBadRequest {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), w.Body.String())
+	}
+	return w.Code, response
+}
+
+func TestRouter_ExportImport(t *testing.T) {
+	source, sourceDB := setupTestRouter(t)
+	defer cleanupTestDB(t, sourceDB)
+	target, targetDB := setupTestRouter(t)
+	defer cleanupTestDB(t, targetDB)
+
+	// Hash once, hooks keep hashed passwords as they are
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	users := make([]apiv1.User, 1000)
+	for i := range users {
+		users[i] = apiv1.User{Username: fmt.Sprintf("user%04d", i), Email: fmt.Sprintf("user%04d@example.com", i), Password: string(hash)}
+	}
+	require.NoError(t, sourceDB.CreateInBatches(users, 200).Error)
+
+	// A user of the target only moves the IDs of the imported ones
+	require.NoError(t, targetDB.Create(&apiv1.User{Username: "local", Email: "local@example.com", Password: string(hash)}).Error)
+
+	lines := exportUsers(t, source)
+	require.Len(t, lines, 1000)
+
+	// Exports take the filters of lists
+	w := httptest.NewRecorder()
+	source.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/export?id[gt]=990", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 10, strings.Count(w.Body.String(), "\n"))
+	w = httptest.NewRecorder()
+	source.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/export?id[gt]=one", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// The password hashes are not exported, a migration supplies them
+	lines = withPassword(t, lines, string(hash))
+	code, response := importUsers(t, target, "fail", lines)
+	require.Equal(t, http.StatusCreated, code)
+	require.Len(t, response.Results, 1000)
+	assert.Equal(t, ImportLineResult{Index: 999, Line: 1000, Status: ImportStatusCreated, UID: users[999].UID}, response.Results[999])
+
+	var imported apiv1.User
+	require.NoError(t, targetDB.Where("uid = ?", users[0].UID).First(&imported).Error)
+	assert.Equal(t, "user0000", imported.Username)
+	assert.Equal(t, string(hash), imported.PasswordHash)
+	assert.NotEqual(t, users[0].ID, imported.ID)
+
+	var count int64
+	require.NoError(t, targetDB.Model(&apiv1.User{}).Count(&count).Error)
+	assert.Equal(t, int64(1001), count)
+
+	// Overwriting updates the rows with the same UID
+	var changed apiv1.User
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &changed))
+	changed.FullName = "Changed"
+	data, err := json.Marshal(changed)
+	require.NoError(t, err)
+	code, response = importUsers(t, target, "overwrite", []string{string(data), lines[1]})
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, ImportStatusUpdated, response.Results[0].Status)
+
+	var overwritten apiv1.User
+	require.NoError(t, targetDB.First(&overwritten, imported.ID).Error)
+	assert.Equal(t, "Changed", overwritten.FullName)
+	assert.Equal(t, imported.ResourceVersion+1, overwritten.ResourceVersion)
+	require.NoError(t, targetDB.Model(&apiv1.User{}).Count(&count).Error)
+	assert.Equal(t, int64(1001), count)
+
+	// Conflicts are skipped or fail, without stopping the other lines
+	code, response = importUsers(t, target, "skip", lines[:1])
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, ImportStatusSkipped, response.Results[0].Status)
+
+	code, response = importUsers(t, target, "fail", []string{lines[0], "", "{not json", `{"username":"new","email":"new@example.com","password":"password123"}`})
+	require.Equal(t, http.StatusMultiStatus, code)
+	require.Len(t, response.Results, 3)
+	assert.Equal(t, ImportStatusFailed, response.Results[0].Status)
+	assert.Equal(t, http.StatusConflict, response.Results[0].Code)
+	assert.NotEmpty(t, response.Results[0].Error)
+	assert.Equal(t, 1, response.Results[1].Index)
+	assert.Equal(t, 3, response.Results[1].Line)
+	assert.Equal(t, http.StatusBadRequest, response.Results[1].Code)
+	assert.Equal(t, ImportStatusCreated, response.Results[2].Status)
+
+	code, _ = importUsers(t, target, "replace", lines[:1])
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestRegisterResource_ExportImport(t *testing.T) {
+	router, db := setupTestRegister(t)
+	defer cleanupTestDB(t, db)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/export", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	body := `{"username":"alice","email":"alice@example.com","password":"password123"}` + "\n" + `{"username":"bob"}`
+	req := httptest.NewRequest("POST", "/api/v1/users/import", bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+	var response ImportResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, ImportStatusCreated, response.Results[0].Status)
+	assert.Equal(t, ImportStatusFailed, response.Results[1].Status)
+	assert.Equal(t, http.StatusUnprocessableEntity, response.Results[1].Code)
+
+	assert.Len(t, exportUsers(t, router), 1)
+}
+
+func TestRouter_ImportJSONAndCSV(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	post := func(query, contentType string, body io.Reader) (int, ImportResponse) {
+		req := httptest.NewRequest("POST", "/api/v1/users/import"+query, body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response ImportResponse
+		if w.Code != http.StatusBadRequest {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), w.Body.String())
+		}
+		return w.Code, response
+	}
+	count := func() int64 {
+		var count int64
+		require.NoError(t, db.Model(&apiv1.User{}).Count(&count).Error)
+		return count
+	}
+
+	// JSON arrays report the index of each item
+	body := `[{"username":"alice","email":"alice@example.com","password":"password123"}, {"username":"bob"}]`
+	code, response := post("", "application/json", strings.NewReader(body))
+	require.Equal(t, http.StatusMultiStatus, code)
+	require.Len(t, response.Results, 2)
+	assert.Equal(t, ImportLineResult{Index: 0, Status: ImportStatusCreated, UID: response.Results[0].UID}, response.Results[0])
+	assert.Equal(t, 1, response.Results[1].Index)
+	assert.Equal(t, ImportStatusFailed, response.Results[1].Status)
+	assert.Equal(t, http.StatusUnprocessableEntity, response.Results[1].Code)
+	assert.NotEmpty(t, response.Results[1].Details)
+	assert.Equal(t, int64(1), count())
+
+	// The statuses are reported as documented
+	req := httptest.NewRequest("POST", "/api/v1/users/import", strings.NewReader(`[{"username":"bob","email":"bob@example.com","password":"password123"}, {"username":"bob"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+	var raw struct {
+		Results []map[string]any `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &raw))
+	require.Len(t, raw.Results, 2)
+	delete(raw.Results[0], "uid")
+	assert.Equal(t, map[string]any{"index": float64(0), "status": "created"}, raw.Results[0])
+	assert.Equal(t, float64(1), raw.Results[1]["index"])
+	assert.Equal(t, "failed", raw.Results[1]["status"])
+	assert.IsType(t, "", raw.Results[1]["error"])
+
+	// Nothing is imported when every resource fails
+	code, response = post("", "application/json", strings.NewReader(`[{"username":"carol"}, {}]`))
+	require.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, ImportStatusFailed, response.Results[0].Status)
+	assert.Equal(t, ImportStatusFailed, response.Results[1].Status)
+	assert.Equal(t, int64(2), count())
+
+	// Atomic imports store nothing when one resource fails
+	body = `[{"username":"carol","email":"carol@example.com","password":"password123"},
+		{"username":"alice","email":"other@example.com","password":"password123"}]`
+	code, response = post("?mode=atomic", "application/json", strings.NewReader(body))
+	require.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, ImportStatusRolledBack, response.Results[0].Status)
+	assert.Equal(t, ImportStatusFailed, response.Results[1].Status)
+	assert.Equal(t, http.StatusConflict, response.Results[1].Code)
+	assert.Equal(t, int64(2), count())
+
+	code, response = post("?mode=atomic", "application/json", strings.NewReader(body[:strings.Index(body, ",\n")]+"]"))
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, ImportStatusCreated, response.Results[0].Status)
+	assert.Equal(t, int64(3), count())
+
+	// CSV files are uploaded as a form, with a header naming the fields
+	upload := func(csv string) (*bytes.Buffer, string) {
+		var buf bytes.Buffer
+		form := multipart.NewWriter(&buf)
+		part, err := form.CreateFormFile("file", "users.csv")
+		require.NoError(t, err)
+		_, err = part.Write([]byte(csv))
+		require.NoError(t, err)
+		require.NoError(t, form.Close())
+		return &buf, form.FormDataContentType()
+	}
+	file, contentType := upload("username,email,password,fullName,role,metadata.labels\n" +
+		"dave,dave@example.com,password123,\"Dave, Jr.\",admin,\"{\"\"team\"\":\"\"ops\"\"}\"\n" +
+		"erin,not-an-email,password123,,,\n")
+	code, response = post("", contentType, file)
+	require.Equal(t, http.StatusMultiStatus, code)
+	require.Len(t, response.Results, 2)
+	assert.Equal(t, ImportStatusCreated, response.Results[0].Status)
+	assert.Equal(t, 2, response.Results[0].Line)
+	assert.Equal(t, 1, response.Results[1].Index)
+	assert.Equal(t, 3, response.Results[1].Line)
+	assert.Equal(t, http.StatusUnprocessableEntity, response.Results[1].Code)
+
+	var dave apiv1.User
+	require.NoError(t, db.Where("username = ?", "dave").First(&dave).Error)
+	assert.Equal(t, "Dave, Jr.", dave.FullName)
+	assert.Equal(t, apiv1.RoleAdmin, dave.Role)
+	assert.Equal(t, "ops", dave.Labels["team"])
+
+	file, contentType = upload("username,nickname\nfrank,frankie\n")
+	code, _ = post("", contentType, file)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = post("", "application/json", strings.NewReader(`{"username":"alice"}`))
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = post("?mode=all", "application/json", strings.NewReader(`[]`))
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// nestedIDParam is the path parameter of the child in nested routes. It is
+// renamed to id before the handlers run, see RegisterNested.
+const nestedIDParam = "childId"
+
+// RegisterNested registers list, create, get, update and delete routes for
+// the resource as a child of another one. path names the parent with its
+// only path parameter, e.g. /api/v1/users/:id/apikeys, and foreignKey is
+// the field (JSON or column name) holding the ID of the parent, e.g.
+// userId. Lists only hold the children of the parent, created and updated
+// resources get its ID as their foreign key, and other resources are
+// answered with 404 as if they did not exist. Gin requires the parameter
+// to be named like the parameters at the same position of other routes,
+// :id under a path served by Register. Versioned routers prefix path with
+// /api/{version}. Nested routes are left out of the OpenAPI document.
+func (r *Router[T]) RegisterNested(path, foreignKey string) {
+	if r.apiVersion != "" {
+		path = "/api/" + r.apiVersion + path
+	}
+	parentParam, err := nestedParentParam(path)
+	if err != nil {
+		panic(err)
+	}
+	if _, ok := r.dao.field(foreignKey); !ok {
+		panic(fmt.Sprintf("RegisterNested: unknown foreign key %q", foreignKey))
+	}
+
+	group := r.engine.Group(path)
+	r.options.use(group, resourceKind(new(T)))
+	{
+		group.GET("", r.nested(parentParam, foreignKey, (*Router[T]).List))
+		group.POST("", r.nested(parentParam, foreignKey, (*Router[T]).Create))
+		group.GET("/:"+nestedIDParam, r.nested(parentParam, foreignKey, (*Router[T]).Get))
+		group.PUT("/:"+nestedIDParam, r.nested(parentParam, foreignKey, (*Router[T]).Update))
+		group.DELETE("/:"+nestedIDParam, r.nested(parentParam, foreignKey, (*Router[T]).Delete))
+	}
+	registerOptionsRoutes(r.engine, group)
+}
+
+// nestedParentParam returns the name of the only path parameter of path
+func nestedParentParam(path string) (string, error) {
+	var params []string
+	for _, segment := range strings.Split(path, "/") {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			params = append(params, name)
+		}
+	}
+	if len(params) != 1 {
+		return "", fmt.Errorf("RegisterNested: path %q must have exactly one parameter naming the parent", path)
+	}
+	return params[0], nil
+}
+
+// nested returns a handler serving a request with handler on a copy of the
+// router restricted to the children of the parent named by the path. The
+// path parameters are rewritten so that id names the child, as the
+// handlers and the audit log expect.
+func (r *Router[T]) nested(parentParam, foreignKey string, handler func(*Router[T], *gin.Context)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parentID, err := strconv.ParseUint(c.Param(parentParam), 10, 64)
+		if err != nil {
+			writeBadRequest(c, "invalid "+parentParam)
+			return
+		}
+		dao, err := r.dao.BelongsTo(foreignKey, uint(parentID))
+		if err != nil {
+			writeInternalError(c, err)
+			return
+		}
+
+		params := make(gin.Params, 0, len(c.Params))
+		for _, param := range c.Params {
+			switch param.Key {
+			case parentParam:
+			case nestedIDParam:
+				params = append(params, gin.Param{Key: "id", Value: param.Value})
+			default:
+				params = append(params, param)
+			}
+		}
+		c.Params = params
+
+		scoped := *r
+		scoped.dao = dao
+		handler(&scoped, c)
+	}
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRouter_RegisterNested(t *testing.T) {
+	r, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+	require.NoError(t, db.AutoMigrate(&apiv1.APIKey{}))
+	NewRouter[apiv1.APIKey](r, db).RegisterNested("/api/v1/users/:id/apikeys", "userId")
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	for _, name := range []string{"alice", "bob"} {
+		require.NoError(t, db.Create(&apiv1.User{Username: name, Email: name + "@example.com", Password: string(hash)}).Error)
+	}
+
+	serve := func(method, path string, body any) *httptest.ResponseRecorder {
+		var data []byte
+		if body != nil {
+			data, err = json.Marshal(body)
+			require.NoError(t, err)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// The owner comes from the path, overriding the body
+	w := serve("POST", "/api/v1/users/1/apikeys", gin.H{"label": "ci"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created apiv1.CreatedAPIKey
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, uint(1), created.UserID)
+	assert.NotEmpty(t, created.Key)
+
+	w = serve("POST", "/api/v1/users/2/apikeys", gin.H{"label": "deploy", "userId": 1})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var other apiv1.CreatedAPIKey
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &other))
+	assert.Equal(t, uint(2), other.UserID)
+
+	list := func(userID int) []apiv1.APIKey {
+		w := serve("GET", fmt.Sprintf("/api/v1/users/%d/apikeys", userID), nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var items []apiv1.APIKey
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &items))
+		return items
+	}
+	keys := list(1)
+	require.Len(t, keys, 1)
+	assert.Equal(t, created.ID, keys[0].ID)
+	keys = list(2)
+	require.Len(t, keys, 1)
+	assert.Equal(t, other.ID, keys[0].ID)
+
+	// The key of user 1 does not exist under user 2
+	path := fmt.Sprintf("/api/v1/users/2/apikeys/%d", created.ID)
+	assert.Equal(t, http.StatusNotFound, serve("GET", path, nil).Code)
+	update := gin.H{"label": "stolen", "prefix": created.Prefix}
+	assert.Equal(t, http.StatusNotFound, serve("PUT", path, update).Code)
+	assert.Equal(t, http.StatusNotFound, serve("DELETE", path, nil).Code)
+
+	path = fmt.Sprintf("/api/v1/users/1/apikeys/%d", created.ID)
+	w = serve("GET", path, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	update = gin.H{"label": "renamed", "prefix": created.Prefix}
+	w = serve("PUT", path, update)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var updated apiv1.APIKey
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, "renamed", updated.Label)
+	assert.Equal(t, uint(1), updated.UserID)
+
+	assert.Equal(t, http.StatusNoContent, serve("DELETE", path, nil).Code)
+	assert.Empty(t, list(1))
+	assert.Len(t, list(2), 1)
+
+	assert.Equal(t, http.StatusBadRequest, serve("GET", "/api/v1/users/abc/apikeys", nil).Code)
+}
@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// MergePatchContentType is the media type of a JSON Merge Patch (RFC 7396)
+const MergePatchContentType = "application/merge-patch+json"
+
+// Patch handles PATCH requests applying a JSON Merge Patch to a resource,
+// named by ID or name
+func (r *Router[T]) Patch(c *gin.Context) {
+	id, ok := parseIDParam(c, r.dao)
+	if !ok {
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType != MergePatchContentType {
+		writeError(c, http.StatusUnsupportedMediaType, meta.StatusReasonUnsupportedMediaType, "content type must be "+MergePatchContentType)
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	var patch map[string]interface{}
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		writeBadRequest(c, "patch must be a JSON object")
+		return
+	}
+
+	existing, err := r.dao.Get(c.Request.Context(), id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			writeNotFound(c)
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	resource, err := applyMergePatch(existing, patch)
+	if err != nil {
+		writeInvalid(c, err)
+		return
+	}
+
+	if err := validateResource(resource); err != nil {
+		writeInvalid(c, err)
+		return
+	}
+	if err := validateUpdate(requestContext(c), resource, existing); err != nil {
+		writeInvalid(c, err)
+		return
+	}
+
+	// Only check the version when If-Match or the patch names one
+	expectedVersion, ok := r.checkIfMatch(c, id)
+	if !ok {
+		return
+	}
+	preconditioned := expectedVersion != 0
+	if metadata, ok := patch["metadata"].(map[string]interface{}); ok {
+		if _, ok := metadata["resourceVersion"]; ok && !preconditioned {
+			expectedVersion = resourceVersion(resource)
+		}
+	}
+
+	if err := r.dao.Save(requestContext(c), id, resource, expectedVersion); err != nil {
+		r.writeUpdateError(c, id, err, preconditioned)
+		return
+	}
+
+	writeResource(c, http.StatusOK, resource)
+}
+
+// applyMergePatch returns a copy of resource with patch applied. Fields that
+// do not exist on the resource are rejected, and fields left out of its JSON,
+// such as password hashes, keep their value.
+func applyMergePatch[T any](resource *T, patch map[string]interface{}) (*T, error) {
+	original, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(original, &document); err != nil {
+		return nil, err
+	}
+
+	merged, err := json.Marshal(mergePatch(document, patch))
+	if err != nil {
+		return nil, err
+	}
+
+	var patched T
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patched); err != nil {
+		return nil, err
+	}
+	keepHiddenFields(reflect.ValueOf(&patched).Elem(), reflect.ValueOf(resource).Elem())
+	return &patched, nil
+}
+
+// mergePatch applies patch to target following RFC 7396
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
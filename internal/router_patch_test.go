@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/meta"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter_Patch(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		code        int
+		reason      meta.StatusReason
+		check       func(t *testing.T, found apiv1.User)
+	}{
+		{
+			name:        "set field",
+			contentType: MergePatchContentType,
+			patch:       `{"fullName":"Updated Name"}`,
+			code:        http.StatusOK,
+			check: func(t *testing.T, found apiv1.User) {
+				assert.Equal(t, "Updated Name", found.FullName)
+				assert.Equal(t, "test@example.com", found.Email)
+			},
+		},
+		{
+			name:        "null zeroes field",
+			contentType: MergePatchContentType,
+			patch:       `{"fullName":null}`,
+			code:        http.StatusOK,
+			check: func(t *testing.T, found apiv1.User) {
+				assert.Empty(t, found.FullName)
+				assert.Equal(t, "testuser", found.Username)
+			},
+		},
+		{
+			name:        "unknown field",
+			contentType: MergePatchContentType,
+			patch:       `{"nickname":"tester"}`,
+			code:        http.StatusBadRequest,
+			reason:      meta.StatusReasonInvalid,
+		},
+		{
+			name:        "invalid value",
+			contentType: MergePatchContentType,
+			patch:       `{"email":"invalid-email"}`,
+			code:        http.StatusBadRequest,
+			reason:      meta.StatusReasonInvalid,
+		},
+		{
+			name:        "current resource version",
+			contentType: MergePatchContentType,
+			patch:       `{"metadata":{"resourceVersion":1},"email":"updated@example.com"}`,
+			code:        http.StatusOK,
+			check: func(t *testing.T, found apiv1.User) {
+				assert.Equal(t, "updated@example.com", found.Email)
+				assert.Equal(t, 2, found.ResourceVersion)
+			},
+		},
+		{
+			name:        "stale resource version",
+			contentType: MergePatchContentType,
+			patch:       `{"metadata":{"resourceVersion":7},"email":"updated@example.com"}`,
+			code:        http.StatusConflict,
+			reason:      meta.StatusReasonConflict,
+		},
+		{
+			name:        "wrong content type",
+			contentType: "application/json",
+			patch:       `{"fullName":"Updated Name"}`,
+			code:        http.StatusUnsupportedMediaType,
+			reason:      meta.StatusReasonUnsupportedMediaType,
+		},
+		{
+			name:        "not an object",
+			contentType: MergePatchContentType,
+			patch:       `["fullName"]`,
+			code:        http.StatusBadRequest,
+			reason:      meta.StatusReasonBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, db := setupTestRouter(t)
+			defer cleanupTestDB(t, db)
+
+			// Create test user
+			user := &apiv1.User{
+				Username: "testuser",
+				Email:    "test@example.com",
+				Password: "password123",
+				FullName: "Test User",
+			}
+			err := db.Create(user).Error
+			assert.NoError(t, err)
+
+			req := httptest.NewRequest("PATCH", fmt.Sprintf("/api/v1/users/%d", user.ID), bytes.NewBufferString(tt.patch))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.reason != "" {
+				assert.Equal(t, tt.reason, decodeStatus(t, w).Reason)
+			}
+			if tt.check == nil {
+				return
+			}
+
+			var response apiv1.User
+			err = json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			tt.check(t, response)
+
+			// Verify the stored resource matches the response
+			var found apiv1.User
+			err = db.First(&found, user.ID).Error
+			assert.NoError(t, err)
+			tt.check(t, found)
+			assert.True(t, found.CheckPassword("password123"))
+		})
+	}
+}
+
+func TestRouter_PatchNotFound(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	req := httptest.NewRequest("PATCH", "/api/v1/users/9999", bytes.NewBufferString(`{"fullName":"x"}`))
+	req.Header.Set("Content-Type", MergePatchContentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRouter_PatchIfMatch(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	// Create test user
+	user := &apiv1.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	err := db.Create(user).Error
+	assert.NoError(t, err)
+
+	// A stale If-Match header fails the precondition
+	req := httptest.NewRequest("PATCH", fmt.Sprintf("/api/v1/users/%d", user.ID), bytes.NewBufferString(`{"fullName":"x"}`))
+	req.Header.Set("Content-Type", MergePatchContentType)
+	req.Header.Set("If-Match", ETag(0))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	// A current If-Match header succeeds
+	req = httptest.NewRequest("PATCH", fmt.Sprintf("/api/v1/users/%d", user.ID), bytes.NewBufferString(`{"fullName":"x"}`))
+	req.Header.Set("Content-Type", MergePatchContentType)
+	req.Header.Set("If-Match", ETag(1))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stats handles GET requests aggregating resources, see statsResources
+func (r *Router[T]) Stats(c *gin.Context) {
+	statsResources(c, r.dao)
+}
+
+// statsResources responds with the aggregations of the agg query
+// parameters, written as FUNCTION:field[:alias] such as COUNT:id:count,
+// for every group of the groupBy parameter, see DAO.Aggregate. Filters
+// using the operator syntax of parseFilterOperators, search and filterExpr
+// restrict the resources aggregated as in List.
+func statsResources[T any](c *gin.Context, dao *DAO[T]) {
+	var aggregations []Aggregation
+	for _, raw := range c.QueryArray("agg") {
+		aggregation, err := parseAggregation(raw)
+		if err != nil {
+			writeBadRequest(c, err.Error())
+			return
+		}
+		aggregations = append(aggregations, aggregation)
+	}
+	if len(aggregations) == 0 {
+		aggregations = []Aggregation{{Function: AggregateCount, Alias: "count"}}
+	}
+
+	operators, err := parseFilterOperators(dao, c.Request.URL.Query())
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	expression, err := parseFilterExpression(dao, c.Query(FilterExpressionParameter))
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	dao = dao.Search(c.Query("search"))
+	if operators != nil {
+		dao = dao.Scope(operators.Scope)
+	}
+	if expression != nil {
+		dao = dao.Scope(expression)
+	}
+
+	results, err := dao.Aggregate(c.Request.Context(), c.Query("groupBy"), aggregations)
+	if err != nil {
+		if errors.Is(err, ErrUnknownField) || errors.Is(err, ErrInvalidAggregation) {
+			writeBadRequest(c, err.Error())
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+	writeResource(c, http.StatusOK, results)
+}
+
+// parseAggregation parses an agg query parameter such as COUNT:id:count
+func parseAggregation(raw string) (Aggregation, error) {
+	parts := strings.Split(raw, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Aggregation{}, fmt.Errorf("invalid aggregation %q, expected FUNCTION:field[:alias]", raw)
+	}
+	aggregation := Aggregation{Function: AggregateFunction(parts[0]), Field: parts[1]}
+	if len(parts) == 3 {
+		aggregation.Alias = parts[2]
+	}
+	return aggregation, nil
+}
@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestDAO_Aggregate(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[TestModel](db)
+	ctx := context.Background()
+
+	for _, name := range []string{"a", "b", "b", "c", "c", "c"} {
+		require.NoError(t, dao.Create(ctx, &TestModel{Name: name}))
+	}
+	// Soft deleted resources are not counted
+	require.NoError(t, dao.Delete(ctx, 6, 0))
+
+	results, err := dao.Aggregate(ctx, "name", []Aggregation{
+		{Function: AggregateCount, Field: "*", Alias: "count"},
+		{Function: "max", Field: "id"},
+		{Function: AggregateSum, Field: "id", Alias: "total"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	for i, expected := range []struct {
+		name              string
+		count, max, total int64
+	}{
+		{"a", 1, 1, 1},
+		{"b", 2, 3, 5},
+		{"c", 2, 5, 9},
+	} {
+		assert.Equal(t, expected.name, results[i]["name"])
+		assert.EqualValues(t, expected.count, results[i]["count"])
+		assert.EqualValues(t, expected.max, results[i]["max_id"])
+		assert.EqualValues(t, expected.total, results[i]["total"])
+	}
+
+	// Without a group everything is aggregated at once
+	results, err = dao.Aggregate(ctx, "", []Aggregation{{Function: AggregateCount}, {Function: AggregateAvg, Field: "id"}})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.EqualValues(t, 5, results[0]["count"])
+	assert.EqualValues(t, 3, results[0]["avg_id"])
+
+	invalid := []struct {
+		groupBy     string
+		aggregation Aggregation
+		err         error
+	}{
+		{"nickname", Aggregation{Function: AggregateCount}, ErrUnknownField},
+		{"name", Aggregation{Function: AggregateSum, Field: "nickname"}, ErrUnknownField},
+		{"name", Aggregation{Function: "MEDIAN", Field: "id"}, ErrInvalidAggregation},
+		{"name", Aggregation{Function: "COUNT(*); DROP TABLE test_models; --"}, ErrInvalidAggregation},
+		{"name", Aggregation{Function: AggregateSum}, ErrInvalidAggregation},
+		{"name", Aggregation{Function: AggregateCount, Alias: `x" FROM users --`}, ErrInvalidAggregation},
+		{"name", Aggregation{Function: AggregateCount, Alias: "name"}, ErrInvalidAggregation},
+	}
+	for _, tt := range invalid {
+		_, err := dao.Aggregate(ctx, tt.groupBy, []Aggregation{tt.aggregation})
+		assert.ErrorIs(t, err, tt.err, tt.aggregation.Function)
+	}
+}
+
+func TestRouter_Stats(t *testing.T) {
+	r, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	for i, role := range []string{apiv1.RoleAdmin, apiv1.RoleUser, apiv1.RoleUser, apiv1.RoleUser} {
+		name := fmt.Sprintf("user%d", i+1)
+		require.NoError(t, db.Create(&apiv1.User{Username: name, Email: name + "@example.com", Password: string(hash), Role: role}).Error)
+	}
+
+	stats := func(query string) []map[string]interface{} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/stats"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var results []map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+		return results
+	}
+
+	assert.Equal(t, []map[string]interface{}{
+		{"role": "admin", "count": float64(1), "min_id": float64(1)},
+		{"role": "user", "count": float64(3), "min_id": float64(2)},
+	}, stats("?groupBy=role&agg=COUNT:id:count&agg=min:id"))
+
+	// Every user is Active after creation
+	assert.Equal(t, []map[string]interface{}{
+		{"phase": "Active", "count": float64(4)},
+	}, stats("?groupBy=phase"))
+
+	// Filters restrict the users aggregated
+	assert.Equal(t, []map[string]interface{}{
+		{"role": "user", "count": float64(2)},
+	}, stats("?groupBy=role&id[gte]=3"))
+
+	for _, query := range []string{"?groupBy=nickname", "?agg=MEDIAN:id", "?agg=COUNT", "?agg=COUNT:id:bad-alias"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/stats"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
@@ -2,20 +2,32 @@ package internal
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"my-embedded-api/apiv1"
 	"my-embedded-api/meta"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 )
 
+// decodeStatus decodes a meta.Status error body
+func decodeStatus(t *testing.T, w *httptest.ResponseRecorder) meta.Status {
+	var status meta.Status
+	err := json.Unmarshal(w.Body.Bytes(), &status)
+	assert.NoError(t, err)
+	return status
+}
+
 func setupTestRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
@@ -94,6 +106,7 @@ func TestRouter_CRUD(t *testing.T) {
 	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/users/%d", found.ID), nil)
 	r.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, meta.StatusReasonNotFound, decodeStatus(t, w).Reason)
 }
 
 func TestRouter_Create(t *testing.T) {
@@ -147,6 +160,60 @@ func TestRouter_Get(t *testing.T) {
 	assert.Equal(t, user.Email, response.Email)
 }
 
+func TestRouter_PasswordWriteOnly(t *testing.T) {
+	router, db := setupTestRouter(t)
+
+	body := `{"username":"testuser","email":"test@example.com","password":"password123"}`
+	req := httptest.NewRequest("POST", "/api/v1/users", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), `"password"`)
+
+	var created apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	// Neither gets nor lists return the password
+	for _, path := range []string{fmt.Sprintf("/api/v1/users/%d", created.ID), "/api/v1/users"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), `"password"`, path)
+	}
+
+	var object map[string]interface{}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", fmt.Sprintf("/api/v1/users/%d", created.ID), nil))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &object))
+	assert.NotContains(t, object, "password")
+
+	// Putting back what was read keeps the stored password
+	var read apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &read))
+	read.FullName = "Test User"
+	encoded, err := json.Marshal(read)
+	require.NoError(t, err)
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/api/v1/users/%d", created.ID), bytes.NewBuffer(encoded))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), `"password"`)
+
+	// So does a patch
+	req = httptest.NewRequest("PATCH", fmt.Sprintf("/api/v1/users/%d", created.ID), bytes.NewBufferString(`{"fullName":"Patched"}`))
+	req.Header.Set("Content-Type", MergePatchContentType)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var stored apiv1.User
+	require.NoError(t, db.First(&stored, created.ID).Error)
+	assert.Equal(t, "Patched", stored.FullName)
+	assert.NoError(t, stored.ComparePassword("password123"))
+}
+
 func TestRouter_Update(t *testing.T) {
 	router, db := setupTestRouter(t)
 
@@ -176,6 +243,20 @@ func TestRouter_Update(t *testing.T) {
 	assert.Equal(t, "updated@example.com", found.Email)
 }
 
+func TestRouter_UpdateNotFound(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	// Missing resources are reported before the body is decoded
+	req := httptest.NewRequest("PUT", "/api/v1/users/42", bytes.NewBufferString("{"))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, meta.StatusReasonNotFound, decodeStatus(t, w).Reason)
+}
+
 func TestRouter_Delete(t *testing.T) {
 	router, db := setupTestRouter(t)
 
@@ -243,7 +324,97 @@ func TestRouter_Validation(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	status := decodeStatus(t, w)
+	assert.Equal(t, meta.StatusReasonInvalid, status.Reason)
+	assert.Equal(t, []meta.StatusCause{
+		{Field: "password", Message: "password is required"},
+		{Field: "username", Message: "username is required"},
+	}, status.Details)
+
+	// Malformed bodies are still bad requests
+	req = httptest.NewRequest("POST", "/api/v1/users", bytes.NewBufferString("{"))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRouter_UpdateValidation(t *testing.T) {
+	router, db := setupTestRouter(t)
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	require.NoError(t, db.Create(user).Error)
+
+	body := `{"username":"testuser","email":"not-an-email","password":"password123"}`
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/api/v1/users/%d", user.ID), bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, []meta.StatusCause{
+		{Field: "email", Message: "email must be a valid email address"},
+	}, decodeStatus(t, w).Details)
+}
+
+func TestRouter_RegisterCustomValidator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	require.NoError(t, db.AutoMigrate(&customValidatedModel{}))
+
+	router := NewRouter[customValidatedModel](engine, db)
+	require.NoError(t, router.RegisterCustomValidator("lowercase", func(fl validator.FieldLevel) bool {
+		return fl.Field().String() == strings.ToLower(fl.Field().String())
+	}))
+	router.Register("/models")
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/models", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := create(`{"name":"Mixed"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, []meta.StatusCause{
+		{Field: "name", Message: "name failed on the 'lowercase' rule"},
+	}, decodeStatus(t, w).Details)
+
+	assert.Equal(t, http.StatusCreated, create(`{"name":"lower"}`).Code)
+}
+
+// customValidatedModel uses a validation tag registered by the test
+type customValidatedModel struct {
+	gorm.Model
+	Name string `json:"name" binding:"required,lowercase"`
+}
+
+func TestRouter_WeakPassword(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	previous := apiv1.CurrentPasswordPolicy()
+	apiv1.SetPasswordPolicy(apiv1.PasswordPolicy{MinLength: 10, RequireDigit: true, RequireSymbol: true})
+	defer apiv1.SetPasswordPolicy(previous)
+
+	body := `{"username":"testuser","email":"test@example.com","password":"weak"}`
+	req := httptest.NewRequest("POST", "/api/v1/users", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
 	assert.Equal(t, http.StatusBadRequest, w.Code)
+	status := decodeStatus(t, w)
+	assert.Equal(t, meta.StatusReasonInvalid, status.Reason)
+	assert.Equal(t, []meta.StatusCause{
+		{Field: "password", Message: "must be at least 10 characters long"},
+		{Field: "password", Message: "must contain a digit"},
+		{Field: "password", Message: "must contain a symbol"},
+	}, status.Details)
 }
 
 func TestRouter_Pagination(t *testing.T) {
@@ -308,3 +479,1374 @@ func TestRouter_Concurrent(t *testing.T) {
 		<-done
 	}
 }
+
+func TestRouter_KeysetPagination(t *testing.T) {
+	router, db := setupTestRouter(t)
+
+	// Create test users
+	users := []apiv1.User{
+		{Username: "user1", Email: "user1@example.com", Password: "pass1"},
+		{Username: "user2", Email: "user2@example.com", Password: "pass2"},
+		{Username: "user3", Email: "user3@example.com", Password: "pass3"},
+	}
+
+	for _, user := range users {
+		err := db.Create(&user).Error
+		assert.NoError(t, err)
+	}
+
+	// First page starting from the beginning
+	req := httptest.NewRequest("GET", "/api/v1/users?after=0&size=2", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response ListResponse[apiv1.User]
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response.Items, 2)
+	assert.NotEmpty(t, response.NextCursor)
+
+	// Last page has no next cursor
+	req = httptest.NewRequest("GET", "/api/v1/users?size=2&after="+response.NextCursor, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response = ListResponse[apiv1.User]{}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response.Items, 1)
+	assert.Equal(t, "user3", response.Items[0].Username)
+	assert.Empty(t, response.NextCursor)
+
+	// Invalid cursor
+	req = httptest.NewRequest("GET", "/api/v1/users?after=abc", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRouter_ListCursor(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	for i := 0; i < 25; i++ {
+		user := &apiv1.User{Username: fmt.Sprintf("user%02d", i), Email: fmt.Sprintf("user%02d@example.com", i), Password: "password123"}
+		require.NoError(t, db.Create(user).Error)
+	}
+
+	list := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/users"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var usernames []string
+	query := "?limit=10&sort=username"
+	for pages := 1; ; pages++ {
+		require.LessOrEqual(t, pages, 3)
+		w := list(query)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response ListResponse[apiv1.User]
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 10, response.Size)
+		for _, user := range response.Items {
+			usernames = append(usernames, user.Username)
+		}
+		if response.NextCursor == "" {
+			break
+		}
+		query = "?limit=10&sort=username&cursor=" + response.NextCursor
+
+		// A row inserted ahead of the cursor is listed exactly once
+		if pages == 1 {
+			user := &apiv1.User{Username: "user15a", Email: "user15a@example.com", Password: "password123"}
+			require.NoError(t, db.Create(user).Error)
+		}
+	}
+
+	require.Len(t, usernames, 26)
+	assert.IsIncreasing(t, usernames)
+	assert.Equal(t, "user15a", usernames[16])
+
+	// Cursor pagination cannot be mixed with pages, and cursors are opaque
+	for _, query := range []string{"?limit=10&page=2", "?cursor=abc&size=5", "?limit=0", "?cursor=%21%21", "?cursor=eyJpZCI6MX0&sort=username"} {
+		w := list(query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestRouter_Sort(t *testing.T) {
+	router, db := setupTestRouter(t)
+
+	// Create test users
+	users := []apiv1.User{
+		{Username: "bob", Email: "bob@example.com", Password: "pass1"},
+		{Username: "carol", Email: "carol@example.com", Password: "pass2"},
+		{Username: "alice", Email: "alice@example.com", Password: "pass3"},
+	}
+
+	for _, user := range users {
+		err := db.Create(&user).Error
+		assert.NoError(t, err)
+	}
+
+	tests := []struct {
+		name     string
+		sort     string
+		code     int
+		expected []string
+	}{
+		{"ascending", "username", http.StatusOK, []string{"alice", "bob", "carol"}},
+		{"descending", "-username", http.StatusOK, []string{"carol", "bob", "alice"}},
+		{"multiple fields", "isActive,-createdAt", http.StatusOK, []string{"alice", "carol", "bob"}},
+		{"invalid field", "unknownField", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/users?sort="+tt.sort, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.expected == nil {
+				return
+			}
+
+			var response []apiv1.User
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			for i, username := range tt.expected {
+				assert.Equal(t, username, response[i].Username)
+			}
+		})
+	}
+}
+
+func TestRouter_UpdateConflict(t *testing.T) {
+	router, db := setupTestRouter(t)
+
+	// Create test user
+	user := &apiv1.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	err := db.Create(user).Error
+	assert.NoError(t, err)
+
+	// First update with the current version succeeds
+	user.Email = "first@example.com"
+	body, _ := json.Marshal(user)
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/api/v1/users/%d", user.ID), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Second update from the same stale copy is rejected
+	user.Email = "second@example.com"
+	body, _ = json.Marshal(user)
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/api/v1/users/%d", user.ID), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "0", w.Header().Get("Retry-After"))
+
+	// The conflict response carries the current object
+	var conflict struct {
+		meta.Status
+		Current apiv1.User `json:"current"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &conflict)
+	assert.NoError(t, err)
+	assert.Equal(t, meta.StatusReasonConflict, conflict.Reason)
+	assert.Equal(t, "first@example.com", conflict.Current.Email)
+	assert.Equal(t, 2, conflict.Current.ResourceVersion)
+
+	// Verify the first update was kept
+	var found apiv1.User
+	err = db.First(&found, user.ID).Error
+	assert.NoError(t, err)
+	assert.Equal(t, "first@example.com", found.Email)
+}
+
+func TestRouter_UpdateIfMatch(t *testing.T) {
+	router, db := setupTestRouter(t)
+
+	// Create test user
+	user := &apiv1.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	err := db.Create(user).Error
+	assert.NoError(t, err)
+
+	// A stale If-Match header fails the precondition
+	user.Email = "updated@example.com"
+	body, _ := json.Marshal(user)
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/api/v1/users/%d", user.ID), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"v7"`)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.Equal(t, meta.StatusReasonPreconditionFailed, decodeStatus(t, w).Reason)
+
+	// A matching If-Match header succeeds
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/api/v1/users/%d", user.ID), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", ETag(1))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRouter_RequireResourceVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	db := setupTestDB(t)
+	NewRouter[apiv1.User](router, db, WithRequireResourceVersion()).Register("/api/v1/users")
+
+	// Create test user
+	user := &apiv1.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	err := db.Create(user).Error
+	assert.NoError(t, err)
+
+	// Update without any version is rejected
+	user.ResourceVersion = 0
+	body, _ := json.Marshal(user)
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/api/v1/users/%d", user.ID), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+}
+
+func TestRouter_Nonexistent(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	user := &apiv1.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	body, _ := json.Marshal(user)
+
+	// Update nonexistent user
+	req := httptest.NewRequest("PUT", "/api/v1/users/99999", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Delete nonexistent user
+	req = httptest.NewRequest("DELETE", "/api/v1/users/99999", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRouter_GetETag(t *testing.T) {
+	router, db := setupTestRouter(t)
+
+	// Create test user
+	user := &apiv1.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	err := db.Create(user).Error
+	assert.NoError(t, err)
+
+	// GET returns the ETag
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/users/%d", user.ID), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"v1"`, w.Header().Get("ETag"))
+
+	// A matching If-None-Match returns 304 without a body
+	req = httptest.NewRequest("GET", fmt.Sprintf("/api/v1/users/%d", user.ID), nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+
+	// A weak or wildcard If-None-Match also matches
+	for _, header := range []string{`W/"v1"`, "*"} {
+		req = httptest.NewRequest("GET", fmt.Sprintf("/api/v1/users/%d", user.ID), nil)
+		req.Header.Set("If-None-Match", header)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+	}
+
+	// A stale If-None-Match returns the resource
+	req = httptest.NewRequest("GET", fmt.Sprintf("/api/v1/users/%d", user.ID), nil)
+	req.Header.Set("If-None-Match", `"v0"`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestETag(t *testing.T) {
+	assert.Equal(t, `"v0"`, ETag(0))
+	assert.Equal(t, `"v12"`, ETag(12))
+
+	assert.True(t, etagMatches(`"v1"`, ETag(1)))
+	assert.True(t, etagMatches(`W/"v1"`, ETag(1)))
+	assert.True(t, etagMatches(`"v0", "v1"`, ETag(1)))
+	assert.True(t, etagMatches("*", ETag(1)))
+	assert.False(t, etagMatches(`"v2"`, ETag(1)))
+}
+
+func TestRouter_Head(t *testing.T) {
+	router, db := setupTestRouter(t)
+
+	// Create test user
+	user := &apiv1.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	err := db.Create(user).Error
+	assert.NoError(t, err)
+
+	// Existing resource
+	req := httptest.NewRequest("HEAD", fmt.Sprintf("/api/v1/users/%d", user.ID), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ETag(1), w.Header().Get("ETag"))
+	assert.Empty(t, w.Body.Bytes())
+
+	// Missing resource
+	req = httptest.NewRequest("HEAD", "/api/v1/users/9999", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+}
+
+func TestRouter_CreateDuplicate(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	create := func(username string) *httptest.ResponseRecorder {
+		body := []byte(fmt.Sprintf(`{"username":%q,"email":"test@example.com","password":"password123"}`, username))
+		req := httptest.NewRequest("POST", "/api/v1/users", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusCreated, create("first").Code)
+
+	// A second user with the same email conflicts
+	w := create("second")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	status := decodeStatus(t, w)
+	assert.Equal(t, meta.StatusReasonConflict, status.Reason)
+	assert.Equal(t, http.StatusConflict, status.Code)
+	assert.Contains(t, status.Message, "email")
+	assert.Equal(t, "email", status.Details[0].Field)
+}
+
+func TestRouter_PaginationHeaders(t *testing.T) {
+	router, db := setupTestRouter(t)
+
+	// Create test users
+	for i := 0; i < 5; i++ {
+		user := apiv1.User{
+			Username: fmt.Sprintf("user%d", i),
+			Email:    fmt.Sprintf("user%d@example.com", i),
+			Password: "password123",
+		}
+		err := db.Create(&user).Error
+		assert.NoError(t, err)
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		present []string
+		absent  []string
+	}{
+		{"first page", "page=1&size=2", []string{`rel="first"`, `rel="last"`, `rel="next"`}, []string{`rel="prev"`}},
+		{"middle page", "page=2&size=2", []string{`rel="first"`, `rel="last"`, `rel="next"`, `rel="prev"`}, nil},
+		{"last page", "page=3&size=2", []string{`rel="first"`, `rel="last"`, `rel="prev"`}, []string{`rel="next"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/users?"+tt.query, nil)
+			req.Host = "api.example.com"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "5", w.Header().Get("X-Total-Count"))
+
+			link := w.Header().Get("Link")
+			assert.Contains(t, link, "<http://api.example.com/api/v1/users?page=3&size=2>; rel=\"last\"")
+			for _, rel := range tt.present {
+				assert.Contains(t, link, rel)
+			}
+			for _, rel := range tt.absent {
+				assert.NotContains(t, link, rel)
+			}
+		})
+	}
+}
+
+func TestRouter_GetByUID(t *testing.T) {
+	router, db := setupTestRouter(t)
+
+	// Create test user
+	user := &apiv1.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	err := db.Create(user).Error
+	assert.NoError(t, err)
+	assert.NotEmpty(t, user.UID)
+
+	// Existing UID
+	req := httptest.NewRequest("GET", "/api/v1/users/uid/"+user.UID, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var found apiv1.User
+	err = json.Unmarshal(w.Body.Bytes(), &found)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+
+	// Missing UID
+	req = httptest.NewRequest("GET", "/api/v1/users/uid/00000000-0000-0000-0000-000000000000", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Malformed UID
+	req = httptest.NewRequest("GET", "/api/v1/users/uid/not-a-uuid", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// The uid column is indexed
+	assert.True(t, db.Migrator().HasIndex(&apiv1.User{}, "UID"))
+}
+
+func TestRouter_Status(t *testing.T) {
+	router, db := setupTestRouter(t)
+
+	// Create test user
+	user := &apiv1.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	err := db.Create(user).Error
+	assert.NoError(t, err)
+
+	// Get status
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/users/%d/status", user.ID), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var status meta.ResourceStatus
+	err = json.Unmarshal(w.Body.Bytes(), &status)
+	assert.NoError(t, err)
+	assert.Equal(t, "Active", status.Phase)
+
+	// Update status
+	body := []byte(`{"phase":"Suspended","message":"Too many login attempts","reason":"Locked","conditions":[{"type":"Ready","status":"False"}]}`)
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/api/v1/users/%d/status", user.ID), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Verify only the status and version changed
+	var found apiv1.User
+	err = db.First(&found, user.ID).Error
+	assert.NoError(t, err)
+	assert.Equal(t, "Suspended", found.Status.Phase)
+	assert.Equal(t, "Locked", found.Status.Reason)
+	condition, exists := found.GetCondition("Ready")
+	assert.True(t, exists)
+	assert.Equal(t, meta.ConditionFalse, condition.Status)
+	assert.False(t, found.Status.LastTransitionTime.IsZero())
+	assert.Equal(t, 2, found.ResourceVersion)
+	assert.Equal(t, user.Email, found.Email)
+	assert.Equal(t, user.Password, found.Password)
+
+	// Missing resource
+	req = httptest.NewRequest("PUT", "/api/v1/users/9999/status", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRouter_BulkUpdate(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	for i := 1; i <= 3; i++ {
+		user := &apiv1.User{Username: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i), Password: "password123"}
+		require.NoError(t, db.Create(user).Error)
+	}
+
+	bulkUpdate := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/api/v1/users/bulk", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Existing users are updated even though one ID is missing
+	w := bulkUpdate(`{"ids":[1,3,99],"patch":{"isActive":false}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response BulkUpdateResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, int64(2), response.Updated)
+
+	var inactive int64
+	require.NoError(t, db.Model(&apiv1.User{}).Where("is_active = ?", false).Count(&inactive).Error)
+	assert.Equal(t, int64(2), inactive)
+
+	tests := []struct {
+		name   string
+		body   string
+		code   int
+		reason meta.StatusReason
+	}{
+		{"none exist", `{"ids":[98,99],"patch":{"isActive":false}}`, http.StatusNotFound, meta.StatusReasonNotFound},
+		{"empty ids", `{"ids":[],"patch":{"isActive":false}}`, http.StatusBadRequest, meta.StatusReasonBadRequest},
+		{"empty patch", `{"ids":[1],"patch":{}}`, http.StatusBadRequest, meta.StatusReasonBadRequest},
+		{"unknown field", `{"ids":[1],"patch":{"nickname":"x"}}`, http.StatusBadRequest, meta.StatusReasonBadRequest},
+		{"protected field", `{"ids":[1],"patch":{"password":"x"}}`, http.StatusBadRequest, meta.StatusReasonInvalid},
+		{"wrong type", `{"ids":[1],"patch":{"isActive":"no"}}`, http.StatusBadRequest, meta.StatusReasonInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := bulkUpdate(tt.body)
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+			assert.Equal(t, tt.reason, decodeStatus(t, w).Reason)
+		})
+	}
+}
+
+func TestRouter_DeleteMany(t *testing.T) {
+	router, db := setupTestRouter(t)
+
+	// Create test users
+	users := []apiv1.User{
+		{Username: "user1", Email: "user1@example.com", Password: "pass1"},
+		{Username: "user2", Email: "user2@example.com", Password: "pass2"},
+		{Username: "user3", Email: "user3@example.com", Password: "pass3"},
+	}
+
+	for _, user := range users {
+		err := db.Create(&user).Error
+		assert.NoError(t, err)
+	}
+
+	// Delete existing and missing users
+	req := httptest.NewRequest("DELETE", "/api/v1/users", bytes.NewBufferString(`{"ids":[1,2,99]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response DeleteManyResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), response.Deleted)
+	assert.Equal(t, []uint{99}, response.NotFound)
+
+	// None of the users exist
+	req = httptest.NewRequest("DELETE", "/api/v1/users", bytes.NewBufferString(`{"ids":[1,2]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Empty list
+	req = httptest.NewRequest("DELETE", "/api/v1/users", bytes.NewBufferString(`{"ids":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Resources with finalizers are reported as not deleted yet
+	finalized := apiv1.User{Username: "user4", Email: "user4@example.com", Password: "pass4"}
+	finalized.Finalizers = []string{"cleanup"}
+	require.NoError(t, db.Create(&finalized).Error)
+
+	req = httptest.NewRequest("DELETE", "/api/v1/users", bytes.NewBufferString(fmt.Sprintf(`{"ids":[%d]}`, finalized.ID)))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	response = DeleteManyResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Zero(t, response.Deleted)
+	assert.Equal(t, []uint{finalized.ID}, response.Finalizing)
+
+	var stored apiv1.User
+	require.NoError(t, db.First(&stored, finalized.ID).Error)
+	assert.NotNil(t, stored.DeletionTimestamp)
+}
+
+func TestRouter_DeleteWithFinalizers(t *testing.T) {
+	router, db := setupTestRouter(t)
+
+	// Create test user with a finalizer
+	user := &apiv1.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	user.Finalizers = []string{"cleanup"}
+	err := db.Create(user).Error
+	assert.NoError(t, err)
+
+	// Delete is accepted but not completed
+	req := httptest.NewRequest("DELETE", fmt.Sprintf("/api/v1/users/%d", user.ID), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var response apiv1.User
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.NotNil(t, response.DeletionTimestamp)
+}
+
+func TestRouter_FinalizerLifecycle(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	user := &apiv1.User{Username: "alice", Email: "alice@example.com", Password: "password123"}
+	user.AddFinalizer("example.com/revoke-accounts")
+	user.AddFinalizer("example.com/archive")
+	require.NoError(t, db.Create(user).Error)
+
+	path := fmt.Sprintf("/api/v1/users/%d", user.ID)
+	serve := func(method, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	get := func() apiv1.User {
+		w := serve("GET", "", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var got apiv1.User
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		return got
+	}
+
+	// Deleting only marks the user as terminating
+	w := serve("DELETE", "", "")
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	terminating := get()
+	assert.True(t, terminating.IsTerminating())
+	assert.True(t, terminating.HasFinalizer("example.com/archive"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var users []apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	require.Len(t, users, 1)
+	assert.NotNil(t, users[0].DeletionTimestamp)
+
+	// Updates cannot cancel the deletion
+	terminating.RemoveFinalizer("example.com/revoke-accounts")
+	terminating.DeletionTimestamp = nil
+	terminating.Password = "password123"
+	body, err := json.Marshal(terminating)
+	require.NoError(t, err)
+	w = serve("PUT", "application/json", string(body))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	updated := get()
+	assert.Equal(t, []string{"example.com/archive"}, updated.Finalizers)
+	assert.True(t, updated.IsTerminating())
+
+	// Removing the last finalizer completes the deletion
+	w = serve("PATCH", MergePatchContentType, `{"metadata": {"finalizers": []}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, serve("GET", "", "").Code)
+}
+
+func TestRouter_GetByName(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	user := func(username, name string) string {
+		return fmt.Sprintf(`{"metadata": {"name": %q}, "username": %q, "email": "%s@example.com", "password": "password123"}`, name, username, username)
+	}
+
+	w := serve("POST", "/api/v1/users", user("alice", "alice"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "alice", created.Name)
+
+	// Unnamed resources do not collide, named ones do
+	require.Equal(t, http.StatusCreated, serve("POST", "/api/v1/users", user("bob", "")).Code)
+	require.Equal(t, http.StatusCreated, serve("POST", "/api/v1/users", user("carol", "")).Code)
+	w = serve("POST", "/api/v1/users", user("dave", "alice"))
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+
+	w = serve("POST", "/api/v1/users", user("erin", "Bad_Name!"))
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "lowercase alphanumerics and dashes")
+
+	// The name stands in for the ID
+	w = serve("GET", "/api/v1/users/alice", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var got apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, created.ID, got.ID)
+	assert.Equal(t, "alice", got.Username)
+
+	body := `{"metadata": {"name": "alice"}, "username": "alice", "email": "alice@example.org", "password": "password123", "fullName": "Alice"}`
+	w = serve("PUT", "/api/v1/users/alice", body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var updated apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, created.ID, updated.ID)
+	assert.Equal(t, "alice@example.org", updated.Email)
+
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/api/v1/users/nobody", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("GET", "/api/v1/users/Not_A_Name", "").Code)
+
+	// So it does for the status and the other sub-resources
+	assert.Equal(t, http.StatusOK, serve("HEAD", "/api/v1/users/alice", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("HEAD", "/api/v1/users/nobody", "").Code)
+	w = serve("PUT", "/api/v1/users/alice/status", `{"phase": "Suspended", "message": "on leave"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = serve("GET", "/api/v1/users/alice/status", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Suspended")
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/api/v1/users/nobody/status", "").Code)
+
+	assert.Equal(t, http.StatusNoContent, serve("DELETE", "/api/v1/users/alice", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/api/v1/users/alice", "").Code)
+	w = serve("POST", "/api/v1/users/alice/restore", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusOK, serve("GET", "/api/v1/users/alice", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("POST", "/api/v1/users/alice/restore", "").Code)
+	assert.Equal(t, http.StatusNoContent, serve("DELETE", "/api/v1/users/alice/purge", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("DELETE", "/api/v1/users/alice/purge", "").Code)
+}
+
+func TestRouter_FieldSelection(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	user := &apiv1.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	err := db.Create(user).Error
+	assert.NoError(t, err)
+
+	// Get with selected fields
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/users/%d?fields=username,createdAt", user.ID), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var object map[string]interface{}
+	err = json.Unmarshal(w.Body.Bytes(), &object)
+	assert.NoError(t, err)
+	assert.Equal(t, "testuser", object["username"])
+	assert.NotContains(t, object, "email")
+	assert.NotContains(t, object, "kind")
+
+	metadata, ok := object["metadata"].(map[string]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, float64(user.ID), metadata["id"])
+	assert.Contains(t, metadata, "createdAt")
+	assert.NotContains(t, metadata, "uid")
+
+	// List with selected fields
+	req = httptest.NewRequest("GET", "/api/v1/users?fields=email", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var items []map[string]interface{}
+	err = json.Unmarshal(w.Body.Bytes(), &items)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "test@example.com", items[0]["email"])
+	assert.NotContains(t, items[0], "username")
+	assert.Contains(t, items[0]["metadata"], "id")
+
+	// Keyset pagination with selected fields
+	req = httptest.NewRequest("GET", "/api/v1/users?after=0&fields=username", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var page ListResponse[map[string]interface{}]
+	err = json.Unmarshal(w.Body.Bytes(), &page)
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, "testuser", page.Items[0]["username"])
+	assert.NotContains(t, page.Items[0], "email")
+
+	// Unknown fields are rejected
+	for _, path := range []string{
+		fmt.Sprintf("/api/v1/users/%d?fields=username,bogus", user.ID),
+		"/api/v1/users?fields=bogus",
+	} {
+		req = httptest.NewRequest("GET", path, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		status := decodeStatus(t, w)
+		assert.Contains(t, status.Message, `unknown field "bogus"`)
+		assert.Contains(t, status.Message, "username")
+	}
+}
+
+func TestRouter_WithDefaultScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	NewRouter[apiv1.User](r, db, WithDefaultScope(ActiveScope)).Register("/users")
+
+	active := &apiv1.User{Username: "active", Email: "active@example.com", Password: "password123"}
+	inactive := &apiv1.User{Username: "inactive", Email: "inactive@example.com", Password: "password123"}
+	require.NoError(t, db.Create(active).Error)
+	require.NoError(t, db.Create(inactive).Error)
+	require.NoError(t, db.Model(inactive).Update("is_active", false).Error)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/users", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var users []apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	require.Len(t, users, 1)
+	assert.Equal(t, "active", users[0].Username)
+	assert.Equal(t, "1", w.Header().Get("X-Total-Count"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", fmt.Sprintf("/users/%d", inactive.ID), nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", fmt.Sprintf("/users/%d", active.ID), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRouter_Count(t *testing.T) {
+	r, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	for _, name := range []string{"alice", "bob"} {
+		require.NoError(t, db.Create(&apiv1.User{Username: name, Email: name + "@example.com", Password: "password123"}).Error)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/count?username=bob", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"count":1}`, w.Body.String())
+	assert.Equal(t, "1", w.Header().Get("X-Total-Count"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/count?username[like]=%25o%25&sort=-username", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"count":1}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("HEAD", "/api/v1/users?search=example.com", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-Total-Count"))
+	assert.Empty(t, w.Body.String())
+}
+
+func TestRouter_RestorePurge(t *testing.T) {
+	r, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	user := &apiv1.User{Username: "alice", Email: "alice@example.com", Password: "password123"}
+	require.NoError(t, db.Create(user).Error)
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, fmt.Sprintf("/api/v1/users/%d%s", user.ID, path), nil))
+		return w
+	}
+
+	// Only deleted resources can be restored
+	assert.Equal(t, http.StatusNotFound, serve("POST", "/restore").Code)
+	require.Equal(t, http.StatusNoContent, serve("DELETE", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("GET", "").Code)
+
+	w := serve("POST", "/restore")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var restored apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &restored))
+	assert.Equal(t, "alice", restored.Username)
+	assert.Equal(t, http.StatusOK, serve("GET", "").Code)
+
+	assert.Equal(t, http.StatusNoContent, serve("DELETE", "/purge").Code)
+	assert.Equal(t, http.StatusNotFound, serve("DELETE", "/purge").Code)
+	assert.Equal(t, http.StatusNotFound, serve("POST", "/restore").Code)
+}
+
+func TestSelectColumns(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	dao := NewDAO[apiv1.User](db)
+
+	fields, err := parseFields[apiv1.User]("username,createdAt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"created_at", "id", "resource_version", "username"}, selectColumns(dao, fields))
+
+	// Fields stored in several columns load every column
+	fields, err = parseFields[apiv1.User]("username,metadata")
+	require.NoError(t, err)
+	assert.Nil(t, selectColumns(dao, fields))
+	assert.Nil(t, selectColumns(dao, nil))
+}
+
+func TestRouter_ListInclude(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	require.NoError(t, db.AutoMigrate(&testOwner{}, &testItem{}))
+	NewRouter[testOwner](r, db).Register("/owners")
+	require.NoError(t, db.Create(&testOwner{Name: "owner", Items: []testItem{{Name: "a"}}}).Error)
+
+	list := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/owners"+query, nil))
+		return w
+	}
+
+	w := list("")
+	require.Equal(t, http.StatusOK, w.Code)
+	var owners []testOwner
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &owners))
+	require.Len(t, owners, 1)
+	assert.Empty(t, owners[0].Items)
+
+	w = list("?include=items")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &owners))
+	require.Len(t, owners, 1)
+	assert.Len(t, owners[0].Items, 1)
+
+	w = list("?include=items&after=0")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"items":[`)
+
+	w = list("?include=permissions")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, decodeStatus(t, w).Message, `unknown association "permissions"`)
+}
+
+func TestRouter_ListETag(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	user := &apiv1.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	err := db.Create(user).Error
+	assert.NoError(t, err)
+
+	list := func(query, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/users"+query, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := list("", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `W/"`))
+
+	tests := []struct {
+		name        string
+		query       string
+		ifNoneMatch string
+		want        int
+	}{
+		{"matching", "", etag, http.StatusNotModified},
+		{"strong form of weak tag", "", strings.TrimPrefix(etag, "W/"), http.StatusNotModified},
+		{"in list", "", `"other", ` + etag, http.StatusNotModified},
+		{"wildcard", "", "*", http.StatusNotModified},
+		{"non-matching", "", `W/"0000"`, http.StatusOK},
+		{"different query", "?page=2", etag, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := list(tt.query, tt.ifNoneMatch)
+			assert.Equal(t, tt.want, w.Code)
+			if tt.want == http.StatusNotModified {
+				assert.Empty(t, w.Body.Bytes())
+			}
+		})
+	}
+
+	// Creating another resource changes the tag
+	other := &apiv1.User{
+		Username: "otheruser",
+		Email:    "other@example.com",
+		Password: "password123",
+	}
+	err = db.Create(other).Error
+	assert.NoError(t, err)
+
+	w = list("", etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	etag = w.Header().Get("ETag")
+
+	// So does updating one of the page
+	require.NoError(t, NewDAO[apiv1.User](db).Update(context.Background(), user.ID, &apiv1.User{FullName: "Test"}, 0))
+	w = list("", etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	// Cursor pages are tagged too
+	w = list("?limit=1", "")
+	assert.Equal(t, http.StatusNotModified, list("?limit=1", w.Header().Get("ETag")).Code)
+}
+
+func TestListETag(t *testing.T) {
+	user := func(id uint, version int) apiv1.User {
+		var u apiv1.User
+		u.ID, u.ResourceVersion = id, version
+		return u
+	}
+	page := []apiv1.User{user(1, 1), user(2, 1)}
+
+	assert.Equal(t, ListETag(page, 2), ListETag([]apiv1.User{user(1, 1), user(2, 1)}, 2))
+	assert.NotEqual(t, ListETag(page, 2), ListETag(page, 3))
+	assert.NotEqual(t, ListETag(page, 2), ListETag([]apiv1.User{user(1, 1), user(2, 2)}, 2))
+	assert.NotEqual(t, ListETag(page, 2), ListETag([]apiv1.User{user(2, 1), user(1, 1)}, 2))
+	assert.NotEqual(t, ListETag(page, 2), ListETag(page[:1], 2))
+}
+
+func TestRouter_IfMatchPreconditions(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	tests := []struct {
+		name    string
+		method  string
+		ifMatch string
+		missing bool
+		want    int
+	}{
+		{"update stale", "PUT", ETag(7), false, http.StatusPreconditionFailed},
+		{"update current", "PUT", ETag(1), false, http.StatusOK},
+		{"update wildcard", "PUT", "*", false, http.StatusOK},
+		{"update wildcard missing", "PUT", "*", true, http.StatusPreconditionFailed},
+		{"delete stale", "DELETE", ETag(7), false, http.StatusPreconditionFailed},
+		{"delete current", "DELETE", ETag(1), false, http.StatusNoContent},
+		{"delete weak current", "DELETE", `W/"v1"`, false, http.StatusNoContent},
+		{"delete wildcard", "DELETE", "*", false, http.StatusNoContent},
+		{"delete wildcard missing", "DELETE", "*", true, http.StatusPreconditionFailed},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &apiv1.User{
+				Username: fmt.Sprintf("user%d", i),
+				Email:    fmt.Sprintf("user%d@example.com", i),
+				Password: "password123",
+			}
+			err := db.Create(user).Error
+			assert.NoError(t, err)
+
+			id := user.ID
+			if tt.missing {
+				id += 1000
+			}
+
+			var body *bytes.Buffer
+			if tt.method == "PUT" {
+				user.Email = fmt.Sprintf("updated%d@example.com", i)
+				encoded, _ := json.Marshal(user)
+				body = bytes.NewBuffer(encoded)
+			} else {
+				body = &bytes.Buffer{}
+			}
+
+			req := httptest.NewRequest(tt.method, fmt.Sprintf("/api/v1/users/%d", id), body)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", tt.ifMatch)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+			if tt.want != http.StatusPreconditionFailed {
+				return
+			}
+
+			status := decodeStatus(t, w)
+			assert.Equal(t, meta.StatusReasonPreconditionFailed, status.Reason)
+			if tt.missing {
+				assert.Nil(t, status.Current)
+				return
+			}
+
+			// The current object is returned so the client can rebase
+			current, ok := status.Current.(map[string]interface{})
+			if assert.True(t, ok) {
+				assert.Equal(t, fmt.Sprintf("user%d@example.com", i), current["email"])
+			}
+
+			// The resource is left untouched
+			var stored apiv1.User
+			assert.NoError(t, db.First(&stored, user.ID).Error)
+			assert.Equal(t, fmt.Sprintf("user%d@example.com", i), stored.Email)
+		})
+	}
+}
+
+func TestRouter_UpdateIgnoresStatus(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	user := &apiv1.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	err := db.Create(user).Error
+	assert.NoError(t, err)
+
+	// PUT /:id cannot change the phase
+	user.Email = "updated@example.com"
+	user.Status.Phase = "Suspended"
+	body, _ := json.Marshal(user)
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/api/v1/users/%d", user.ID), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var updated apiv1.User
+	err = json.Unmarshal(w.Body.Bytes(), &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, "Active", updated.Status.Phase)
+
+	var found apiv1.User
+	err = db.First(&found, user.ID).Error
+	assert.NoError(t, err)
+	assert.Equal(t, "updated@example.com", found.Email)
+	assert.Equal(t, "Active", found.Status.Phase)
+
+	// PUT /:id/status can
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/api/v1/users/%d/status", user.ID), bytes.NewBufferString(`{"phase":"Suspended"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	err = db.First(&found, user.ID).Error
+	assert.NoError(t, err)
+	assert.Equal(t, "Suspended", found.Status.Phase)
+	assert.Equal(t, "updated@example.com", found.Email)
+}
+
+func TestNewVersionedRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	v1 := NewVersionedRouter[apiv1.User](engine, db, "v1",
+		WithMiddleware(DeprecationMiddleware("v1", "Wed, 31 Dec 2025 23:59:59 GMT")))
+	v1.Register("/users")
+	v2 := NewVersionedRouter[apiv1.User](engine, db, "v2")
+	v2.Register("/users")
+	assert.Equal(t, "v1", v1.APIVersion())
+	assert.Equal(t, "v2", v2.APIVersion())
+
+	// Users created through v1 are served by v2
+	body := `{"username":"testuser","email":"test@example.com","password":"password123"}`
+	req := httptest.NewRequest("POST", "/api/v1/users", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Wed, 31 Dec 2025 23:59:59 GMT", w.Header().Get("Sunset"))
+
+	var created apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest("GET", fmt.Sprintf("/api/v2/users/%d", created.ID), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+}
+
+// testKeyring has keys that may be expanded
+type testKeyring struct {
+	gorm.Model
+	Name string
+	Keys []testKey `json:"keys,omitempty" gorm:"foreignKey:KeyringID"`
+	Tags []testTag `json:"tags,omitempty" gorm:"foreignKey:KeyringID"`
+}
+
+func (testKeyring) ExpandableAssociations() []string {
+	return []string{"Keys"}
+}
+
+type testKey struct {
+	gorm.Model
+	KeyringID uint
+	Name      string
+}
+
+type testTag struct {
+	gorm.Model
+	KeyringID uint
+	Name      string
+}
+
+func TestRouter_Expand(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	require.NoError(t, db.AutoMigrate(&testKeyring{}, &testKey{}, &testTag{}))
+	NewRouter[testKeyring](r, db).Register("/keyrings")
+	RegisterResource[testKeyring](r, db, "/registered")
+	keyring := &testKeyring{Name: "ring", Keys: []testKey{{Name: "a"}, {Name: "b"}}, Tags: []testTag{{Name: "t"}}}
+	require.NoError(t, db.Create(keyring).Error)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	get := func(path string) testKeyring {
+		w := serve(path)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var got testKeyring
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		return got
+	}
+
+	for _, base := range []string{"/keyrings", "/registered"} {
+		item := fmt.Sprintf("%s/%d", base, keyring.ID)
+		assert.Empty(t, get(item).Keys, base)
+		assert.Len(t, get(item+"?expand=Keys").Keys, 2, base)
+		assert.Len(t, get(item+"?expand=keys").Keys, 2, base)
+
+		// Associations the resource does not allow are rejected
+		for _, expand := range []string{"Tags", "Owner"} {
+			w := serve(item + "?expand=" + expand)
+			assert.Equal(t, http.StatusBadRequest, w.Code, base)
+			assert.Contains(t, decodeStatus(t, w).Message, fmt.Sprintf("unknown expansion %q", expand))
+			assert.Equal(t, http.StatusBadRequest, serve(base+"?expand="+expand).Code, base)
+		}
+	}
+
+	w := serve("/keyrings")
+	require.Equal(t, http.StatusOK, w.Code)
+	var keyrings []testKeyring
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &keyrings))
+	require.Len(t, keyrings, 1)
+	assert.Empty(t, keyrings[0].Keys)
+
+	w = serve("/keyrings?expand=Keys")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &keyrings))
+	require.Len(t, keyrings, 1)
+	assert.Len(t, keyrings[0].Keys, 2)
+	assert.Empty(t, keyrings[0].Tags)
+
+	w = serve("/registered?expand=Keys")
+	require.Equal(t, http.StatusOK, w.Code)
+	var page ListResponse[testKeyring]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.Len(t, page.Items[0].Keys, 2)
+}
@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpsertRequest is the body of an upsert request
+type UpsertRequest struct {
+	// ConflictColumns are the fields of a unique key identifying the
+	// resource to update, by JSON or column name, such as ["email"]
+	ConflictColumns []string `json:"conflictColumns"`
+
+	// Resource is the resource to create or to update the stored one with
+	Resource json.RawMessage `json:"resource"`
+}
+
+// Upsert handles POST requests creating the given resource with 201, or
+// updating the resource with the same conflict columns with 200
+func (r *Router[T]) Upsert(c *gin.Context) {
+	var req UpsertRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		writeInvalid(c, err)
+		return
+	}
+	if len(req.ConflictColumns) == 0 {
+		writeBadRequest(c, "conflictColumns must name at least one field")
+		return
+	}
+
+	var resource T
+	if len(req.Resource) > 0 {
+		if err := json.Unmarshal(req.Resource, &resource); err != nil {
+			writeInvalid(c, err)
+			return
+		}
+	}
+	if !r.checkResource(c, &resource) {
+		return
+	}
+
+	created, err := r.dao.upsert(requestContext(c), &resource, req.ConflictColumns)
+	if err != nil {
+		if errors.Is(err, ErrUnknownField) || errors.Is(err, ErrNotUniqueKey) {
+			writeBadRequest(c, err.Error())
+			return
+		}
+		writeWriteError(c, err)
+		return
+	}
+
+	if created {
+		writeResource(c, http.StatusCreated, createResponse(&resource))
+		return
+	}
+	writeResource(c, http.StatusOK, resource)
+}
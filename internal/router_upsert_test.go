@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/meta"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_Upsert(t *testing.T) {
+	r, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	upsert := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/users/upsert", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := upsert(`{"conflictColumns":["email"],"resource":{"username":"alice","email":"alice@example.com","password":"password123"}}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, 1, created.ResourceVersion)
+
+	w = upsert(`{"conflictColumns":["email"],"resource":{"username":"alice","email":"alice@example.com","password":"password123","fullName":"Alice"}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var updated apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, created.ID, updated.ID)
+	assert.Equal(t, "Alice", updated.FullName)
+	assert.Equal(t, 2, updated.ResourceVersion)
+
+	tests := []struct {
+		name   string
+		body   string
+		code   int
+		reason meta.StatusReason
+	}{
+		{"malformed body", `{"conflictColumns":`, http.StatusBadRequest, meta.StatusReasonInvalid},
+		{"missing conflict columns", `{"resource":{"username":"bob"}}`, http.StatusBadRequest, meta.StatusReasonBadRequest},
+		{"unknown field", `{"conflictColumns":["nickname"],"resource":{"username":"bob","email":"bob@example.com","password":"password123"}}`,
+			http.StatusBadRequest, meta.StatusReasonBadRequest},
+		{"not unique", `{"conflictColumns":["fullName"],"resource":{"username":"bob","email":"bob@example.com","password":"password123"}}`,
+			http.StatusBadRequest, meta.StatusReasonBadRequest},
+		{"invalid resource", `{"conflictColumns":["email"],"resource":{"username":"bob"}}`,
+			http.StatusUnprocessableEntity, meta.StatusReasonInvalid},
+		{"other unique column", `{"conflictColumns":["email"],"resource":{"username":"alice","email":"bob@example.com","password":"password123"}}`,
+			http.StatusConflict, meta.StatusReasonConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := upsert(tt.body)
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+			assert.Equal(t, tt.reason, decodeStatus(t, w).Reason)
+		})
+	}
+}
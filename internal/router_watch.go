@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Watch streams changes to the resources as Server-Sent Events until the
+// client disconnects. When the resourceVersion query parameter is given
+// only changes producing a newer version are sent; deletions are always
+// sent.
+func (r *Router[T]) Watch(c *gin.Context) {
+	sinceVersion := 0
+	if raw := c.Query("resourceVersion"); raw != "" {
+		version, err := strconv.Atoi(raw)
+		if err != nil || version < 0 {
+			writeBadRequest(c, "invalid resourceVersion")
+			return
+		}
+		sinceVersion = version
+	}
+
+	// The subscription ends when the request context is cancelled
+	ctx := c.Request.Context()
+	events := r.dao.Watch(ctx)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type != EventDeleted && resourceVersion(&event.Object) <= sinceVersion {
+				continue
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(c.Writer, "data: %s\n\n", data); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
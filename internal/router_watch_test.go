@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readWatchEvent reads the next SSE data line from a watch stream
+func readWatchEvent(t *testing.T, reader *bufio.Reader) WatchEvent[apiv1.User] {
+	for {
+		line, err := reader.ReadString('\n')
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var event WatchEvent[apiv1.User]
+			assert.NoError(t, json.Unmarshal([]byte(data), &event))
+			return event
+		}
+	}
+}
+
+func TestRouter_Watch(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/v1/users?watch=true", nil)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	reader := bufio.NewReader(resp.Body)
+
+	// Create
+	body := `{"username":"testuser","email":"test@example.com","password":"password123"}`
+	created, err := http.Post(server.URL+"/api/v1/users", "application/json", strings.NewReader(body))
+	assert.NoError(t, err)
+	created.Body.Close()
+	assert.Equal(t, http.StatusCreated, created.StatusCode)
+
+	event := readWatchEvent(t, reader)
+	assert.Equal(t, EventAdded, event.Type)
+	assert.Equal(t, "testuser", event.Object.Username)
+	id := event.Object.ID
+
+	// Update
+	update := `{"username":"testuser","email":"updated@example.com","password":"password123"}`
+	putReq, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/v1/users/%d", server.URL, id), bytes.NewBufferString(update))
+	putReq.Header.Set("Content-Type", "application/json")
+	updated, err := http.DefaultClient.Do(putReq)
+	assert.NoError(t, err)
+	updated.Body.Close()
+	assert.Equal(t, http.StatusOK, updated.StatusCode)
+
+	event = readWatchEvent(t, reader)
+	assert.Equal(t, EventModified, event.Type)
+	assert.Equal(t, "updated@example.com", event.Object.Email)
+
+	// Delete
+	delReq, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/api/v1/users/%d", server.URL, id), nil)
+	deleted, err := http.DefaultClient.Do(delReq)
+	assert.NoError(t, err)
+	deleted.Body.Close()
+	assert.Equal(t, http.StatusNoContent, deleted.StatusCode)
+
+	event = readWatchEvent(t, reader)
+	assert.Equal(t, EventDeleted, event.Type)
+	assert.Equal(t, id, event.Object.ID)
+}
+
+func TestRouter_WatchResourceVersion(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/v1/users?watch=true&resourceVersion=1", nil)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+
+	// The create produces version 1 and is skipped
+	body := `{"username":"testuser","email":"test@example.com","password":"password123"}`
+	created, err := http.Post(server.URL+"/api/v1/users", "application/json", strings.NewReader(body))
+	assert.NoError(t, err)
+	var user apiv1.User
+	assert.NoError(t, json.NewDecoder(created.Body).Decode(&user))
+	created.Body.Close()
+
+	update := `{"metadata":{"resourceVersion":1},"username":"testuser","email":"updated@example.com","password":"password123"}`
+	putReq, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/v1/users/%d", server.URL, user.ID), bytes.NewBufferString(update))
+	putReq.Header.Set("Content-Type", "application/json")
+	updated, err := http.DefaultClient.Do(putReq)
+	assert.NoError(t, err)
+	updated.Body.Close()
+
+	event := readWatchEvent(t, reader)
+	assert.Equal(t, EventModified, event.Type)
+	assert.Greater(t, event.Object.ResourceVersion, 1)
+
+	// Invalid versions are rejected
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users?watch=true&resourceVersion=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
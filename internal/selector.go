@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// annotationKeyPattern matches annotation keys: a name of alphanumerics,
+// dashes, underscores and dots, optionally prefixed by a DNS subdomain and
+// a slash, such as example.com/backup
+var annotationKeyPattern = regexp.MustCompile(`^([a-z0-9]([-a-z0-9.]*[a-z0-9])?/)?[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+
+// ParseAnnotationSelector parses an annotation selector such as
+// backup=true,tier=gold into the annotations it requires. Annotations are
+// not meant for selecting sets of resources, so only equality is
+// supported.
+func ParseAnnotationSelector(selector string) (map[string]string, error) {
+	required := make(map[string]string)
+	for _, requirement := range strings.Split(selector, ",") {
+		key, value, ok := strings.Cut(requirement, "=")
+		key = strings.TrimSpace(key)
+		if !ok || strings.HasPrefix(value, "=") {
+			return nil, fmt.Errorf("invalid annotation selector requirement %q: only key=value is supported", requirement)
+		}
+		if !annotationKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid annotation key %q", key)
+		}
+		required[key] = strings.TrimSpace(value)
+	}
+	return required, nil
+}
+
+// AnnotationSelectorScope restricts queries to the resources whose JSON
+// annotations column holds every annotation of required, extracting them
+// with the JSON functions of the database
+func AnnotationSelectorScope(column string, required map[string]string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, key := range slices.Sorted(maps.Keys(required)) {
+			target := clause.Column{Name: column}
+			// Quoting the key keeps its dots and slashes out of the path
+			path := `$."` + key + `"`
+			switch db.Dialector.Name() {
+			case "postgres":
+				db = db.Where("CAST(? AS jsonb) ->> ? = ?", target, key, required[key])
+			case "mysql":
+				db = db.Where("JSON_UNQUOTE(JSON_EXTRACT(?, ?)) = ?", target, path, required[key])
+			default:
+				db = db.Where("JSON_EXTRACT(?, ?) = ?", target, path, required[key])
+			}
+		}
+		return db
+	}
+}
+
+// parseAnnotationSelector parses the annotationSelector query parameter
+// into a scope for the resources of dao, or nil when it is not given
+func parseAnnotationSelector[T any](dao *DAO[T], selector string) (func(*gorm.DB) *gorm.DB, error) {
+	if selector == "" {
+		return nil, nil
+	}
+	column, ok := dao.Column("annotations")
+	if !ok {
+		return nil, fmt.Errorf("annotationSelector is not supported by resources without annotations")
+	}
+	required, err := ParseAnnotationSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return AnnotationSelectorScope(column, required), nil
+}
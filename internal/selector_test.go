@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/meta"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestParseAnnotationSelector(t *testing.T) {
+	required, err := ParseAnnotationSelector("backup=true, example.com/tier=gold,empty=")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"backup": "true", "example.com/tier": "gold", "empty": ""}, required)
+
+	for _, selector := range []string{"backup", "backup!=true", "backup==true", "tier in (gold)", `a"b=1`, "=true"} {
+		_, err := ParseAnnotationSelector(selector)
+		assert.Error(t, err, selector)
+	}
+}
+
+func TestRouter_ListAnnotationSelector(t *testing.T) {
+	router, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	// Hash once, hooks keep hashed passwords as they are
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	for name, annotations := range map[string]map[string]string{
+		"alice": {"backup": "true", "example.com/tier": "gold"},
+		"bob":   {"backup": "true", "example.com/tier": "silver"},
+		"carol": {"backup": "false"},
+		"dave":  nil,
+	} {
+		user := &apiv1.User{
+			BaseResource: meta.BaseResource{ObjectMeta: meta.ObjectMeta{Annotations: annotations}},
+			Username:     name, Email: name + "@example.com", Password: string(hash),
+		}
+		require.NoError(t, db.Create(user).Error)
+	}
+
+	usernames := func(selector string) []string {
+		req := httptest.NewRequest("GET", "/api/v1/users?sort=username&annotationSelector="+url.QueryEscape(selector), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var users []apiv1.User
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+		var names []string
+		for _, user := range users {
+			names = append(names, user.Username)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"alice", "bob"}, usernames("backup=true"))
+	assert.Equal(t, []string{"carol"}, usernames("backup=false"))
+	assert.Equal(t, []string{"bob"}, usernames("backup=true,example.com/tier=silver"))
+	assert.Empty(t, usernames("example.com/tier=bronze"))
+
+	// Counts apply the selector too
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("HEAD", "/api/v1/users?annotationSelector=backup%3Dtrue", nil))
+	assert.Equal(t, "2", w.Header().Get("X-Total-Count"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users?annotationSelector=backup", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRegisterResource_ListAnnotationSelector(t *testing.T) {
+	router, db := setupTestRegister(t)
+	defer cleanupTestDB(t, db)
+
+	for name, annotations := range map[string]map[string]string{"alice": {"backup": "true"}, "bob": nil} {
+		user := &apiv1.User{
+			BaseResource: meta.BaseResource{ObjectMeta: meta.ObjectMeta{Annotations: annotations}},
+			Username:     name, Email: name + "@example.com", Password: "password123",
+		}
+		require.NoError(t, db.Create(user).Error)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users?annotationSelector=backup%3Dtrue", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response ListResponse[apiv1.User]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Items, 1)
+	assert.Equal(t, "alice", response.Items[0].Username)
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// writeStatus writes a meta.Status error body
+func writeStatus(c *gin.Context, status meta.Status) {
+	c.JSON(status.Code, status)
+}
+
+// writeError writes a meta.Status error body with the given code and reason
+func writeError(c *gin.Context, code int, reason meta.StatusReason, message string, details ...meta.StatusCause) {
+	writeStatus(c, meta.Status{
+		Code:    code,
+		Reason:  reason,
+		Message: message,
+		Details: details,
+	})
+}
+
+// writeBadRequest writes a 400 for a malformed request
+func writeBadRequest(c *gin.Context, message string) {
+	writeError(c, http.StatusBadRequest, meta.StatusReasonBadRequest, message)
+}
+
+// writeInvalid writes a 400 for a resource that failed binding or
+// validation, listing each failed field when the error carries them. A
+// *meta.Status returned by the validation is written as is.
+func writeInvalid(c *gin.Context, err error) {
+	var status *meta.Status
+	if errors.As(err, &status) {
+		writeStatus(c, *status)
+		return
+	}
+
+	var details []meta.StatusCause
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		for _, fieldErr := range validationErrors {
+			details = append(details, meta.StatusCause{
+				Field:   lowerFirst(fieldErr.Field()),
+				Message: "failed on the '" + fieldErr.Tag() + "' rule",
+			})
+		}
+	}
+
+	var causes causesError
+	if errors.As(err, &causes) {
+		details = append(details, causes.StatusCauses()...)
+	}
+
+	writeError(c, http.StatusBadRequest, meta.StatusReasonInvalid, err.Error(), details...)
+}
+
+// causesError is an error about given fields of a resource, such as a
+// password breaking the password policy
+type causesError interface {
+	error
+	StatusCauses() []meta.StatusCause
+}
+
+// writeNotFound writes a 404 for a missing resource
+func writeNotFound(c *gin.Context) {
+	writeError(c, http.StatusNotFound, meta.StatusReasonNotFound, "resource not found")
+}
+
+// writeInternalError writes a 500 for an unexpected failure, or a 503 when
+// the database stayed busy
+func writeInternalError(c *gin.Context, err error) {
+	if errors.Is(err, ErrBusy) {
+		writeUnavailable(c, err)
+		return
+	}
+	writeError(c, http.StatusInternalServerError, meta.StatusReasonInternalError, err.Error())
+}
+
+// writeUnavailable writes a 503 asking the client to retry shortly
+func writeUnavailable(c *gin.Context, err error) {
+	c.Header("Retry-After", "1")
+	writeError(c, http.StatusServiceUnavailable, meta.StatusReasonServiceUnavailable, err.Error())
+}
+
+// lowerFirst converts a Go field name to its usual JSON spelling
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,74 @@
+//go:build !notrace
+
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// tracerName names the tracer and the service in exported spans
+const tracerName = "play-api"
+
+// tracerShutdownTimeout bounds flushing the remaining spans on shutdown
+const tracerShutdownTimeout = 5 * time.Second
+
+// InitTracer exports spans to the OTLP/HTTP collector at endpoint, e.g.
+// "localhost:4318", and propagates W3C trace context. The returned function
+// flushes and stops the exporter.
+func InitTracer(endpoint string) (func(), error) {
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpoint(endpoint),
+		otlptracehttp.WithInsecure(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", tracerName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracerShutdownTimeout)
+		defer cancel()
+		otel.Handle(provider.Shutdown(ctx))
+	}, nil
+}
+
+// TracingMiddleware starts a span for every request, continuing the trace
+// given in the request headers
+func TracingMiddleware() gin.HandlerFunc {
+	return otelgin.Middleware(tracerName)
+}
+
+// startSpan starts a span for a DAO operation on a resource table. The
+// returned function ends it.
+func startSpan(ctx context.Context, name, table, kind string, id uint) (context.Context, func()) {
+	attributes := []attribute.KeyValue{
+		attribute.String("db.table", table),
+		attribute.String("resource.kind", kind),
+	}
+	if id != 0 {
+		attributes = append(attributes, attribute.Int64("resource.id", int64(id)))
+	}
+
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name)
+	span.SetAttributes(attributes...)
+	return ctx, func() { span.End() }
+}
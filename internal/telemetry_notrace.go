@@ -0,0 +1,26 @@
+//go:build notrace
+
+package internal
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InitTracer does nothing when built with the notrace tag
+func InitTracer(endpoint string) (func(), error) {
+	return func() {}, nil
+}
+
+// TracingMiddleware does nothing when built with the notrace tag
+func TracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+	}
+}
+
+// startSpan does nothing when built with the notrace tag
+func startSpan(ctx context.Context, name, table, kind string, id uint) (context.Context, func()) {
+	return ctx, func() {}
+}
@@ -0,0 +1,100 @@
+//go:build !notrace
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// setupTestTracer records spans in memory for the duration of the test
+func setupTestTracer(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder
+}
+
+// spanAttributes returns the attributes of a span as a map
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attributes := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	return attributes
+}
+
+func TestDAO_Tracing(t *testing.T) {
+	recorder := setupTestTracer(t)
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	dao := NewDAO[apiv1.User](db)
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	assert.NoError(t, dao.Create(context.Background(), user))
+	_, err := dao.Get(context.Background(), user.ID)
+	assert.NoError(t, err)
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	assert.Equal(t, "dao.Create", spans[0].Name())
+	assert.Equal(t, "dao.Get", spans[1].Name())
+
+	attributes := spanAttributes(spans[1])
+	assert.Equal(t, "users", attributes["db.table"].AsString())
+	assert.Equal(t, "User", attributes["resource.kind"].AsString())
+	assert.Equal(t, int64(user.ID), attributes["resource.id"].AsInt64())
+}
+
+func TestRouter_Tracing(t *testing.T) {
+	recorder := setupTestTracer(t)
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	NewRouter[apiv1.User](engine, db).Register("/api/v1/users")
+
+	user := &apiv1.User{Username: "testuser", Email: "test@example.com", Password: "password123"}
+	assert.NoError(t, db.Create(user).Error)
+
+	// The incoming trace context is continued
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/users/%d", user.ID), nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	for _, span := range spans {
+		assert.Equal(t, traceID, span.SpanContext().TraceID().String())
+	}
+
+	// The DAO span is a child of the request span
+	assert.Equal(t, "dao.Get", spans[0].Name())
+	assert.Equal(t, "/api/v1/users/:id", spans[1].Name())
+	assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+}
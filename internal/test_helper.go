@@ -22,8 +22,9 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		os.RemoveAll(tmpDir)
 	})
 
-	dbPath := filepath.Join(tmpDir, "test.db")
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	// Use the default SQLite settings of OpenDatabase
+	config := DatabaseConfig{Path: filepath.Join(tmpDir, "test.db"), JournalMode: "WAL", BusyTimeoutMillis: 5000}
+	db, err := gorm.Open(sqlite.Open(sqliteDSN(config)), &gorm.Config{})
 	if err != nil {
 		t.Fatalf("Failed to open database: %v", err)
 	}
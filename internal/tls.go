@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// tlsVersions are the accepted values of Server.TLSMinVersion
+var tlsVersions = map[string]uint16{
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+// secureCipherSuites are the TLS 1.2 cipher suites offered by NewTLSConfig,
+// all of them forward secret AEAD suites. TLS 1.3 suites are not
+// configurable and are all secure.
+var secureCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// NewTLSConfig creates a server TLS configuration accepting minVersion,
+// "TLS12" or "TLS13", and above, without the weak TLS 1.2 cipher suites
+func NewTLSConfig(minVersion string) (*tls.Config, error) {
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("invalid TLS minimum version %q, supported versions are: TLS12, TLS13", minVersion)
+	}
+	return &tls.Config{
+		MinVersion:   version,
+		CipherSuites: secureCipherSuites,
+		// Ignored since Go 1.18, which always orders the suites itself
+		PreferServerCipherSuites: true,
+	}, nil
+}
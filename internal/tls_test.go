@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"crypto/tls"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTLSServer starts an HTTPS server with the configuration of
+// NewTLSConfig and the httptest certificate
+func newTestTLSServer(t *testing.T, minVersion string) *httptest.Server {
+	config, err := NewTLSConfig(minVersion)
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = config
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// tls12Client returns a client of server that speaks at most TLS 1.2
+func tls12Client(server *httptest.Server) *http.Client {
+	config := server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	config.MaxVersion = tls.VersionTLS12
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	config, err := NewTLSConfig("TLS12")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	for _, suite := range tls.InsecureCipherSuites() {
+		assert.NotContains(t, config.CipherSuites, suite.ID, suite.Name)
+	}
+
+	config, err = NewTLSConfig("TLS13")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+
+	_, err = NewTLSConfig("TLS10")
+	assert.ErrorContains(t, err, "invalid TLS minimum version")
+}
+
+func TestNewTLSConfig_Handshake(t *testing.T) {
+	server := newTestTLSServer(t, "TLS12")
+
+	resp, err := server.Client().Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotNil(t, resp.TLS)
+	assert.True(t, resp.TLS.HandshakeComplete)
+
+	// A TLS 1.2 client must use one of the secure suites
+	resp, err = tls12Client(server).Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, uint16(tls.VersionTLS12), resp.TLS.Version)
+	assert.Contains(t, secureCipherSuites, resp.TLS.CipherSuite)
+}
+
+func TestNewTLSConfig_MinVersion(t *testing.T) {
+	server := newTestTLSServer(t, "TLS13")
+
+	_, err := tls12Client(server).Get(server.URL)
+	assert.Error(t, err, "a TLS 1.2 client must be refused")
+}
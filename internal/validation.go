@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"my-embedded-api/meta"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// StructTagValidator checks resources against the rules of their binding
+// struct tags, such as binding:"required,email". Failed fields are named
+// after their JSON names.
+type StructTagValidator struct {
+	validate *validator.Validate
+}
+
+// NewStructTagValidator creates a validator for binding struct tags
+func NewStructTagValidator() *StructTagValidator {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate.SetTagName("binding")
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		// Fields without a JSON name keep their Go name
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return &StructTagValidator{validate: validate}
+}
+
+// RegisterValidation adds a custom tag usable in binding struct tags. It
+// must be called before validating.
+func (v *StructTagValidator) RegisterValidation(tag string, fn validator.Func) error {
+	return v.validate.RegisterValidation(tag, fn)
+}
+
+// Validate returns a human-readable message per failed field of resource,
+// keyed by its JSON path, or nil if the resource is valid
+func (v *StructTagValidator) Validate(resource any) map[string]string {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(v.validate.Struct(resource), &validationErrors) {
+		return nil
+	}
+
+	fields := make(map[string]string, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		// The namespace starts with the struct name
+		_, path, _ := strings.Cut(fieldErr.Namespace(), ".")
+		fields[path] = fieldErrorMessage(path, fieldErr)
+	}
+	return fields
+}
+
+// fieldErrorMessage describes a failed validation rule
+func fieldErrorMessage(field string, fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required", "required_without":
+		return field + " is required"
+	case "email":
+		return field + " must be a valid email address"
+	case "min":
+		return fmt.Sprintf("%s must be at least %s", field, fieldErr.Param())
+	case "max":
+		return fmt.Sprintf("%s must be at most %s", field, fieldErr.Param())
+	case "len":
+		return fmt.Sprintf("%s must have length %s", field, fieldErr.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.ReplaceAll(fieldErr.Param(), " ", ", "))
+	default:
+		return fmt.Sprintf("%s failed on the '%s' rule", field, fieldErr.Tag())
+	}
+}
+
+// writeUnprocessable writes a 422 listing the message of each failed field
+func writeUnprocessable(c *gin.Context, fields map[string]string) {
+	writeStatus(c, unprocessableStatus(fields))
+}
+
+// unprocessableStatus is the meta.Status of a resource failing its struct
+// tag validation, with a detail per field sorted by field name
+func unprocessableStatus(fields map[string]string) meta.Status {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	details := make([]meta.StatusCause, len(names))
+	messages := make([]string, len(names))
+	for i, name := range names {
+		details[i] = meta.StatusCause{Field: name, Message: fields[name]}
+		messages[i] = fields[name]
+	}
+	return meta.Status{
+		Code:    http.StatusUnprocessableEntity,
+		Reason:  meta.StatusReasonInvalid,
+		Message: strings.Join(messages, "; "),
+		Details: details,
+	}
+}
@@ -0,0 +1,28 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStructTagValidator(t *testing.T) {
+	type address struct {
+		City string `json:"city" binding:"required"`
+	}
+	type resource struct {
+		Name    string  `json:"name" binding:"required,min=3"`
+		Kind    string  `json:"kind" binding:"oneof=a b"`
+		Email   string  `json:"email,omitempty" binding:"omitempty,email"`
+		Address address `json:"address"`
+	}
+
+	v := NewStructTagValidator()
+	assert.Nil(t, v.Validate(&resource{Name: "abc", Kind: "a", Address: address{City: "Berlin"}}))
+	assert.Equal(t, map[string]string{
+		"name":         "name must be at least 3",
+		"kind":         "kind must be one of: a, b",
+		"email":        "email must be a valid email address",
+		"address.city": "address.city is required",
+	}, v.Validate(&resource{Name: "ab", Kind: "c", Email: "invalid"}))
+}
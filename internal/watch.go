@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"context"
+	"sync"
+)
+
+// EventType describes the kind of change a watch event reports
+type EventType string
+
+const (
+	// EventAdded is sent when a resource is created
+	EventAdded EventType = "ADDED"
+
+	// EventModified is sent when a resource is updated
+	EventModified EventType = "MODIFIED"
+
+	// EventDeleted is sent when a resource is removed
+	EventDeleted EventType = "DELETED"
+)
+
+// watchBufferSize is the number of events buffered per subscriber. A
+// subscriber that falls further behind is disconnected so that it does not
+// silently miss events.
+const watchBufferSize = 64
+
+// WatchEvent is a change to a resource delivered to watchers
+type WatchEvent[T any] struct {
+	Type   EventType `json:"type"`
+	Object T         `json:"object"`
+}
+
+// Broadcaster fans out watch events to every subscriber
+type Broadcaster[T any] struct {
+	mu          sync.Mutex
+	subscribers map[chan WatchEvent[T]]struct{}
+}
+
+// NewBroadcaster creates a new Broadcaster without subscribers
+func NewBroadcaster[T any]() *Broadcaster[T] {
+	return &Broadcaster[T]{
+		subscribers: make(map[chan WatchEvent[T]]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving every event published from now on.
+// The channel is closed when ctx is cancelled or the subscriber is too slow
+// to keep up.
+func (b *Broadcaster[T]) Subscribe(ctx context.Context) <-chan WatchEvent[T] {
+	events := make(chan WatchEvent[T], watchBufferSize)
+
+	b.mu.Lock()
+	b.subscribers[events] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.unsubscribe(events)
+	}()
+
+	return events
+}
+
+// Publish sends an event to all subscribers without blocking
+func (b *Broadcaster[T]) Publish(event WatchEvent[T]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for events := range b.subscribers {
+		select {
+		case events <- event:
+		default:
+			delete(b.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+// unsubscribe removes and closes a subscriber channel if still registered
+func (b *Broadcaster[T]) unsubscribe(events chan WatchEvent[T]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[events]; ok {
+		delete(b.subscribers, events)
+		close(events)
+	}
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroadcaster(t *testing.T) {
+	b := NewBroadcaster[string]()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := b.Subscribe(ctx)
+	second := b.Subscribe(context.Background())
+
+	// Every subscriber receives every event
+	b.Publish(WatchEvent[string]{Type: EventAdded, Object: "a"})
+	assert.Equal(t, WatchEvent[string]{Type: EventAdded, Object: "a"}, <-first)
+	assert.Equal(t, WatchEvent[string]{Type: EventAdded, Object: "a"}, <-second)
+
+	// Cancelling the context closes the channel
+	cancel()
+	select {
+	case _, ok := <-first:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("subscriber channel was not closed")
+	}
+
+	// Slow subscribers are dropped instead of blocking Publish
+	for i := 0; i <= watchBufferSize; i++ {
+		b.Publish(WatchEvent[string]{Type: EventModified, Object: "b"})
+	}
+	received := 0
+	for range second {
+		received++
+	}
+	assert.Equal(t, watchBufferSize, received)
+}
+
+func TestDAO_Watch(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	dao := NewDAO[TestModel](db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := dao.Watch(ctx)
+
+	resource := &TestModel{Name: "watched"}
+	assert.NoError(t, dao.Create(context.Background(), resource))
+	event := <-events
+	assert.Equal(t, EventAdded, event.Type)
+	assert.Equal(t, "watched", event.Object.Name)
+
+	resource.Name = "renamed"
+	assert.NoError(t, dao.Update(context.Background(), resource.ID, resource, 0))
+	event = <-events
+	assert.Equal(t, EventModified, event.Type)
+	assert.Equal(t, "renamed", event.Object.Name)
+
+	assert.NoError(t, dao.Delete(context.Background(), resource.ID, 0))
+	event = <-events
+	assert.Equal(t, EventDeleted, event.Type)
+	assert.Equal(t, resource.ID, event.Object.ID)
+}
@@ -0,0 +1,327 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookVerb is the change a webhook notifies about
+type WebhookVerb string
+
+const (
+	WebhookVerbCreate WebhookVerb = "create"
+	WebhookVerbUpdate WebhookVerb = "update"
+	WebhookVerbDelete WebhookVerb = "delete"
+)
+
+// webhookVerbs maps the watch events to the verbs of webhooks
+var webhookVerbs = map[EventType]WebhookVerb{
+	EventAdded:    WebhookVerbCreate,
+	EventModified: WebhookVerbUpdate,
+	EventDeleted:  WebhookVerbDelete,
+}
+
+const (
+	// WebhookSignatureHeader carries the HMAC-SHA256 of the payload, keyed
+	// with the secret of the sink, as "sha256=" followed by its hex digest
+	WebhookSignatureHeader = "X-Webhook-Signature"
+
+	// WebhookIDHeader carries the ID of the payload, the same for every
+	// attempt, so that receivers can drop duplicates
+	WebhookIDHeader = "X-Webhook-ID"
+)
+
+const (
+	// webhookQueueSize is the number of deliveries waiting for the
+	// dispatcher. Events arriving while the queue is full are dropped.
+	webhookQueueSize = 1024
+
+	// webhookWorkers is the number of deliveries sent concurrently
+	webhookWorkers = 4
+
+	// webhookAttempts is how often a delivery is tried before giving up
+	webhookAttempts = 5
+
+	// webhookBackoff is the wait before the first retry, doubling for
+	// every further one
+	webhookBackoff = 500 * time.Millisecond
+
+	// webhookTimeout bounds every delivery attempt
+	webhookTimeout = 10 * time.Second
+)
+
+// WebhookSink is a receiver of webhooks. Kinds and Verbs filter the events
+// sent to it; empty filters match every event.
+type WebhookSink struct {
+	// URL receives the payloads as POST requests
+	URL string `yaml:"url" json:"url"`
+
+	// Secret signs the payloads, see WebhookSignatureHeader
+	Secret string `yaml:"secret" json:"secret"`
+
+	// Kinds are the resource kinds sent, e.g. User
+	Kinds []string `yaml:"kinds" json:"kinds"`
+
+	// Verbs are the changes sent: create, update or delete
+	Verbs []WebhookVerb `yaml:"verbs" json:"verbs"`
+}
+
+// Validate reports an invalid URL or verb
+func (s WebhookSink) Validate() error {
+	parsed, err := url.Parse(s.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q: must be an absolute http or https URL", s.URL)
+	}
+	for _, verb := range s.Verbs {
+		switch verb {
+		case WebhookVerbCreate, WebhookVerbUpdate, WebhookVerbDelete:
+		default:
+			return fmt.Errorf("invalid webhook verb %q, supported verbs are: %s, %s, %s",
+				verb, WebhookVerbCreate, WebhookVerbUpdate, WebhookVerbDelete)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the sink receives changes of verb to resources
+// of kind
+func (s WebhookSink) matches(kind string, verb WebhookVerb) bool {
+	return (len(s.Kinds) == 0 || slices.Contains(s.Kinds, kind)) &&
+		(len(s.Verbs) == 0 || slices.Contains(s.Verbs, verb))
+}
+
+// WebhookPayload is the JSON body of a webhook
+type WebhookPayload struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"`
+	Verb      WebhookVerb     `json:"verb"`
+	Timestamp time.Time       `json:"timestamp"`
+	Object    json.RawMessage `json:"object"`
+}
+
+// webhookDelivery is a payload waiting to be sent to a sink
+type webhookDelivery struct {
+	sink WebhookSink
+	id   string
+	body []byte
+}
+
+// WebhookDispatcher sends webhooks for resource changes in the background,
+// so that requests do not wait for the receivers. Failed deliveries are
+// retried with exponential backoff. Shutdown drains the queue.
+type WebhookDispatcher struct {
+	sinks  []WebhookSink
+	client *http.Client
+
+	// attempts and backoff control retries, see webhookAttempts and
+	// webhookBackoff
+	attempts int
+	backoff  time.Duration
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan webhookDelivery
+	wg     sync.WaitGroup
+
+	// ctx is cancelled when Shutdown gives up waiting, aborting the
+	// deliveries in flight
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewWebhookDispatcher starts a dispatcher sending webhooks to sinks
+func NewWebhookDispatcher(sinks []WebhookSink) *WebhookDispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &WebhookDispatcher{
+		sinks:    sinks,
+		client:   &http.Client{Timeout: webhookTimeout},
+		attempts: webhookAttempts,
+		backoff:  webhookBackoff,
+		queue:    make(chan webhookDelivery, webhookQueueSize),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	for i := 0; i < webhookWorkers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	return d
+}
+
+// Dispatch queues a webhook about a change of verb to resource, of the
+// given kind, for every sink it matches. It never blocks: events are
+// dropped, and logged, when the queue is full or the dispatcher is shut
+// down.
+func (d *WebhookDispatcher) Dispatch(kind string, verb WebhookVerb, resource any) {
+	var sinks []WebhookSink
+	for _, sink := range d.sinks {
+		if sink.matches(kind, verb) {
+			sinks = append(sinks, sink)
+		}
+	}
+	if len(sinks) == 0 {
+		return
+	}
+
+	// The resource is encoded now, before the caller changes it again.
+	// Receivers are outside the API, so secrets are redacted as in the
+	// audit log.
+	object, err := redactJSON(resource)
+	if err != nil {
+		slog.Error("Webhook payload failed", "kind", kind, "verb", verb, "error", err)
+		return
+	}
+	payload := WebhookPayload{
+		ID:        uuid.NewString(),
+		Kind:      kind,
+		Verb:      verb,
+		Timestamp: time.Now().UTC(),
+		Object:    object,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Webhook payload failed", "kind", kind, "verb", verb, "error", err)
+		return
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, sink := range sinks {
+		if d.closed {
+			slog.Warn("Webhook dropped after shutdown", "url", sink.URL, "id", payload.ID)
+			continue
+		}
+		select {
+		case d.queue <- webhookDelivery{sink: sink, id: payload.ID, body: body}:
+		default:
+			slog.Warn("Webhook dropped, queue full", "url", sink.URL, "id", payload.ID)
+		}
+	}
+}
+
+// Shutdown stops accepting webhooks and waits until the queued ones are
+// delivered or ctx is done, in which case the remaining deliveries are
+// abandoned and ctx.Err() is returned
+func (d *WebhookDispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// work sends queued deliveries until the queue is closed and drained
+func (d *WebhookDispatcher) work() {
+	defer d.wg.Done()
+	for delivery := range d.queue {
+		d.deliver(delivery)
+	}
+}
+
+// deliver sends a delivery, retrying network errors, 429 and 5xx responses
+// with exponential backoff
+func (d *WebhookDispatcher) deliver(delivery webhookDelivery) {
+	backoff := d.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := d.send(delivery)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= d.attempts {
+			slog.Error("Webhook delivery failed", "url", delivery.sink.URL, "id", delivery.id,
+				"attempts", attempt, "error", err)
+			return
+		}
+		slog.Warn("Webhook delivery failed, retrying", "url", delivery.sink.URL, "id", delivery.id,
+			"attempt", attempt, "backoff", backoff, "error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-d.ctx.Done():
+			slog.Error("Webhook delivery abandoned on shutdown", "url", delivery.sink.URL, "id", delivery.id)
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// send makes one attempt to deliver, reporting whether a failure is worth
+// retrying
+func (d *WebhookDispatcher) send(delivery webhookDelivery) (bool, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, delivery.sink.URL, bytes.NewReader(delivery.body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIDHeader, delivery.id)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(delivery.sink.Secret, delivery.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return d.ctx.Err() == nil, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("receiver responded %s", resp.Status)
+	default:
+		return false, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+}
+
+// SignWebhook returns the value of WebhookSignatureHeader for body signed
+// with secret
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook reports whether signature, the value of
+// WebhookSignatureHeader, is valid for body signed with secret
+func VerifyWebhook(secret string, body []byte, signature string) bool {
+	digest, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	expected, err := hex.DecodeString(digest)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookRequest is a webhook received by a test receiver
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver records the webhooks it receives, answering status for
+// each attempt in turn and 200 once statuses run out
+func webhookReceiver(t *testing.T, statuses ...int) (*httptest.Server, func() []webhookRequest) {
+	var mu sync.Mutex
+	var received []webhookRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+		status := http.StatusOK
+		if len(received) < len(statuses) {
+			status = statuses[len(received)]
+		}
+		received = append(received, webhookRequest{header: r.Header.Clone(), body: body})
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []webhookRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]webhookRequest(nil), received...)
+	}
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	receiver, received := webhookReceiver(t, http.StatusInternalServerError)
+	ignored, ignoredReceived := webhookReceiver(t)
+	dispatcher := NewWebhookDispatcher([]WebhookSink{
+		{URL: receiver.URL, Secret: "s3cret", Kinds: []string{"User"}, Verbs: []WebhookVerb{WebhookVerbCreate}},
+		{URL: ignored.URL, Secret: "other", Kinds: []string{"Group"}},
+	})
+	dispatcher.backoff = time.Millisecond
+
+	dao := NewDAO[apiv1.User](db, WithWebhooks(dispatcher))
+	user := &apiv1.User{Username: "alice", Email: "alice@example.com", Password: "password123"}
+	require.NoError(t, dao.Create(context.Background(), user))
+
+	// Updates are filtered out by the verbs of the sink
+	user.FullName = "Alice"
+	require.NoError(t, dao.Update(context.Background(), user.ID, user, 0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, dispatcher.Shutdown(ctx))
+
+	// The first attempt failed with 500, so the same payload came again
+	requests := received()
+	require.Len(t, requests, 2)
+	assert.Equal(t, requests[0].body, requests[1].body)
+	assert.Equal(t, requests[0].header.Get(WebhookIDHeader), requests[1].header.Get(WebhookIDHeader))
+	assert.Empty(t, ignoredReceived())
+
+	request := requests[1]
+	assert.Equal(t, "application/json", request.header.Get("Content-Type"))
+	assert.True(t, VerifyWebhook("s3cret", request.body, request.header.Get(WebhookSignatureHeader)))
+	assert.False(t, VerifyWebhook("wrong", request.body, request.header.Get(WebhookSignatureHeader)))
+
+	var payload WebhookPayload
+	require.NoError(t, json.Unmarshal(request.body, &payload))
+	assert.Equal(t, request.header.Get(WebhookIDHeader), payload.ID)
+	assert.Equal(t, "User", payload.Kind)
+	assert.Equal(t, WebhookVerbCreate, payload.Verb)
+	assert.WithinDuration(t, time.Now(), payload.Timestamp, time.Minute)
+	var object apiv1.User
+	require.NoError(t, json.Unmarshal(payload.Object, &object))
+	assert.Equal(t, user.UID, object.UID)
+	assert.Equal(t, "alice", object.Username)
+
+	// Neither the password nor its hash reach receivers
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(payload.Object, &fields))
+	assert.NotContains(t, fields, "password")
+	assert.NotContains(t, string(request.body), user.PasswordHash)
+
+	// Changes after shutdown are dropped rather than blocking
+	require.NoError(t, dao.Delete(context.Background(), user.ID, 0))
+	assert.Len(t, received(), 2)
+}
+
+func TestWebhookDispatcher_RedactsSecrets(t *testing.T) {
+	receiver, received := webhookReceiver(t)
+	dispatcher := NewWebhookDispatcher([]WebhookSink{{URL: receiver.URL, Secret: "s3cret"}})
+
+	resource := struct {
+		Name     string `json:"name"`
+		APIToken string `json:"apiToken"`
+	}{Name: "service", APIToken: "t0ken"}
+	dispatcher.Dispatch("Service", WebhookVerbCreate, resource)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, dispatcher.Shutdown(ctx))
+
+	requests := received()
+	require.Len(t, requests, 1)
+	var payload WebhookPayload
+	require.NoError(t, json.Unmarshal(requests[0].body, &payload))
+	assert.JSONEq(t, `{"name":"service","apiToken":"[REDACTED]"}`, string(payload.Object))
+}
+
+func TestWebhookDispatcher_GiveUp(t *testing.T) {
+	// Client errors are not retried, server errors until attempts run out
+	for _, tt := range []struct {
+		status   int
+		attempts int
+	}{
+		{http.StatusBadRequest, 1},
+		{http.StatusServiceUnavailable, 3},
+	} {
+		receiver, received := webhookReceiver(t, tt.status, tt.status, tt.status, tt.status)
+		dispatcher := NewWebhookDispatcher([]WebhookSink{{URL: receiver.URL}})
+		dispatcher.attempts = 3
+		dispatcher.backoff = time.Millisecond
+
+		dispatcher.Dispatch("User", WebhookVerbDelete, map[string]string{"uid": "1"})
+		require.NoError(t, dispatcher.Shutdown(context.Background()))
+		assert.Len(t, received(), tt.attempts, "status %d", tt.status)
+	}
+}
+
+func TestWebhookSink_Validate(t *testing.T) {
+	assert.NoError(t, WebhookSink{URL: "https://hooks.example.com/play", Verbs: []WebhookVerb{WebhookVerbDelete}}.Validate())
+	assert.ErrorContains(t, WebhookSink{URL: "hooks.example.com"}.Validate(), "invalid webhook URL")
+	assert.ErrorContains(t, WebhookSink{URL: "http://hooks.example.com", Verbs: []WebhookVerb{"patch"}}.Validate(), "invalid webhook verb")
+}
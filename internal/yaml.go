@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLContentType is the media type of YAML request and response bodies
+const YAMLContentType = "application/yaml"
+
+// yamlContentTypes are the media types accepted as YAML
+var yamlContentTypes = []string{YAMLContentType, "application/x-yaml", "text/yaml"}
+
+// YAMLBodyMiddleware converts YAML request bodies to JSON, so that handlers
+// bind them like JSON bodies. Malformed YAML is rejected with 400, naming
+// the line of the error where the parser reports it.
+func YAMLBodyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || !slices.Contains(yamlContentTypes, c.ContentType()) {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body.Close()
+		if err != nil {
+			writeBadRequest(c, "failed to read request body")
+			c.Abort()
+			return
+		}
+		converted, err := yamlToJSON(body)
+		if err != nil {
+			writeBadRequest(c, "invalid YAML: "+err.Error())
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(converted))
+		c.Request.ContentLength = int64(len(converted))
+		c.Request.Header.Set("Content-Type", binding.MIMEJSON)
+		c.Next()
+	}
+}
+
+// yamlToJSON converts the first YAML document of data to JSON
+func yamlToJSON(data []byte) ([]byte, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonValue(value))
+}
+
+// jsonValue converts the mappings with non-string keys of a decoded YAML
+// value, which JSON cannot encode, to mappings keyed by strings
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = jsonValue(item)
+		}
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonValue(item)
+		}
+	}
+	return value
+}
+
+// jsonToYAML converts a JSON document to YAML in block style, keeping the
+// order of the keys
+func jsonToYAML(data []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	clearStyle(&node)
+	return yaml.Marshal(&node)
+}
+
+// clearStyle resets the flow and quoting styles that node and its
+// children got from JSON. Strings that would read as another type stay
+// quoted.
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+// yamlRequested reports whether the Accept header of a request prefers
+// YAML to JSON
+func yamlRequested(c *gin.Context) bool {
+	format := c.NegotiateFormat(append([]string{binding.MIMEJSON}, yamlContentTypes...)...)
+	return slices.Contains(yamlContentTypes, format)
+}
+
+// writeResource writes obj as JSON, or as YAML when the Accept header of
+// the request prefers it
+func writeResource(c *gin.Context, code int, obj any) {
+	if !yamlRequested(c) {
+		c.JSON(code, obj)
+		return
+	}
+
+	data, err := json.Marshal(obj)
+	if err == nil {
+		data, err = jsonToYAML(data)
+	}
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+	c.Data(code, YAMLContentType+"; charset=utf-8", data)
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRouter_YAML(t *testing.T) {
+	r, db := setupTestRouter(t)
+	defer cleanupTestDB(t, db)
+
+	serve := func(method, path, contentType, accept, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) map[string]interface{} {
+		assert.Equal(t, "application/yaml; charset=utf-8", w.Header().Get("Content-Type"))
+		var object map[string]interface{}
+		require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &object), w.Body.String())
+		return object
+	}
+
+	manifest := `
+username: alice
+email: alice@example.com
+password: password123
+fullName: "123"
+metadata:
+  labels:
+    team: platform
+`
+	w := serve("POST", "/api/v1/users", YAMLContentType, YAMLContentType, manifest)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	created := decode(w)
+	assert.Equal(t, "alice", created["username"])
+	metadata := created["metadata"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"team": "platform"}, metadata["labels"])
+	id := metadata["id"]
+
+	// Strings that read as numbers stay strings, keys keep their order
+	w = serve("GET", fmt.Sprintf("/api/v1/users/%v", id), "", "application/yaml", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "123", decode(w)["fullName"])
+	assert.True(t, strings.HasPrefix(w.Body.String(), "kind: User\napiVersion: v1\nmetadata:\n"), w.Body.String())
+
+	w = serve("PUT", fmt.Sprintf("/api/v1/users/%v", id), "application/x-yaml", "text/yaml", strings.Replace(manifest, `"123"`, "Alice", 1))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "Alice", decode(w)["fullName"])
+
+	w = serve("GET", "/api/v1/users", "", YAMLContentType, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var items []map[string]interface{}
+	require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &items))
+	require.Len(t, items, 1)
+	assert.Equal(t, "alice", items[0]["username"])
+
+	// So do the other routes answering with a resource
+	w = serve("GET", fmt.Sprintf("/api/v1/users/uid/%v", metadata["uid"]), "", YAMLContentType, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "Alice", decode(w)["fullName"])
+	w = serve("GET", "/api/v1/users/count", "", YAMLContentType, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 1, decode(w)["count"])
+
+	// JSON is unchanged
+	w = serve("GET", fmt.Sprintf("/api/v1/users/%v", id), "", "", "")
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	var user map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.Equal(t, "alice", user["username"])
+
+	// Malformed YAML is a bad request naming the line
+	w = serve("POST", "/api/v1/users", YAMLContentType, "", "username: alice\nemail: [unclosed\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, decodeStatus(t, w).Message, "line")
+}
+
+func TestRegisterResource_YAML(t *testing.T) {
+	router, db := setupTestRegister(t)
+	defer cleanupTestDB(t, db)
+
+	req := httptest.NewRequest("POST", "/api/v1/users", strings.NewReader("username: bob\nemail: bob@example.com\npassword: password123\n"))
+	req.Header.Set("Content-Type", YAMLContentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+	req = httptest.NewRequest("GET", "/api/v1/users", nil)
+	req.Header.Set("Accept", YAMLContentType)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list map[string]interface{}
+	require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, 1, list["total"])
+}
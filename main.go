@@ -2,110 +2,101 @@ package main
 
 import (
 	"context"
-	"log"
-	"net/http"
+	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"my-embedded-api/apiv1"
 	"my-embedded-api/internal"
-
-	"github.com/gin-gonic/gin"
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+	"my-embedded-api/server"
 )
 
-// Config holds the application configuration
-type Config struct {
-	// Server configuration
-	Server struct {
-		Port string `default:":8080"`
-	}
-
-	// Database configuration
-	Database struct {
-		Path string `default:"app.db"`
-	}
-
-	// Logging configuration
-	Logging struct {
-		Level string `default:"info"`
-	}
-}
-
-// NewConfig creates a new configuration with default values
-func NewConfig() *Config {
-	config := &Config{}
-
-	// Set default values
-	config.Server.Port = ":8080"
-	config.Database.Path = "app.db"
-	config.Logging.Level = "info"
-
-	return config
+// fatal logs an error and exits
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 func main() {
-	// Load configuration
-	config := NewConfig()
-
-	// Initialize standard logger
-	stdLogger := log.New(os.Stdout, "", log.LstdFlags)
-
-	// Initialize GORM logger
-	gormLogger := logger.Default.LogMode(logger.Info)
-
-	// Initialize database with logging
-	db, err := gorm.Open(sqlite.Open(config.Database.Path), &gorm.Config{
-		Logger: gormLogger,
-	})
+	// Load and validate the configuration from flags, environment and file
+	config, err := server.LoadConfig(os.Args[1:])
 	if err != nil {
-		stdLogger.Fatalf("Failed to connect to database: %v", err)
+		fatal(slog.Default(), "Invalid configuration", err)
 	}
 
-	// Initialize Gin router
-	router := gin.Default()
+	// Initialize structured logger, used by gin and GORM as well
+	logger := internal.NewLogger(os.Stdout, config.Logging.Level)
+	slog.SetDefault(logger)
+	internal.ConfigureGin(logger, config.Logging.Level)
 
-	// Add middleware
-	router.Use(gin.Recovery())
-	router.Use(gin.Logger())
-
-	// Register resources
-	internal.RegisterResource[apiv1.User](router, db, "/api/v1/users")
+	// Export traces if a collector is configured
+	if config.Tracing.Endpoint != "" {
+		shutdownTracer, err := internal.InitTracer(config.Tracing.Endpoint)
+		if err != nil {
+			fatal(logger, "Failed to initialize tracing", err)
+		}
+		defer shutdownTracer()
+	}
 
-	// Create HTTP server
-	srv := &http.Server{
-		Addr:    config.Server.Port,
-		Handler: router,
+	// Initialize database with logging
+	db, err := server.OpenDatabase(config)
+	if err != nil {
+		fatal(logger, "Failed to connect to database", err)
 	}
 
-	// Start server in a goroutine
-	go func() {
-		stdLogger.Printf("Starting server on %s", config.Server.Port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			stdLogger.Fatalf("Failed to start server: %v", err)
+	// Show what the migration of the tables would execute, without running it
+	if config.MigrateDryRun {
+		models := []interface{}{&internal.AuditEvent{}, &apiv1.AuditEntry{}, &apiv1.User{}}
+		if config.Auth.JWTSecret != "" {
+			models = append(models, &apiv1.APIKey{})
 		}
-	}()
-
-	// Wait for interrupt signal
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+		statements, err := internal.MigratePreview(db, models...)
+		if err != nil {
+			fatal(logger, "Failed to preview migration", err)
+		}
+		for _, statement := range statements {
+			fmt.Println(statement + ";")
+		}
+		return
+	}
 
-	// Graceful shutdown
-	stdLogger.Println("Shutting down server...")
+	srv, err := server.NewServer(config, db)
+	if err != nil {
+		fatal(logger, "Failed to create server", err)
+	}
+	registerResources(srv, config)
 
-	// Create shutdown context with 5 second timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// Serve until interrupted, then shut down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	// Attempt graceful shutdown
-	if err := srv.Shutdown(ctx); err != nil {
-		stdLogger.Fatalf("Server forced to shutdown: %v", err)
+	if err := srv.Run(ctx); err != nil {
+		fatal(logger, "Server failed", err)
 	}
+}
 
-	stdLogger.Println("Server exiting")
+// registerResources serves the users and, when authentication is
+// configured, the API keys of srv
+func registerResources(srv *server.Server, config *server.Config) {
+	var userOptions []server.RouterOption
+	if config.Auth.JWTSecret != "" {
+		// Any signed in user may read users, only admins may create,
+		// change, import or delete them
+		userOptions = append(userOptions, internal.WithRoleRequirements(map[string][]string{
+			"GET":              {apiv1.RoleAdmin, apiv1.RoleUser},
+			"HEAD":             {apiv1.RoleAdmin, apiv1.RoleUser},
+			internal.AnyMethod: {apiv1.RoleAdmin},
+		}))
+	}
+	server.RegisterResource[apiv1.User](srv, "/api/v1/users", userOptions...)
+	srv.RegisterPasswordChange("/api/v1/users")
+
+	if config.Auth.JWTSecret != "" {
+		// Only admins may manage the API keys of machine clients
+		server.RegisterResource[apiv1.APIKey](srv, "/api/v1/apikeys", internal.WithRoleRequirements(map[string][]string{
+			internal.AnyMethod: {apiv1.RoleAdmin},
+		}))
+	}
 }
@@ -15,9 +15,11 @@ import (
 	"my-embedded-api/apiv1"
 	"my-embedded-api/internal"
 	"my-embedded-api/meta"
+	"my-embedded-api/server"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -163,7 +165,7 @@ func TestUserAPI(t *testing.T) {
 	assert.NoError(t, err)
 	resp, err = http.DefaultClient.Do(req)
 	assert.NoError(t, err)
-	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
 	resp.Body.Close()
 
 	// Verify deletion
@@ -310,11 +312,11 @@ func TestServer_ErrorHandling(t *testing.T) {
 
 	resp, err := http.Post(server.URL()+"/api/v1/users", "application/json", bytes.NewBuffer(body))
 	assert.NoError(t, err)
-	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
 	resp.Body.Close()
 
-	// Test invalid user ID
-	resp, err = http.Get(server.URL() + "/api/v1/users/invalid")
+	// Test invalid user ID, neither a number nor a name
+	resp, err = http.Get(server.URL() + "/api/v1/users/Invalid_ID")
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 	resp.Body.Close()
@@ -366,3 +368,60 @@ func TestServer_GracefulShutdown(t *testing.T) {
 	_, err = http.Get("http://localhost:8080/api/v1/users")
 	assert.Error(t, err)
 }
+
+func TestRegisterResources_Roles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config, err := server.LoadConfig(nil)
+	require.NoError(t, err)
+	config.Database.Path = filepath.Join(t.TempDir(), "test.db")
+	config.Logging.Level = "silent"
+	config.Auth.JWTSecret = "test-secret"
+	db, err := server.OpenDatabase(config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	srv, err := server.NewServer(config, db)
+	require.NoError(t, err)
+	registerResources(srv, config)
+
+	admin := &apiv1.User{Username: "admin", Email: "admin@example.com", Password: "password123", Role: apiv1.RoleAdmin}
+	require.NoError(t, db.Create(admin).Error)
+	regular := &apiv1.User{Username: "regular", Email: "regular@example.com", Password: "password123"}
+	require.NoError(t, db.Create(regular).Error)
+	tokenFor := func(user *apiv1.User) string {
+		token, _, err := internal.IssueToken([]byte(config.Auth.JWTSecret), user, time.Hour, time.Now())
+		require.NoError(t, err)
+		return token
+	}
+	self := fmt.Sprintf("/api/v1/users/%d", regular.ID)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		token  string
+		code   int
+	}{
+		{"user reads", "GET", self, "", tokenFor(regular), http.StatusOK},
+		{"user promotes themselves", "PUT", self, `{"role":"admin"}`, tokenFor(regular), http.StatusForbidden},
+		{"user creates", "POST", "/api/v1/users", `{"username":"eve","email":"eve@example.com","password":"password123","role":"admin"}`, tokenFor(regular), http.StatusForbidden},
+		{"user imports", "POST", "/api/v1/users/import", `{"username":"eve"}`, tokenFor(regular), http.StatusForbidden},
+		{"user bulk updates", "PATCH", "/api/v1/users/bulk", `{"ids":[1],"patch":{"isActive":false}}`, tokenFor(regular), http.StatusForbidden},
+		{"user counts API keys", "HEAD", "/api/v1/apikeys", "", tokenFor(regular), http.StatusForbidden},
+		{"admin promotes", "PUT", self, `{"role":"admin"}`, tokenFor(admin), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(w, req)
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+		})
+	}
+}
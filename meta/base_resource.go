@@ -1,7 +1,13 @@
 package meta
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,6 +27,40 @@ type ResourceStatus struct {
 
 	// LastTransitionTime is the last time the condition transitioned from one status to another
 	LastTransitionTime time.Time `json:"lastTransitionTime,omitempty"`
+
+	// Conditions are the independent observations of the resource's current state
+	Conditions []Condition `json:"conditions,omitempty" gorm:"column:status_conditions;serializer:json"`
+}
+
+// Condition status values
+const (
+	ConditionTrue    = "True"
+	ConditionFalse   = "False"
+	ConditionUnknown = "Unknown"
+)
+
+// ConditionReady is the type of the condition mirroring the phase set by
+// SetStatus: True when the resource is Active, Unknown while it is Pending
+// or has no phase and False otherwise
+const ConditionReady = "Ready"
+
+// Condition describes one aspect of the state of a resource, such as
+// whether it is ready
+type Condition struct {
+	// Type is the CamelCase name of the condition, unique within a resource
+	Type string `json:"type"`
+
+	// Status is one of True, False or Unknown
+	Status string `json:"status"`
+
+	// Reason is a brief CamelCase string that describes the last transition
+	Reason string `json:"reason,omitempty"`
+
+	// Message provides a human-readable message with details about the last transition
+	Message string `json:"message,omitempty"`
+
+	// LastTransitionTime is the last time the condition changed
+	LastTransitionTime time.Time `json:"lastTransitionTime,omitempty"`
 }
 
 // TypeMeta describes an individual object in an API response or request
@@ -43,7 +83,11 @@ type ObjectMeta struct {
 	ID uint `gorm:"primaryKey" json:"id"`
 
 	// UID is the unique in time and space value for this object.
-	UID string `gorm:"type:char(36)" json:"uid,omitempty"`
+	UID string `gorm:"type:char(36);index" json:"uid,omitempty"`
+
+	// Name optionally identifies the object in URLs in place of its ID. It
+	// is unique among the live objects of a kind, see ValidateName.
+	Name string `gorm:"size:253;uniqueIndex:,where:name <> '' AND deleted_at IS NULL" json:"name,omitempty"`
 
 	// ResourceVersion is a string that identifies the internal version of this object
 	// that can be used by clients to determine when objects have changed.
@@ -63,10 +107,46 @@ type ObjectMeta struct {
 	// external tools to store and retrieve arbitrary metadata.
 	Annotations map[string]string `gorm:"serializer:json" json:"annotations,omitempty"`
 
+	// OwnerReferences lists the objects this object depends on. When every
+	// owner is gone the object may be garbage collected.
+	OwnerReferences []OwnerReference `gorm:"serializer:json" json:"ownerReferences,omitempty"`
+
+	// Finalizers must all be removed before the object is deleted from storage.
+	// While any remain, a delete request only sets DeletionTimestamp.
+	Finalizers []string `gorm:"serializer:json" json:"finalizers,omitempty"`
+
+	// DeletionTimestamp is the time deletion was requested for an object that
+	// still has finalizers. It is nil for objects that are not being deleted.
+	DeletionTimestamp *time.Time `json:"deletionTimestamp,omitempty"`
+
+	// DeletedAt is the time the object was deleted, null for live objects.
+	// Deleted objects stay in storage, hidden from queries, until they are
+	// restored or purged.
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deletedAt,omitempty"`
+
 	// Status represents the current state of the resource
 	Status ResourceStatus `json:"status,omitempty" gorm:"embedded"`
 }
 
+// OwnerReference identifies an object that owns another object
+type OwnerReference struct {
+	// Kind of the owner
+	Kind string `json:"kind"`
+
+	// APIVersion of the owner
+	APIVersion string `json:"apiVersion"`
+
+	// UID of the owner
+	UID string `json:"uid"`
+
+	// Name of the owner
+	Name string `json:"name,omitempty"`
+
+	// BlockOwnerDeletion prevents the owner from being deleted while this
+	// reference exists
+	BlockOwnerDeletion bool `json:"blockOwnerDeletion,omitempty"`
+}
+
 // BaseResource is the base type that all resources should embed
 type BaseResource struct {
 	TypeMeta   `json:",inline"`
@@ -95,6 +175,11 @@ func (b *BaseResource) GetUID() string {
 	return b.UID
 }
 
+// GetName returns the name of the resource, empty if it has none
+func (b *BaseResource) GetName() string {
+	return b.Name
+}
+
 // GetResourceVersion returns the resource version
 func (b *BaseResource) GetResourceVersion() int {
 	return b.ResourceVersion
@@ -110,12 +195,147 @@ func (b *BaseResource) GetAPIVersion() string {
 	return b.APIVersion
 }
 
-// SetStatus updates the resource status
+// SetOwnerReference adds an owner reference or replaces the existing
+// reference with the same UID
+func (b *BaseResource) SetOwnerReference(owner OwnerReference) {
+	for i := range b.OwnerReferences {
+		if b.OwnerReferences[i].UID == owner.UID {
+			b.OwnerReferences[i] = owner
+			return
+		}
+	}
+	b.OwnerReferences = append(b.OwnerReferences, owner)
+}
+
+// ClearOwnerReference removes the owner reference with the given UID
+func (b *BaseResource) ClearOwnerReference(uid string) {
+	for i, owner := range b.OwnerReferences {
+		if owner.UID == uid {
+			b.OwnerReferences = append(b.OwnerReferences[:i], b.OwnerReferences[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetOwnerReferences returns the owner references of the resource
+func (b *BaseResource) GetOwnerReferences() []OwnerReference {
+	return b.OwnerReferences
+}
+
+// GetFinalizers returns the finalizers of the resource
+func (b *BaseResource) GetFinalizers() []string {
+	return b.Finalizers
+}
+
+// GetDeletionTimestamp returns when deletion was requested, or nil
+func (b *BaseResource) GetDeletionTimestamp() *time.Time {
+	return b.DeletionTimestamp
+}
+
+// IsTerminating reports whether deletion was requested and waits for the
+// finalizers to be removed
+func (b *BaseResource) IsTerminating() bool {
+	return b.DeletionTimestamp != nil
+}
+
+// HasFinalizer reports whether the resource has the finalizer
+func (b *BaseResource) HasFinalizer(finalizer string) bool {
+	return slices.Contains(b.Finalizers, finalizer)
+}
+
+// AddFinalizer adds a finalizer unless the resource already has it
+func (b *BaseResource) AddFinalizer(finalizer string) {
+	if !b.HasFinalizer(finalizer) {
+		b.Finalizers = append(b.Finalizers, finalizer)
+	}
+}
+
+// RemoveFinalizer removes a finalizer. The list stays non-nil, so that
+// updates remove the last finalizer rather than keep the stored ones.
+func (b *BaseResource) RemoveFinalizer(finalizer string) {
+	remaining := make([]string, 0, len(b.Finalizers))
+	for _, f := range b.Finalizers {
+		if f != finalizer {
+			remaining = append(remaining, f)
+		}
+	}
+	b.Finalizers = remaining
+}
+
+// GetStatus returns the resource status
+func (b *BaseResource) GetStatus() ResourceStatus {
+	return b.Status
+}
+
+// SetStatus updates the resource status and mirrors the phase into the
+// ConditionReady condition, so that clients reading conditions see it too
 func (b *BaseResource) SetStatus(phase, message, reason string) {
 	b.Status.Phase = phase
 	b.Status.Message = message
 	b.Status.Reason = reason
 	b.Status.LastTransitionTime = time.Now()
+
+	if reason == "" {
+		reason = phase
+	}
+	b.SetCondition(Condition{Type: ConditionReady, Status: readyStatus(phase), Reason: reason, Message: message})
+}
+
+// readyStatus is the status of the ConditionReady condition of a resource
+// in phase
+func readyStatus(phase string) string {
+	switch phase {
+	case "Active":
+		return ConditionTrue
+	case "", "Pending":
+		return ConditionUnknown
+	default:
+		return ConditionFalse
+	}
+}
+
+// SetCondition adds a condition or replaces the existing condition of the
+// same type. LastTransitionTime only changes when the status flips: an
+// update keeping the status keeps the time of the existing condition, and
+// a new status takes the given time, or the current time if it is zero.
+func (b *BaseResource) SetCondition(condition Condition) {
+	for i := range b.Status.Conditions {
+		existing := &b.Status.Conditions[i]
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		} else if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = time.Now()
+		}
+		*existing = condition
+		return
+	}
+	if condition.LastTransitionTime.IsZero() {
+		condition.LastTransitionTime = time.Now()
+	}
+	b.Status.Conditions = append(b.Status.Conditions, condition)
+}
+
+// GetCondition gets a condition by type
+func (b *BaseResource) GetCondition(conditionType string) (Condition, bool) {
+	for _, condition := range b.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition, true
+		}
+	}
+	return Condition{}, false
+}
+
+// RemoveCondition removes a condition by type
+func (b *BaseResource) RemoveCondition(conditionType string) {
+	for i, condition := range b.Status.Conditions {
+		if condition.Type == conditionType {
+			b.Status.Conditions = append(b.Status.Conditions[:i], b.Status.Conditions[i+1:]...)
+			return
+		}
+	}
 }
 
 // Validate performs basic validation of the resource
@@ -126,6 +346,35 @@ func (b *BaseResource) Validate() error {
 	if b.APIVersion == "" {
 		return errors.New("apiVersion is required")
 	}
+	if b.Name != "" {
+		if err := ValidateName(b.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MaxNameLength is the longest Name of an object
+const MaxNameLength = 253
+
+// namePattern matches names of lowercase alphanumerics and dashes that
+// start and end with an alphanumeric, as DNS labels do
+var namePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// ValidateName checks that name can be the Name of an object: lowercase
+// alphanumerics and dashes, starting and ending with an alphanumeric, at
+// most MaxNameLength characters and not only digits, which would be taken
+// for an ID.
+func ValidateName(name string) error {
+	if len(name) > MaxNameLength {
+		return fmt.Errorf("name must be at most %d characters long", MaxNameLength)
+	}
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("name %q must consist of lowercase alphanumerics and dashes, starting and ending with an alphanumeric", name)
+	}
+	if strings.Trim(name, "0123456789") == "" {
+		return fmt.Errorf("name %q must not be a number", name)
+	}
 	return nil
 }
 
@@ -138,9 +387,13 @@ func (b *BaseResource) BeforeCreate(tx *gorm.DB) error {
 		b.ResourceVersion = 1
 	}
 
-	// Set initial status
+	// Set initial status, keeping a Ready condition set by the creator
 	if b.Status.Phase == "" {
+		ready, set := b.GetCondition(ConditionReady)
 		b.SetStatus("Pending", "Resource is being created", "")
+		if set {
+			b.SetCondition(ready)
+		}
 	}
 
 	// Validate the resource
@@ -192,3 +445,138 @@ func (b *BaseResource) DeleteMetadata(key string) {
 	}
 	delete(b.Annotations, key)
 }
+
+// DeepCopy returns a *BaseResource copy of b, see the DeepCopy function,
+// or nil if b is nil. Resources embedding BaseResource define their own
+// DeepCopy returning their type.
+func (b *BaseResource) DeepCopy() interface{} {
+	copied, err := DeepCopy(b)
+	if err != nil || copied == nil {
+		return nil
+	}
+	return copied
+}
+
+// DeepCopy returns a copy of resource sharing no memory with it, made by
+// encoding resource as JSON and decoding the result into a new T. Fields
+// left out of the JSON encoding, such as password hashes, stay zero in the
+// copy. It returns nil if resource is nil.
+func DeepCopy[T any](resource *T) (*T, error) {
+	if resource == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, fmt.Errorf("copying %T: %w", resource, err)
+	}
+	copied := new(T)
+	if err := json.Unmarshal(data, copied); err != nil {
+		return nil, fmt.Errorf("copying %T: %w", resource, err)
+	}
+	return copied, nil
+}
+
+// DeepEqual reports whether other, a BaseResource or a resource embedding
+// one, holds the same values as b, see DeepEqual. Only the fields of
+// BaseResource are compared.
+func (b *BaseResource) DeepEqual(other interface{}) bool {
+	var o *BaseResource
+	switch v := other.(type) {
+	case *BaseResource:
+		o = v
+	case BaseResource:
+		o = &v
+	default:
+		value := reflect.Indirect(reflect.ValueOf(other))
+		if value.Kind() != reflect.Struct {
+			return false
+		}
+		embedded := value.FieldByName("BaseResource")
+		if !embedded.IsValid() || embedded.Type() != reflect.TypeFor[BaseResource]() {
+			return false
+		}
+		copied := embedded.Interface().(BaseResource)
+		o = &copied
+	}
+	if b == nil || o == nil {
+		return b == nil && o == nil
+	}
+	return DeepEqual(b, o)
+}
+
+// DeepEqual reports whether x and y, resources of the same type, hold the
+// same values in all their exported fields. The UpdatedAt and
+// ResourceVersion of their ObjectMeta are ignored, as every update changes
+// them, and times are equal when they are the same instant, whatever their
+// location, as they are once stored.
+func DeepEqual(x, y interface{}) bool {
+	return deepEqual(reflect.ValueOf(x), reflect.ValueOf(y))
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	objectMetaType = reflect.TypeFor[ObjectMeta]()
+)
+
+// deepEqual implements DeepEqual for values of any kind
+func deepEqual(x, y reflect.Value) bool {
+	if !x.IsValid() || !y.IsValid() {
+		return x.IsValid() == y.IsValid()
+	}
+	if x.Type() != y.Type() {
+		return false
+	}
+	if x.Type() == timeType {
+		return x.Interface().(time.Time).Equal(y.Interface().(time.Time))
+	}
+	switch x.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if x.IsNil() || y.IsNil() {
+			return x.IsNil() == y.IsNil()
+		}
+		return deepEqual(x.Elem(), y.Elem())
+	case reflect.Struct:
+		for i := 0; i < x.NumField(); i++ {
+			field := x.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if x.Type() == objectMetaType && (field.Name == "UpdatedAt" || field.Name == "ResourceVersion") {
+				continue
+			}
+			if !deepEqual(x.Field(i), y.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice, reflect.Array:
+		if x.Kind() == reflect.Slice && x.IsNil() != y.IsNil() {
+			return false
+		}
+		if x.Len() != y.Len() {
+			return false
+		}
+		for i := 0; i < x.Len(); i++ {
+			if !deepEqual(x.Index(i), y.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if x.IsNil() != y.IsNil() || x.Len() != y.Len() {
+			return false
+		}
+		for _, key := range x.MapKeys() {
+			value := y.MapIndex(key)
+			if !value.IsValid() || !deepEqual(x.MapIndex(key), value) {
+				return false
+			}
+		}
+		return true
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		// Resources never hold them
+		return false
+	default:
+		return x.Equal(y)
+	}
+}
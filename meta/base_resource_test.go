@@ -1,10 +1,12 @@
 package meta
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -166,3 +168,234 @@ func TestBaseResource_Timestamps(t *testing.T) {
 	// Verify UpdatedAt changed
 	assert.NotEqual(t, resource.CreatedAt, resource.UpdatedAt)
 }
+
+func TestBaseResource_Conditions(t *testing.T) {
+	resource := &TestResource{}
+
+	// Add conditions
+	resource.SetCondition(Condition{Type: "Ready", Status: ConditionTrue})
+	resource.SetCondition(Condition{Type: "DBConnected", Status: ConditionFalse, Reason: "Timeout"})
+	assert.Len(t, resource.Status.Conditions, 2)
+
+	condition, exists := resource.GetCondition("Ready")
+	assert.True(t, exists)
+	assert.Equal(t, ConditionTrue, condition.Status)
+	assert.False(t, condition.LastTransitionTime.IsZero())
+
+	// Replace in place without duplicating
+	resource.SetCondition(Condition{Type: "Ready", Status: ConditionFalse})
+	assert.Len(t, resource.Status.Conditions, 2)
+	condition, _ = resource.GetCondition("Ready")
+	assert.Equal(t, ConditionFalse, condition.Status)
+
+	// Remove
+	resource.RemoveCondition("Ready")
+	_, exists = resource.GetCondition("Ready")
+	assert.False(t, exists)
+	assert.Len(t, resource.Status.Conditions, 1)
+
+	// Removing a missing condition is a no-op
+	resource.RemoveCondition("Missing")
+	assert.Len(t, resource.Status.Conditions, 1)
+}
+
+func TestBaseResource_ConditionTransitionTime(t *testing.T) {
+	resource := &TestResource{}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	resource.SetCondition(Condition{Type: "Synced", Status: ConditionFalse, LastTransitionTime: start})
+	condition, _ := resource.GetCondition("Synced")
+	assert.Equal(t, start, condition.LastTransitionTime)
+
+	// Updates keeping the status keep the time, but not the reason
+	resource.SetCondition(Condition{Type: "Synced", Status: ConditionFalse, Reason: "Retrying"})
+	resource.SetCondition(Condition{Type: "Synced", Status: ConditionFalse, Reason: "Retrying", LastTransitionTime: time.Now()})
+	condition, _ = resource.GetCondition("Synced")
+	assert.Equal(t, start, condition.LastTransitionTime)
+	assert.Equal(t, "Retrying", condition.Reason)
+
+	// Flipping the status moves it
+	resource.SetCondition(Condition{Type: "Synced", Status: ConditionTrue})
+	condition, _ = resource.GetCondition("Synced")
+	assert.True(t, condition.LastTransitionTime.After(start))
+	flipped := condition.LastTransitionTime
+	resource.SetCondition(Condition{Type: "Synced", Status: ConditionTrue, Message: "again"})
+	condition, _ = resource.GetCondition("Synced")
+	assert.Equal(t, flipped, condition.LastTransitionTime)
+}
+
+func TestBaseResource_SetStatusReadyCondition(t *testing.T) {
+	resource := &TestResource{}
+
+	resource.SetStatus("Pending", "Starting", "")
+	ready, exists := resource.GetCondition(ConditionReady)
+	assert.True(t, exists)
+	assert.Equal(t, ConditionUnknown, ready.Status)
+	assert.Equal(t, "Pending", ready.Reason)
+	assert.Equal(t, "Starting", ready.Message)
+
+	resource.SetStatus("Active", "Resource is active", "Created")
+	ready, _ = resource.GetCondition(ConditionReady)
+	assert.Equal(t, ConditionTrue, ready.Status)
+	assert.Equal(t, "Created", ready.Reason)
+	activeSince := ready.LastTransitionTime
+
+	// The phase is still set as before, and staying active keeps the time
+	resource.SetStatus("Active", "Resource updated", "Updated")
+	assert.Equal(t, "Active", resource.Status.Phase)
+	assert.Equal(t, "Updated", resource.Status.Reason)
+	ready, _ = resource.GetCondition(ConditionReady)
+	assert.Equal(t, activeSince, ready.LastTransitionTime)
+	assert.Len(t, resource.Status.Conditions, 1)
+
+	// Other conditions are independent of the phase
+	resource.SetCondition(Condition{Type: "Synced", Status: ConditionFalse})
+	resource.SetStatus("Failed", "Resource failed", "Error")
+	ready, _ = resource.GetCondition(ConditionReady)
+	assert.Equal(t, ConditionFalse, ready.Status)
+	synced, _ := resource.GetCondition("Synced")
+	assert.Equal(t, ConditionFalse, synced.Status)
+}
+
+func TestBaseResource_DeepCopyAndEqual(t *testing.T) {
+	now := time.Now()
+	resource := &TestResource{Name: "test"}
+	resource.Kind = "Test"
+	resource.APIVersion = "v1"
+	resource.ID = 1
+	resource.ResourceVersion = 3
+	resource.CreatedAt = now
+	resource.UpdatedAt = now
+	resource.Labels = map[string]string{"team": "ops"}
+	resource.Finalizers = []string{"cleanup"}
+	resource.SetStatus("Active", "ready", "")
+
+	deletion := now.Add(time.Hour)
+	resource.DeletionTimestamp = &deletion
+	copied, err := DeepCopy(resource)
+	require.NoError(t, err)
+	assert.True(t, resource.DeepEqual(copied))
+	assert.Equal(t, "test", copied.Name)
+	assert.Equal(t, "ops", copied.Labels["team"])
+
+	// The copy shares no memory with the original
+	copied.Labels["team"] = "dev"
+	copied.Finalizers[0] = "other"
+	copied.Status.Conditions[0].Status = ConditionFalse
+	*copied.DeletionTimestamp = now
+	assert.Equal(t, "ops", resource.Labels["team"])
+	assert.Equal(t, "cleanup", resource.Finalizers[0])
+	assert.Equal(t, ConditionTrue, resource.Status.Conditions[0].Status)
+	assert.Equal(t, deletion, *resource.DeletionTimestamp)
+	assert.False(t, resource.DeepEqual(copied))
+	assert.Nil(t, (*BaseResource)(nil).DeepCopy())
+	base, ok := resource.BaseResource.DeepCopy().(*BaseResource)
+	require.True(t, ok)
+	assert.True(t, resource.BaseResource.DeepEqual(base))
+	none, err := DeepCopy[TestResource](nil)
+	assert.NoError(t, err)
+	assert.Nil(t, none)
+
+	// Values JSON cannot encode fail the copy rather than panic
+	_, err = DeepCopy(&struct{ C chan int }{})
+	assert.Error(t, err)
+
+	// The update time and version are ignored, other fields are not
+	other := *resource
+	other.Labels = map[string]string{"team": "ops"}
+	other.ResourceVersion = 4
+	other.UpdatedAt = now.Add(time.Minute)
+	assert.True(t, resource.DeepEqual(&other))
+	assert.True(t, resource.DeepEqual(other.BaseResource))
+	other.CreatedAt = now.Add(-time.Minute)
+	assert.False(t, resource.DeepEqual(&other))
+
+	assert.False(t, resource.DeepEqual("test"))
+	assert.False(t, resource.DeepEqual((*BaseResource)(nil)))
+
+	// DeepEqual compares whole resources, times by instant
+	other = *resource
+	other.ResourceVersion = 4
+	other.CreatedAt = now.UTC()
+	assert.True(t, DeepEqual(resource, &other))
+	other.Name = "changed"
+	assert.True(t, resource.DeepEqual(&other))
+	assert.False(t, DeepEqual(resource, &other))
+	assert.False(t, DeepEqual(resource, &other.BaseResource))
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"alice", "a", "web-01", "1st", strings.Repeat("a", MaxNameLength)} {
+		assert.NoError(t, ValidateName(name), name)
+	}
+	for _, name := range []string{"", "Bad_Name!", "Alice", "-web", "web-", "a.b", "42", strings.Repeat("a", MaxNameLength+1)} {
+		assert.Error(t, ValidateName(name), name)
+	}
+
+	resource := &TestResource{BaseResource: BaseResource{TypeMeta: TypeMeta{Kind: "Test", APIVersion: "v1"}}}
+	assert.NoError(t, resource.Validate())
+	resource.ObjectMeta.Name = "Bad_Name!"
+	assert.Error(t, resource.Validate())
+}
+
+func TestBaseResource_Finalizers(t *testing.T) {
+	resource := &TestResource{}
+	assert.False(t, resource.HasFinalizer("cleanup"))
+
+	resource.AddFinalizer("cleanup")
+	resource.AddFinalizer("revoke")
+	resource.AddFinalizer("cleanup")
+	assert.Equal(t, []string{"cleanup", "revoke"}, resource.Finalizers)
+	assert.True(t, resource.HasFinalizer("revoke"))
+
+	resource.RemoveFinalizer("cleanup")
+	resource.RemoveFinalizer("missing")
+	assert.Equal(t, []string{"revoke"}, resource.Finalizers)
+
+	// The last removal leaves an empty list rather than nil
+	resource.RemoveFinalizer("revoke")
+	assert.NotNil(t, resource.Finalizers)
+	assert.Empty(t, resource.Finalizers)
+
+	assert.False(t, resource.IsTerminating())
+	now := time.Now()
+	resource.DeletionTimestamp = &now
+	assert.True(t, resource.IsTerminating())
+}
+
+func TestBaseResource_ConditionsPersisted(t *testing.T) {
+	db := setupTestDB(t)
+
+	resource := &TestResource{Name: "test"}
+	resource.SetCondition(Condition{Type: "Ready", Status: ConditionTrue, Reason: "Started"})
+	err := db.Create(resource).Error
+	assert.NoError(t, err)
+
+	var found TestResource
+	err = db.First(&found, resource.ID).Error
+	assert.NoError(t, err)
+
+	condition, exists := found.GetCondition("Ready")
+	assert.True(t, exists)
+	assert.Equal(t, "Started", condition.Reason)
+	assert.True(t, db.Migrator().HasColumn(&TestResource{}, "status_conditions"))
+}
+
+func TestBaseResource_OwnerReferences(t *testing.T) {
+	resource := &TestResource{}
+
+	// Add owners
+	resource.SetOwnerReference(OwnerReference{Kind: "User", APIVersion: "v1", UID: "uid-1", Name: "alice"})
+	resource.SetOwnerReference(OwnerReference{Kind: "User", APIVersion: "v1", UID: "uid-2", Name: "bob"})
+	assert.Len(t, resource.OwnerReferences, 2)
+
+	// Replace by UID
+	resource.SetOwnerReference(OwnerReference{Kind: "User", APIVersion: "v1", UID: "uid-1", BlockOwnerDeletion: true})
+	assert.Len(t, resource.OwnerReferences, 2)
+	assert.True(t, resource.OwnerReferences[0].BlockOwnerDeletion)
+
+	// Clear
+	resource.ClearOwnerReference("uid-1")
+	assert.Len(t, resource.GetOwnerReferences(), 1)
+	assert.Equal(t, "uid-2", resource.OwnerReferences[0].UID)
+}
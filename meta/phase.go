@@ -0,0 +1,68 @@
+package meta
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrInvalidPhaseTransition is returned by SetPhase when the resource kind
+// does not allow moving from its current phase to the requested one
+var ErrInvalidPhaseTransition = errors.New("invalid phase transition")
+
+// PhaseTransition maps each phase to the phases it may move to. The empty
+// phase is the state of a resource that has never been assigned one.
+type PhaseTransition map[string][]string
+
+// PhaseTransitioner is implemented by resource types that restrict their
+// phase changes
+type PhaseTransitioner interface {
+	PhaseTransitions() PhaseTransition
+}
+
+var (
+	phaseTransitionsMu sync.RWMutex
+	phaseTransitions   = make(map[string]PhaseTransition)
+)
+
+// RegisterPhaseTransitions registers the allowed phase transitions of a
+// resource kind. Kinds without registered transitions allow any change.
+func RegisterPhaseTransitions(kind string, transitioner PhaseTransitioner) {
+	phaseTransitionsMu.Lock()
+	defer phaseTransitionsMu.Unlock()
+	phaseTransitions[kind] = transitioner.PhaseTransitions()
+}
+
+// Allows reports whether moving from phase from to phase to is allowed.
+// Staying in the same phase is always allowed.
+func (p PhaseTransition) Allows(from, to string) bool {
+	if from == to {
+		return true
+	}
+	for _, next := range p[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// SetPhase moves the resource to the next phase, enforcing the transitions
+// registered for its kind
+func (b *BaseResource) SetPhase(next string) error {
+	phaseTransitionsMu.RLock()
+	transitions, ok := phaseTransitions[b.Kind]
+	phaseTransitionsMu.RUnlock()
+
+	current := b.Status.Phase
+	if ok && !transitions.Allows(current, next) {
+		return fmt.Errorf("%w: %s cannot move from %q to %q", ErrInvalidPhaseTransition, b.Kind, current, next)
+	}
+
+	if current != next {
+		b.Status.Phase = next
+		b.Status.LastTransitionTime = time.Now()
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package meta
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// LifecycleResource is a test resource with restricted phase transitions
+type LifecycleResource struct {
+	BaseResource
+}
+
+func (r *LifecycleResource) PhaseTransitions() PhaseTransition {
+	return PhaseTransition{
+		"":        {"Pending"},
+		"Pending": {"Active", "Failed"},
+		"Active":  {"Deleted"},
+	}
+}
+
+func TestBaseResource_SetPhase(t *testing.T) {
+	RegisterPhaseTransitions("LifecycleResource", &LifecycleResource{})
+
+	resource := &LifecycleResource{}
+	resource.Kind = "LifecycleResource"
+
+	// Allowed transitions
+	assert.NoError(t, resource.SetPhase("Pending"))
+	assert.NoError(t, resource.SetPhase("Active"))
+	assert.Equal(t, "Active", resource.Status.Phase)
+	assert.False(t, resource.Status.LastTransitionTime.IsZero())
+
+	// Staying in the same phase
+	assert.NoError(t, resource.SetPhase("Active"))
+
+	// Disallowed transition keeps the current phase
+	err := resource.SetPhase("Pending")
+	assert.True(t, errors.Is(err, ErrInvalidPhaseTransition))
+	assert.Equal(t, "Active", resource.Status.Phase)
+}
+
+func TestBaseResource_SetPhaseUnregistered(t *testing.T) {
+	resource := &TestResource{}
+	resource.Kind = "TestResource"
+
+	// Kinds without registered transitions allow any change
+	assert.NoError(t, resource.SetPhase("Active"))
+	assert.NoError(t, resource.SetPhase("Pending"))
+	assert.Equal(t, "Pending", resource.Status.Phase)
+}
@@ -0,0 +1,35 @@
+package meta
+
+import "context"
+
+// rolesContextKey is the context key holding the roles of the caller
+type rolesContextKey struct{}
+
+// WithRoles returns a context recording roles as those granted to the
+// authenticated caller of a request, so that resources can check them when
+// validating changes
+func WithRoles(ctx context.Context, roles ...string) context.Context {
+	return context.WithValue(ctx, rolesContextKey{}, roles)
+}
+
+// RolesFromContext returns the roles stored by WithRoles. ok is false when
+// the request was not authenticated, for example because the server does
+// not require authentication.
+func RolesFromContext(ctx context.Context) (roles []string, ok bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	roles, ok = ctx.Value(rolesContextKey{}).([]string)
+	return roles, ok
+}
+
+// HasRole reports whether the caller recorded by WithRoles was granted role
+func HasRole(ctx context.Context, role string) bool {
+	roles, _ := RolesFromContext(ctx)
+	for _, granted := range roles {
+		if granted == role {
+			return true
+		}
+	}
+	return false
+}
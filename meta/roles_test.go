@@ -0,0 +1,26 @@
+package meta
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRolesFromContext(t *testing.T) {
+	roles, ok := RolesFromContext(context.Background())
+	assert.False(t, ok)
+	assert.Empty(t, roles)
+	assert.False(t, HasRole(context.Background(), "admin"))
+
+	// An authenticated caller without roles is still recorded
+	_, ok = RolesFromContext(WithRoles(context.Background()))
+	assert.True(t, ok)
+
+	ctx := WithRoles(context.Background(), "user", "admin")
+	roles, ok = RolesFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"user", "admin"}, roles)
+	assert.True(t, HasRole(ctx, "admin"))
+	assert.False(t, HasRole(ctx, "editor"))
+}
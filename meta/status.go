@@ -0,0 +1,89 @@
+package meta
+
+// StatusReason is a machine readable CamelCase description of why a request
+// failed
+type StatusReason string
+
+const (
+	// StatusReasonBadRequest means the request itself was malformed, such as
+	// an unparsable ID or query parameter
+	StatusReasonBadRequest StatusReason = "BadRequest"
+
+	// StatusReasonInvalid means the submitted resource failed validation
+	StatusReasonInvalid StatusReason = "Invalid"
+
+	// StatusReasonUnauthorized means the request carries no valid
+	// credentials
+	StatusReasonUnauthorized StatusReason = "Unauthorized"
+
+	// StatusReasonForbidden means the caller is authenticated but lacks the
+	// roles the request requires
+	StatusReasonForbidden StatusReason = "Forbidden"
+
+	// StatusReasonNotFound means the requested resource does not exist
+	StatusReasonNotFound StatusReason = "NotFound"
+
+	// StatusReasonConflict means the write conflicts with the stored state,
+	// either a newer resource version or a duplicate unique value
+	StatusReasonConflict StatusReason = "Conflict"
+
+	// StatusReasonPreconditionFailed means a conditional request header such
+	// as If-Match did not hold
+	StatusReasonPreconditionFailed StatusReason = "PreconditionFailed"
+
+	// StatusReasonPreconditionRequired means the request must be made
+	// conditional
+	StatusReasonPreconditionRequired StatusReason = "PreconditionRequired"
+
+	// StatusReasonRequestEntityTooLarge means the request body exceeds the
+	// size limit of the endpoint
+	StatusReasonRequestEntityTooLarge StatusReason = "RequestEntityTooLarge"
+
+	// StatusReasonUnsupportedMediaType means the request body has a content
+	// type the endpoint does not accept
+	StatusReasonUnsupportedMediaType StatusReason = "UnsupportedMediaType"
+
+	// StatusReasonTooManyRequests means the client exceeded its rate limit
+	// and should retry after the Retry-After delay
+	StatusReasonTooManyRequests StatusReason = "TooManyRequests"
+
+	// StatusReasonServiceUnavailable means the server is temporarily unable
+	// to handle the request, which may succeed when retried
+	StatusReasonServiceUnavailable StatusReason = "ServiceUnavailable"
+
+	// StatusReasonInternalError means the server failed to handle the request
+	StatusReasonInternalError StatusReason = "InternalError"
+)
+
+// StatusCause describes a single problem with a field of the request
+type StatusCause struct {
+	// Field is the JSON name of the offending field
+	Field string `json:"field,omitempty"`
+
+	// Message is a human-readable description of the problem
+	Message string `json:"message"`
+}
+
+// Status is the body returned by the API for every failed request
+type Status struct {
+	// Code is the HTTP status code of the response
+	Code int `json:"code"`
+
+	// Reason is a CamelCase string meant for machine parsing
+	Reason StatusReason `json:"reason"`
+
+	// Message is a human-readable description of the failure
+	Message string `json:"message"`
+
+	// Details lists per-field causes, such as validation failures
+	Details []StatusCause `json:"details,omitempty"`
+
+	// Current holds the stored object when a conditional write was rejected,
+	// so that the client can rebase its change
+	Current interface{} `json:"current,omitempty"`
+}
+
+// Error implements the error interface
+func (s *Status) Error() string {
+	return s.Message
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/internal"
+)
+
+// auditVerbs maps the mutating HTTP methods to the verbs of audit entries
+var auditVerbs = map[string]string{
+	http.MethodPost:   apiv1.AuditVerbCreate,
+	http.MethodPut:    apiv1.AuditVerbUpdate,
+	http.MethodPatch:  apiv1.AuditVerbPatch,
+	http.MethodDelete: apiv1.AuditVerbDelete,
+}
+
+// auditEntries stores the records of the audit log as apiv1.AuditEntry
+// resources, served at AuditLogPath
+type auditEntries struct {
+	dao *internal.DAO[apiv1.AuditEntry]
+}
+
+// StoreAuditRecord creates the audit entry of record
+func (e auditEntries) StoreAuditRecord(ctx context.Context, record *internal.AuditRecord) error {
+	entry := &apiv1.AuditEntry{
+		Actor:         record.Actor,
+		Verb:          auditVerbs[record.Method],
+		ResourceKind:  record.Kind,
+		ResourceID:    record.ResourceID,
+		ResourceUID:   record.ResourceUID,
+		Method:        record.Method,
+		Path:          record.Path,
+		RequestDigest: record.RequestDigest,
+		RequestBody:   record.RequestBody,
+		Status:        record.Status,
+		Timestamp:     record.Timestamp,
+	}
+	entry.Default()
+	return e.dao.Create(ctx, entry)
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"my-embedded-api/apiv1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditEntries(t *testing.T) {
+	s := setupTestServer(t, func(config *Config) {
+		config.Audit.StoreRequestBodies = true
+	})
+
+	w := serve(s, "POST", "/api/v1/users", `{"username":"alice","email":"alice@example.com","password":"password123"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var user apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+
+	path := fmt.Sprintf("/api/v1/users/%d", user.ID)
+	w = serve(s, "PUT", path, `{"username":"alice","email":"alice@example.com","password":"password123","fullName":"Alice"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, http.StatusNoContent, serve(s, "DELETE", path, "").Code)
+	require.NoError(t, s.Close(context.Background()))
+
+	// Each request is an entry named after the change it asked for
+	w = serve(s, "GET", AuditLogPath+"?sort=id", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var entries []apiv1.AuditEntry
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	require.Len(t, entries, 3)
+
+	verbs := []string{apiv1.AuditVerbCreate, apiv1.AuditVerbUpdate, apiv1.AuditVerbDelete}
+	for i, entry := range entries {
+		assert.Equal(t, verbs[i], entry.Verb)
+		assert.Equal(t, "User", entry.ResourceKind)
+		assert.Equal(t, user.ID, entry.ResourceID)
+		assert.Equal(t, "AuditEntry", entry.Kind)
+	}
+	assert.Equal(t, user.UID, entries[0].ResourceUID)
+	assert.JSONEq(t, `{"username":"alice","email":"alice@example.com","password":"[REDACTED]"}`, entries[0].RequestBody)
+
+	// The standard list filters apply
+	w = serve(s, "GET", AuditLogPath+"?verb[in]=update,delete", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	assert.Len(t, entries, 2)
+
+	// The audit log cannot be written through the API
+	assert.Equal(t, http.StatusNotFound, serve(s, "DELETE", fmt.Sprintf("%s/%d", AuditLogPath, entries[0].ID), "").Code)
+}
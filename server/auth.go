@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/internal"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// dummyPasswordHash is compared against when the user does not exist, so
+// that unknown usernames take as long to reject as wrong passwords
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// users authenticates the apiv1.User resources stored in db, by password
+// or with their apiv1.APIKey resources
+type users struct {
+	db *gorm.DB
+}
+
+// newAuthenticator returns the authenticator of the users and API keys
+// stored in db, as used by the login endpoint and the resources
+func newAuthenticator(db *gorm.DB) internal.Authenticator {
+	return users{db: db}
+}
+
+// Authenticate returns the active user with the given credentials, or
+// internal.ErrInvalidCredentials
+func (u users) Authenticate(ctx context.Context, username, password string) (internal.TokenSubject, error) {
+	var user apiv1.User
+	err := u.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, internal.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if user.ComparePassword(password) != nil || !user.IsActive {
+		return nil, internal.ErrInvalidCredentials
+	}
+	return &user, nil
+}
+
+// AuthenticateAPIKey looks up key by its prefix and returns its owner, or
+// internal.ErrInvalidAPIKey if the key is unknown, wrong, expired or owned
+// by an inactive user
+func (u users) AuthenticateAPIKey(ctx context.Context, key string, now time.Time) (internal.TokenSubject, string, error) {
+	prefix, secret, ok := apiv1.ParseAPIKey(key)
+	if !ok {
+		return nil, "", internal.ErrInvalidAPIKey
+	}
+
+	db := u.db.WithContext(ctx)
+	var apiKey apiv1.APIKey
+	err := db.Where("prefix = ?", prefix).First(&apiKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", internal.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if !apiKey.CheckSecret(secret) || apiKey.Expired(now) {
+		return nil, "", internal.ErrInvalidAPIKey
+	}
+
+	var user apiv1.User
+	err = db.First(&user, apiKey.UserID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", internal.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if !user.IsActive {
+		return nil, "", internal.ErrInvalidAPIKey
+	}
+
+	// Skip the hooks and the resource version, usage is not a change
+	if err := db.Model(&apiKey).UpdateColumn("last_used_at", now).Error; err != nil {
+		return nil, "", err
+	}
+	return &user, apiKey.Prefix, nil
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/internal"
+	"my-embedded-api/meta"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeStatus decodes the meta.Status body of an error response
+func decodeStatus(t *testing.T, w *httptest.ResponseRecorder) meta.Status {
+	var status meta.Status
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status), w.Body.String())
+	return status
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	s := setupTestServer(t, nil)
+	auth := newAuthenticator(s.db)
+	ctx := context.Background()
+
+	active := &apiv1.User{Username: "alice", Email: "alice@example.com", Password: "password123"}
+	require.NoError(t, s.db.Create(active).Error)
+	inactive := &apiv1.User{Username: "bob", Email: "bob@example.com", Password: "password123"}
+	require.NoError(t, s.db.Create(inactive).Error)
+	require.NoError(t, s.db.Model(inactive).Update("is_active", false).Error)
+
+	user, err := auth.Authenticate(ctx, "alice", "password123")
+	require.NoError(t, err)
+	assert.Equal(t, active.ID, user.GetID())
+	assert.Equal(t, active.UID, user.GetUID())
+	assert.Equal(t, []string{apiv1.RoleUser}, user.GetRoles())
+
+	// Every failure is the same error
+	failures := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"wrong password", "alice", "wrong"},
+		{"unknown user", "nobody", "password123"},
+		{"inactive user", "bob", "password123"},
+	}
+	for _, tt := range failures {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.Authenticate(ctx, tt.username, tt.password)
+			assert.ErrorIs(t, err, internal.ErrInvalidCredentials)
+		})
+	}
+}
+
+func TestAuthenticator_APIKeys(t *testing.T) {
+	s := setupTestServer(t, func(config *Config) {
+		config.Auth.JWTSecret = "secret"
+	})
+	RegisterResource[apiv1.APIKey](s, "/api/v1/apikeys",
+		internal.WithRoleRequirements(map[string][]string{"POST": {apiv1.RoleAdmin}, "DELETE": {apiv1.RoleAdmin}}))
+	db := s.db
+
+	admin := &apiv1.User{Username: "admin", Email: "admin@example.com", Password: "password123", Role: apiv1.RoleAdmin}
+	require.NoError(t, db.Create(admin).Error)
+	token, _, err := internal.IssueToken([]byte("secret"), admin, time.Hour, time.Now())
+	require.NoError(t, err)
+
+	serve := func(method, path, authorization, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	// The key is only returned on creation
+	w := serve("POST", "/api/v1/apikeys", "Bearer "+token,
+		`{"userId":`+strconv.FormatUint(uint64(admin.ID), 10)+`,"label":"ci"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "secretHash")
+	var created apiv1.CreatedAPIKey
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	key := created.Key
+	require.NotEmpty(t, key)
+	assert.Equal(t, "ci", created.Label)
+	keyPath := "/api/v1/apikeys/" + strconv.FormatUint(uint64(created.ID), 10)
+
+	w = serve("GET", keyPath, "Bearer "+token, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var fetched map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &fetched))
+	assert.NotContains(t, fetched, "key")
+	assert.NotContains(t, fetched, "secretHash")
+	assert.Equal(t, created.Prefix, fetched["prefix"])
+
+	t.Run("valid key", func(t *testing.T) {
+		w := serve("GET", "/api/v1/users", "ApiKey "+key, "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var stored apiv1.APIKey
+		require.NoError(t, db.Where("prefix = ?", created.Prefix).First(&stored).Error)
+		assert.NotNil(t, stored.LastUsedAt)
+	})
+
+	t.Run("key acts as its owner", func(t *testing.T) {
+		w := serve("POST", "/api/v1/apikeys", "ApiKey "+key, `{"userId":1}`)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		prefix, _, ok := apiv1.ParseAPIKey(key)
+		require.True(t, ok)
+		w := serve("GET", "/api/v1/users", "ApiKey "+apiv1.APIKeyPrefix+"_"+prefix+"_wrong", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, meta.StatusReasonUnauthorized, decodeStatus(t, w).Reason)
+	})
+
+	t.Run("malformed key", func(t *testing.T) {
+		w := serve("GET", "/api/v1/users", "ApiKey not-a-key", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Values("WWW-Authenticate"), `ApiKey realm="play-api"`)
+	})
+
+	t.Run("expired key", func(t *testing.T) {
+		w := serve("POST", "/api/v1/apikeys", "Bearer "+token, `{"userId":1}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var expiring apiv1.CreatedAPIKey
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &expiring))
+		require.NoError(t, db.Model(&apiv1.APIKey{}).Where("prefix = ?", expiring.Prefix).
+			UpdateColumn("expires_at", time.Now().Add(-time.Minute)).Error)
+
+		w = serve("GET", "/api/v1/users", "ApiKey "+expiring.Key, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("revoked key", func(t *testing.T) {
+		w := serve("DELETE", keyPath, "Bearer "+token, "")
+		require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+		w = serve("GET", "/api/v1/users", "ApiKey "+key, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,351 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/internal"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the application configuration. Values are resolved in the
+// order flag > environment > config file > default tag, see LoadConfig.
+type Config struct {
+	// Server configuration
+	Server struct {
+		Port string `yaml:"port" default:":8080"`
+
+		// TLS certificate and key files; the server speaks plain HTTP
+		// unless both are set
+		TLSCertFile string `yaml:"tlsCertFile"`
+		TLSKeyFile  string `yaml:"tlsKeyFile"`
+
+		// TLSMinVersion is the oldest TLS version accepted, TLS12 or TLS13
+		TLSMinVersion string `yaml:"tlsMinVersion" default:"TLS12"`
+	} `yaml:"server"`
+
+	// Database configuration
+	Database internal.DatabaseConfig `yaml:"database"`
+
+	// Logging configuration
+	Logging struct {
+		Level string `yaml:"level" default:"info"`
+	} `yaml:"logging"`
+
+	// Authentication configuration
+	Auth struct {
+		// JWTSecret is the HMAC key of bearer tokens; authentication is off
+		// when empty
+		JWTSecret string `yaml:"jwtSecret"`
+
+		// TokenTTLSeconds is how long tokens issued by the login endpoint
+		// stay valid
+		TokenTTLSeconds int `yaml:"tokenTTLSeconds" default:"3600"`
+
+		// PasswordPolicy is enforced on new user passwords
+		PasswordPolicy apiv1.PasswordPolicy `yaml:"passwordPolicy"`
+	} `yaml:"auth"`
+
+	// CORS configuration; cross-origin requests are refused when no origin
+	// is allowed
+	CORS internal.CORSConfig `yaml:"cors"`
+
+	// Tracing configuration
+	Tracing struct {
+		// Endpoint is the OTLP/HTTP collector; tracing is off when empty
+		Endpoint string `yaml:"endpoint"`
+	} `yaml:"tracing"`
+
+	// Audit log configuration
+	Audit struct {
+		// StoreRequestBodies keeps the JSON request bodies in the audit
+		// entries, with their secrets redacted; only their digest is kept
+		// otherwise
+		StoreRequestBodies bool `yaml:"storeRequestBodies"`
+	} `yaml:"audit"`
+
+	// Webhooks are notified of resource changes
+	Webhooks []internal.WebhookSink `yaml:"webhooks"`
+
+	// MigrateDryRun asks to print the migration SQL and exit instead of
+	// serving; it is only set by the --migrate-dry-run flag
+	MigrateDryRun bool `yaml:"-" json:"-"`
+}
+
+// logLevels are the accepted values of Logging.Level
+var logLevels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true, "silent": true}
+
+// journalModes are the accepted values of Database.JournalMode
+var journalModes = map[string]bool{"DELETE": true, "TRUNCATE": true, "PERSIST": true, "MEMORY": true, "WAL": true, "OFF": true}
+
+// NewConfig creates a new configuration with the default values from the
+// struct tags
+func NewConfig() *Config {
+	config := &Config{}
+	if err := applyDefaults(reflect.ValueOf(config).Elem()); err != nil {
+		// The tags are part of the source, so this is a programming error
+		panic(err)
+	}
+	return config
+}
+
+// LoadConfig builds the configuration from the defaults, the YAML file
+// named by the --config flag, the environment and the command line flags
+// in args, in increasing order of precedence. The result is validated.
+func LoadConfig(args []string) (*Config, error) {
+	config := NewConfig()
+
+	flags := flag.NewFlagSet("play-api", flag.ContinueOnError)
+	path := flags.String("config", "", "path to a YAML or JSON configuration file")
+	port := flags.String("port", "", "address to listen on, e.g. :8080")
+	driver := flags.String("db-driver", "", "database driver: sqlite, postgres or mysql")
+	dbPath := flags.String("db-path", "", "SQLite file or database DSN")
+	level := flags.String("log-level", "", "log level: debug, info, warn, error or silent")
+	flags.BoolVar(&config.MigrateDryRun, "migrate-dry-run", false, "print the SQL of the database migration and exit")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *path != "" {
+		if err := config.LoadFile(*path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := config.LoadEnv(); err != nil {
+		return nil, err
+	}
+
+	// Only flags given on the command line override other sources
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			config.Server.Port = *port
+		case "db-driver":
+			config.Database.Driver = internal.DatabaseDriver(*driver)
+		case "db-path":
+			config.Database.Path = *dbPath
+		case "log-level":
+			config.Logging.Level = *level
+		}
+	})
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ErrConfigNotFound is returned when the config file does not exist
+var ErrConfigNotFound = errors.New("config file not found")
+
+// ErrConfigParse is returned when the config file cannot be parsed
+var ErrConfigParse = errors.New("invalid config file")
+
+// LoadConfigFile builds the configuration from the defaults and the file
+// at path, see LoadFile. The result is not validated.
+func LoadConfigFile(path string) (*Config, error) {
+	config := NewConfig()
+	if err := config.LoadFile(path); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// LoadFile merges the values set in a config file into the configuration.
+// Files ending in .json are parsed as JSON, whose keys are matched to the
+// fields case-insensitively, and any other file as YAML. The errors match
+// ErrConfigNotFound or ErrConfigParse with errors.Is.
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrConfigNotFound, path)
+	}
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, c)
+	} else {
+		err = yaml.Unmarshal(data, c)
+	}
+	if err != nil {
+		return fmt.Errorf("%w %s: %v", ErrConfigParse, path, err)
+	}
+	return nil
+}
+
+// LoadEnv overrides configuration values with those set in the environment.
+// DB_PATH and LOG_LEVEL are accepted as short forms of DATABASE_PATH and
+// LOGGING_LEVEL, which win when both are set.
+func (c *Config) LoadEnv() error {
+	aliases := []struct {
+		name   string
+		target *string
+	}{
+		{"DB_PATH", &c.Database.Path},
+		{"LOG_LEVEL", &c.Logging.Level},
+	}
+	for _, alias := range aliases {
+		if value, ok := os.LookupEnv(alias.name); ok {
+			*alias.target = value
+		}
+	}
+
+	values := map[string]*string{
+		"SERVER_PORT":   &c.Server.Port,
+		"TLS_CERT_FILE": &c.Server.TLSCertFile,
+		"TLS_KEY_FILE":  &c.Server.TLSKeyFile,
+		"DATABASE_PATH": &c.Database.Path,
+		"LOGGING_LEVEL": &c.Logging.Level,
+		"JWT_SECRET":    &c.Auth.JWTSecret,
+
+		"DB_JOURNAL_MODE": &c.Database.JournalMode,
+
+		"OTEL_EXPORTER_OTLP_ENDPOINT": &c.Tracing.Endpoint,
+	}
+	for name, target := range values {
+		if value, ok := os.LookupEnv(name); ok {
+			*target = value
+		}
+	}
+	if value, ok := os.LookupEnv("DATABASE_DRIVER"); ok {
+		c.Database.Driver = internal.DatabaseDriver(value)
+	}
+
+	ints := map[string]*int{
+		"DB_MAX_OPEN_CONNS":             &c.Database.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS":             &c.Database.MaxIdleConns,
+		"DB_CONN_MAX_LIFETIME_SECONDS":  &c.Database.ConnMaxLifetimeSeconds,
+		"DB_CONN_MAX_IDLE_TIME_SECONDS": &c.Database.ConnMaxIdleTimeSeconds,
+		"DB_BUSY_TIMEOUT_MS":            &c.Database.BusyTimeoutMillis,
+		"DB_SLOW_QUERY_THRESHOLD_MS":    &c.Database.SlowQueryThresholdMs,
+		"JWT_TOKEN_TTL_SECONDS":         &c.Auth.TokenTTLSeconds,
+		"PASSWORD_MIN_LENGTH":           &c.Auth.PasswordPolicy.MinLength,
+	}
+	if value, ok := os.LookupEnv("AUDIT_STORE_REQUEST_BODIES"); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid AUDIT_STORE_REQUEST_BODIES: %w", err)
+		}
+		c.Audit.StoreRequestBodies = parsed
+	}
+
+	for name, target := range ints {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*target = parsed
+	}
+	return nil
+}
+
+// Validate reports the first invalid configuration value
+func (c *Config) Validate() error {
+	_, port, err := net.SplitHostPort(c.Server.Port)
+	if err != nil {
+		return fmt.Errorf("invalid server port %q: %w", c.Server.Port, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid server port %q: port must be a number between 0 and 65535", c.Server.Port)
+	}
+
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		return fmt.Errorf("TLS requires both a certificate and a key file")
+	}
+	if _, err := internal.NewTLSConfig(c.Server.TLSMinVersion); err != nil {
+		return err
+	}
+
+	switch c.Database.Driver {
+	case internal.DriverSQLite, internal.DriverPostgres, internal.DriverMySQL:
+	default:
+		return fmt.Errorf("unsupported database driver %q, supported drivers are: %s, %s, %s",
+			c.Database.Driver, internal.DriverSQLite, internal.DriverPostgres, internal.DriverMySQL)
+	}
+	if c.Database.Path == "" {
+		return fmt.Errorf("database path is required")
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 ||
+		c.Database.ConnMaxLifetimeSeconds < 0 || c.Database.ConnMaxIdleTimeSeconds < 0 {
+		return fmt.Errorf("database pool settings must not be negative")
+	}
+	if c.Database.BusyTimeoutMillis < 0 {
+		return fmt.Errorf("database busy timeout must not be negative")
+	}
+	if c.Database.SlowQueryThresholdMs < 0 {
+		return fmt.Errorf("database slow query threshold must not be negative")
+	}
+	if !journalModes[strings.ToUpper(c.Database.JournalMode)] {
+		return fmt.Errorf("invalid database journal mode %q", c.Database.JournalMode)
+	}
+
+	if !logLevels[c.Logging.Level] {
+		return fmt.Errorf("invalid logging level %q", c.Logging.Level)
+	}
+
+	if c.Auth.TokenTTLSeconds <= 0 {
+		return fmt.Errorf("token TTL must be positive")
+	}
+	if err := c.Auth.PasswordPolicy.Validate(); err != nil {
+		return err
+	}
+
+	for _, sink := range c.Webhooks {
+		if err := sink.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return c.CORS.Validate()
+}
+
+// applyDefaults sets every field of the struct v that has a default tag,
+// descending into nested structs
+func applyDefaults(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := applyDefaults(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := t.Field(i).Tag.Lookup("default")
+		if !ok {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid default for %s: %w", t.Field(i).Name, err)
+			}
+			field.SetInt(int64(n))
+		default:
+			return fmt.Errorf("unsupported default for %s of kind %s", t.Field(i).Name, field.Kind())
+		}
+	}
+	return nil
+}
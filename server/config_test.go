@@ -0,0 +1,267 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/internal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Defaults(t *testing.T) {
+	config := NewConfig()
+
+	assert.Equal(t, ":8080", config.Server.Port)
+	assert.Equal(t, internal.DriverSQLite, config.Database.Driver)
+	assert.Equal(t, "app.db", config.Database.Path)
+	assert.Equal(t, "info", config.Logging.Level)
+
+	// Zero pool settings keep the database/sql defaults
+	assert.Equal(t, 0, config.Database.MaxOpenConns)
+	assert.Equal(t, 0, config.Database.MaxIdleConns)
+	assert.Equal(t, 0, config.Database.ConnMaxLifetimeSeconds)
+	assert.Equal(t, 0, config.Database.ConnMaxIdleTimeSeconds)
+
+	assert.Equal(t, apiv1.DefaultPasswordPolicy(), config.Auth.PasswordPolicy)
+	assert.Equal(t, "TLS12", config.Server.TLSMinVersion)
+	assert.Equal(t, 200, config.Database.SlowQueryThresholdMs)
+}
+
+func TestConfig_LoadEnv(t *testing.T) {
+	t.Setenv("SERVER_PORT", ":9090")
+	t.Setenv("DATABASE_DRIVER", "postgres")
+	t.Setenv("DATABASE_PATH", "host=localhost dbname=app")
+	t.Setenv("LOGGING_LEVEL", "warn")
+	t.Setenv("JWT_SECRET", "s3cret")
+	t.Setenv("DB_MAX_OPEN_CONNS", "25")
+	t.Setenv("DB_MAX_IDLE_CONNS", "5")
+	t.Setenv("DB_CONN_MAX_LIFETIME_SECONDS", "300")
+	t.Setenv("DB_CONN_MAX_IDLE_TIME_SECONDS", "60")
+	t.Setenv("AUDIT_STORE_REQUEST_BODIES", "true")
+
+	config := NewConfig()
+	assert.NoError(t, config.LoadEnv())
+	assert.Equal(t, ":9090", config.Server.Port)
+	assert.Equal(t, internal.DriverPostgres, config.Database.Driver)
+	assert.Equal(t, "host=localhost dbname=app", config.Database.Path)
+	assert.Equal(t, "warn", config.Logging.Level)
+	assert.Equal(t, "s3cret", config.Auth.JWTSecret)
+	assert.Equal(t, 25, config.Database.MaxOpenConns)
+	assert.Equal(t, 5, config.Database.MaxIdleConns)
+	assert.Equal(t, 300, config.Database.ConnMaxLifetimeSeconds)
+	assert.Equal(t, 60, config.Database.ConnMaxIdleTimeSeconds)
+	assert.True(t, config.Audit.StoreRequestBodies)
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "many")
+	assert.Error(t, NewConfig().LoadEnv())
+}
+
+func TestConfig_LoadEnvAliases(t *testing.T) {
+	t.Setenv("DB_PATH", "short.db")
+	t.Setenv("LOG_LEVEL", "debug")
+
+	config := NewConfig()
+	assert.NoError(t, config.LoadEnv())
+	assert.Equal(t, "short.db", config.Database.Path)
+	assert.Equal(t, "debug", config.Logging.Level)
+
+	// The long names take precedence
+	t.Setenv("DATABASE_PATH", "long.db")
+	t.Setenv("LOGGING_LEVEL", "error")
+
+	config = NewConfig()
+	assert.NoError(t, config.LoadEnv())
+	assert.Equal(t, "long.db", config.Database.Path)
+	assert.Equal(t, "error", config.Logging.Level)
+}
+
+// writeConfigFile writes a YAML config file and returns its path
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfig_Precedence(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  port: ":7000"
+database:
+  path: file.db
+  maxIdleConns: 4
+logging:
+  level: error
+auth:
+  passwordPolicy:
+    minLength: 12
+    requireDigit: true
+    bannedPasswords: [letmein123456]
+`)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want func(t *testing.T, config *Config)
+	}{
+		{
+			name: "defaults",
+			want: func(t *testing.T, config *Config) {
+				assert.Equal(t, ":8080", config.Server.Port)
+				assert.Equal(t, "app.db", config.Database.Path)
+			},
+		},
+		{
+			name: "file over defaults",
+			args: []string{"--config", path},
+			want: func(t *testing.T, config *Config) {
+				assert.Equal(t, ":7000", config.Server.Port)
+				assert.Equal(t, "file.db", config.Database.Path)
+				assert.Equal(t, 4, config.Database.MaxIdleConns)
+				assert.Equal(t, "error", config.Logging.Level)
+				assert.Equal(t, apiv1.PasswordPolicy{
+					MinLength:       12,
+					RequireDigit:    true,
+					BannedPasswords: []string{"letmein123456"},
+				}, config.Auth.PasswordPolicy)
+
+				// Values missing from the file keep their defaults
+				assert.Equal(t, internal.DriverSQLite, config.Database.Driver)
+			},
+		},
+		{
+			name: "env over file",
+			env:  map[string]string{"SERVER_PORT": ":7100", "LOGGING_LEVEL": "debug"},
+			args: []string{"--config", path},
+			want: func(t *testing.T, config *Config) {
+				assert.Equal(t, ":7100", config.Server.Port)
+				assert.Equal(t, "debug", config.Logging.Level)
+				assert.Equal(t, "file.db", config.Database.Path)
+			},
+		},
+		{
+			name: "flag over env",
+			env:  map[string]string{"SERVER_PORT": ":7100", "DATABASE_PATH": "env.db"},
+			args: []string{"--config", path, "--port", ":7200"},
+			want: func(t *testing.T, config *Config) {
+				assert.Equal(t, ":7200", config.Server.Port)
+				assert.Equal(t, "env.db", config.Database.Path)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			config, err := LoadConfig(tt.args)
+			assert.NoError(t, err)
+			if err == nil {
+				tt.want(t, config)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_MigrateDryRun(t *testing.T) {
+	config, err := LoadConfig(nil)
+	require.NoError(t, err)
+	assert.False(t, config.MigrateDryRun)
+
+	config, err = LoadConfig([]string{"--migrate-dry-run"})
+	require.NoError(t, err)
+	assert.True(t, config.MigrateDryRun)
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"unparsable port", nil, []string{"--port", ":http-alt"}, "invalid server port"},
+		{"port out of range", map[string]string{"SERVER_PORT": ":70000"}, nil, "invalid server port"},
+		{"port without colon", nil, []string{"--port", "8080"}, "invalid server port"},
+		{"TLS cert without key", map[string]string{"TLS_CERT_FILE": "server.crt"}, nil, "both a certificate and a key"},
+		{"unknown driver", map[string]string{"DATABASE_DRIVER": "oracle"}, nil, "unsupported database driver"},
+		{"negative idle time", map[string]string{"DB_CONN_MAX_IDLE_TIME_SECONDS": "-1"}, nil, "must not be negative"},
+		{"negative slow query threshold", map[string]string{"DB_SLOW_QUERY_THRESHOLD_MS": "-1"}, nil, "slow query threshold must not be negative"},
+		{"zero token TTL", map[string]string{"JWT_TOKEN_TTL_SECONDS": "0"}, nil, "token TTL must be positive"},
+		{"zero password length", map[string]string{"PASSWORD_MIN_LENGTH": "0"}, nil, "password minimum length must be positive"},
+		{"unknown log level", nil, []string{"--log-level", "loud"}, "invalid logging level"},
+		{"unknown log level from env", map[string]string{"LOG_LEVEL": "loud"}, nil, "invalid logging level"},
+		{"empty database path", map[string]string{"DB_PATH": ""}, nil, "database path is required"},
+		{"missing file", nil, []string{"--config", "/nonexistent/config.yaml"}, "config file not found"},
+		{"unknown flag", nil, []string{"--verbose"}, "flag provided but not defined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			_, err := LoadConfig(tt.args)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	yamlPath := writeConfigFile(t, `
+server:
+  port: ":7000"
+database:
+  path: "host=db user=app dbname=app"
+auth:
+  tokenTTLSeconds: 60
+webhooks:
+  - url: https://hooks.example.com/play
+    secret: s3cret
+    verbs: [create, delete]
+`)
+	jsonPath := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(jsonPath, []byte(`{
+  "server": {"port": ":7000"},
+  "database": {"path": "host=db user=app dbname=app"},
+  "auth": {"tokenTTLSeconds": 60},
+  "webhooks": [{"url": "https://hooks.example.com/play", "secret": "s3cret", "verbs": ["create", "delete"]}]
+}`), 0o600))
+
+	for _, path := range []string{yamlPath, jsonPath} {
+		t.Run(filepath.Ext(path), func(t *testing.T) {
+			config, err := LoadConfigFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, ":7000", config.Server.Port)
+			assert.Equal(t, "host=db user=app dbname=app", config.Database.Path)
+			assert.Equal(t, 60, config.Auth.TokenTTLSeconds)
+			assert.Equal(t, []internal.WebhookSink{{
+				URL: "https://hooks.example.com/play", Secret: "s3cret",
+				Verbs: []internal.WebhookVerb{internal.WebhookVerbCreate, internal.WebhookVerbDelete},
+			}}, config.Webhooks)
+
+			// Values missing from the file keep their defaults
+			assert.Equal(t, "info", config.Logging.Level)
+		})
+	}
+}
+
+func TestLoadConfigFile_Errors(t *testing.T) {
+	_, err := LoadConfigFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, err, ErrConfigNotFound)
+	assert.NotErrorIs(t, err, ErrConfigParse)
+
+	_, err = LoadConfigFile(writeConfigFile(t, "server: [unclosed"))
+	assert.ErrorIs(t, err, ErrConfigParse)
+	assert.NotErrorIs(t, err, ErrConfigNotFound)
+
+	jsonPath := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(jsonPath, []byte(`{"server": `), 0o600))
+	_, err = LoadConfigFile(jsonPath)
+	assert.ErrorIs(t, err, ErrConfigParse)
+}
@@ -0,0 +1,251 @@
+// Package server assembles the API into an embeddable HTTP server, so that
+// other binaries can serve their resources the same way as play-api does.
+package server
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/internal"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// shutdownTimeout bounds how long Run waits for in-flight requests
+	shutdownTimeout = 5 * time.Second
+
+	// gzipMinSize is the smallest response body worth compressing
+	gzipMinSize = 1024
+
+	// LoginPath is the endpoint issuing tokens when Auth.JWTSecret is set
+	LoginPath = "/api/v1/auth/login"
+
+	// AuditPath lists the audit events of the changes to resources, with
+	// the resource before and after each change
+	AuditPath = "/audit"
+
+	// AuditLogPath lists the audit entries of the mutating requests, with
+	// their caller, outcome and request body
+	AuditLogPath = "/api/v1/audit"
+
+	// metricsNamespace prefixes the metrics served at /metrics
+	metricsNamespace = "play_api"
+
+	// Title and version of the OpenAPI spec served at /openapi.json
+	openAPITitle   = "play-api"
+	openAPIVersion = "1.0.0"
+)
+
+// RouterOption configures the routes of a resource
+type RouterOption = internal.RouterOption
+
+// OpenDatabase connects to the database selected by config
+func OpenDatabase(config *Config) (*gorm.DB, error) {
+	return internal.OpenDatabase(config.Database, config.Logging.Level)
+}
+
+// Server serves the API resources together with the health probes, the
+// metrics endpoint and the audit log
+type Server struct {
+	config  *Config
+	db      *gorm.DB
+	engine  *gin.Engine
+	audit   *internal.AuditDAO
+	openAPI *internal.OpenAPIGenerator
+
+	// auditLog records the mutating requests to resources
+	auditLog *internal.AuditRecorder
+
+	// webhooks is nil unless webhooks are configured
+	webhooks *internal.WebhookDispatcher
+}
+
+// NewServer creates a server for the resources stored in db. Requests are
+// logged through slog.Default, and config.Auth.PasswordPolicy becomes the
+// policy of new user passwords.
+func NewServer(config *Config, db *gorm.DB) (*Server, error) {
+	apiv1.SetPasswordPolicy(config.Auth.PasswordPolicy)
+
+	engine := gin.New()
+
+	// Add middleware
+	engine.Use(gin.Recovery())
+	engine.Use(internal.RequestIDMiddleware())
+	engine.Use(internal.NewSlogMiddleware(slog.Default()))
+	engine.Use(internal.NewMetricsMiddleware(metricsNamespace))
+	engine.Use(internal.GzipMiddleware(gzip.DefaultCompression, gzipMinSize))
+	if len(config.CORS.AllowedOrigins) > 0 {
+		if err := config.CORS.Validate(); err != nil {
+			return nil, err
+		}
+		engine.Use(internal.NewCORSMiddleware(config.CORS))
+	}
+
+	// Register health probes and metrics
+	internal.RegisterHealthRoutes(engine, db)
+	internal.RegisterMetricsRoute(engine)
+
+	// Record the changes the DAOs make, with snapshots of the resources
+	audit := internal.NewAuditDAO(db)
+	if err := audit.AutoMigrate(); err != nil {
+		return nil, err
+	}
+
+	// Issue tokens for the JWT authentication of resources, and store the
+	// API keys accepted alongside them
+	if config.Auth.JWTSecret != "" {
+		ttl := time.Duration(config.Auth.TokenTTLSeconds) * time.Second
+		internal.RegisterLogin(engine, newAuthenticator(db), LoginPath, []byte(config.Auth.JWTSecret), ttl)
+		if err := db.AutoMigrate(&apiv1.APIKey{}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Document the registered resources
+	openAPI := internal.NewOpenAPIGenerator(openAPITitle, openAPIVersion)
+	internal.RegisterOpenAPI(engine, openAPI)
+
+	s := &Server{config: config, db: db, engine: engine, audit: audit, openAPI: openAPI}
+
+	// Record every mutating request to the resources
+	entries := internal.NewDAO[apiv1.AuditEntry](db)
+	if err := entries.AutoMigrate(context.Background()); err != nil {
+		return nil, err
+	}
+	s.auditLog = internal.NewAuditRecorder(auditEntries{dao: entries}, config.Audit.StoreRequestBodies)
+
+	// Notify the configured receivers of every change
+	if len(config.Webhooks) > 0 {
+		for _, sink := range config.Webhooks {
+			if err := sink.Validate(); err != nil {
+				return nil, err
+			}
+		}
+		s.webhooks = internal.NewWebhookDispatcher(config.Webhooks)
+	}
+
+	// Serve the audit events and entries, to admins only when
+	// authentication is on
+	auditOptions := s.defaultOptions()
+	if config.Auth.JWTSecret != "" {
+		admin := []string{apiv1.RoleAdmin}
+		auditOptions = append(auditOptions, internal.WithRoleRequirements(map[string][]string{
+			"GET": admin, "HEAD": admin,
+		}))
+	}
+	internal.RegisterAudit(engine, audit, AuditPath, auditOptions...)
+	internal.NewRouter[apiv1.AuditEntry](engine, db, auditOptions...).RegisterReadOnly(AuditLogPath)
+	return s, nil
+}
+
+// RegisterResource serves the CRUD routes of T under path and documents
+// them at /openapi.json. Changes are audited, and require a bearer token or
+// an API key when Auth.JWTSecret is configured; opts are applied after these
+// defaults. It is a function rather than a method because methods cannot
+// have type parameters.
+func RegisterResource[T any](s *Server, path string, opts ...RouterOption) {
+	internal.RegisterResource[T](s.engine, s.db, path, append(s.defaultOptions(), opts...)...)
+}
+
+// RegisterPasswordChange serves PUT usersPath/:id/password, which changes
+// the password of a user registered under usersPath. It is audited and
+// authenticated like RegisterResource.
+func (s *Server) RegisterPasswordChange(usersPath string, opts ...RouterOption) {
+	internal.RegisterPasswordChange[apiv1.User](s.engine, s.db, usersPath, append(s.defaultOptions(), opts...)...)
+}
+
+// defaultOptions returns the router options applied to every resource
+func (s *Server) defaultOptions() []RouterOption {
+	options := []RouterOption{
+		internal.WithDAOOptions(internal.WithAudit(s.audit), internal.WithMetrics(metricsNamespace)),
+		internal.WithOpenAPI(s.openAPI),
+	}
+	if s.webhooks != nil {
+		options = append(options, internal.WithDAOOptions(internal.WithWebhooks(s.webhooks)))
+	}
+	options = append(options, internal.WithAuditLog(s.auditLog))
+	if s.config.Auth.JWTSecret != "" {
+		options = append(options,
+			internal.WithAuth(internal.NewJWTMiddleware([]byte(s.config.Auth.JWTSecret), internal.ClaimsKey,
+				internal.WithAPIKeys(newAuthenticator(s.db)))))
+	}
+	return options
+}
+
+// Close stores the queued audit entries and delivers the queued webhooks,
+// waiting until ctx is done at most. Servers whose Handler is served
+// elsewhere call it once requests stopped.
+func (s *Server) Close(ctx context.Context) error {
+	if err := s.auditLog.Shutdown(ctx); err != nil {
+		return err
+	}
+	if s.webhooks == nil {
+		return nil
+	}
+	return s.webhooks.Shutdown(ctx)
+}
+
+// Handler returns the HTTP handler serving every registered route
+func (s *Server) Handler() http.Handler {
+	return s.engine
+}
+
+// Run listens on Server.Port until ctx is done, then shuts down gracefully,
+// waiting up to five seconds for in-flight requests and then the queued
+// audit entries and webhooks. It serves HTTPS when both
+// Server.TLSCertFile and Server.TLSKeyFile are configured.
+func (s *Server) Run(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:    s.config.Server.Port,
+		Handler: s.engine,
+	}
+
+	certFile, keyFile := s.config.Server.TLSCertFile, s.config.Server.TLSKeyFile
+	useTLS := certFile != "" && keyFile != ""
+	if useTLS {
+		tlsConfig, err := internal.NewTLSConfig(s.config.Server.TLSMinVersion)
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig = tlsConfig
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "addr", srv.Addr, "tls", useTLS)
+		if useTLS {
+			errs <- srv.ListenAndServeTLS(certFile, keyFile)
+			return
+		}
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	if err := s.Close(shutdownCtx); err != nil {
+		return err
+	}
+	slog.Info("Server exiting")
+	return nil
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"my-embedded-api/apiv1"
+	"my-embedded-api/internal"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestServer creates a server backed by a temporary SQLite database
+func setupTestServer(t *testing.T, configure func(*Config)) *Server {
+	gin.SetMode(gin.TestMode)
+	config, err := LoadConfig(nil)
+	require.NoError(t, err)
+	config.Database.Path = filepath.Join(t.TempDir(), "test.db")
+	config.Logging.Level = "silent"
+	if configure != nil {
+		configure(config)
+	}
+
+	db, err := OpenDatabase(config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	s, err := NewServer(config, db)
+	require.NoError(t, err)
+	RegisterResource[apiv1.User](s, "/api/v1/users")
+	return s
+}
+
+// serve sends a request to the server handler
+func serve(s *Server, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestServer_Handler(t *testing.T) {
+	s := setupTestServer(t, nil)
+
+	assert.Equal(t, http.StatusOK, serve(s, "GET", "/livez", "").Code)
+	assert.Equal(t, http.StatusOK, serve(s, "GET", "/metrics", "").Code)
+
+	// Registered resources are documented
+	w := serve(s, "GET", "/openapi.json", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"/api/v1/users/{id}"`)
+
+	w = serve(s, "POST", "/api/v1/users", `{"username":"alice","email":"alice@example.com","password":"password123"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.NotEmpty(t, w.Header().Get("X-Request-ID"))
+
+	var created apiv1.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, http.StatusOK, serve(s, "GET", "/api/v1/users/1", "").Code)
+
+	// The queries of registered resources are measured
+	w = serve(s, "GET", "/metrics", "")
+	assert.Contains(t, w.Body.String(), `play_api_db_query_duration_seconds_count{operation="create"}`)
+
+	// Changes to registered resources are audited, both as changes with
+	// snapshots and as requests
+	w = serve(s, "GET", AuditPath+"?resource=users&id=1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"action":"CREATE"`)
+	assert.Contains(t, w.Body.String(), `"after":`)
+	assert.NotContains(t, w.Body.String(), "$2a$")
+	require.NoError(t, s.Close(context.Background()))
+	w = serve(s, "GET", AuditLogPath+"?resourceId=1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"verb":"create"`)
+	assert.NotContains(t, w.Body.String(), "password123")
+}
+
+func TestServer_JWTAuth(t *testing.T) {
+	s := setupTestServer(t, func(config *Config) {
+		config.Auth.JWTSecret = "secret"
+	})
+
+	assert.Equal(t, http.StatusUnauthorized, serve(s, "GET", "/api/v1/users", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(s, "GET", AuditPath, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(s, "GET", AuditLogPath, "").Code)
+	assert.Equal(t, http.StatusOK, serve(s, "GET", "/livez", "").Code)
+}
+
+func TestServer_Run(t *testing.T) {
+	s := setupTestServer(t, func(config *Config) {
+		config.Server.Port = "127.0.0.1:0"
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+}
+
+func TestServer_Webhooks(t *testing.T) {
+	received := make(chan internal.WebhookPayload, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload internal.WebhookPayload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload
+	}))
+	defer receiver.Close()
+
+	s := setupTestServer(t, func(config *Config) {
+		config.Webhooks = []internal.WebhookSink{{URL: receiver.URL, Secret: "secret"}}
+	})
+
+	w := serve(s, "POST", "/api/v1/users", `{"username":"alice","email":"alice@example.com","password":"password123"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// Close waits for the queued webhook
+	require.NoError(t, s.Close(context.Background()))
+	select {
+	case payload := <-received:
+		assert.Equal(t, "User", payload.Kind)
+		assert.Equal(t, internal.WebhookVerbCreate, payload.Verb)
+	default:
+		t.Fatal("webhook was not delivered before Close returned")
+	}
+}
+
+func TestServer_AuditLog(t *testing.T) {
+	s := setupTestServer(t, nil)
+
+	w := serve(s, "POST", "/api/v1/users", `{"username":"alice","email":"alice@example.com","password":"password123"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.NoError(t, s.Close(context.Background()))
+
+	w = serve(s, "GET", AuditLogPath+"?verb=create", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var entries []apiv1.AuditEntry
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	require.Len(t, entries, 1)
+	assert.Equal(t, "User", entries[0].ResourceKind)
+	assert.NotEmpty(t, entries[0].RequestDigest)
+
+	// Bodies are only stored when configured to
+	assert.Empty(t, entries[0].RequestBody)
+}
+
+func TestServer_RunListenError(t *testing.T) {
+	s := setupTestServer(t, func(config *Config) {
+		config.Server.Port = "invalid:address:0"
+	})
+
+	assert.Error(t, s.Run(context.Background()))
+}
+
+func TestServer_CORS(t *testing.T) {
+	s := setupTestServer(t, func(config *Config) {
+		config.CORS.AllowedOrigins = []string{"https://app.example.com"}
+	})
+
+	req := httptest.NewRequest("OPTIONS", "/api/v1/users", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestServer_Login(t *testing.T) {
+	s := setupTestServer(t, func(config *Config) {
+		config.Auth.JWTSecret = "secret"
+	})
+	require.NoError(t, s.db.Create(&apiv1.User{Username: "alice", Email: "alice@example.com", Password: "password123"}).Error)
+
+	w := serve(s, "POST", LoginPath, `{"username":"alice","password":"password123"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	req := httptest.NewRequest("GET", "/api/v1/users", nil)
+	req.Header.Set("Authorization", "Bearer "+resp.Token)
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}